@@ -0,0 +1,190 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontmatterProblem is a file whose frontmatter can't be parsed, so updates
+// refuse to modify it
+type FrontmatterProblem struct {
+	// Path is relative to the vault root
+	Path  string `json:"path"`
+	Error string `json:"error"`
+	// Line is the line of the file the YAML error points at, or 0 if unknown
+	Line int `json:"line,omitempty"`
+	// Hint is a guess at the cause, or "" when there is no good guess
+	Hint string `json:"hint,omitempty"`
+}
+
+var (
+	yamlErrorLinePattern = regexp.MustCompile(`line (\d+):`)
+	yamlLineRefPattern   = regexp.MustCompile(`line (\d+)`)
+)
+
+// AuditFrontmatter finds every file in the vault at rootPath whose
+// frontmatter fails to parse, sorted by path.
+func (m *DefaultTagManager) AuditFrontmatter(ctx context.Context, rootPath string) ([]FrontmatterProblem, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	problems := []FrontmatterProblem{}
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		content, err := readNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			if err := m.scanFailed(&ScanError{Path: fileInfo.Path, Phase: ScanPhaseRead, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, _, err := m.parseFrontmatter(string(content)); err == nil {
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootPath, fileInfo.Path)
+		if err != nil {
+			relPath = fileInfo.Path
+		}
+		frontmatter, _, _ := splitFrontmatter(string(content))
+		problems = append(problems, diagnoseFrontmatter(filepath.ToSlash(relPath), frontmatter))
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// diagnoseFrontmatter explains why frontmatter, which fails to parse, is
+// malformed
+func diagnoseFrontmatter(path, frontmatter string) FrontmatterProblem {
+	var nodes map[string]yaml.Node
+	err := yaml.Unmarshal([]byte(frontmatter), &nodes)
+	if err == nil {
+		return FrontmatterProblem{Path: path, Error: "frontmatter could not be parsed"}
+	}
+
+	problem := FrontmatterProblem{Path: path, Error: yamlErrorMessage(err)}
+	lines := strings.Split(frontmatter, "\n")
+
+	// yaml omits the line number of some errors, in which case the first line
+	// matching the guessed cause is reported
+	line := 0
+	if match := yamlErrorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ = strconv.Atoi(match[1])
+	}
+	problem.Hint, line = frontmatterHint(err.Error(), lines, line)
+	if line > 0 {
+		// The opening --- is the first line of the file
+		problem.Line = line + 1
+	}
+	return problem
+}
+
+// frontmatterHint guesses at the mistake behind a YAML error reported on line
+// (1-based, or 0 if unknown) of the frontmatter lines, returning the hint and
+// the line it applies to
+func frontmatterHint(message string, lines []string, line int) (string, int) {
+	value := func(l string) string {
+		_, v, _ := strings.Cut(l, ":")
+		return strings.TrimSpace(v)
+	}
+	// find returns line if known, or else the first line matching
+	find := func(matches func(l string) bool) (int, bool) {
+		if line > 0 {
+			return line, line <= len(lines) && matches(lines[line-1])
+		}
+		for i, l := range lines {
+			if matches(l) {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	}
+	tabIndented := func(l string) bool {
+		return strings.HasPrefix(strings.TrimLeft(l, " "), "\t")
+	}
+	unbalancedQuote := func(l string) bool {
+		v := value(l)
+		return strings.HasPrefix(v, `"`) && !strings.HasSuffix(v[1:], `"`) ||
+			strings.HasPrefix(v, "'") && !strings.HasSuffix(v[1:], "'")
+	}
+
+	switch {
+	case strings.Contains(message, "already defined"):
+		return "a property appears twice; merge the two into one", line
+	case strings.Contains(message, "cannot unmarshal"):
+		return "frontmatter must be a list of `key: value` properties", line
+	case strings.Contains(message, "found unknown escape character"):
+		n, _ := find(func(l string) bool { return strings.HasPrefix(value(l), `"`) && strings.Contains(l, `\`) })
+		return "a double-quoted value contains a backslash; use single quotes instead", n
+	case strings.Contains(message, "unknown anchor"):
+		n, _ := find(func(l string) bool { return strings.HasPrefix(value(l), "*") })
+		return "a value starts with *, which YAML reads as an alias; put it in quotes", n
+	case strings.Contains(message, "found character that cannot start any token"):
+		if n, ok := find(tabIndented); ok {
+			return "indentation uses tabs, which YAML doesn't allow; indent with spaces", n
+		}
+		n, _ := find(func(l string) bool {
+			v := value(l)
+			return v != "" && strings.ContainsRune("@`%", rune(v[0]))
+		})
+		return "a value starts with a character YAML reserves, such as @ or `; put it in quotes", n
+	case strings.Contains(message, "found unexpected end of stream"):
+		n, _ := find(unbalancedQuote)
+		return "a quoted value is missing its closing quote", n
+	case strings.Contains(message, "mapping values are not allowed"):
+		if n, ok := find(func(l string) bool { return strings.Contains(value(l), ": ") }); ok {
+			return "a value contains ': '; put the whole value in quotes", n
+		}
+		if line > 0 && line <= len(lines) && strings.HasPrefix(lines[line-1], " ") {
+			return "a line is indented under a property which already has a value", line
+		}
+		return "the line above is not a `key: value` property; add the missing ':'", line
+	case strings.Contains(message, "did not find expected ',' or ']'"),
+		strings.Contains(message, "did not find expected ',' or '}'"):
+		return "an inline [list] is unclosed, or an item contains a bracket; close it or quote the item", line
+	case strings.Contains(message, "did not find expected key"):
+		if n, ok := find(func(l string) bool {
+			v := value(l)
+			return strings.HasPrefix(v, "[") && !strings.HasSuffix(v, "]")
+		}); ok {
+			return "text follows an inline [list]; put the whole value in quotes", n
+		}
+		return "indentation is inconsistent; list items and nested properties must line up", line
+	case strings.Contains(message, "could not find expected ':'"):
+		return "a line is neither a `key: value` property nor a list item; add the missing ':'", line
+	}
+	return "", line
+}
+
+// yamlErrorMessage returns the message of a YAML error in frontmatter without
+// its leading line number, with any other lines it mentions counted from the
+// start of the file
+func yamlErrorMessage(err error) string {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	message = strings.TrimSpace(strings.TrimPrefix(message, "unmarshal errors:"))
+	if loc := yamlErrorLinePattern.FindStringIndex(message); loc != nil && loc[0] == 0 {
+		message = strings.TrimSpace(message[loc[1]:])
+	}
+	return yamlLineRefPattern.ReplaceAllStringFunc(message, func(ref string) string {
+		n, _ := strconv.Atoi(strings.TrimPrefix(ref, "line "))
+		return fmt.Sprintf("line %d", n+1)
+	})
+}
@@ -0,0 +1,75 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestAuditFrontmatter(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"good.md":          "---\ntags: [golang]\ntitle: Fine\n---\nbody\n",
+		"plain.md":         "no frontmatter #tag\n",
+		"colon.md":         "---\ntags: [golang]\ntitle: Meeting: notes\n---\n",
+		"unclosed.md":      "---\ntags: [a, b\n---\n",
+		"tabs.md":          "---\ntags:\n\t- golang\n---\n",
+		"quote.md":         "---\ntitle: \"open\n---\n",
+		"duplicate.md":     "---\ntitle: one\ntitle: two\n---\n",
+		"indent.md":        "---\ntags:\n  - a\n - b\n---\n",
+		"reserved.md":      "---\nauthor: @me\n---\n",
+		"list.md":          "---\n- a\n- b\n---\n",
+		"nested/broken.md": "---\ntitle: x\n  bad: y\n---\n",
+	})
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	problems, err := manager.AuditFrontmatter(context.Background(), root)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		path string
+		line int
+		hint string
+	}{
+		{path: "colon.md", line: 3, hint: "contains ': '"},
+		{path: "duplicate.md", line: 3, hint: "appears twice"},
+		{path: "indent.md", line: 3, hint: "indentation is inconsistent"},
+		{path: "list.md", line: 2, hint: "key: value"},
+		{path: "nested/broken.md", line: 3, hint: "indented under a property"},
+		{path: "quote.md", line: 2, hint: "missing its closing quote"},
+		{path: "reserved.md", line: 2, hint: "character YAML reserves"},
+		{path: "tabs.md", line: 3, hint: "tabs"},
+		{path: "unclosed.md", line: 2, hint: "unclosed"},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			var found *tagmanager.FrontmatterProblem
+			for i := range problems {
+				if problems[i].Path == test.path {
+					found = &problems[i]
+				}
+			}
+			require.NotNil(t, found)
+			assert.NotContains(t, found.Error, "yaml:")
+			assert.Equal(t, test.line, found.Line)
+			assert.Contains(t, found.Hint, test.hint)
+		})
+	}
+	assert.Len(t, problems, 9)
+
+	t.Run("Command", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "audit", "frontmatter", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{
+			"Found 9 files with malformed frontmatter",
+			"colon.md:3: mapping values are not allowed",
+			"duplicate.md:3: mapping key \"title\" already defined at line 2",
+			"hint: a value contains ': '",
+		})
+	})
+}
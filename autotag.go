@@ -0,0 +1,138 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AutoTagRule tags the notes matching its path, its content, or both when
+// both are set
+type AutoTagRule struct {
+	// Path is a glob of vault-relative paths, such as Meetings/** or
+	// Daily/*.md; one ending in / matches every note beneath that folder
+	Path string `yaml:"path,omitempty"`
+	// Content is a regular expression matched against the whole note, such
+	// as TODO or (?i)\bmeeting notes\b
+	Content string `yaml:"content,omitempty"`
+	// Tags are added to each matching note which lacks them
+	Tags []string `yaml:"tags"`
+}
+
+// pattern returns the glob the rule's path matches vault-relative paths with,
+// or "" when it has no path
+func (r AutoTagRule) pattern() string {
+	if strings.HasSuffix(r.Path, "/") {
+		return r.Path + "**"
+	}
+	return r.Path
+}
+
+// validateAutoTagRules reports rules which can't be applied
+func validateAutoTagRules(rules []AutoTagRule) error {
+	for i, rule := range rules {
+		if rule.Path == "" && rule.Content == "" {
+			return fmt.Errorf("autotag[%d]: must have a path, a content pattern or both", i)
+		}
+		if len(rule.Tags) == 0 {
+			return fmt.Errorf("autotag[%d]: must have tags", i)
+		}
+		for _, segment := range strings.Split(rule.pattern(), "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("autotag[%d]: invalid path %q", i, rule.Path)
+			}
+		}
+		if _, err := regexp.Compile(rule.Content); err != nil {
+			return fmt.Errorf("autotag[%d]: invalid content pattern: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// PlanAutoTags applies the config's autotag rules to the notes under
+// rootPath, returning an op per note adding the tags of its matching rules
+// which it doesn't have yet. UpdateTagsPerFile applies the ops.
+func (m *DefaultTagManager) PlanAutoTags(ctx context.Context, rootPath string) ([]FileTagOp, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+	if err := validateAutoTagRules(m.config.AutoTag); err != nil {
+		return nil, err
+	}
+
+	contents := make([]*regexp.Regexp, len(m.config.AutoTag))
+	for i, rule := range m.config.AutoTag {
+		if rule.Content != "" {
+			contents[i] = regexp.MustCompile(rule.Content)
+		}
+	}
+
+	ops := []FileTagOp{}
+files:
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		relPath, err := filepath.Rel(rootPath, fileInfo.Path)
+		if err != nil {
+			continue
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		has := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			has[m.normalizeTag(tag)] = true
+		}
+
+		// The note is read once, for the first rule matching on content
+		var content *string
+		var add []string
+		for i, rule := range m.config.AutoTag {
+			if rule.Path != "" && !matchGlob(rule.pattern(), relPath) {
+				continue
+			}
+			if contents[i] != nil {
+				if content == nil {
+					data, err := readNote(ctx, m.config, fileInfo.Path)
+					if err != nil {
+						if err := m.scanFailed(&ScanError{Path: fileInfo.Path, Phase: ScanPhaseRead, Err: err}); err != nil {
+							return nil, err
+						}
+						continue files
+					}
+					text := string(data)
+					content = &text
+				}
+				if !contents[i].MatchString(*content) {
+					continue
+				}
+			}
+			for _, tag := range m.normalizeTags(rule.Tags) {
+				if !has[tag] {
+					has[tag] = true
+					add = append(add, tag)
+				}
+			}
+		}
+		if len(add) > 0 {
+			ops = append(ops, FileTagOp{Path: relPath, Add: add})
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if !m.ordered {
+		sort.Slice(ops, func(i, j int) bool {
+			return ops[i].Path < ops[j].Path
+		})
+	}
+	return ops, nil
+}
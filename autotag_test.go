@@ -0,0 +1,151 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestPlanAutoTags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"Meetings/standup.md":      "# Standup",
+		"Meetings/2025/review.md":  "TODO: follow up\n#meeting",
+		"Projects/alpha.md":        "Plan\nTODO write spec",
+		"Projects/beta.md":         "---\ntags: [todo]\n---\nTODO nothing new",
+		"Daily/2025-03-01.md":      "Nothing to do",
+		"MeetingsArchive/notes.md": "# Old",
+	})
+
+	tests := []struct {
+		name     string
+		rules    []tagmanager.AutoTagRule
+		expected []tagmanager.FileTagOp
+	}{
+		{
+			name:  "Folder",
+			rules: []tagmanager.AutoTagRule{{Path: "Meetings/", Tags: []string{"meeting"}}},
+			expected: []tagmanager.FileTagOp{
+				{Path: "Meetings/standup.md", Add: []string{"meeting"}},
+			},
+		},
+		{
+			name:  "Content",
+			rules: []tagmanager.AutoTagRule{{Content: "TODO", Tags: []string{"#todo"}}},
+			expected: []tagmanager.FileTagOp{
+				{Path: "Meetings/2025/review.md", Add: []string{"todo"}},
+				{Path: "Projects/alpha.md", Add: []string{"todo"}},
+			},
+		},
+		{
+			name:  "PathAndContent",
+			rules: []tagmanager.AutoTagRule{{Path: "Projects/*.md", Content: `(?m)^TODO`, Tags: []string{"todo", "project"}}},
+			expected: []tagmanager.FileTagOp{
+				{Path: "Projects/alpha.md", Add: []string{"todo", "project"}},
+				{Path: "Projects/beta.md", Add: []string{"project"}},
+			},
+		},
+		{
+			name: "RulesCombined",
+			rules: []tagmanager.AutoTagRule{
+				{Path: "Meetings/**", Tags: []string{"meeting"}},
+				{Content: "TODO", Tags: []string{"todo", "meeting"}},
+			},
+			expected: []tagmanager.FileTagOp{
+				{Path: "Meetings/2025/review.md", Add: []string{"todo"}},
+				{Path: "Meetings/standup.md", Add: []string{"meeting"}},
+				{Path: "Projects/alpha.md", Add: []string{"todo", "meeting"}},
+				{Path: "Projects/beta.md", Add: []string{"meeting"}},
+			},
+		},
+		{
+			name:     "NoMatch",
+			rules:    []tagmanager.AutoTagRule{{Path: "Inbox/", Tags: []string{"inbox"}}},
+			expected: []tagmanager.FileTagOp{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.AutoTag = test.rules
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			ops, err := manager.PlanAutoTags(context.Background(), root)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, ops)
+		})
+	}
+}
+
+func TestValidateAutoTagRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     tagmanager.AutoTagRule
+		expected string
+	}{
+		{name: "NoMatcher", rule: tagmanager.AutoTagRule{Tags: []string{"todo"}},
+			expected: "autotag[0]: must have a path, a content pattern or both"},
+		{name: "NoTags", rule: tagmanager.AutoTagRule{Path: "Meetings/"},
+			expected: "autotag[0]: must have tags"},
+		{name: "BadPath", rule: tagmanager.AutoTagRule{Path: "Meetings/[", Tags: []string{"meeting"}},
+			expected: `autotag[0]: invalid path "Meetings/["`},
+		{name: "BadContent", rule: tagmanager.AutoTagRule{Content: "TODO(", Tags: []string{"todo"}},
+			expected: "autotag[0]: invalid content pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.AutoTag = []tagmanager.AutoTagRule{test.rule}
+			err := tagmanager.ValidateConfig(config)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+func TestAutotagCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"Meetings/standup.md": "# Standup",
+		"notes.md":            "TODO: call",
+	})
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"autotag:\n  - path: Meetings/\n    tags: [meeting]\n  - content: TODO\n    tags: [todo]\n"), 0644))
+
+	output, err := run(t, "--config", configFile, "autotag", "--root", root)
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"DRY RUN MODE", "Would tag 2 files:",
+		"Meetings/standup.md: #meeting", "notes.md: #todo"})
+	content, err := os.ReadFile(filepath.Join(root, "notes.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "TODO: call", string(content))
+
+	output, err = run(t, "--config", configFile, "autotag", "--root", root, "--apply")
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Tagged 2 files:"})
+	assert.NotContains(t, output, "DRY RUN MODE")
+	content, err = os.ReadFile(filepath.Join(root, "notes.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "todo")
+
+	output, err = run(t, "--config", configFile, "autotag", "--root", root, "--apply")
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Tagged 0 files:"})
+
+	_, err = run(t, "autotag", "--root", root)
+	assert.EqualError(t, err, "no autotag rules in the config")
+}
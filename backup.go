@@ -0,0 +1,192 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup modes for Config.Backup
+const (
+	// BackupTree copies files into BackupDir/<timestamp>/ inside the vault,
+	// one timestamped tree per operation
+	BackupTree = "tree"
+	// BackupSibling copies each file to a .bak file beside it, keeping only
+	// the copy from before the most recent modification
+	BackupSibling = "sibling"
+
+	// BackupDir is the directory inside a vault which holds backup trees.
+	// Scans never descend into it.
+	BackupDir = ".tag-manager-backup"
+	// BackupSuffix is appended to a file's name for its sibling backup
+	BackupSuffix = ".bak"
+
+	// backupTimeFormat names backup trees so they sort oldest first
+	backupTimeFormat = "20060102T150405.000000000"
+)
+
+func validateBackupMode(mode string) error {
+	switch mode {
+	case "", BackupTree, BackupSibling:
+		return nil
+	}
+	return fmt.Errorf("invalid backup mode %q: must be %s or %s", mode, BackupTree, BackupSibling)
+}
+
+// backup copies files before an operation modifies them. A nil backup copies
+// nothing, so callers needn't check whether backups are enabled.
+type backup struct {
+	mode     string
+	rootPath string
+	perm     filePerm
+	// dir is the tree this operation's copies go in, for BackupTree
+	dir string
+	// saved is set once a file has been copied
+	saved bool
+}
+
+// startBackup begins the backup for one operation on the vault at rootPath,
+// returning nil when backups are disabled.
+func (m *DefaultTagManager) startBackup(rootPath string) *backup {
+	switch m.config.Backup {
+	case BackupTree:
+		return &backup{
+			mode:     BackupTree,
+			rootPath: rootPath,
+			perm:     newFilePerm(m.config),
+			dir:      filepath.Join(rootPath, BackupDir, time.Now().UTC().Format(backupTimeFormat)),
+		}
+	case BackupSibling:
+		return &backup{mode: BackupSibling, rootPath: rootPath}
+	}
+	return nil
+}
+
+// save copies path as it is stored on disk, so encrypted notes stay encrypted
+func (b *backup) save(path string) error {
+	if b == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to back up: %w", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to back up: %w", err)
+	}
+
+	target := path + BackupSuffix
+	if b.mode == BackupTree {
+		relPath, err := filepath.Rel(b.rootPath, path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			return fmt.Errorf("failed to back up: %s is outside %s", path, b.rootPath)
+		}
+		target = filepath.Join(b.dir, relPath)
+		if err := b.perm.mkdirAll(filepath.Dir(target)); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(target, content, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to back up: %w", err)
+	}
+	b.saved = true
+	return nil
+}
+
+// location returns the tree holding this operation's copies, or "" when
+// nothing was copied into one
+func (b *backup) location() string {
+	if b == nil || b.mode != BackupTree || !b.saved {
+		return ""
+	}
+	return b.dir
+}
+
+// PruneBackups removes the backup trees in the vault at rootPath except the
+// newest keep, and only those older than olderThan when it is non-zero. When
+// olderThan is non-zero, sibling .bak files older than it are removed too.
+// The paths removed are returned.
+func (m *DefaultTagManager) PruneBackups(ctx context.Context, rootPath string, keep int, olderThan time.Duration) ([]string, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	var removed []string
+	cutoff := time.Now().Add(-olderThan)
+
+	entries, err := os.ReadDir(filepath.Join(rootPath, BackupDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var trees []string
+	for _, entry := range entries {
+		if _, err := time.Parse(backupTimeFormat, entry.Name()); entry.IsDir() && err == nil {
+			trees = append(trees, entry.Name())
+		}
+	}
+	sort.Strings(trees)
+
+	for i, name := range trees {
+		if ctx.Err() != nil {
+			return removed, ctx.Err()
+		}
+		if i >= len(trees)-keep {
+			break
+		}
+
+		created, _ := time.Parse(backupTimeFormat, name)
+		if olderThan > 0 && created.After(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(rootPath, BackupDir, name)
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %s: %w", name, err)
+		}
+		removed = append(removed, path)
+	}
+
+	if olderThan > 0 {
+		err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if d.IsDir() {
+				if d.Name() == BackupDir || d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, BackupSuffix) || !isNote(m.config, strings.TrimSuffix(path, BackupSuffix), noteExtensions(m.config)) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove backup %s: %w", path, err)
+			}
+			removed = append(removed, path)
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
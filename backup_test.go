@@ -0,0 +1,138 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestBackups(t *testing.T) {
+	ctx := context.Background()
+	original := "---\ntags: [golang]\n---\n#draft note\n"
+
+	newManager := func(t *testing.T, mode string) tagmanager.TagManager {
+		config := tagmanager.DefaultConfig()
+		config.Backup = mode
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+		return manager
+	}
+
+	t.Run("Tree", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"notes/a.md": original, "b.md": "#other"})
+		manager := newManager(t, tagmanager.BackupTree)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+		require.NotEmpty(t, result.Backup)
+		assert.Equal(t, filepath.Join(root, tagmanager.BackupDir), filepath.Dir(result.Backup))
+
+		backedUp, err := os.ReadFile(filepath.Join(result.Backup, "notes", "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, original, string(backedUp))
+		assert.NoFileExists(t, filepath.Join(result.Backup, "b.md"))
+
+		// Backups are never scanned
+		found, err := manager.FindFilesByTags(ctx, []string{"draft"}, root)
+		require.NoError(t, err)
+		assert.Empty(t, found["draft"])
+
+		update, err := manager.UpdateTags(ctx, []string{"python"}, nil, root, []string{"notes/a.md"}, false)
+		require.NoError(t, err)
+		require.NotEmpty(t, update.Backup)
+		assert.NotEqual(t, result.Backup, update.Backup)
+
+		backedUp, err = os.ReadFile(filepath.Join(update.Backup, "notes", "a.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(backedUp), "#wip note")
+	})
+
+	t.Run("Sibling", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": original})
+		manager := newManager(t, tagmanager.BackupSibling)
+
+		result, err := manager.UpdateTags(ctx, []string{"python"}, nil, root, []string{"a.md"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Backup)
+
+		backedUp, err := os.ReadFile(filepath.Join(root, "a.md"+tagmanager.BackupSuffix))
+		require.NoError(t, err)
+		assert.Equal(t, original, string(backedUp))
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": original})
+		manager := newManager(t, tagmanager.BackupTree)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, true)
+		require.NoError(t, err)
+		assert.Empty(t, result.Backup)
+		assert.NoDirExists(t, filepath.Join(root, tagmanager.BackupDir))
+	})
+
+	t.Run("InvalidMode", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.Backup = "zip"
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid backup mode")
+	})
+}
+
+func TestPruneBackups(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	setup := func(t *testing.T) (string, []string) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		var trees []string
+		for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour} {
+			name := now.Add(-age).Format("20060102T150405.000000000")
+			require.NoError(t, os.MkdirAll(filepath.Join(root, tagmanager.BackupDir, name), 0755))
+			trees = append(trees, filepath.Join(root, tagmanager.BackupDir, name))
+		}
+
+		sibling := filepath.Join(root, "a.md"+tagmanager.BackupSuffix)
+		require.NoError(t, os.WriteFile(sibling, []byte("#golang"), tagmanager.DefaultFilePermissions))
+		old := now.Add(-72 * time.Hour)
+		require.NoError(t, os.Chtimes(sibling, old, old))
+		return root, trees
+	}
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	t.Run("Keep", func(t *testing.T) {
+		root, trees := setup(t)
+		removed, err := manager.PruneBackups(ctx, root, 1, 0)
+		require.NoError(t, err)
+		assert.Equal(t, trees[:2], removed)
+		assert.DirExists(t, trees[2])
+		assert.FileExists(t, filepath.Join(root, "a.md"+tagmanager.BackupSuffix))
+	})
+
+	t.Run("OlderThan", func(t *testing.T) {
+		root, trees := setup(t)
+		removed, err := manager.PruneBackups(ctx, root, 0, 60*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, []string{trees[0], filepath.Join(root, "a.md"+tagmanager.BackupSuffix)}, removed)
+		assert.DirExists(t, trees[1])
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		root, _ := setup(t)
+		var stdout, stderr bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "backup", "prune", "--root=" + root, "--keep=2"}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, stdout.String(), "Removed 1 backups")
+	})
+}
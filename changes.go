@@ -0,0 +1,114 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Kinds of TagChange
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// TagChange is a file whose tags changed
+type TagChange struct {
+	// Path is relative to the vault root
+	Path    string   `json:"path"`
+	Change  string   `json:"change"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// IndexedAt is when the index recorded the file's current tags
+	IndexedAt time.Time `json:"indexed_at"`
+}
+
+// WhatChanged returns the files whose tags changed since the given time,
+// sorted by path. The index is brought up to date first, and each file's
+// tags are compared with its last index record from before since. Compacting
+// the index forgets earlier records, so a file indexed again after since with
+// no record left from before is reported as created.
+func (m *DefaultTagManager) WhatChanged(ctx context.Context, rootPath string, since time.Time) ([]TagChange, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	if _, err := m.UpdateIndex(ctx, rootPath); err != nil {
+		return nil, fmt.Errorf("failed to update index: %w", err)
+	}
+
+	// Each file's state at since, and its latest state
+	before := make(map[string]IndexRecord)
+	current := make(map[string]IndexRecord)
+	err := readIndexLog(filepath.Join(rootPath, IndexDir, IndexFileName), func(record IndexRecord) {
+		if !record.IndexedAt.After(since) {
+			before[record.Path] = record
+		}
+		current[record.Path] = record
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []TagChange{}
+	for path, record := range current {
+		old, known := before[path]
+		if known && old.Deleted {
+			known = false
+		}
+		if !known && record.Deleted {
+			continue
+		}
+
+		change := TagChange{Path: filepath.ToSlash(path), IndexedAt: record.IndexedAt}
+		switch {
+		case !known:
+			change.Change = ChangeCreated
+			change.Added = record.Tags
+		case record.Deleted:
+			change.Change = ChangeDeleted
+			change.Removed = old.Tags
+		default:
+			change.Change = ChangeModified
+			change.Added = tagsMissingFrom(record.Tags, old.Tags)
+			change.Removed = tagsMissingFrom(old.Tags, record.Tags)
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// tagsMissingFrom returns the tags in tags which aren't in other
+func tagsMissingFrom(tags, other []string) []string {
+	var missing []string
+	for _, tag := range tags {
+		if !containsTag(other, tag) {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
+
+// parseSince parses a point in time given as RFC 3339, a date such as
+// 2026-01-31, or a duration before now such as 24h
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339, YYYY-MM-DD or a duration such as 24h", value)
+}
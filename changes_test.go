@@ -0,0 +1,77 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestWhatChanged(t *testing.T) {
+	ctx := context.Background()
+	root := writeVault(t, map[string]string{
+		"a.md":       "#golang",
+		"b.md":       "#python",
+		"notes/c.md": "#rust",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	// The first call builds the index, so every file is new
+	changes, err := manager.WhatChanged(ctx, root, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, tagmanager.ChangeCreated, changes[0].Change)
+
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.md"), []byte("#golang #docker"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.Remove(filepath.Join(root, "b.md")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "d.md"), []byte("#new"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes/c.md"), []byte("#rust edited"), tagmanager.DefaultFilePermissions))
+
+	changes, err = manager.WhatChanged(ctx, root, since)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, "a.md", changes[0].Path)
+	assert.Equal(t, tagmanager.ChangeModified, changes[0].Change)
+	assert.Equal(t, []string{"docker"}, changes[0].Added)
+	assert.Empty(t, changes[0].Removed)
+
+	assert.Equal(t, "b.md", changes[1].Path)
+	assert.Equal(t, tagmanager.ChangeDeleted, changes[1].Change)
+	assert.Equal(t, []string{"python"}, changes[1].Removed)
+
+	assert.Equal(t, "d.md", changes[2].Path)
+	assert.Equal(t, tagmanager.ChangeCreated, changes[2].Change)
+	assert.Equal(t, []string{"new"}, changes[2].Added)
+
+	// Nothing changed after the last update
+	changes, err = manager.WhatChanged(ctx, root, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	t.Run("CLI", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "changes", "--root", root, "--since", "1h"},
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{"a.md (created)", "d.md (created)", "+ #new"})
+	})
+
+	t.Run("InvalidSince", func(t *testing.T) {
+		for _, since := range []string{"", "yesterday", "-1h"} {
+			err := tagmanager.RunCmd([]string{"tag-manager", "changes", "--root", root, "--since", since},
+				&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+			assert.Error(t, err, since)
+		}
+	})
+}
@@ -0,0 +1,424 @@
+package tagmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// JournalChangeSet is the journal kind of an applied ChangeSet
+const JournalChangeSet = "changeset"
+
+// ChangeSet composes several tag operations on one vault into a single unit.
+// Operations are planned together, each seeing the changes of those before
+// it, so the plan can be previewed as a whole. Apply writes every file or
+// none, and journals the set as one operation which undo rolls back at once.
+//
+//	plan, err := manager.BeginChangeSet(ctx, root).
+//		Migrate("inbox.md").
+//		Update([]string{"project"}, nil, "inbox.md").
+//		Replace(TagReplacement{OldTag: "draft", NewTag: "wip"}).
+//		Plan()
+type ChangeSet struct {
+	ctx      context.Context
+	manager  *DefaultTagManager
+	rootPath string
+	ops      []changeOp
+	// kind is what the set is journaled as
+	kind string
+
+	// planned is the most recent plan, cleared when an operation is added
+	planned *changeSetPlan
+}
+
+// changeOp is either a vault wide replacement or an update of listed files
+type changeOp struct {
+	replacements []TagReplacement
+	addTags      []string
+	removeTags   []string
+	filePaths    []string
+}
+
+// ChangePlan previews the files a ChangeSet would modify
+type ChangePlan struct {
+	Files  []PlannedChange `json:"files"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// PlannedChange is a file a ChangeSet would modify and how
+type PlannedChange struct {
+	// Path is relative to the vault root
+	Path string `json:"path"`
+	// Diff is a unified diff of the note's plaintext
+	Diff string `json:"diff"`
+}
+
+type ChangeSetResult struct {
+	ModifiedFiles []string `json:"modified_files"`
+	Errors        []string `json:"errors,omitempty"`
+	// Backup is the backup tree holding the files as they were before
+	Backup string `json:"backup,omitempty"`
+	// Operation is the journal id of the changes, which undo takes
+	Operation string `json:"operation,omitempty"`
+}
+
+// plannedFile is a note as read from disk and as the operations leave it
+type plannedFile struct {
+	path string
+	// stored is the file as read, encrypted if a CryptHook matches
+	stored   []byte
+	original string
+	content  string
+	format   textFormat
+}
+
+type changeSetPlan struct {
+	plan  *ChangePlan
+	files []*plannedFile
+}
+
+// BeginChangeSet starts an empty ChangeSet on the vault at rootPath
+func (m *DefaultTagManager) BeginChangeSet(ctx context.Context, rootPath string) *ChangeSet {
+	return &ChangeSet{ctx: ctx, manager: m, rootPath: rootPath, kind: JournalChangeSet}
+}
+
+// Replace renames tags across the vault, as ReplaceTagsBatch does
+func (cs *ChangeSet) Replace(replacements ...TagReplacement) *ChangeSet {
+	cs.ops = append(cs.ops, changeOp{replacements: replacements})
+	cs.planned = nil
+	return cs
+}
+
+// Update adds and removes tags in the files listed relative to the vault
+// root, as UpdateTags does, migrating their top-of-file hashtags too
+func (cs *ChangeSet) Update(addTags, removeTags []string, filePaths ...string) *ChangeSet {
+	cs.ops = append(cs.ops, changeOp{addTags: addTags, removeTags: removeTags, filePaths: filePaths})
+	cs.planned = nil
+	return cs
+}
+
+// Migrate moves the top-of-file hashtags of the files listed relative to the
+// vault root into their frontmatter
+func (cs *ChangeSet) Migrate(filePaths ...string) *ChangeSet {
+	return cs.Update(nil, nil, filePaths...)
+}
+
+// Plan works out the changes every operation makes without modifying files.
+// Problems with individual files or tags are reported in the plan's Errors;
+// a ChangeSet with errors can't be applied.
+func (cs *ChangeSet) Plan() (*ChangePlan, error) {
+	m := cs.manager
+	if err := m.validator.ValidatePath(cs.rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	plan := &ChangePlan{Files: []PlannedChange{}}
+	files := make(map[string]*plannedFile)
+	var order []*plannedFile
+
+	load := func(path string) (*plannedFile, error) {
+		path = filepath.Clean(path)
+		if file, ok := files[path]; ok {
+			return file, nil
+		}
+
+		stored, content, err := readStoredNote(cs.ctx, m.config, path)
+		if err != nil {
+			return nil, err
+		}
+
+		text, format := normalizeText(string(content))
+		file := &plannedFile{path: path, stored: stored, original: text, content: text, format: format}
+		files[path] = file
+		order = append(order, file)
+		return file, nil
+	}
+
+	for _, op := range cs.ops {
+		if cs.ctx.Err() != nil {
+			return nil, cs.ctx.Err()
+		}
+
+		if len(op.replacements) > 0 {
+			cs.planReplace(op.replacements, plan, load, order)
+			continue
+		}
+		cs.planUpdate(op, plan, load)
+	}
+
+	var changed []*plannedFile
+	for _, file := range order {
+		if file.content == file.original {
+			continue
+		}
+		changed = append(changed, file)
+
+		relPath := cs.relPath(file.path)
+		plan.Files = append(plan.Files, PlannedChange{
+			Path: relPath,
+			Diff: unifiedDiff("a/"+relPath, "b/"+relPath, file.format.restore(file.original), file.format.restore(file.content)),
+		})
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].path < changed[j].path })
+	sort.Slice(plan.Files, func(i, j int) bool { return plan.Files[i].Path < plan.Files[j].Path })
+
+	cs.planned = &changeSetPlan{plan: plan, files: changed}
+	return plan, nil
+}
+
+func (cs *ChangeSet) planReplace(replacements []TagReplacement, plan *ChangePlan, load func(string) (*plannedFile, error), loaded []*plannedFile) {
+	m := cs.manager
+
+	var oldTags []string
+	for _, replacement := range replacements {
+		oldTags = append(oldTags, replacement.OldTag)
+	}
+	if len(oldTags) == 0 {
+		return
+	}
+
+	found, err := m.FindFilesByTags(cs.ctx, oldTags, cs.rootPath)
+	if err != nil {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("error finding files to replace tags in: %v", err))
+		return
+	}
+
+	// Files already changed by earlier operations may have gained the tags
+	// since they were read from disk
+	var paths []string
+	for _, file := range loaded {
+		paths = append(paths, file.path)
+	}
+	for _, fileList := range found {
+		paths = append(paths, fileList...)
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[filepath.Clean(path)] {
+			continue
+		}
+		seen[filepath.Clean(path)] = true
+
+		file, err := load(path)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %v", cs.relPath(path), err))
+			continue
+		}
+		if hasIgnoreFileDirective(file.content) {
+			continue
+		}
+		file.content = m.replaceTagsInContent(file.content, replacements)
+	}
+}
+
+func (cs *ChangeSet) planUpdate(op changeOp, plan *ChangePlan, load func(string) (*plannedFile, error)) {
+	m := cs.manager
+
+	addTags, removeTags := []string{}, []string{}
+	if len(op.addTags) > 0 || len(op.removeTags) > 0 {
+		var err error
+		addTags, removeTags, err = m.resolveTagConflicts(op.addTags, op.removeTags)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("tag conflict resolution failed: %v", err))
+			return
+		}
+	}
+
+	for _, filePath := range op.filePaths {
+		absolutePath, err := m.notePath(cs.rootPath, filePath)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %v", filePath, err))
+			continue
+		}
+
+		file, err := load(absolutePath)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %v", filePath, err))
+			continue
+		}
+
+		update, err := m.updateTagsInContent(file.content, m.normalizeTags(addTags), removeTags)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: %v", filePath, err))
+			continue
+		}
+		file.content = update.content
+	}
+}
+
+// Apply writes the planned changes, planning first if operations were added
+// since the last Plan. Nothing is written if the plan has errors, or any file
+// changed on disk since it was planned or can't be written, and files already
+// written are put back if a later write fails.
+func (cs *ChangeSet) Apply() (*ChangeSetResult, error) {
+	if cs.planned == nil {
+		if _, err := cs.Plan(); err != nil {
+			return nil, err
+		}
+	}
+	m := cs.manager
+	planned := cs.planned
+
+	if len(planned.plan.Errors) > 0 {
+		return nil, fmt.Errorf("change set has %d errors: %s", len(planned.plan.Errors), strings.Join(planned.plan.Errors, "; "))
+	}
+
+	for _, file := range planned.files {
+		current, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cs.relPath(file.path), err)
+		}
+		if !bytes.Equal(current, file.stored) {
+			return nil, fmt.Errorf("%s: file has changed since the change set was planned", cs.relPath(file.path))
+		}
+		if err := checkWritable(m.config, file.path); err != nil {
+			return nil, fmt.Errorf("%s: %w", cs.relPath(file.path), err)
+		}
+	}
+
+	result := &ChangeSetResult{ModifiedFiles: []string{}, Errors: []string{}}
+	backup := m.startBackup(cs.rootPath)
+	journal := m.startJournal(cs.rootPath, cs.kind)
+
+	var written []*plannedFile
+	for _, file := range planned.files {
+		if err := cs.writePlanned(file, backup, journal); err != nil {
+			err = fmt.Errorf("failed to apply change set: %s: %w", cs.relPath(file.path), err)
+			for _, done := range written {
+				if restoreErr := overwriteFile(done.path, done.stored, m.config.PreserveMtime); restoreErr != nil {
+					err = fmt.Errorf("%w; failed to restore %s: %v", err, cs.relPath(done.path), restoreErr)
+				}
+			}
+			return nil, err
+		}
+		written = append(written, file)
+		result.ModifiedFiles = append(result.ModifiedFiles, cs.relPath(file.path))
+	}
+
+	result.Backup = backup.location()
+
+	id, err := journal.commit()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Operation = id
+
+	// The operations are done, so the ChangeSet starts over empty
+	cs.planned = nil
+	cs.ops = nil
+
+	return result, nil
+}
+
+func (cs *ChangeSet) writePlanned(file *plannedFile, backup *backup, journal *journal) error {
+	if err := backup.save(file.path); err != nil {
+		return err
+	}
+
+	content := []byte(file.format.restore(file.content))
+	if err := writeNote(cs.ctx, cs.manager.config, file.path, file.stored, content); err != nil {
+		return err
+	}
+	journal.record(file.path, file.stored, content)
+	return nil
+}
+
+func (cs *ChangeSet) relPath(path string) string {
+	relPath, err := filepath.Rel(cs.rootPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// checkWritable reports why path can't be rewritten, without modifying it
+func checkWritable(config *Config, path string) error {
+	if hook := cryptHook(config, path); hook != nil && len(hook.Encrypt) == 0 {
+		return fmt.Errorf("no encrypt command configured for %s files", hook.Pattern)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("file is not writable: %w", err)
+	}
+	return file.Close()
+}
+
+// WithTransactional returns a manager whose ReplaceTagsBatch modifies every
+// file or none, as Config.Transactional does.
+func (m *DefaultTagManager) WithTransactional(enabled bool) TagManager {
+	transactional := *m
+	transactional.transactional = enabled
+	return &transactional
+}
+
+// replaceTagsTransactional stages every replacement as a ChangeSet before
+// writing anything, so either all files are modified or none are.
+func (m *DefaultTagManager) replaceTagsTransactional(ctx context.Context, replacements []TagReplacement, rootPath string) (*TagReplaceResult, error) {
+	changes := m.BeginChangeSet(ctx, rootPath).Replace(replacements...)
+	changes.kind = JournalReplace
+
+	applied, err := changes.Apply()
+	if err != nil {
+		return nil, fmt.Errorf("transactional replace failed: %w", err)
+	}
+
+	result := &TagReplaceResult{
+		ModifiedFiles: []string{},
+		FailedFiles:   []string{},
+		Errors:        applied.Errors,
+		Backup:        applied.Backup,
+		Operation:     applied.Operation,
+	}
+	for _, relPath := range applied.ModifiedFiles {
+		file := filepath.Join(rootPath, filepath.FromSlash(relPath))
+		result.ModifiedFiles = append(result.ModifiedFiles, file)
+		m.fileDone(file, true, nil)
+	}
+	return result, nil
+}
+
+// UpdatePreview is what UpdateTags would do, without modifying any file
+type UpdatePreview struct {
+	// Files are the notes which would be modified, with their diffs
+	Files         []PlannedChange `json:"files"`
+	FilesMigrated []string        `json:"files_migrated"`
+	TagsAdded     map[string]int  `json:"tags_added"`
+	TagsRemoved   map[string]int  `json:"tags_removed"`
+	// Conflicts are tags both added and removed, which are left alone
+	Conflicts []string `json:"conflicts,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// PreviewUpdateTags runs UpdateTags as a dry run and plans the same update as
+// a ChangeSet, returning the diff of each file it would modify.
+func (m *DefaultTagManager) PreviewUpdateTags(ctx context.Context, addTags []string, removeTags []string, rootPath string, filePaths []string) (*UpdatePreview, error) {
+	result, err := m.UpdateTags(ctx, addTags, removeTags, rootPath, filePaths, true)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := m.BeginChangeSet(ctx, rootPath).Update(addTags, removeTags, filePaths...).Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &UpdatePreview{
+		Files:         plan.Files,
+		FilesMigrated: result.FilesMigrated,
+		TagsAdded:     result.TagsAdded,
+		TagsRemoved:   result.TagsRemoved,
+		Errors:        result.Errors,
+	}
+	for _, tag := range m.normalizeTags(addTags) {
+		if containsTag(m.normalizeTags(removeTags), tag) {
+			preview.Conflicts = append(preview.Conflicts, tag)
+		}
+	}
+	return preview, nil
+}
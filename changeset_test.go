@@ -0,0 +1,211 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestChangeSet(t *testing.T) {
+	ctx := context.Background()
+
+	newManager := func(t *testing.T, config *tagmanager.Config) tagmanager.TagManager {
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+		return manager
+	}
+
+	readFile := func(t *testing.T, root, name string) string {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("ComposesOperations", func(t *testing.T) {
+		inbox := "#draft #idea\nSome thoughts\n"
+		other := "---\ntags: [draft]\n---\nbody\n"
+		root := writeVault(t, map[string]string{"inbox.md": inbox, "other.md": other, "plain.md": "no tags\n"})
+		manager := newManager(t, tagmanager.DefaultConfig())
+
+		changes := manager.BeginChangeSet(ctx, root).
+			Migrate("inbox.md").
+			Update([]string{"project"}, nil, "inbox.md").
+			Replace(tagmanager.TagReplacement{OldTag: "draft", NewTag: "wip"})
+
+		plan, err := changes.Plan()
+		require.NoError(t, err)
+		assert.Empty(t, plan.Errors)
+		require.Len(t, plan.Files, 2)
+		assert.Equal(t, "inbox.md", plan.Files[0].Path)
+		assert.Contains(t, plan.Files[0].Diff, "-#draft #idea")
+		assert.Contains(t, plan.Files[0].Diff, "wip")
+		assert.Equal(t, "other.md", plan.Files[1].Path)
+
+		// Planning modifies nothing
+		assert.Equal(t, inbox, readFile(t, root, "inbox.md"))
+		assert.Equal(t, other, readFile(t, root, "other.md"))
+
+		result, err := changes.Apply()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"inbox.md", "other.md"}, result.ModifiedFiles)
+		require.NotEmpty(t, result.Operation)
+
+		fileTags, err := manager.GetFilesTags(ctx, []string{filepath.Join(root, "inbox.md")})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"wip", "idea", "project"}, fileTags[0].Tags)
+		assert.NotContains(t, readFile(t, root, "other.md"), "draft")
+
+		// The whole set is one journal operation, undone in one step
+		ops, err := tagmanager.ReadJournal(root)
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, tagmanager.JournalChangeSet, ops[0].Kind)
+
+		undo, err := manager.Undo(ctx, root, result.Operation, false)
+		require.NoError(t, err)
+		assert.Empty(t, undo.Errors)
+		assert.Equal(t, inbox, readFile(t, root, "inbox.md"))
+		assert.Equal(t, other, readFile(t, root, "other.md"))
+	})
+
+	t.Run("ErrorsPreventApply", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft"})
+		manager := newManager(t, tagmanager.DefaultConfig())
+
+		changes := manager.BeginChangeSet(ctx, root).
+			Replace(tagmanager.TagReplacement{OldTag: "draft", NewTag: "wip"}).
+			Update([]string{"x-tag"}, nil, "missing.md", "../outside.md")
+
+		plan, err := changes.Plan()
+		require.NoError(t, err)
+		require.Len(t, plan.Errors, 2)
+		assert.Contains(t, plan.Errors[0], "missing.md")
+		assert.Contains(t, plan.Errors[1], "cannot contain '..'")
+
+		_, err = changes.Apply()
+		assert.ErrorContains(t, err, "change set has 2 errors")
+		assert.Equal(t, "#draft", readFile(t, root, "a.md"))
+	})
+
+	t.Run("ChangedSincePlan", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft", "b.md": "#draft"})
+		manager := newManager(t, tagmanager.DefaultConfig())
+
+		changes := manager.BeginChangeSet(ctx, root).Replace(tagmanager.TagReplacement{OldTag: "draft", NewTag: "wip"})
+		_, err := changes.Plan()
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.md"), []byte("#draft edited"), tagmanager.DefaultFilePermissions))
+
+		_, err = changes.Apply()
+		assert.ErrorContains(t, err, "b.md: file has changed since the change set was planned")
+		assert.Equal(t, "#draft", readFile(t, root, "a.md"))
+	})
+
+	t.Run("UnwritableRefused", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft", "b.secret.md": "#draft"})
+		config := tagmanager.DefaultConfig()
+		// Notes with a decrypt command but no encrypt command can't be written
+		config.CryptHooks = []tagmanager.CryptHook{{Pattern: "*.secret.md", Decrypt: []string{"cat"}}}
+		manager := newManager(t, config)
+
+		_, err := manager.BeginChangeSet(ctx, root).
+			Replace(tagmanager.TagReplacement{OldTag: "draft", NewTag: "wip"}).
+			Apply()
+		assert.ErrorContains(t, err, "b.secret.md: no encrypt command")
+		assert.Equal(t, "#draft", readFile(t, root, "a.md"))
+		assert.Equal(t, "#draft", readFile(t, root, "b.secret.md"))
+	})
+}
+
+func TestTransactionalReplace(t *testing.T) {
+	ctx := context.Background()
+	replacements := []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}
+
+	t.Run("ModifiesAll", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft", "b.md": "---\ntags: [draft]\n---\n"})
+		config := tagmanager.DefaultConfig()
+		config.Transactional = true
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		result, err := manager.ReplaceTagsBatch(ctx, replacements, root, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(root, "a.md"), filepath.Join(root, "b.md")}, result.ModifiedFiles)
+		require.NotEmpty(t, result.Operation)
+
+		ops, err := tagmanager.ReadJournal(root)
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, tagmanager.JournalReplace, ops[0].Kind)
+	})
+
+	t.Run("ModifiesNoneOnFailure", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft", "b.secret.md": "#draft"})
+		config := tagmanager.DefaultConfig()
+		config.CryptHooks = []tagmanager.CryptHook{{Pattern: "*.secret.md", Decrypt: []string{"cat"}}}
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		_, err = manager.WithTransactional(true).ReplaceTagsBatch(ctx, replacements, root, false)
+		assert.ErrorContains(t, err, "transactional replace failed: b.secret.md: no encrypt command")
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#draft", string(content))
+
+		// Without the mode the writable file is still modified
+		result, err := manager.ReplaceTagsBatch(ctx, replacements, root, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(root, "a.md")}, result.ModifiedFiles)
+		assert.Len(t, result.FailedFiles, 1)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft"})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		result, err := manager.WithTransactional(true).ReplaceTagsBatch(ctx, replacements, root, true)
+		require.NoError(t, err)
+		assert.Len(t, result.ModifiedFiles, 1)
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#draft", string(content))
+	})
+}
+
+func TestPreviewUpdateTags(t *testing.T) {
+	inbox := "#draft\nSome thoughts\n"
+	root := writeVault(t, map[string]string{"inbox.md": inbox, "tagged.md": "---\ntags: [golang]\n---\n"})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	_, data, err := tagmanager.PreviewUpdateTagsTool(context.Background(), &mcp.CallToolRequest{}, tagmanager.PreviewUpdateTagsParams{
+		AddTags:    []string{"project", "golang"},
+		RemoveTags: []string{"golang"},
+		FilePaths:  []string{"inbox.md", "tagged.md", "missing.md"},
+		Root:       root,
+	}, manager)
+	require.NoError(t, err)
+
+	preview := data.(*tagmanager.UpdatePreview)
+	require.Len(t, preview.Files, 2)
+	assert.Equal(t, "inbox.md", preview.Files[0].Path)
+	assert.Contains(t, preview.Files[0].Diff, "-#draft")
+	assert.Contains(t, preview.Files[1].Diff, "+tags: [golang, project]")
+	assert.Equal(t, []string{"inbox.md"}, preview.FilesMigrated)
+	assert.Equal(t, map[string]int{"draft": 1, "project": 2}, preview.TagsAdded)
+	assert.Equal(t, []string{"golang"}, preview.Conflicts)
+	require.Len(t, preview.Errors, 1)
+	assert.Contains(t, preview.Errors[0], "missing.md")
+
+	content, err := os.ReadFile(filepath.Join(root, "inbox.md"))
+	require.NoError(t, err)
+	assert.Equal(t, inbox, string(content))
+}
@@ -0,0 +1,157 @@
+package tagmanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckpointFileName records, inside IndexDir, the files a replace or update
+// has finished, so an interrupted run can be resumed
+const CheckpointFileName = "checkpoint.jsonl"
+
+// checkpointHeader is the first line of the checkpoint file. Each line after
+// it is a checkpointEntry.
+type checkpointHeader struct {
+	Kind string `json:"kind"`
+	// Params is a hash of the operation's tags and files, so only the same
+	// operation resumes from the checkpoint
+	Params string    `json:"params"`
+	Time   time.Time `json:"time"`
+}
+
+type checkpointEntry struct {
+	Done string `json:"done"`
+}
+
+// checkpoint appends each file a batch finishes to the checkpoint file, which
+// is removed once the batch completes. A nil checkpoint records nothing, as
+// for dry runs.
+type checkpoint struct {
+	path string
+	file *os.File
+	// done are the files an interrupted run finished, which a resumed run
+	// skips
+	done map[string]bool
+}
+
+// WithResume returns a manager whose ReplaceTagsBatch and UpdateTags resume an
+// interrupted run of the same operation, skipping the files it finished.
+func (m *DefaultTagManager) WithResume(resume bool) TagManager {
+	resuming := *m
+	resuming.resume = resume
+	return &resuming
+}
+
+// startCheckpoint begins the checkpoint of an operation of kind with params.
+// Resuming reads the files an interrupted run of it finished; otherwise any
+// earlier checkpoint is replaced.
+func (m *DefaultTagManager) startCheckpoint(rootPath, kind string, params any) (*checkpoint, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	header := checkpointHeader{Kind: kind, Params: hashContent(data), Time: time.Now().UTC()}
+
+	cp := &checkpoint{path: filepath.Join(rootPath, IndexDir, CheckpointFileName), done: make(map[string]bool)}
+	perm := newFilePerm(m.config)
+	if m.resume {
+		previous, done, err := readCheckpoint(cp.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no interrupted %s to resume", kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if previous.Kind != header.Kind || previous.Params != header.Params {
+			return nil, fmt.Errorf("the interrupted operation was a %s with different tags or files; run without --resume to start over", previous.Kind)
+		}
+		cp.done = done
+	} else {
+		line, err := json.Marshal(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		if err := perm.mkdirAll(filepath.Dir(cp.path)); err != nil {
+			return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		if err := perm.writeFile(cp.path, append(line, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+	}
+
+	if cp.file, err = perm.openAppend(cp.path); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// readCheckpoint returns the header of the checkpoint file at path and the
+// files it records as done
+func readCheckpoint(path string) (checkpointHeader, map[string]bool, error) {
+	var header checkpointHeader
+	file, err := os.Open(path)
+	if err != nil {
+		return header, nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil {
+		return header, nil, fmt.Errorf("checkpoint %s is corrupt", path)
+	}
+	done := make(map[string]bool)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		// A torn final write from an interrupted process is not fatal
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Done != "" {
+			done[entry.Done] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return header, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return header, done, nil
+}
+
+// skip reports whether an interrupted run already finished path
+func (c *checkpoint) skip(path string) bool {
+	return c != nil && c.done[path]
+}
+
+// record notes that path is finished
+func (c *checkpoint) record(path string) error {
+	if c == nil {
+		return nil
+	}
+	line, err := json.Marshal(checkpointEntry{Done: path})
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// finish closes the checkpoint, removing it when the batch completed so
+// there is nothing left to resume
+func (c *checkpoint) finish(completed bool) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if completed {
+		if err := os.Remove(c.path); err != nil {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestResume(t *testing.T) {
+	note := "---\ntags: [golang]\n---\nBody\n"
+	files := map[string]string{"a.md": note, "b.md": note, "c.md": note}
+	paths := []string{"a.md", "b.md", "c.md"}
+	checkpointPath := func(root string) string {
+		return filepath.Join(root, tagmanager.IndexDir, tagmanager.CheckpointFileName)
+	}
+
+	t.Run("ResumesInterruptedUpdate", func(t *testing.T) {
+		root := writeVault(t, files)
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		// Interrupt once the first file is done
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		result, err := manager.WithProgress(func(tagmanager.FileResult) { cancel() }).
+			UpdateTags(ctx, []string{"reviewed"}, nil, root, paths, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md"}, result.ModifiedFiles)
+		assert.FileExists(t, checkpointPath(root))
+
+		var modified []string
+		result, err = manager.WithResume(true).WithProgress(func(r tagmanager.FileResult) {
+			modified = append(modified, r.Path)
+		}).UpdateTags(context.Background(), []string{"reviewed"}, nil, root, paths, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Resumed)
+		assert.Equal(t, []string{"b.md", "c.md"}, modified)
+		assert.Equal(t, []string{"b.md", "c.md"}, result.ModifiedFiles)
+		assert.NoFileExists(t, checkpointPath(root))
+
+		for _, path := range paths {
+			content, err := os.ReadFile(filepath.Join(root, path))
+			require.NoError(t, err)
+			assert.Equal(t, "---\ntags: [golang, reviewed]\n---\nBody\n", string(content), path)
+		}
+	})
+
+	t.Run("DifferentOperation", func(t *testing.T) {
+		root := writeVault(t, files)
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err = manager.WithProgress(func(tagmanager.FileResult) { cancel() }).
+			UpdateTags(ctx, []string{"reviewed"}, nil, root, paths, false)
+		require.NoError(t, err)
+
+		_, err = manager.WithResume(true).UpdateTags(context.Background(), []string{"draft"}, nil, root, paths, false)
+		assert.ErrorContains(t, err, "different tags or files")
+
+		_, err = manager.WithResume(true).ReplaceTagsBatch(context.Background(),
+			[]tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		assert.ErrorContains(t, err, "different tags or files")
+	})
+
+	t.Run("NothingToResume", func(t *testing.T) {
+		root := writeVault(t, files)
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.UpdateTags(context.Background(), []string{"reviewed"}, nil, root, paths, false)
+		require.NoError(t, err)
+		assert.NoFileExists(t, checkpointPath(root))
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "reviewed", "--files", "a.md",
+			"--root", root, "--resume"}, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.ErrorContains(t, err, "no interrupted update to resume")
+	})
+}
@@ -0,0 +1,58 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultChunkSize is the number of files between checkpoints of a batch
+const DefaultChunkSize = 500
+
+func validateChunkSize(size int) error {
+	if size < 0 {
+		return fmt.Errorf("invalid chunk_size %d: must not be negative", size)
+	}
+	return nil
+}
+
+// chunker paces the files of a replace or update in chunks of
+// Config.ChunkSize. Between chunks it checkpoints the journal, so a crash
+// loses at most one chunk of undo history, and stops the batch when the
+// context's deadline would pass before the next chunk is done.
+type chunker struct {
+	ctx     context.Context
+	meter   func(done, total int)
+	size    int
+	total   int
+	started time.Time
+	journal *journal
+}
+
+func (m *DefaultTagManager) newChunker(ctx context.Context, total int, journal *journal) *chunker {
+	return &chunker{ctx: ctx, meter: m.metered, size: m.config.ChunkSize, total: total, started: time.Now(), journal: journal}
+}
+
+// proceed returns nil when file i of the batch should be processed, or why
+// the batch stops before it
+func (c *chunker) proceed(i int) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	c.meter(i, c.total)
+	if c.size <= 0 || i == 0 || i%c.size != 0 {
+		return nil
+	}
+
+	if err := c.journal.checkpoint(); err != nil {
+		return err
+	}
+	if deadline, ok := c.ctx.Deadline(); ok {
+		perFile := time.Since(c.started) / time.Duration(i)
+		next := time.Duration(min(c.size, c.total-i))
+		if time.Now().Add(perFile * next).After(deadline) {
+			return fmt.Errorf("the next chunk would not finish before the deadline: %w", context.DeadlineExceeded)
+		}
+	}
+	return nil
+}
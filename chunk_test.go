@@ -0,0 +1,82 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestChunkedBatches(t *testing.T) {
+	note := "---\ntags: [golang]\n---\nBody\n"
+	files := map[string]string{"a.md": note, "b.md": note, "c.md": note}
+
+	t.Run("StopsWhenCanceled", func(t *testing.T) {
+		root := writeVault(t, files)
+		config := tagmanager.DefaultConfig()
+		config.ChunkSize = 1
+		defaultManager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		// Cancel once the second file is done
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := 0
+		manager := defaultManager.WithProgress(func(tagmanager.FileResult) {
+			if done++; done == 2 {
+				cancel()
+			}
+		})
+
+		result, err := manager.UpdateTags(ctx, []string{"reviewed"}, nil, root, []string{"a.md", "b.md", "c.md"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md", "b.md"}, result.ModifiedFiles)
+		assert.Equal(t, []string{"c.md"}, result.Remaining)
+		assert.Contains(t, result.Stopped, "context canceled")
+
+		content, err := os.ReadFile(filepath.Join(root, "c.md"))
+		require.NoError(t, err)
+		assert.Equal(t, note, string(content))
+
+		// The checkpoint after the first file is superseded by the commit
+		ops, err := tagmanager.ReadJournal(root)
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, result.Operation, ops[0].ID)
+		assert.Len(t, ops[0].Files, 2)
+
+		// Resuming with the remaining files finishes the batch
+		result, err = manager.UpdateTags(context.Background(), []string{"reviewed"}, nil, root, result.Remaining, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c.md"}, result.ModifiedFiles)
+		assert.Empty(t, result.Remaining)
+	})
+
+	t.Run("ReplaceStopsWhenCanceled", func(t *testing.T) {
+		root := writeVault(t, files)
+		defaultManager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		manager := defaultManager.WithProgress(func(tagmanager.FileResult) {
+			cancel()
+		})
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		require.NoError(t, err)
+		assert.Len(t, result.ModifiedFiles, 1)
+		assert.Len(t, result.Remaining, 2)
+		assert.NotEmpty(t, result.Stopped)
+	})
+
+	t.Run("InvalidChunkSize", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.ChunkSize = -1
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid chunk_size")
+	})
+}
@@ -0,0 +1,112 @@
+package tagmanager
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Actions of a CleanAction
+const (
+	CleanRemove = "remove"
+	CleanRename = "rename"
+)
+
+// CleanAction is what clean does with an invalid tag in every file it is in
+type CleanAction struct {
+	Tag    string `json:"tag"`
+	Action string `json:"action"`
+	// NewTag is the validator's suggested spelling a CleanRename renames the
+	// tag to
+	NewTag string `json:"new_tag,omitempty"`
+	// Issues are why the validator rejects the tag
+	Issues []string `json:"issues"`
+	// Files are relative to the root, with / separators
+	Files []string `json:"files"`
+}
+
+// CleanPlan is the changes clean makes to the invalid tags of a vault
+type CleanPlan struct {
+	// Actions are sorted by tag
+	Actions []CleanAction `json:"actions"`
+	// Ops are the per-file changes carrying out the actions, sorted by path,
+	// for UpdateTagsPerFile
+	Ops []FileTagOp `json:"ops"`
+}
+
+// CleanResult is a CleanPlan with the outcome of carrying it out, or of
+// the dry run
+type CleanResult struct {
+	*CleanPlan
+	*TagUpdateResult
+}
+
+// PlanClean decides what to do with each tag under rootPath which fails
+// validation: a tag the validator suggests a valid spelling for is renamed to
+// it, and the rest, such as hex colors, IDs and tags with excluded keywords,
+// are removed. Protected tags are kept. UpdateTagsPerFile applies the plan's
+// ops.
+func (m *DefaultTagManager) PlanClean(ctx context.Context, rootPath string) (*CleanPlan, error) {
+	validation, err := m.ValidateVault(ctx, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &CleanPlan{Actions: []CleanAction{}, Ops: []FileTagOp{}}
+	ops := make(map[string]*FileTagOp)
+	for _, invalid := range validation.Invalid {
+		if m.isProtected(invalid.Tag) {
+			continue
+		}
+		action := CleanAction{Tag: invalid.Tag, Action: CleanRemove, Issues: invalid.Issues, Files: []string{}}
+		if fixed := m.suggestedTag(invalid.Suggestions); fixed != "" {
+			action.Action, action.NewTag = CleanRename, fixed
+		}
+
+		for _, path := range invalid.Files {
+			relPath, err := filepath.Rel(rootPath, path)
+			if err != nil {
+				continue
+			}
+			relPath = filepath.ToSlash(relPath)
+			action.Files = append(action.Files, relPath)
+
+			op := ops[relPath]
+			if op == nil {
+				op = &FileTagOp{Path: relPath}
+				ops[relPath] = op
+			}
+			op.Remove = append(op.Remove, invalid.Tag)
+			if action.NewTag != "" {
+				op.Add = append(op.Add, action.NewTag)
+			}
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	for _, op := range ops {
+		plan.Ops = append(plan.Ops, *op)
+	}
+	sort.Slice(plan.Ops, func(i, j int) bool {
+		return plan.Ops[i].Path < plan.Ops[j].Path
+	})
+	return plan, nil
+}
+
+// suggestedTag returns the first spelling the validator suggests among
+// suggestions which is itself valid, or "" when there is none. Advice such
+// as "Consider: color-ff0000" isn't taken; only "Suggested:" spellings are.
+func (m *DefaultTagManager) suggestedTag(suggestions []string) string {
+	for _, suggestion := range suggestions {
+		tag, ok := strings.CutPrefix(suggestion, "Suggested: ")
+		if !ok {
+			continue
+		}
+		tag = m.normalizeTag(tag)
+		if m.validator.ValidateTag(tag).IsValid {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestPlanClean(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"alpha.md":         "---\ntags: [golang, \"ff0000\", my--tag, ab]\n---\n# Alpha #golang",
+		"Projects/beta.md": "---\ntags:\n  - my--tag\n  - secret-plans\n---\n# Beta",
+		"gamma.md":         "---\ntags: [keep--me]\n---\n# Gamma",
+		"clean.md":         "# Clean #golang",
+	})
+	config := tagmanager.DefaultConfig()
+	config.ExcludeKeywords = append(config.ExcludeKeywords, "secret")
+	config.ProtectedTags = []string{"keep--me"}
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	plan, err := manager.PlanClean(context.Background(), root)
+	require.NoError(t, err)
+
+	actions := make(map[string]tagmanager.CleanAction)
+	for _, action := range plan.Actions {
+		assert.NotEmpty(t, action.Issues, action.Tag)
+		action.Issues = nil
+		actions[action.Tag] = action
+	}
+	assert.Equal(t, map[string]tagmanager.CleanAction{
+		"ab":           {Tag: "ab", Action: tagmanager.CleanRemove, Files: []string{"alpha.md"}},
+		"ff0000":       {Tag: "ff0000", Action: tagmanager.CleanRemove, Files: []string{"alpha.md"}},
+		"my--tag":      {Tag: "my--tag", Action: tagmanager.CleanRename, NewTag: "my-tag", Files: []string{"Projects/beta.md", "alpha.md"}},
+		"secret-plans": {Tag: "secret-plans", Action: tagmanager.CleanRemove, Files: []string{"Projects/beta.md"}},
+	}, actions)
+
+	assert.Equal(t, []tagmanager.FileTagOp{
+		{Path: "Projects/beta.md", Add: []string{"my-tag"}, Remove: []string{"my--tag", "secret-plans"}},
+		{Path: "alpha.md", Add: []string{"my-tag"}, Remove: []string{"ab", "ff0000", "my--tag"}},
+	}, plan.Ops)
+}
+
+func TestCleanCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	original := "---\ntags: [golang, my--tag, ab]\n---\n# Alpha"
+	root := writeVault(t, map[string]string{"alpha.md": original})
+	read := func(t *testing.T) string {
+		content, err := os.ReadFile(filepath.Join(root, "alpha.md"))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	output, err := run(t, "clean", "--root", root)
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"DRY RUN MODE", "Would clean 2 invalid tags in 1 files:",
+		"#ab: remove (Tag must be at least 3 characters long)", "#my--tag: rename to #my-tag", "    alpha.md"})
+	assert.Equal(t, original, read(t))
+
+	output, err = run(t, "--dry-run", "clean", "--root", root, "--apply")
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Would clean 2 invalid tags"})
+	assert.Equal(t, original, read(t))
+
+	output, err = run(t, "clean", "--root", root, "--apply")
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Cleaned 2 invalid tags in 1 files:", "Journaled as operation"})
+	assert.NotContains(t, output, "DRY RUN MODE")
+	assert.Equal(t, "---\ntags: [golang, my-tag]\n---\n# Alpha", read(t))
+
+	output, err = run(t, "clean", "--root", root, "--apply")
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Cleaned 0 invalid tags in 0 files:"})
+}
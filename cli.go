@@ -1,15 +1,23 @@
 package tagmanager
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -21,13 +29,32 @@ type RunCmdOptions struct {
 	Stdout io.Writer
 	// Stderr writer for error output (defaults to os.Stderr)
 	Stderr io.Writer
+	// Stdin reader for interactive prompts (defaults to os.Stdin)
+	Stdin io.Reader
 }
 
 // commandContext holds runtime context for command execution
 type commandContext struct {
 	stdout  io.Writer
 	stderr  io.Writer
+	stdin   io.Reader
+	config  *Config
 	manager TagManager
+	// info is for informational text, such as banners and summaries, which
+	// --quiet discards
+	info io.Writer
+	// failIfEmpty fails searches which find nothing with ExitEmpty
+	failIfEmpty bool
+	// jsonIndent indents JSON output for reading, rather than one line per
+	// result
+	jsonIndent bool
+	// color colors text output
+	color palette
+	// root is the default of each command's --root: the global --root, or
+	// the vault found from the current directory
+	root string
+	// stdinFlag is the flag which read stdin, which only one flag can
+	stdinFlag string
 }
 
 func RunCmd(args []string, options *RunCmdOptions) error {
@@ -42,12 +69,30 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
 
 	var (
-		help       = fs.Bool("h", false, "Show help")
-		mcpOption  = fs.Bool("mcp", false, "Run as MCP server")
-		verbose    = fs.Bool("v", false, "Verbose output")
-		dryRun     = fs.Bool("dry-run", false, "Show what would be changed without making changes")
-		configFile = fs.String("config", "", "Path to configuration file")
+		help        = fs.Bool("h", false, "Show help")
+		mcpOption   = fs.Bool("mcp", false, "Run as MCP server")
+		httpAddr    = fs.String("http", "", "With -mcp, serve a read-only query console for the root over HTTP at this address")
+		httpMCP     = fs.Bool("http-mcp", false, "With --http, also serve the MCP tools at /mcp, limited to the root and the configured roots")
+		verbose     = fs.Bool("v", false, "Verbose output")
+		quiet       = fs.Bool("q", false, "Quiet output")
+		failIfEmpty = fs.Bool("fail-if-empty", false, "Exit with code 3 when a search finds nothing")
+		dryRun      = fs.Bool("dry-run", false, "Show what would be changed without making changes")
+		configFile  = fs.String("config", "", "Path to configuration file; the .tag-manager.yaml of the vault when not given")
+		failOnScan  = fs.Bool("fail-on-scan-error", false, "Fail instead of skipping files which can't be scanned")
+		strict      = fs.Bool("strict", false, "Fail on skipped files, ambiguous frontmatter, truncated input and tag collisions")
+		backupMode  = fs.String("backup", "", "Back up files before modifying them: tree or sibling")
+		jsonIndent  = fs.Bool("json-indent", false, "Indent JSON output; the default when output is a terminal")
+		color       = fs.Bool("color", false, "Color text output even when it isn't a terminal")
+		noColor     = fs.Bool("no-color", false, "Don't color text output, as when NO_COLOR is set")
+		noDefaults  = fs.Bool("no-default-excludes", false, "Scan the directories and files excluded by default")
+		rootDir     = fs.String("root", "", "Vault root for every command; found from the current directory when not given")
+		profile     = fs.String("profile", "", "Use the root and options of this profile from the config")
 	)
+	var excludeDirs, excludePatterns stringsFlag
+	fs.Var(&excludeDirs, "exclude-dir", "Also skip directories with this name, repeatable")
+	fs.Var(&excludePatterns, "exclude-pattern", "Also skip files matching this name pattern, repeatable")
+	fs.BoolVar(verbose, "verbose", false, "Verbose output, including files skipped because of scan errors")
+	fs.BoolVar(quiet, "quiet", false, "Print only results and errors, without banners or summaries")
 
 	if len(args) > 1 {
 		if err := fs.Parse(args[1:]); err != nil {
@@ -63,6 +108,26 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 		return ShowHelp(stdout)
 	}
 
+	// Without --config, the vault's own config applies
+	if *configFile == "" {
+		if path, ok := vaultConfigFile(*rootDir); ok {
+			*configFile = path
+		}
+	}
+
+	if *httpAddr != "" && !*mcpOption {
+		return fmt.Errorf("--http requires -mcp")
+	}
+	if *httpMCP && *httpAddr == "" {
+		return fmt.Errorf("--http-mcp requires --http")
+	}
+	if *mcpOption && *httpAddr != "" {
+		root, err := defaultRoot(*rootDir)
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		return ServeMCPHTTP(*configFile, *httpAddr, root, *httpMCP)
+	}
 	if *mcpOption {
 		var transport *mcp.InMemoryTransport
 		if options != nil && options.MCPTransport != nil {
@@ -82,13 +147,42 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 
 	config, err := LoadConfig(*configFile)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		// config validate reports the problems of the file itself
+		if len(remaining) < 2 || remaining[0] != "config" || remaining[1] != "validate" {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		config = DefaultConfig()
+	}
+	if *profile != "" {
+		var profileRoot string
+		if config, profileRoot, err = applyProfile(config, *profile); err != nil {
+			return err
+		}
+		if *rootDir == "" {
+			*rootDir = profileRoot
+		}
+	}
+	if *failOnScan {
+		config.FailOnScanError = true
+	}
+	if *strict {
+		config.Strict = true
+	}
+	if *backupMode != "" {
+		config.Backup = *backupMode
+	}
+	if *noDefaults {
+		config.ExcludeDirs, config.ExcludePatterns = withoutDefaultExcludes(config)
 	}
+	config.ExcludeDirs = append(config.ExcludeDirs, excludeDirs...)
+	config.ExcludePatterns = append(config.ExcludePatterns, excludePatterns...)
 
 	// Initialize command context with writers
 	cmdCtx := &commandContext{
 		stdout: io.Writer(os.Stdout),
 		stderr: io.Writer(os.Stderr),
+		stdin:  io.Reader(os.Stdin),
+		config: config,
 	}
 
 	if options != nil {
@@ -98,14 +192,53 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 		if options.Stderr != nil {
 			cmdCtx.stderr = options.Stderr
 		}
+		if options.Stdin != nil {
+			cmdCtx.stdin = options.Stdin
+		}
+	}
+	cmdCtx.info = cmdCtx.stdout
+	if *quiet {
+		cmdCtx.info = io.Discard
+	}
+	cmdCtx.failIfEmpty = *failIfEmpty
+	cmdCtx.jsonIndent = isTerminal(cmdCtx.stdout)
+	cmdCtx.color = newPalette(cmdCtx.stdout, *color, *noColor)
+	if cmdCtx.root, err = defaultRoot(*rootDir); err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "json-indent" {
+			cmdCtx.jsonIndent = *jsonIndent
+		}
+	})
+
+	// config runs without a manager, so it can inspect an invalid configuration
+	if remaining[0] == "config" {
+		return configCommand(cmdCtx, *configFile, remaining[1:])
+	}
+	if remaining[0] == "version" {
+		return versionCommand(cmdCtx, remaining[1:])
 	}
 
-	ctx := context.Background()
+	// Ctrl-C stops batches cleanly between files, leaving a checkpoint to resume
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	manager, err := NewDefaultTagManager(config)
 	if err != nil {
 		return fmt.Errorf("failed to create tag manager: %w", err)
 	}
-	cmdCtx.manager = manager
+	report := &ScanReport{}
+	cmdCtx.manager = manager.WithScanReport(report)
+	if *verbose {
+		defer printScanErrors(cmdCtx.stderr, report)
+	}
+
+	for _, conflict := range manager.Rules().Conflicts() {
+		if config.Strict {
+			return fmt.Errorf("tag rules conflict: %s", conflict)
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stderr, "warning: %s\n", conflict)
+	}
 
 	switch remaining[0] {
 	case "find":
@@ -114,6 +247,16 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 		return getTagInfoCommand(ctx, cmdCtx, remaining[1:], *verbose)
 	case "list":
 		return listTagsCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "tree":
+		return treeCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "graph":
+		return graphCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "cloud":
+		return cloudCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "export":
+		return exportCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "report":
+		return reportCommand(ctx, cmdCtx, remaining[1:], *verbose)
 	case "replace":
 		return replaceTagCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
 	case "update":
@@ -124,11 +267,74 @@ func RunCmd(args []string, options *RunCmdOptions) error {
 		return validateTagsCommand(ctx, cmdCtx, remaining[1:], *verbose)
 	case "file-tags":
 		return getFileTagsCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "audit":
+		return auditCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "fix":
+		return fixCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "autotag":
+		return autotagCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "clean":
+		return cleanCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "import":
+		return importCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "init":
+		return initCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "index":
+		return indexCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "changes":
+		return changesCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "history":
+		return historyCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "triage":
+		return triageCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "tui":
+		return tuiCommand(ctx, cmdCtx, remaining[1:], *dryRun)
+	case "backup":
+		return backupCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "undo":
+		return undoCommand(ctx, cmdCtx, remaining[1:], *dryRun, *verbose)
+	case "selftest":
+		return selfTestCommand(ctx, cmdCtx, remaining[1:], *verbose)
+	case "doctor":
+		return doctorCommand(ctx, cmdCtx, *configFile, remaining[1:], *verbose)
+	case "completion":
+		return completionCommand(ctx, cmdCtx, remaining[1:])
 	default:
 		return fmt.Errorf("unknown command: %s", remaining[0])
 	}
 }
 
+// stringsFlag is a flag which may be given more than once, collecting each
+// value
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// withoutDefaultExcludes returns the exclude_dirs and exclude_patterns of
+// config without those DefaultConfig adds, keeping the ones configured
+func withoutDefaultExcludes(config *Config) ([]string, []string) {
+	defaults := DefaultConfig()
+	var dirs, patterns []string
+	for _, dir := range config.ExcludeDirs {
+		if !slices.Contains(defaults.ExcludeDirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, pattern := range config.ExcludePatterns {
+		if !slices.Contains(defaults.ExcludePatterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return dirs, patterns
+}
+
 func ShowHelp(w io.Writer) error {
 	help := `Obsidian Tag Manager - Manage tags in Obsidian vaults
 
@@ -138,30 +344,118 @@ Usage:
 
 Options:
   -h, --help           Show this help message
-  -v, --verbose        Enable verbose output
+  -v, --verbose        Enable verbose output, listing files skipped by scan errors
+  -q, --quiet          Print only results and errors, without banners or summaries
+  --fail-if-empty      Exit with code 3 when find, info, list, untagged or changes finds nothing
   --dry-run            Preview changes without modifying files
-  --config FILE        Path to configuration file
+  --config FILE        Path to configuration file (default the vault's .tag-manager.yaml)
+  --root DIR           Vault root for every command (default: the vault containing the current directory)
+  --profile NAME       Use the root and options of a profile from the config
+  --fail-on-scan-error Fail instead of skipping files which can't be read
+  --strict             Fail on skipped files, ambiguous frontmatter, truncated input and tag collisions
+  --backup MODE        Back up files before modifying them: tree or sibling
+  --exclude-dir DIR    Also skip directories with this name (repeatable)
+  --exclude-pattern P  Also skip files matching this name pattern (repeatable)
+  --no-default-excludes Scan the directories and files excluded by default
+  --json-indent        Indent JSON output (default when output is a terminal)
+  --color              Color text output even when it isn't a terminal
+  --no-color           Don't color text output (also set by NO_COLOR)
   -mcp                 Run as MCP server
+  --http ADDR          With -mcp, serve a read-only tag query console for the root at ADDR/
+  --http-mcp           With --http, also serve the MCP tools at ADDR/mcp, limited to the root and configured roots
 
 Commands:
   find         Find files containing specific tags
   info         Get detailed information about tags
   list         List all tags with usage statistics
+  tree         Show nested tags as a tree with the files of each level
+  cloud        Weigh tags by use for a word cloud, optionally drawn as SVG
+  export       Dump every tag of every file, with where and on which lines, as JSON or CSV
+  report       Write a standalone HTML report of tag statistics and problems to share
+  graph        Export tag co-occurrence or hierarchy as a Graphviz or Mermaid diagram
   replace      Replace/rename tags across files
   update       Add or remove tags from specific files
   untagged     Find files without any tags
   validate     Validate tag syntax and suggest fixes
   file-tags    Get tags for specific files
+  audit        Audit the vault (flat-tags, frontmatter)
+  fix          Repair what an audit finds, where the fix is unambiguous (frontmatter)
+  autotag      Tag files by the path and content rules in the config (dry run unless --apply)
+  import       Add and remove tags per file from a CSV or JSON manifest
+  clean        Remove invalid tags, or rename them where validate suggests a fix (dry run unless --apply)
+  init         Scan a vault and write a starter .tag-manager.yaml
+  index        Maintain the persistent tag index (build, compact, inspect)
+  changes      List files whose tags changed since a time, from the index
+  history      Show when tags were added, removed and moved in the vault's git history
+  triage       Interactively tag untagged files, resuming where the last session stopped
+  tui          Browse tags and their files, renaming and merging tags in place
+  backup       Manage backups made by --backup (prune)
+  undo         Roll back a journaled replace or update
+  selftest     Try replace and update on a temporary copy of part of the vault
+  doctor       Check the vault's health: unreadable files, bad frontmatter, tag and config problems
+  completion   Print a shell completion script (bash, zsh, fish)
+  config       Manage configuration files (init, validate, show)
+  version      Print the version, commit, build date, and Go and MCP versions
 
 Examples:
   tag-manager find --tags="#golang,#python" --root="/path/to/vault"
+  tag-manager find --tags="golang,python" --match=all --root="/path/to/vault"
+  tag-manager find --tags="golang" --not-tags="draft,archive" --root="/path/to/vault"
+  tag-manager untagged --root="/path/to/vault" --path-prefix=Inbox --sort=mtime
+  tag-manager validate --root="/path/to/vault"
+  tag-manager --root="/path/to/vault" list --sort=count
+  tag-manager --config=config.yaml --profile=work list
+  tag-manager autotag --root="/path/to/vault" --apply
+  tag-manager clean --root="/path/to/vault" --apply
   tag-manager list --root="/path/to/vault" --min-count=2
+  tag-manager tree --root="/path/to/vault" --depth=2
+  tag-manager graph --root="/path/to/vault" --diagram=mermaid --top=20 --min-weight=2
+  tag-manager cloud --root="/path/to/vault" --top=50 --svg=tags.svg
+  tag-manager export --root="/path/to/vault" --format=csv > tags.csv
+  tag-manager report --root="/path/to/vault" --html=report.html
+  tag-manager import --root="/path/to/vault" --manifest=retag.csv --dry-run
+  tag-manager list --root="/path/to/vault" --changed-since=origin/main
+  tag-manager list --root="/path/to/vault" --sort=name
+  tag-manager list --root="/path/to/vault" --limit=50 --offset=100
   tag-manager replace --old="#old-tag" --new="#new-tag" --root="/path/to/vault" --dry-run
+  tag-manager replace --old="todo" --new="task" --root="/path/to/vault" --path-prefix=Projects/alpha
+  tag-manager replace --old="c" --new="c-lang" --root="/path/to/vault" --interactive
   tag-manager update --add="golang,python" --remove="old-tag" --root="/path/to/vault" --files="file1.md,file2.md" --dry-run
+  tag-manager update --add="golang" --root="/path/to/vault" --files="file1.md" --apply
   tag-manager untagged --root="/path/to/vault"
+  tag-manager untagged --root="/path/to/vault" --order=mtime:desc
   tag-manager validate --tags="#test,#invalid-tag!"
   tag-manager file-tags --files="/path/file1.md,/path/file2.md"
+  tag-manager audit flat-tags --root="/path/to/vault" --min-count=5
+  tag-manager audit frontmatter --root="/path/to/vault"
+  tag-manager fix frontmatter --root="/path/to/vault" --dry-run
+  tag-manager init --root="/path/to/vault"
+  tag-manager --config="/path/to/config.yaml" config init --path="/path/to/annotated.yaml"
+  tag-manager config validate --path="/path/to/config.yaml"
+  tag-manager --config="/path/to/config.yaml" --strict config show
+  tag-manager index build --root="/path/to/vault"
+  tag-manager --exclude-dir=Templates --exclude-pattern="*.canvas.md" list --root="/path/to/vault"
+  tag-manager --backup=tree replace --old="draft" --new="wip" --root="/path/to/vault"
+  tag-manager backup prune --root="/path/to/vault" --keep=3 --older-than=720h
+  tag-manager undo --last --root="/path/to/vault"
+  tag-manager undo --list --root="/path/to/vault"
+  tag-manager selftest --root="/path/to/vault" --sample=200
+  tag-manager doctor --root="/path/to/vault"
+  tag-manager index inspect --root="/path/to/vault" --file="notes/todo.md"
+  tag-manager changes --root="/path/to/vault" --since=24h
+  tag-manager history --root="/path/to/vault" --tags="golang,project/alpha"
+  tag-manager triage --root="/path/to/vault"
+  tag-manager tui --root="/path/to/vault"
+  source <(tag-manager completion bash)
+  tag-manager version --json
   tag-manager -mcp --config="/path/to/config.yaml"
+  tag-manager -mcp --http=127.0.0.1:8080 --root="/path/to/vault"
+
+Exit codes:
+  0  Success
+  1  Usage error, such as a bad flag or vault path, or another failure before the operation ran
+  2  The operation ran but failed for some files, found problems, or stopped before finishing
+  3  Nothing found, with --fail-if-empty
 
 For more information, visit: https://github.com/thrawn01/tag-manager
 `
@@ -172,70 +466,165 @@ For more information, visit: https://github.com/thrawn01/tag-manager
 func findFilesCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
 	fs := flag.NewFlagSet("find", flag.ContinueOnError)
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-
 	const defaultMaxResults = 100
 
-	tags := fs.String("tags", "", "Comma-separated list of tags to search for")
-	root := fs.String("root", cwd, "Root directory to search")
-	maxResults := fs.Int("max-results", defaultMaxResults, "Maximum files per tag")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	tags := fs.String("tags", "", "Comma-separated list of tags to search for, or - to read one per line from stdin")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	maxResults := fs.Int("max-results", defaultMaxResults, "Maximum files per tag, or in all with --match=all")
+	notTags := fs.String("not-tags", "", "Comma-separated list of tags; files carrying any of them are left out of the results")
+	match := fs.String("match", MatchAny, "Find the files with any of the tags, listed per tag, or with all of them, as one list: any or all")
+	changedSince := fs.String("changed-since", "", "Only scan files changed since this git ref")
+	extensions := fs.String("extensions", "", "Comma-separated extensions of the files to scan, such as md,mdx,txt, instead of the config's")
+	order := fs.String("order", "", "Order files by path, mtime or size, optionally suffixed :asc or :desc")
+	print0 := fs.Bool("print0", false, "Print each matching file once, followed by a NUL, for xargs -0")
+	pages := addPageFlags(fs, "files per tag")
+	outputFlags := addOutputFlags(fs, true)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	if err := pages.validate(); err != nil {
+		return err
+	}
+	if err := validateMatchMode(*match); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *print0 && !output.text() {
+		return fmt.Errorf("--print0 cannot be combined with --format=%s", output.format)
+	}
+
+	manager, err := scopedManager(ctx, cmdCtx, *root, *changedSince, *extensions)
+	if err != nil {
+		return err
+	}
+	if manager, err = withOrder(manager, *order); err != nil {
+		return err
+	}
 
 	if *tags == "" {
 		return fmt.Errorf("--tags is required")
 	}
 
-	tagList := strings.Split(*tags, ",")
-	for i := range tagList {
-		tagList[i] = strings.TrimSpace(tagList[i])
+	tagList, err := listFlag(cmdCtx, "tags", *tags)
+	if err != nil {
+		return err
+	}
+	if *notTags != "" {
+		notTagList, err := listFlag(cmdCtx, "not-tags", *notTags)
+		if err != nil {
+			return err
+		}
+		manager = manager.WithNotTags(notTagList)
+	}
+
+	if *match == MatchAll {
+		files, err := manager.FindFilesWithAllTags(ctx, tagList, *root)
+		if err != nil {
+			return failedOperation(err)
+		}
+		files = page(pages, files)
+		if len(files) > *maxResults {
+			files = files[:*maxResults]
+		}
+
+		if written, err := output.write(cmdCtx.stdout, files, func() table { return fileListTable(files) }); written || err != nil {
+			if err != nil {
+				return err
+			}
+			return checkEmpty(cmdCtx, len(files))
+		}
+		if *print0 {
+			printNulSeparated(cmdCtx.stdout, files)
+			return checkEmpty(cmdCtx, len(files))
+		}
+
+		names := make([]string, len(tagList))
+		for i, tag := range tagList {
+			names[i] = cmdCtx.color.tag("#" + strings.TrimPrefix(tag, "#"))
+		}
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %s files with all of %s:\n",
+			cmdCtx.color.count(strconv.Itoa(len(files))), strings.Join(names, ", "))
+		for _, file := range files {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file)
+		}
+		return checkEmpty(cmdCtx, len(files))
 	}
 
-	results, err := cmdCtx.manager.FindFilesByTags(ctx, tagList, *root)
+	results, err := manager.FindFilesByTags(ctx, tagList, *root)
 	if err != nil {
-		return err
+		return failedOperation(err)
 	}
 
 	for tag, files := range results {
+		files = page(pages, files)
+		results[tag] = files
 		if len(files) > *maxResults {
 			files = files[:*maxResults]
 			results[tag] = files
 		}
 	}
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(results)
+	found := 0
+	for _, files := range results {
+		found += len(files)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, results, func() table { return tagFilesTable(results) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, found)
+	}
+
+	if *print0 {
+		// Files are listed in the order of the tags given, each only once
+		var paths []string
+		seen := make(map[string]bool)
+		for _, tag := range tagList {
+			for _, file := range results[strings.TrimPrefix(tag, "#")] {
+				if !seen[file] {
+					seen[file] = true
+					paths = append(paths, file)
+				}
+			}
+		}
+		printNulSeparated(cmdCtx.stdout, paths)
+		return checkEmpty(cmdCtx, found)
 	}
 
 	for tag, files := range results {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n#%s (%d files):\n", tag, len(files))
+		_, _ = fmt.Fprintf(cmdCtx.info, "\n%s (%s files):\n", cmdCtx.color.tag("#"+tag), cmdCtx.color.count(strconv.Itoa(len(files))))
 		for _, file := range files {
 			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file)
 		}
 	}
 
-	return nil
+	return checkEmpty(cmdCtx, found)
 }
 
 func getTagInfoCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
 	fs := flag.NewFlagSet("info", flag.ContinueOnError)
 
-	cwd, err := os.Getwd()
+	tags := fs.String("tags", "", "Comma-separated list of tags, or - to read one per line from stdin")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	changedSince := fs.String("changed-since", "", "Only scan files changed since this git ref")
+	extensions := fs.String("extensions", "", "Comma-separated extensions of the files to scan, such as md,mdx,txt, instead of the config's")
+	outputFlags := addOutputFlags(fs, true)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		return err
 	}
 
-	tags := fs.String("tags", "", "Comma-separated list of tags")
-	root := fs.String("root", cwd, "Root directory to search")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
-
-	if err := fs.Parse(args); err != nil {
+	manager, err := scopedManager(ctx, cmdCtx, *root, *changedSince, *extensions)
+	if err != nil {
 		return err
 	}
 
@@ -243,23 +632,55 @@ func getTagInfoCommand(ctx context.Context, cmdCtx *commandContext, args []strin
 		return fmt.Errorf("--tags is required")
 	}
 
-	tagList := strings.Split(*tags, ",")
-	for i := range tagList {
-		tagList[i] = strings.TrimSpace(tagList[i])
+	tagList, err := listFlag(cmdCtx, "tags", *tags)
+	if err != nil {
+		return err
 	}
 
-	infos, err := cmdCtx.manager.GetTagsInfo(ctx, tagList, *root)
+	infos, err := manager.ProfileTags(ctx, tagList, *root)
 	if err != nil {
-		return err
+		return failedOperation(err)
+	}
+
+	found := 0
+	for _, info := range infos {
+		found += info.Count
 	}
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(infos)
+	if written, err := output.write(cmdCtx.stdout, infos, func() table { return tagProfileTable(infos) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, found)
 	}
 
 	for _, info := range infos {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n#%s:\n", info.Name)
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Count: %d\n", info.Count)
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s:\n", cmdCtx.color.tag("#"+info.Name))
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Count: %s\n", cmdCtx.color.count(strconv.Itoa(info.Count)))
+		if len(info.Metadata) > 0 {
+			keys := make([]string, 0, len(info.Metadata))
+			for key := range info.Metadata {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s\n", key, info.Metadata[key])
+			}
+		}
+		if info.Count > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Written in: frontmatter of %d files, body of %d files\n", info.FrontmatterFiles, info.BodyFiles)
+		}
+		if info.FirstModified != nil {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Modified: %s to %s\n",
+				info.FirstModified.Format(time.DateOnly), info.LastModified.Format(time.DateOnly))
+		}
+		if len(info.CoTags) > 0 {
+			coTags := make([]string, len(info.CoTags))
+			for i, coTag := range info.CoTags {
+				coTags[i] = fmt.Sprintf("%s (%d)", cmdCtx.color.tag("#"+coTag.Tag), coTag.Count)
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Often with: %s\n", strings.Join(coTags, ", "))
+		}
 		if verbose {
 			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Files:\n")
 			for _, file := range info.Files {
@@ -268,30 +689,64 @@ func getTagInfoCommand(ctx context.Context, cmdCtx *commandContext, args []strin
 		}
 	}
 
-	return nil
+	return checkEmpty(cmdCtx, found)
 }
 
 func listTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
 	fs := flag.NewFlagSet("list", flag.ContinueOnError)
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-
-	root := fs.String("root", cwd, "Root directory to search")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
 	minCount := fs.Int("min-count", 1, "Minimum usage count")
 	pattern := fs.String("pattern", "", "Optional regex pattern to filter tags")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	pinnedOnly := fs.Bool("pinned-only", false, "Only show tags pinned in the config")
+	changedSince := fs.String("changed-since", "", "Only scan files changed since this git ref")
+	extensions := fs.String("extensions", "", "Comma-separated extensions of the files to scan, such as md,mdx,txt, instead of the config's")
+	sortBy := fs.String("sort", "", "Sort tags by name, count or files instead of count, keeping pinned tags first")
+	order := fs.String("order", "", "Sort direction with --sort: asc or desc (default asc for name, desc otherwise)")
+	pages := addPageFlags(fs, "tags")
+	outputFlags := addOutputFlags(fs, true)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	if err := pages.validate(); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *order != "" && *sortBy == "" {
+		return fmt.Errorf("--order requires --sort")
+	}
+	if *sortBy != "" {
+		// Reject a bad --sort or --order before scanning the vault
+		if err := SortTags(nil, *sortBy, *order); err != nil {
+			return err
+		}
+	}
 
-	tags, err := cmdCtx.manager.ListAllTags(ctx, *root, *minCount)
+	manager, err := scopedManager(ctx, cmdCtx, *root, *changedSince, *extensions)
 	if err != nil {
 		return err
 	}
+	manager, clearProgress := meterProgress(manager, cmdCtx, output)
+
+	tags, err := manager.ListAllTags(ctx, *root, *minCount)
+	clearProgress()
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if *pinnedOnly {
+		var pinned []TagInfo
+		for _, tag := range tags {
+			if tag.Pinned {
+				pinned = append(pinned, tag)
+			}
+		}
+		tags = pinned
+	}
 
 	if *pattern != "" {
 		// Filter tags by pattern
@@ -304,288 +759,2544 @@ func listTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string,
 		tags = filtered
 	}
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(tags)
+	if *sortBy != "" {
+		if err := SortTags(tags, *sortBy, *order); err != nil {
+			return err
+		}
+	}
+	total := len(tags)
+	tags = page(pages, tags)
+
+	if written, err := output.write(cmdCtx.stdout, tags, func() table { return tagsTable(tags) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(tags))
 	}
 
-	_, _ = fmt.Fprintf(cmdCtx.stdout, "\nFound %d tags:\n", len(tags))
+	if pages.paged() {
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d tags, showing %d from offset %d:\n", total, len(tags), pages.offset)
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d tags:\n", len(tags))
+	}
 	for _, tag := range tags {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "  #%-30s %d files\n", tag.Name, tag.Count)
+		name := cmdCtx.color.tag(fmt.Sprintf("#%-30s", tag.Name))
+		count := cmdCtx.color.count(strconv.Itoa(tag.Count))
+		var suffix string
+		if tag.Pinned {
+			suffix = " (pinned)"
+		}
+		if len(tag.Metadata) > 0 {
+			suffix += " " + strings.Join(metadataPairs(tag.Metadata), ", ")
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s %s files%s\n", name, count, suffix)
 	}
 
-	return nil
+	return checkEmpty(cmdCtx, len(tags))
 }
 
-func replaceTagCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
-	fs := flag.NewFlagSet("replace", flag.ContinueOnError)
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
+func treeCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("tree", flag.ContinueOnError)
 
-	replacements := fs.String("replacements", "", "Comma-separated replacements (old1:new1,old2:new2)")
-	old := fs.String("old", "", "Old tag to replace")
-	new := fs.String("new", "", "New tag name")
-	root := fs.String("root", cwd, "Root directory to search")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
-	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	depth := fs.Int("depth", 0, "Only show this many levels of the tree, 0 for all; counts still include deeper tags")
+	outputFlags := addOutputFlags(fs, true)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-
-	var replaceList []TagReplacement
-
-	if *replacements != "" {
-		pairs := strings.Split(*replacements, ",")
-		for _, pair := range pairs {
-			parts := strings.Split(pair, ":")
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid replacement format: %s", pair)
-			}
-			replaceList = append(replaceList, TagReplacement{
-				OldTag: strings.TrimSpace(parts[0]),
-				NewTag: strings.TrimSpace(parts[1]),
-			})
-		}
-	} else if *old != "" && *new != "" {
-		replaceList = append(replaceList, TagReplacement{
-			OldTag: *old,
-			NewTag: *new,
-		})
-	} else {
-		return fmt.Errorf("either --replacements or both --old and --new are required")
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *depth < 0 {
+		return fmt.Errorf("invalid depth %d: must be 0 or more", *depth)
 	}
 
-	dryRun := globalDryRun || *localDryRun
-	if dryRun {
-		_, _ = fmt.Fprintln(cmdCtx.stdout, "DRY RUN MODE - No files will be modified")
+	if verbose {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Building the tag tree of %s\n", *root)
 	}
 
-	result, err := cmdCtx.manager.ReplaceTagsBatch(ctx, replaceList, *root, dryRun)
+	nodes, err := cmdCtx.manager.TagTree(ctx, *root)
 	if err != nil {
-		return err
+		return failedOperation(fmt.Errorf("failed to build tag tree: %w", err))
 	}
+	nodes = pruneTagTree(nodes, *depth)
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(result)
+	if written, err := output.write(cmdCtx.stdout, nodes, func() table { return tagTreeTable(nodes) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(nodes))
 	}
 
-	_, _ = fmt.Fprintf(cmdCtx.stdout, "\nModified files: %d\n", len(result.ModifiedFiles))
-	if verbose {
-		for _, file := range result.ModifiedFiles {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file)
-		}
+	_, _ = fmt.Fprintf(cmdCtx.info, "Found %d top-level tags:\n", len(nodes))
+	for _, node := range nodes {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "%s %s\n", cmdCtx.color.tag("#"+node.Name), tagNodeCounts(cmdCtx, node))
+		printTagTree(cmdCtx, node.Children, "")
 	}
+	return checkEmpty(cmdCtx, len(nodes))
+}
 
-	if len(result.FailedFiles) > 0 {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "\nFailed files: %d\n", len(result.FailedFiles))
-		for i, file := range result.FailedFiles {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s\n", file, result.Errors[i])
+// pruneTagTree drops the nodes deeper than depth levels, keeping their
+// parents' totals; a depth of 0 keeps every level
+func pruneTagTree(nodes []TagNode, depth int) []TagNode {
+	if depth == 0 {
+		return nodes
+	}
+	pruned := make([]TagNode, len(nodes))
+	for i, node := range nodes {
+		pruned[i] = node
+		pruned[i].Children = nil
+		if depth > 1 {
+			pruned[i].Children = pruneTagTree(node.Children, depth-1)
 		}
 	}
-
-	return nil
+	return pruned
 }
 
-func untaggedFilesCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
-	fs := flag.NewFlagSet("untagged", flag.ContinueOnError)
+// printTagTree prints nodes beneath their parent, drawing the branches of
+// the tree after indent
+func printTagTree(cmdCtx *commandContext, nodes []TagNode, indent string) {
+	for i, node := range nodes {
+		branch, nested := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, nested = "└── ", "    "
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "%s%s%s %s\n", indent, branch, cmdCtx.color.tag(node.Name), tagNodeCounts(cmdCtx, node))
+		printTagTree(cmdCtx, node.Children, indent+nested)
+	}
+}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+// tagNodeCounts describes the files of node, with those of its nested tags
+// when they add any
+func tagNodeCounts(cmdCtx *commandContext, node TagNode) string {
+	counts := cmdCtx.color.count(fmt.Sprintf("%d files", node.Count))
+	if node.Total != node.Count {
+		counts += fmt.Sprintf(", %d with nested tags", node.Total)
 	}
+	return counts
+}
+
+func graphCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
 
-	root := fs.String("root", cwd, "Root directory to search")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	kind := fs.String("kind", GraphCooccurrence, "Relationships to draw: cooccurrence or hierarchy")
+	diagram := fs.String("diagram", DiagramDOT, "Diagram syntax of the text output: dot or mermaid")
+	top := fs.Int("top", 0, "Only draw this many of the most used tags, 0 for all")
+	minWeight := fs.Int("min-weight", 1, "Only draw links shared by at least this many files")
+	outputFlags := addOutputFlags(fs, true)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-
-	files, err := cmdCtx.manager.GetUntaggedFiles(ctx, *root)
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
 	if err != nil {
 		return err
 	}
-
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(files)
-	}
-
-	_, _ = fmt.Fprintf(cmdCtx.stdout, "\nFound %d untagged files:\n", len(files))
-	for _, file := range files {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file.Path)
+	if err := validateGraphKind(*kind); err != nil {
+		return err
 	}
-
-	return nil
-}
-
-func validateTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
-	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
-	tags := fs.String("tags", "", "Comma-separated list of tags to validate")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
-
-	if err := fs.Parse(args); err != nil {
+	if err := validateDiagram(*diagram); err != nil {
 		return err
 	}
-
-	if *tags == "" {
-		return fmt.Errorf("--tags is required")
+	if *top < 0 {
+		return fmt.Errorf("invalid top %d: must be 0 or more", *top)
 	}
 
-	tagList := strings.Split(*tags, ",")
-	for i := range tagList {
-		tagList[i] = strings.TrimSpace(tagList[i])
+	if verbose {
+		_, _ = fmt.Fprintf(cmdCtx.stderr, "Building the %s graph of %s\n", *kind, *root)
 	}
 
-	results := cmdCtx.manager.ValidateTags(ctx, tagList)
-
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(results)
+	graph, err := cmdCtx.manager.TagGraph(ctx, *kind, *root)
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to build tag graph: %w", err))
 	}
+	graph.prune(*top, *minWeight)
 
-	for tag, result := range results {
-		if result.IsValid {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n✓ %s: VALID\n", tag)
-		} else {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n✗ %s: INVALID\n", tag)
-			for _, issue := range result.Issues {
-				_, _ = fmt.Fprintf(cmdCtx.stdout, "  Issue: %s\n", issue)
-			}
-			for _, suggestion := range result.Suggestions {
-				_, _ = fmt.Fprintf(cmdCtx.stdout, "  → %s\n", suggestion)
-			}
+	if written, err := output.write(cmdCtx.stdout, graph, func() table { return graphEdgesTable(graph) }); written || err != nil {
+		if err != nil {
+			return err
 		}
+		return checkEmpty(cmdCtx, len(graph.Nodes))
 	}
 
-	return nil
+	if *diagram == DiagramMermaid {
+		err = writeMermaid(cmdCtx.stdout, graph)
+	} else {
+		err = writeDOT(cmdCtx.stdout, graph)
+	}
+	if err != nil {
+		return err
+	}
+	return checkEmpty(cmdCtx, len(graph.Nodes))
 }
 
-func getFileTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
-	fs := flag.NewFlagSet("file-tags", flag.ContinueOnError)
-	files := fs.String("files", "", "Comma-separated list of file paths")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
+func cloudCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("cloud", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	minCount := fs.Int("min-count", 1, "Minimum usage count")
+	top := fs.Int("top", 0, "Only include this many of the most used tags, 0 for all")
+	scale := fs.String("scale", CloudScaleLog, "How counts become weights: log or linear")
+	svg := fs.String("svg", "", "Also draw the cloud as an SVG image to this file")
+	outputFlags := addOutputFlags(fs, true)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-
-	if *files == "" {
-		return fmt.Errorf("--files is required")
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *top < 0 {
+		return fmt.Errorf("invalid top %d: must be 0 or more", *top)
+	}
+	// Reject a bad --scale before scanning the vault
+	if _, err := TagCloud(nil, *scale, 0); err != nil {
+		return err
 	}
 
-	fileList := strings.Split(*files, ",")
-	for i := range fileList {
-		fileList[i] = strings.TrimSpace(fileList[i])
+	if verbose {
+		_, _ = fmt.Fprintf(cmdCtx.stderr, "Weighing the tags of %s\n", *root)
 	}
 
-	fileTags, err := cmdCtx.manager.GetFilesTags(ctx, fileList)
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	tags, err := manager.ListAllTags(ctx, *root, *minCount)
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to list tags: %w", err))
+	}
+	words, err := TagCloud(tags, *scale, *top)
 	if err != nil {
 		return err
 	}
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(fileTags)
+	if *svg != "" {
+		if err := newFilePerm(cmdCtx.config).writeFile(*svg, []byte(cloudSVG(words))); err != nil {
+			return fmt.Errorf("failed to write SVG: %w", err)
+		}
+	}
+
+	if written, err := output.write(cmdCtx.stdout, words, func() table { return cloudTable(words) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(words))
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Found %d tags:\n", len(words))
+	for _, word := range words {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s %s files, weight %d\n", cmdCtx.color.tag(fmt.Sprintf("#%-30s", word.Tag)),
+			cmdCtx.color.count(strconv.Itoa(word.Count)), word.Weight)
+	}
+	if *svg != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Cloud drawn to %s\n", *svg)
+	}
+	return checkEmpty(cmdCtx, len(words))
+}
+
+func exportCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, true)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		_, _ = fmt.Fprintf(cmdCtx.stderr, "Exporting the tags of %s\n", *root)
+	}
+
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	export, err := manager.ExportTags(ctx, *root)
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to export tags: %w", err))
+	}
+
+	if written, err := output.write(cmdCtx.stdout, export, func() table { return exportTable(export) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(export.Entries))
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Exported %d tags in %d of %d files:\n", len(export.Tags), countPaths(export.Entries), export.Files)
+	for _, entry := range export.Entries {
+		lines := make([]string, len(entry.Lines))
+		for i, line := range entry.Lines {
+			lines[i] = strconv.Itoa(line)
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s (%s", entry.Path, cmdCtx.color.tag("#"+entry.Tag), entry.Source)
+		switch len(lines) {
+		case 0:
+		case 1:
+			_, _ = fmt.Fprintf(cmdCtx.stdout, ", line %s", lines[0])
+		default:
+			_, _ = fmt.Fprintf(cmdCtx.stdout, ", lines %s", strings.Join(lines, ", "))
+		}
+		_, _ = fmt.Fprintln(cmdCtx.stdout, ")")
+	}
+	return checkEmpty(cmdCtx, len(export.Entries))
+}
+
+func reportCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	top := fs.Int("top", 25, "Number of most used tags to list, 0 for all")
+	html := fs.String("html", "", "Write the HTML report to this file rather than stdout")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *top < 0 {
+		return fmt.Errorf("invalid top %d: must be 0 or more", *top)
+	}
+
+	if verbose {
+		_, _ = fmt.Fprintf(cmdCtx.stderr, "Reporting on the tags of %s\n", *root)
+	}
+
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	report, err := manager.Report(ctx, *root, *top)
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to build report: %w", err))
+	}
+
+	if *html != "" {
+		var page strings.Builder
+		if err := writeReportHTML(&page, report); err != nil {
+			return err
+		}
+		if err := newFilePerm(cmdCtx.config).writeFile(*html, []byte(page.String())); err != nil {
+			return err
+		}
+	}
+
+	if written, err := output.write(cmdCtx.stdout, report, nil); written || err != nil {
+		return err
+	}
+
+	if *html == "" {
+		return writeReportHTML(cmdCtx.stdout, report)
+	}
+	_, _ = fmt.Fprintf(cmdCtx.info, "Report written to %s: %d files, %d tags, %d untagged files, %d invalid tags, %d duplicate candidates\n",
+		*html, report.Files, report.Tags, len(report.Untagged), len(report.Invalid), len(report.Duplicates))
+	return nil
+}
+
+// countPaths returns how many files entries are in
+func countPaths(entries []ExportEntry) int {
+	paths := 0
+	for i, entry := range entries {
+		if i == 0 || entry.Path != entries[i-1].Path {
+			paths++
+		}
+	}
+	return paths
+}
+
+func replaceTagCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("replace", flag.ContinueOnError)
+
+	replacements := fs.String("replacements", "", "Comma-separated replacements (old1:new1,old2:new2)")
+	old := fs.String("old", "", "Old tag to replace")
+	new := fs.String("new", "", "New tag name")
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	files := fs.String("files", "", "Comma-separated files, directories or globs relative to --root to limit the replace to, or - to read one per line from stdin")
+	pathPrefix := fs.String("path-prefix", "", "Only replace in the files in this folder, relative to the root, such as Projects/alpha")
+	outputFlags := addOutputFlags(fs, false)
+	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+	transactional := fs.Bool("transactional", false, "Modify every file or none")
+	stream := fs.Bool("stream", false, "Print each file's result as soon as it is done (JSON lines with --json, YAML documents with --format=yaml)")
+	outputPatch := fs.String("output-patch", "", "Write the changes to this file as a unified diff instead of modifying the vault")
+	preflight := fs.String("preflight", "", "Check every target file can be modified before modifying any: abort or skip")
+	timeout := fs.Duration("timeout", 0, "Stop between files after this long, such as 10m, reporting the files left")
+	resume := fs.Bool("resume", false, "Continue an interrupted run of the same command, skipping the files it finished")
+	maxModified := fs.Int("max-modified", 0, "Abort before modifying anything if more than this many files would be modified; overrides max_modified, -1 for no limit")
+	interactive := fs.Bool("interactive", false, "Show each occurrence of the old tags in context and ask whether to rename it")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *interactive {
+		switch {
+		case !output.text():
+			return fmt.Errorf("--interactive cannot be combined with --format=%s", output.format)
+		case *transactional:
+			return fmt.Errorf("--interactive cannot be combined with --transactional")
+		case *stream:
+			return fmt.Errorf("--interactive cannot be combined with --stream")
+		}
+	}
+
+	var replaceList []TagReplacement
+
+	if *replacements != "" {
+		pairs := strings.Split(*replacements, ",")
+		for _, pair := range pairs {
+			parts := strings.Split(pair, ":")
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid replacement format: %s", pair)
+			}
+			replaceList = append(replaceList, TagReplacement{
+				OldTag: strings.TrimSpace(parts[0]),
+				NewTag: strings.TrimSpace(parts[1]),
+			})
+		}
+	} else if *old != "" && *new != "" {
+		replaceList = append(replaceList, TagReplacement{
+			OldTag: *old,
+			NewTag: *new,
+		})
+	} else {
+		return fmt.Errorf("either --replacements or both --old and --new are required")
+	}
+
+	if err := checkVault(*root); err != nil {
+		return err
+	}
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun || *outputPatch != "", *apply)
+
+	manager := cmdCtx.manager
+	if *files != "" {
+		fileList, err := fileListFlag(cmdCtx, "files", *files)
+		if err != nil {
+			return err
+		}
+		if fileList, err = manager.ExpandFilePaths(ctx, *root, fileList); err != nil {
+			return err
+		}
+		if len(fileList) == 0 {
+			return fmt.Errorf("no files match --files %q", *files)
+		}
+		manager = manager.WithFilter(fileListFilter(*root, fileList))
+	}
+	if *pathPrefix != "" {
+		filter, err := PathPrefixFilter(*pathPrefix)
+		if err != nil {
+			return err
+		}
+		manager = manager.WithFilter(filter)
+	}
+	if *transactional {
+		manager = manager.WithTransactional(true)
+	}
+	if manager, err = withPreflight(manager, *preflight); err != nil {
+		return err
+	}
+	if *maxModified != 0 {
+		manager = manager.WithMaxModified(*maxModified)
+	}
+	if *resume {
+		manager = manager.WithResume(true)
+	}
+	clearProgress := func() {}
+	if *interactive {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "For each occurrence:\n%s\n", occurrenceHelp)
+		stdin, err := cmdCtx.stdinFor("interactive")
+		if err != nil {
+			return err
+		}
+		prompt := &occurrencePrompt{cmdCtx: cmdCtx, reader: bufio.NewReader(stdin), root: *root}
+		manager = manager.WithOccurrences(prompt.decide)
+	} else if *stream {
+		manager = streamProgress(manager, cmdCtx.stdout, output, cmdCtx.color)
+	} else {
+		manager, clearProgress = meterProgress(manager, cmdCtx, output)
+	}
+
+	ctx, cancel := withTimeout(ctx, *timeout)
+	defer cancel()
+
+	result, err := manager.ReplaceTagsBatch(ctx, replaceList, *root, dryRun)
+	clearProgress()
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if *outputPatch != "" {
+		if err := writePatch(cmdCtx.config, *root, *outputPatch, result.Diffs); err != nil {
+			return err
+		}
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	if *outputPatch != "" {
+		printPatchWritten(cmdCtx.info, *outputPatch, result.Diffs)
+	} else if dryRun {
+		printDiffs(cmdCtx.stdout, cmdCtx.color, result.Diffs)
+	}
+	printPreflight(cmdCtx.stdout, result.Preflight)
+	if result.Resumed > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Resumed, skipping %d files the interrupted run finished\n", result.Resumed)
+	}
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nModified files: %d\n", len(result.ModifiedFiles))
+	if verbose {
+		for _, file := range result.ModifiedFiles {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s\n", file)
+		}
+	}
+	if result.Backup != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Originals backed up to %s\n", result.Backup)
+	}
+	if result.Operation != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Journaled as operation %s (tag-manager undo --op-id=%s)\n", result.Operation, result.Operation)
+	}
+
+	if len(result.FailedFiles) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s\n", cmdCtx.color.fail(fmt.Sprintf("Failed files: %d", len(result.FailedFiles))))
+		for i, file := range result.FailedFiles {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s\n", file, cmdCtx.color.fail(result.Errors[i]))
+		}
+	}
+
+	if err := printStopped(cmdCtx.stdout, result.Stopped, result.Remaining, !dryRun); err != nil {
+		return err
+	}
+	return failedFiles(len(result.FailedFiles))
+}
+
+func untaggedFilesCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("untagged", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	changedSince := fs.String("changed-since", "", "Only scan files changed since this git ref")
+	extensions := fs.String("extensions", "", "Comma-separated extensions of the files to scan, such as md,mdx,txt, instead of the config's")
+	order := fs.String("order", "", "Order files by path, mtime or size, optionally suffixed :asc or :desc")
+	print0 := fs.Bool("print0", false, "Print each file followed by a NUL, for xargs -0")
+	pathPrefix := fs.String("path-prefix", "", "Only report files in this folder, relative to the root, such as Inbox")
+	sortBy := fs.String("sort", "", "Sort files by path, mtime (newest first) or size (largest first); --order sets the direction too")
+	var taggedWith string
+	usage := "Comma-separated list of tags; report the files carrying none of them instead of the files without tags"
+	fs.StringVar(&taggedWith, "tagged-with", "", usage)
+	fs.StringVar(&taggedWith, "missing", "", usage)
+	pages := addPageFlags(fs, "files")
+	outputFlags := addOutputFlags(fs, true)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := pages.validate(); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *print0 && !output.text() {
+		return fmt.Errorf("--print0 cannot be combined with --format=%s", output.format)
+	}
+
+	scanOrder := *order
+	if *sortBy != "" {
+		if *order != "" {
+			return fmt.Errorf("--sort cannot be combined with --order")
+		}
+		// Sorting puts the newest and largest files first, which triage wants
+		switch *sortBy {
+		case OrderByPath:
+			scanOrder = OrderByPath
+		case OrderByModTime, OrderBySize:
+			scanOrder = *sortBy + ":desc"
+		default:
+			return fmt.Errorf("invalid sort %q: must be %s, %s or %s", *sortBy, OrderByPath, OrderByModTime, OrderBySize)
+		}
+	}
+
+	manager, err := scopedManager(ctx, cmdCtx, *root, *changedSince, *extensions)
+	if err != nil {
+		return err
+	}
+	if *pathPrefix != "" {
+		filter, err := PathPrefixFilter(*pathPrefix)
+		if err != nil {
+			return err
+		}
+		manager = manager.WithFilter(filter)
+	}
+	if manager, err = withOrder(manager, scanOrder); err != nil {
+		return err
+	}
+
+	var files []FileTagInfo
+	noun := "untagged files"
+	if taggedWith != "" {
+		tagList, err := listFlag(cmdCtx, "tagged-with", taggedWith)
+		if err != nil {
+			return err
+		}
+		if files, err = manager.GetFilesNotTaggedWith(ctx, tagList, *root); err != nil {
+			return failedOperation(err)
+		}
+		noun = "files without " + strings.Join(tagList, ", ")
+	} else if files, err = manager.GetUntaggedFiles(ctx, *root); err != nil {
+		return failedOperation(err)
+	}
+	total := len(files)
+	files = page(pages, files)
+
+	if written, err := output.write(cmdCtx.stdout, files, func() table { return pathsTable(files) }); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(files))
+	}
+
+	if *print0 {
+		paths := make([]string, len(files))
+		for i, file := range files {
+			paths[i] = file.Path
+		}
+		printNulSeparated(cmdCtx.stdout, paths)
+		return checkEmpty(cmdCtx, len(files))
+	}
+
+	if pages.paged() {
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d %s, showing %d from offset %d:\n", total, noun, len(files), pages.offset)
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d %s:\n", len(files), noun)
+	}
+	for _, file := range files {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file.Path)
+	}
+
+	return checkEmpty(cmdCtx, len(files))
+}
+
+func validateTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+
+	tags := fs.String("tags", "", "Comma-separated list of tags to validate, or - to read one per line from stdin")
+	root := fs.String("root", cmdCtx.root, "Root directory whose tags are all validated when --tags isn't given")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *tags == "" {
+		return validateVaultCommand(ctx, cmdCtx, *root, output)
+	}
+
+	tagList, err := listFlag(cmdCtx, "tags", *tags)
+	if err != nil {
+		return err
+	}
+
+	results := cmdCtx.manager.ValidateTags(ctx, tagList)
+
+	if written, err := output.write(cmdCtx.stdout, results, nil); written || err != nil {
+		return err
+	}
+
+	for tag, result := range results {
+		if result.IsValid {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s %s: %s\n", cmdCtx.color.ok("✓"), cmdCtx.color.tag(tag), cmdCtx.color.ok("VALID"))
+		} else {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s %s: %s\n", cmdCtx.color.fail("✗"), cmdCtx.color.tag(tag), cmdCtx.color.fail("INVALID"))
+			for _, issue := range result.Issues {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  Issue: %s\n", issue)
+			}
+			for _, suggestion := range result.Suggestions {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  → %s\n", suggestion)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateVaultCommand validates every tag in the vault at root, failing
+// when any are invalid
+func validateVaultCommand(ctx context.Context, cmdCtx *commandContext, root string, output *output) error {
+	if err := checkVault(root); err != nil {
+		return err
+	}
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	result, err := manager.ValidateVault(ctx, root)
+	clearProgress()
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	written, err := output.write(cmdCtx.stdout, result, nil)
+	if err != nil {
+		return err
+	}
+	if !written {
+		for _, invalid := range result.Invalid {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s %s: %s (%s files)\n", cmdCtx.color.fail("✗"), cmdCtx.color.tag(invalid.Tag),
+				cmdCtx.color.fail("INVALID"), cmdCtx.color.count(strconv.Itoa(len(invalid.Files))))
+			for _, issue := range invalid.Issues {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  Issue: %s\n", issue)
+			}
+			for _, suggestion := range invalid.Suggestions {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  → %s\n", suggestion)
+			}
+			for _, file := range invalid.Files {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "    %s\n", file)
+			}
+		}
+		_, _ = fmt.Fprintf(cmdCtx.info, "\nChecked %d tags in %d files: %d invalid\n", result.Tags, result.Files, len(result.Invalid))
+	}
+
+	if len(result.Invalid) > 0 {
+		return withExitCode(ExitErrors, fmt.Errorf("found %d invalid tags", len(result.Invalid)))
+	}
+	return nil
+}
+
+func getFileTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("file-tags", flag.ContinueOnError)
+	files := fs.String("files", "", "Comma-separated list of file paths, or - to read one per line from stdin")
+	root := fs.String("root", cmdCtx.root, "Root directory the paths are relative to, for directories and globs such as Daily/2025-03-*.md")
+	outputFlags := addOutputFlags(fs, true)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *files == "" {
+		return fmt.Errorf("--files is required")
+	}
+
+	fileList, err := fileListFlag(cmdCtx, "files", *files)
+	if err != nil {
+		return err
+	}
+
+	if fileList, err = rootedFilePaths(ctx, cmdCtx.manager, *root, fileList); err != nil {
+		return err
+	}
+	if len(fileList) == 0 {
+		return fmt.Errorf("no files match --files %q", *files)
+	}
+
+	fileTags, err := cmdCtx.manager.GetFilesTags(ctx, fileList)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, fileTags, func() table { return fileTagsTable(fileTags) }); written || err != nil {
+		return err
+	}
+
+	for _, file := range fileTags {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s:\n", file.Path)
+		if len(file.Tags) == 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  (no tags)\n")
+		} else {
+			for _, tag := range file.Tags {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  #%s\n", tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+func updateCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+
+	addTags := fs.String("add", "", "Comma-separated tags to add")
+	removeTags := fs.String("remove", "", "Comma-separated tags to remove")
+	files := fs.String("files", "", "Comma-separated file paths, directories or globs relative to root, or - to read one per line from stdin")
+	root := fs.String("root", cmdCtx.root, "Root directory for file paths")
+	outputFlags := addOutputFlags(fs, false)
+	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+	stream := fs.Bool("stream", false, "Print each file's result as soon as it is done (JSON lines with --json, YAML documents with --format=yaml)")
+	outputPatch := fs.String("output-patch", "", "Write the changes to this file as a unified diff instead of modifying the vault")
+	preflight := fs.String("preflight", "", "Check every target file can be modified before modifying any: abort or skip")
+	migrate := fs.String("migrate", "", "Comma-separated patterns of the top-of-file hashtags to migrate, such as project/*; the rest stay inline")
+	timeout := fs.Duration("timeout", 0, "Stop between files after this long, such as 10m, reporting the files left")
+	resume := fs.Bool("resume", false, "Continue an interrupted run of the same command, skipping the files it finished")
+	maxModified := fs.Int("max-modified", 0, "Abort before modifying anything if more than this many files would be modified; overrides max_modified, -1 for no limit")
+	opsFile := fs.String("ops", "", "JSON file of per-file operations, [{\"path\", \"add\", \"remove\"}], or - for stdin; replaces --add, --remove and --files")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	var ops []FileTagOp
+	var filePaths []string
+	if *opsFile != "" {
+		if *addTags != "" || *removeTags != "" || *files != "" {
+			return fmt.Errorf("--ops cannot be combined with --add, --remove or --files")
+		}
+		if ops, err = readFileTagOps(*opsFile, cmdCtx.stdin); err != nil {
+			return err
+		}
+	} else {
+		if err := ValidateUpdateParameters(*addTags, *removeTags, *files); err != nil {
+			return err
+		}
+		if *files == "-" {
+			var stdin io.Reader
+			if stdin, err = cmdCtx.stdinFor("files"); err == nil {
+				filePaths, err = readFilePaths(stdin, *root)
+			}
+		} else {
+			filePaths, err = ParseFilePaths(*files, *root)
+		}
+		if err != nil {
+			return err
+		}
+		if filePaths, err = cmdCtx.manager.ExpandFilePaths(ctx, *root, filePaths); err != nil {
+			return err
+		}
+		if len(filePaths) == 0 {
+			return fmt.Errorf("no files match --files %q", *files)
+		}
+	}
+
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun || *outputPatch != "", *apply)
+
+	manager := cmdCtx.manager
+	if manager, err = withPreflight(manager, *preflight); err != nil {
+		return err
+	}
+	if *maxModified != 0 {
+		manager = manager.WithMaxModified(*maxModified)
+	}
+	if *resume {
+		manager = manager.WithResume(true)
+	}
+	if manager, err = withMigrate(manager, parseTagList(*migrate)); err != nil {
+		return err
+	}
+	clearProgress := func() {}
+	if *stream {
+		manager = streamProgress(manager, cmdCtx.stdout, output, cmdCtx.color)
+	} else {
+		manager, clearProgress = meterProgress(manager, cmdCtx, output)
+	}
+
+	ctx, cancel := withTimeout(ctx, *timeout)
+	defer cancel()
+
+	var result *TagUpdateResult
+	if ops != nil {
+		result, err = manager.UpdateTagsPerFile(ctx, *root, ops, dryRun)
+	} else {
+		result, err = manager.UpdateTags(ctx, parseTagList(*addTags), parseTagList(*removeTags), *root, filePaths, dryRun)
+	}
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to update tags: %w", err))
+	}
+
+	if *outputPatch != "" {
+		if err := writePatch(cmdCtx.config, *root, *outputPatch, result.Diffs); err != nil {
+			return err
+		}
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	if *outputPatch != "" {
+		printPatchWritten(cmdCtx.info, *outputPatch, result.Diffs)
+	} else if dryRun {
+		printDiffs(cmdCtx.stdout, cmdCtx.color, result.Diffs)
+	}
+
+	return printUpdateResult(cmdCtx, result, dryRun)
+}
+
+// printUpdateResult prints the outcome of an update after its diffs, failing
+// when files couldn't be updated
+func printUpdateResult(cmdCtx *commandContext, result *TagUpdateResult, dryRun bool) error {
+	printPreflight(cmdCtx.stdout, result.Preflight)
+	if result.Resumed > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Resumed, skipping %d files the interrupted run finished\n", result.Resumed)
+	}
+
+	if len(result.FilesMigrated) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Files with migrated hashtags: %d\n", len(result.FilesMigrated))
+		for _, file := range result.FilesMigrated {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s\n", file)
+		}
+	}
+
+	if len(result.ModifiedFiles) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Modified files: %d\n", len(result.ModifiedFiles))
+		for _, file := range result.ModifiedFiles {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s\n", file)
+		}
+	}
+
+	if len(result.TagsAdded) > 0 {
+		_, _ = fmt.Fprintln(cmdCtx.info, "Tags added:")
+		for tag, count := range result.TagsAdded {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s: %d files\n", tag, count)
+		}
+	}
+
+	if len(result.TagsRemoved) > 0 {
+		_, _ = fmt.Fprintln(cmdCtx.info, "Tags removed:")
+		for tag, count := range result.TagsRemoved {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s: %d files\n", tag, count)
+		}
+	}
+
+	if result.Backup != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Originals backed up to %s\n", result.Backup)
+	}
+	if result.Operation != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Journaled as operation %s (tag-manager undo --op-id=%s)\n", result.Operation, result.Operation)
+	}
+
+	if len(result.Errors) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "Errors: %d\n", len(result.Errors))
+		for _, errMsg := range result.Errors {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", errMsg)
+		}
+		if result.Stopped == "" {
+			return failedFiles(len(result.Errors))
+		}
+	}
+
+	return printStopped(cmdCtx.stdout, result.Stopped, result.Remaining, !dryRun)
+}
+
+func backupCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return fmt.Errorf("backup requires a subcommand: prune")
+	}
+
+	fs := flag.NewFlagSet("backup prune", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	keep := fs.Int("keep", 5, "Number of most recent backup trees to keep")
+	olderThan := fs.Duration("older-than", 0, "Only remove backups older than this, including sibling .bak files (e.g. 720h)")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *keep < 0 {
+		return fmt.Errorf("--keep cannot be negative")
+	}
+
+	removed, err := cmdCtx.manager.PruneBackups(ctx, *root, *keep, *olderThan)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if !output.text() {
+		if removed == nil {
+			removed = []string{}
+		}
+		_, err := output.write(cmdCtx.stdout, removed, nil)
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Removed %d backups\n", len(removed))
+	if verbose {
+		for _, path := range removed {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s\n", path)
+		}
+	}
+	return nil
+}
+
+func undoCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	last := fs.Bool("last", false, "Undo the most recent operation not yet undone (the default)")
+	opID := fs.String("op-id", "", "Id of the journaled operation to undo")
+	list := fs.Bool("list", false, "List the journaled operations instead of undoing one")
+	outputFlags := addOutputFlags(fs, false)
+	localDryRun := fs.Bool("dry-run", false, "Show what would be restored without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *last && *opID != "" {
+		return fmt.Errorf("--last and --op-id cannot be used together")
+	}
+
+	if *list {
+		ops, err := ReadJournal(*root)
+		if err != nil {
+			return err
+		}
+		if !output.text() {
+			if ops == nil {
+				ops = []JournalOperation{}
+			}
+			_, err := output.write(cmdCtx.stdout, ops, nil)
+			return err
+		}
+		for _, op := range ops {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "%s  %-7s  %d files", op.ID, op.Kind, len(op.Files))
+			if op.Undoes != "" {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  (undoes %s)", op.Undoes)
+			}
+			_, _ = fmt.Fprintln(cmdCtx.stdout)
+			if verbose {
+				for _, file := range op.Files {
+					_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file.Path)
+				}
+			}
+		}
+		return nil
+	}
+
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun, *apply)
+
+	result, err := cmdCtx.manager.Undo(ctx, *root, *opID, dryRun)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Undoing operation %s\n", result.Undone)
+	_, _ = fmt.Fprintf(cmdCtx.info, "Restored files: %d\n", len(result.RestoredFiles))
+	if verbose {
+		for _, file := range result.RestoredFiles {
+			_, _ = fmt.Fprintf(cmdCtx.info, "  %s\n", file)
+		}
+	}
+	if result.Backup != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Originals backed up to %s\n", result.Backup)
+	}
+
+	if len(result.Errors) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "Errors: %d\n", len(result.Errors))
+		for _, errMsg := range result.Errors {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", errMsg)
+		}
+		return failedFiles(len(result.Errors))
+	}
+
+	return nil
+}
+
+func selfTestCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	sample := fs.Int("sample", DefaultSelfTestSample, "Number of files to copy and test, 0 for all")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *sample < 0 {
+		return fmt.Errorf("--sample cannot be negative")
+	}
+
+	report, err := cmdCtx.manager.SelfTest(ctx, *root, *sample)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if !output.text() {
+		if _, err := output.write(cmdCtx.stdout, report, nil); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Tested a copy of %d files", report.Files)
+		if report.Tag != "" {
+			_, _ = fmt.Fprintf(cmdCtx.info, ", renaming #%s", report.Tag)
+		}
+		_, _ = fmt.Fprintln(cmdCtx.info)
+
+		if len(report.Skipped) > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "Skipped %d files which update refuses to modify:\n", len(report.Skipped))
+			for _, message := range report.Skipped {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", message)
+			}
+		}
+
+		const maxFailures = 5
+		for _, check := range report.Checks {
+			status := "PASS"
+			if !check.Passed {
+				status = "FAIL"
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  [%s] %s\n", status, check.Name)
+
+			for i, failure := range check.Failures {
+				if i == maxFailures && !verbose {
+					_, _ = fmt.Fprintf(cmdCtx.stdout, "         ... and %d more (use --verbose to list all)\n", len(check.Failures)-maxFailures)
+					break
+				}
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "         %s\n", failure)
+			}
+		}
+
+		_, _ = fmt.Fprintf(cmdCtx.info, "Confidence: %.0f%% of tested files passed every check\n", report.Confidence*100)
+	}
+
+	if report.Failed() {
+		return withExitCode(ExitErrors, fmt.Errorf("self-test found problems; review them before modifying the vault"))
+	}
+	return nil
+}
+
+// doctorCommand runs the vault health checks, listing what each found with
+// a suggestion of what to do about it
+func doctorCommand(ctx context.Context, cmdCtx *commandContext, configFile string, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	report, err := cmdCtx.manager.Doctor(ctx, *root)
+	if err != nil {
+		return failedOperation(err)
+	}
+	if configFile != "" {
+		if err := report.AddConfigFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	if !output.text() {
+		if _, err := output.write(cmdCtx.stdout, report, nil); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Checked %d files in %s\n\n", report.Files, *root)
+
+		const maxItems = 5
+		for _, check := range report.Checks {
+			if check.Count == 0 {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  [%s] %s\n", cmdCtx.color.ok(" OK "), check.Name)
+				continue
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  [%s] %s: %s\n", cmdCtx.color.fail("FAIL"), check.Name, cmdCtx.color.count(strconv.Itoa(check.Count)))
+
+			for i, item := range check.Items {
+				if i == maxItems && !verbose {
+					_, _ = fmt.Fprintf(cmdCtx.stdout, "         ... and %d more (use --verbose to list all)\n", len(check.Items)-maxItems)
+					break
+				}
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "         %s\n", item)
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "         -> %s\n", check.Suggestion)
+		}
+
+		if problems := report.Problems(); problems > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d problems\n", problems)
+		} else {
+			_, _ = fmt.Fprintf(cmdCtx.info, "\nNo problems found\n")
+		}
+	}
+
+	if problems := report.Problems(); problems > 0 {
+		return withExitCode(ExitErrors, fmt.Errorf("doctor found %d problems", problems))
+	}
+	return nil
+}
+
+// versionCommand prints the build info of the running binary
+func versionCommand(cmdCtx *commandContext, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	info := ReadBuildInfo()
+	if written, err := output.write(cmdCtx.stdout, info, nil); written || err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "tag-manager %s\n", info.Version)
+	if info.Commit != "" {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Commit:       %s\n", info.Commit)
+	}
+	if info.Date != "" {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Built:        %s\n", info.Date)
+	}
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Go:           %s\n", info.GoVersion)
+	if info.MCPSDKVersion != "" {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  MCP SDK:      %s\n", info.MCPSDKVersion)
+	}
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  MCP protocol: %s\n", info.MCPProtocolVersion)
+	return nil
+}
+
+// completionCommand prints a completion script, or with tags, the vault's tag
+// names which the scripts complete flag values with
+func completionCommand(ctx context.Context, cmdCtx *commandContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("completion requires a shell: bash, zsh, fish")
+	}
+	if args[0] != "tags" {
+		return writeCompletion(cmdCtx.stdout, args[0])
+	}
+
+	fs := flag.NewFlagSet("completion tags", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	tags, err := cmdCtx.manager.ListAllTags(ctx, *root, 1)
+	if err != nil {
+		return failedOperation(err)
+	}
+	for _, tag := range tags {
+		_, _ = fmt.Fprintln(cmdCtx.stdout, tag.Name)
+	}
+	return nil
+}
+
+func configCommand(cmdCtx *commandContext, configFile string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("config requires a subcommand: init, validate, show")
+	}
+
+	switch args[0] {
+	case "init":
+		return configInitCommand(cmdCtx, args[1:])
+	case "validate":
+		return configValidateCommand(cmdCtx, configFile, args[1:])
+	case "show":
+		return configShowCommand(cmdCtx, configFile, args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand: %s", args[0])
+	}
+}
+
+// configInitCommand writes the effective configuration, with every option
+// commented, so the options can be discovered and tuned
+func configInitCommand(cmdCtx *commandContext, args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	path := fs.String("path", VaultConfigFile, "File to write the configuration to, or - for stdout")
+	force := fs.Bool("force", false, "Overwrite an existing file, keeping the options it sets")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config := cmdCtx.config
+	if *path != "-" {
+		if _, err := os.Stat(*path); err == nil && !*force {
+			return fmt.Errorf("%s already exists (use --force to overwrite it, keeping the options it sets)", *path)
+		}
+		merged, err := mergeConfigFile(config, *path)
+		if err != nil {
+			return err
+		}
+		config = merged
+	}
+
+	content, err := renderConfig(config)
+	if err != nil {
+		return err
+	}
+	if *path == "-" {
+		_, _ = fmt.Fprint(cmdCtx.stdout, content)
+		return nil
+	}
+	if err := newFilePerm(cmdCtx.config).writeFile(*path, []byte(content)); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmdCtx.info, "Wrote configuration to %s\n", *path)
+	return nil
+}
+
+// configValidateCommand reports every problem of a config file, by line and
+// column, instead of only the first as loading it does
+func configValidateCommand(cmdCtx *commandContext, configFile string, args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	path := fs.String("path", configFile, "Config file to validate (default --config, or "+VaultConfigFile+")")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = VaultConfigFile
+	}
+
+	problems, err := checkConfigFile(*path)
+	if err != nil {
+		return err
+	}
+
+	if written, err := output.write(cmdCtx.stdout, problems, nil); written || err != nil {
+		if err != nil || len(problems) == 0 {
+			return err
+		}
+		return withExitCode(ExitErrors, fmt.Errorf("%s has %d problems", *path, len(problems)))
+	}
+
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "%s %s is valid\n", cmdCtx.color.ok("✓"), *path)
+		return nil
+	}
+	for _, problem := range problems {
+		location := *path
+		if problem.Line > 0 {
+			location += fmt.Sprintf(":%d", problem.Line)
+		}
+		if problem.Column > 0 {
+			location += fmt.Sprintf(":%d", problem.Column)
+		}
+		message := problem.Message
+		if problem.Key != "" && !strings.HasPrefix(message, problem.Key) {
+			message = problem.Key + ": " + message
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "%s: %s\n", location, cmdCtx.color.fail(message))
+	}
+	return withExitCode(ExitErrors, fmt.Errorf("%s has %d problems", *path, len(problems)))
+}
+
+// configShowCommand prints the effective configuration: the defaults, with
+// the config file and the flags which override it applied
+func configShowCommand(cmdCtx *commandContext, configFile string, args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if !output.text() {
+		value, err := configValue(cmdCtx.config)
+		if err != nil {
+			return err
+		}
+		_, err = output.write(cmdCtx.stdout, value, nil)
+		return err
+	}
+
+	node, err := configNode(cmdCtx.config)
+	if err != nil {
+		return err
+	}
+	content, err := encodeConfigNode(node)
+	if err != nil {
+		return err
+	}
+	if configFile == "" {
+		_, _ = fmt.Fprintln(cmdCtx.info, "# Defaults, with flags applied (no --config given)")
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "# Defaults, with %s and flags applied\n", configFile)
+	}
+	_, _ = fmt.Fprint(cmdCtx.stdout, content)
+	return nil
+}
+
+func auditCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("audit requires a subcommand: flat-tags, frontmatter")
+	}
+
+	switch args[0] {
+	case "flat-tags":
+		return auditFlatTagsCommand(ctx, cmdCtx, args[1:], verbose)
+	case "frontmatter":
+		return auditFrontmatterCommand(ctx, cmdCtx, args[1:])
+	default:
+		return fmt.Errorf("unknown audit subcommand: %s", args[0])
+	}
+}
+
+func auditFlatTagsCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("audit flat-tags", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	minCount := fs.Int("min-count", 5, "Minimum number of files using the flat tag")
+	threshold := fs.Float64("threshold", 0.9, "Minimum fraction of files sharing the namespace")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if *threshold <= 0 || *threshold > 1 {
+		return fmt.Errorf("--threshold must be greater than 0 and at most 1")
+	}
+
+	suggestions, err := cmdCtx.manager.SuggestNamespaces(ctx, *root, *minCount, *threshold)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, suggestions, nil); written || err != nil {
+		return err
+	}
+
+	if len(suggestions) == 0 {
+		_, _ = fmt.Fprintln(cmdCtx.info, "\nNo flat tags found that belong to a single namespace")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d flat tags to move into a namespace:\n", len(suggestions))
+	mapping := make([]string, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  #%-30s -> #%s (%d/%d files)\n", suggestion.Tag,
+			suggestion.SuggestedTag, suggestion.CoOccurrence, suggestion.Count)
+		mapping = append(mapping, suggestion.Tag+":"+suggestion.SuggestedTag)
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nReview, then apply with:\n  tag-manager replace --replacements=%q --root=%q --dry-run\n",
+		strings.Join(mapping, ","), *root)
+
+	return nil
+}
+
+func auditFrontmatterCommand(ctx context.Context, cmdCtx *commandContext, args []string) error {
+	fs := flag.NewFlagSet("audit frontmatter", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory to search")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	problems, err := cmdCtx.manager.AuditFrontmatter(ctx, *root)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, problems, nil); written || err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintln(cmdCtx.info, "\nNo files with malformed frontmatter")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d files with malformed frontmatter:\n", len(problems))
+	printFrontmatterProblems(cmdCtx.stdout, problems)
+	_, _ = fmt.Fprintln(cmdCtx.info, "\nupdate skips these files until their frontmatter is fixed")
+
+	return nil
+}
+
+// printNulSeparated prints each path followed by a NUL, which unlike a
+// newline can't appear in a path
+func printNulSeparated(w io.Writer, paths []string) {
+	for _, path := range paths {
+		_, _ = fmt.Fprintf(w, "%s\x00", path)
+	}
+}
+
+func printFrontmatterProblems(w io.Writer, problems []FrontmatterProblem) {
+	for _, problem := range problems {
+		location := problem.Path
+		if problem.Line > 0 {
+			location = fmt.Sprintf("%s:%d", problem.Path, problem.Line)
+		}
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", location, problem.Error)
+		if problem.Hint != "" {
+			_, _ = fmt.Fprintf(w, "      hint: %s\n", problem.Hint)
+		}
+	}
+}
+
+// withTimeout bounds ctx by timeout, when it is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// printStopped reports the files an operation stopped before, returning an
+// error when there are any. A resumable operation left a checkpoint.
+func printStopped(w io.Writer, stopped string, remaining []string, resumable bool) error {
+	if stopped == "" {
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "\nStopped with %d files left: %s\n", len(remaining), stopped)
+	for _, file := range remaining {
+		_, _ = fmt.Fprintf(w, "  %s\n", file)
+	}
+	if resumable {
+		_, _ = fmt.Fprintln(w, "Run the same command with --resume to continue")
+	}
+	return withExitCode(ExitErrors, fmt.Errorf("stopped before %d files", len(remaining)))
+}
+
+// streamProgress returns manager printing each file's result to w as soon as
+// it is done: as a JSON line, a YAML document, or the template run on it in
+// those formats
+func streamProgress(manager TagManager, w io.Writer, output *output, color palette) TagManager {
+	lines := *output
+	lines.indent = false
+	return manager.WithProgress(func(result FileResult) {
+		switch {
+		case !lines.text():
+			if lines.format == FormatYAML {
+				_, _ = fmt.Fprintln(w, "---")
+			}
+			_, _ = lines.write(w, result, nil)
+		case result.Error != "":
+			_, _ = fmt.Fprintf(w, "  %s %s: %s\n", color.fail("✗"), result.Path, color.fail(result.Error))
+		case result.Modified:
+			_, _ = fmt.Fprintf(w, "  %s %s\n", color.ok("✓"), result.Path)
+		default:
+			_, _ = fmt.Fprintf(w, "  - %s (unchanged)\n", result.Path)
+		}
+	})
+}
+
+// meterProgress returns manager drawing a progress bar on stderr, and a func
+// clearing it once the command is done. The bar is only drawn for text
+// output on a terminal, so it never mixes with output meant for programs.
+func meterProgress(manager TagManager, cmdCtx *commandContext, output *output) (TagManager, func()) {
+	if !output.text() || !isTerminal(cmdCtx.stderr) {
+		return manager, func() {}
+	}
+	bar := &progressBar{w: cmdCtx.stderr}
+	return manager.WithMeter(bar.update), bar.clear
+}
+
+// progressBarInterval limits how often the progress bar is redrawn
+const progressBarInterval = 100 * time.Millisecond
+
+// progressBar draws a scan's file count, or a batch's files done and the
+// time left, redrawing one line in place
+type progressBar struct {
+	w       io.Writer
+	total   int
+	started time.Time
+	drawn   time.Time
+	visible bool
+}
+
+func (b *progressBar) update(progress Progress) {
+	now := time.Now()
+	if progress.Total != b.total || progress.Done == 0 {
+		// A new scan or batch starts its own bar and estimate
+		b.total = progress.Total
+		b.started = now
+	}
+	if now.Sub(b.drawn) < progressBarInterval {
+		return
+	}
+	b.drawn = now
+	b.visible = true
+
+	if progress.Total == 0 {
+		_, _ = fmt.Fprintf(b.w, "\r\x1b[KScanning... %d files", progress.Done)
+		return
+	}
+	const width = 30
+	filled := width * progress.Done / progress.Total
+	line := fmt.Sprintf("[%s%s] %d/%d files", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), progress.Done, progress.Total)
+	if progress.Done > 0 {
+		elapsed := now.Sub(b.started)
+		left := elapsed * time.Duration(progress.Total-progress.Done) / time.Duration(progress.Done)
+		line += fmt.Sprintf(", %s left", left.Round(time.Second))
+	}
+	_, _ = fmt.Fprintf(b.w, "\r\x1b[K%s", line)
+}
+
+func (b *progressBar) clear() {
+	if b.visible {
+		_, _ = fmt.Fprint(b.w, "\r\x1b[K")
+		b.visible = false
+	}
+}
+
+// withPreflight checks the files manager's operations target as mode says,
+// leaving manager unchanged when mode is empty
+func withPreflight(manager TagManager, mode string) (TagManager, error) {
+	if mode == "" {
+		return manager, nil
+	}
+	if err := validatePreflightMode(mode); err != nil {
+		return nil, err
+	}
+	return manager.WithPreflight(mode), nil
+}
+
+func printPreflight(w io.Writer, problems []PreflightProblem) {
+	if len(problems) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "Preflight skipped %d files which can't be modified:\n", len(problems))
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", problem.Path, problem.Problem)
+	}
+}
+
+func printPatchWritten(w io.Writer, path string, changes []PlannedChange) {
+	_, _ = fmt.Fprintf(w, "Wrote a patch changing %d files to %s; the vault was not modified (apply it from the vault root with git apply)\n", len(changes), path)
+}
+
+// printDiffs prints each diff, colored by color
+func printDiffs(w io.Writer, color palette, changes []PlannedChange) {
+	for _, change := range changes {
+		_, _ = fmt.Fprint(w, color.diff(change.Diff))
+	}
+}
+
+func fixCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	if len(args) == 0 || args[0] != "frontmatter" {
+		return fmt.Errorf("fix requires a subcommand: frontmatter")
+	}
+
+	fs := flag.NewFlagSet("fix frontmatter", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, false)
+	localDryRun := fs.Bool("dry-run", false, "Show the repairs as diffs without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun, *apply)
+
+	result, err := cmdCtx.manager.FixFrontmatter(ctx, *root, dryRun)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nRepaired files: %d\n", len(result.Fixed))
+	for _, fix := range result.Fixed {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s\n", fix.Path, strings.Join(fix.Fixes, "; "))
+		if dryRun || verbose {
+			_, _ = fmt.Fprint(cmdCtx.stdout, fix.Diff)
+		}
+	}
+	if result.Backup != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Originals backed up to %s\n", result.Backup)
+	}
+	if result.Operation != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Journaled as operation %s (tag-manager undo --op-id=%s)\n", result.Operation, result.Operation)
+	}
+
+	if len(result.Unfixed) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\nFiles to repair by hand: %d\n", len(result.Unfixed))
+		printFrontmatterProblems(cmdCtx.stdout, result.Unfixed)
+	}
+
+	for _, err := range result.Errors {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "error: %s\n", err)
+	}
+
+	return failedFiles(len(result.Errors))
+}
+
+func autotagCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("autotag", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, false)
+	apply := fs.Bool("apply", false, "Tag the files; without it autotag only shows what it would tag")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if len(cmdCtx.config.AutoTag) == 0 {
+		return fmt.Errorf("no autotag rules in the config")
+	}
+
+	// Files are only changed with --apply, whatever default_dry_run says
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || !*apply, *apply)
+
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	ops, err := manager.PlanAutoTags(ctx, *root)
+	if err != nil {
+		clearProgress()
+		return failedOperation(fmt.Errorf("failed to apply autotag rules: %w", err))
+	}
+	result := &TagUpdateResult{ModifiedFiles: []string{}, TagsAdded: map[string]int{}, TagsRemoved: map[string]int{}}
+	if len(ops) > 0 {
+		result, err = manager.UpdateTagsPerFile(ctx, *root, ops, dryRun)
+	}
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to update tags: %w", err))
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	if dryRun {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Would tag %d files:\n", len(ops))
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Tagged %d files:\n", len(ops))
+	}
+	for _, op := range ops {
+		tags := make([]string, len(op.Add))
+		for i, tag := range op.Add {
+			tags[i] = cmdCtx.color.tag("#" + tag)
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s\n", op.Path, strings.Join(tags, ", "))
+	}
+	if dryRun && verbose {
+		printDiffs(cmdCtx.stdout, cmdCtx.color, result.Diffs)
+	}
+
+	if result.Backup != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Originals backed up to %s\n", result.Backup)
+	}
+	if result.Operation != "" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Journaled as operation %s (tag-manager undo --op-id=%s)\n", result.Operation, result.Operation)
+	}
+	if len(result.Errors) > 0 {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "Errors: %d\n", len(result.Errors))
+		for _, errMsg := range result.Errors {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", errMsg)
+		}
+	}
+	return failedFiles(len(result.Errors))
+}
+
+func cleanCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("clean", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, false)
+	apply := fs.Bool("apply", false, "Change the files; without it clean only lists what it would change")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	// Files are only changed with --apply, whatever default_dry_run says
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || !*apply, *apply)
+
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	plan, err := manager.PlanClean(ctx, *root)
+	if err != nil {
+		clearProgress()
+		return failedOperation(fmt.Errorf("failed to plan clean: %w", err))
+	}
+	result := &TagUpdateResult{ModifiedFiles: []string{}, TagsAdded: map[string]int{}, TagsRemoved: map[string]int{}}
+	if len(plan.Ops) > 0 {
+		result, err = manager.UpdateTagsPerFile(ctx, *root, plan.Ops, dryRun)
+	}
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to update tags: %w", err))
+	}
+
+	if written, err := output.write(cmdCtx.stdout, CleanResult{plan, result}, nil); written || err != nil {
+		return err
+	}
+
+	if dryRun {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Would clean %d invalid tags in %d files:\n", len(plan.Actions), len(plan.Ops))
+	} else {
+		_, _ = fmt.Fprintf(cmdCtx.info, "Cleaned %d invalid tags in %d files:\n", len(plan.Actions), len(plan.Ops))
+	}
+	for _, action := range plan.Actions {
+		change := "remove"
+		if action.Action == CleanRename {
+			change = "rename to " + cmdCtx.color.tag("#"+action.NewTag)
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %s (%s)\n", cmdCtx.color.tag("#"+action.Tag), change, strings.Join(action.Issues, "; "))
+		for _, path := range action.Files {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "    %s\n", path)
+		}
+	}
+	if dryRun && verbose {
+		printDiffs(cmdCtx.stdout, cmdCtx.color, result.Diffs)
+	}
+	return printUpdateResult(cmdCtx, result, dryRun)
+}
+
+func importCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory the manifest's paths are relative to")
+	manifest := fs.String("manifest", "", "CSV or JSON manifest of the tags to add and remove per file, or - for stdin")
+	manifestFormat := fs.String("manifest-format", "", "Format of the manifest: csv or json; by default its extension")
+	outputFlags := addOutputFlags(fs, false)
+	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+	if *manifest == "" {
+		return fmt.Errorf("--manifest parameter is required")
+	}
+
+	ops, err := readManifest(*manifest, *manifestFormat, cmdCtx.stdin)
+	if err != nil {
+		return err
+	}
+	if err := validateManifest(ctx, cmdCtx.manager, *root, ops); err != nil {
+		return fmt.Errorf("manifest %s: %w", *manifest, err)
+	}
+
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun, *apply)
+
+	manager, clearProgress := meterProgress(cmdCtx.manager, cmdCtx, output)
+	result, err := manager.UpdateTagsPerFile(ctx, *root, ops, dryRun)
+	clearProgress()
+	if err != nil {
+		return failedOperation(fmt.Errorf("failed to import tags: %w", err))
+	}
+
+	if written, err := output.write(cmdCtx.stdout, result, nil); written || err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Manifest %s: %d files\n", *manifest, len(ops))
+	if dryRun {
+		printDiffs(cmdCtx.stdout, cmdCtx.color, result.Diffs)
+	}
+	return printUpdateResult(cmdCtx, result, dryRun)
+}
+
+func initCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	yes := fs.Bool("yes", false, "Write the proposed config without asking for confirmation")
+	force := fs.Bool("force", false, "Overwrite an existing config file")
+	buildIndex := fs.Bool("build-index", false, "Build the initial tag index after writing the config")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	proposal, err := cmdCtx.manager.ProposeConfig(ctx, *root)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, proposal, nil); written || err != nil {
+		return err
+	}
+
+	content, err := proposal.Render(cmdCtx.config)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "\nScanned %d files in %s\n", proposal.TotalFiles, *root)
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Tag style:        %s (%d files with frontmatter tags, %d with inline hashtags)\n",
+		proposal.TagStyle, proposal.FrontmatterTagFiles, proposal.InlineTagFiles)
+	tagsStyle, casing := proposal.TagsStyle, proposal.CaseConvention
+	if tagsStyle == TagsStylePreserve {
+		tagsStyle = "mixed, each note keeps its own"
+	}
+	if casing == TagCaseAny {
+		casing = "mixed, any case accepted"
 	}
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Frontmatter tags: %s\n", tagsStyle)
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Casing:           %s\n", casing)
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Excluded folders: %s\n", strings.Join(proposal.ExcludeDirs, ", "))
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "  Protected tags:   %s\n", strings.Join(proposal.ProtectedTags, ", "))
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "\nProposed %s:\n\n%s\n", VaultConfigFile, content)
 
-	for _, file := range fileTags {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s:\n", file.Path)
-		if len(file.Tags) == 0 {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  (no tags)\n")
-		} else {
-			for _, tag := range file.Tags {
-				_, _ = fmt.Fprintf(cmdCtx.stdout, "  #%s\n", tag)
-			}
+	if !*yes {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "Write %s? [y/N] ", filepath.Join(*root, VaultConfigFile))
+		if !confirm(cmdCtx.stdin) {
+			_, _ = fmt.Fprintln(cmdCtx.stdout, "Aborted, no files written")
+			return nil
+		}
+	}
+
+	path, err := writeVaultConfig(cmdCtx.config, *root, content, *force)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "Wrote %s\nCommands run inside the vault, or with --root=%q, load it without --config\n", path, *root)
+
+	if *buildIndex {
+		stats, err := cmdCtx.manager.UpdateIndex(ctx, *root)
+		if err != nil {
+			return failedOperation(fmt.Errorf("failed to build index: %w", err))
 		}
+		_, _ = fmt.Fprintf(cmdCtx.info, "Indexed %d files\n", stats.Files)
 	}
 
 	return nil
 }
 
-func updateCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
-	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+func indexCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("index requires a subcommand: build, compact, inspect")
+	}
+
+	fs := flag.NewFlagSet("index "+args[0], flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	outputFlags := addOutputFlags(fs, false)
+
+	var tagFilter, fileFilter *string
+	if args[0] == "inspect" {
+		tagFilter = fs.String("tag", "", "Only show records containing this tag")
+		fileFilter = fs.String("file", "", "Only show the record for this file (relative to root)")
+	}
 
-	cwd, err := os.Getwd()
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		return err
 	}
 
-	addTags := fs.String("add", "", "Comma-separated tags to add")
-	removeTags := fs.String("remove", "", "Comma-separated tags to remove")
-	files := fs.String("files", "", "Comma-separated file paths relative to root")
-	root := fs.String("root", cwd, "Root directory for file paths")
-	jsonOutput := fs.Bool("json", false, "Output as JSON")
-	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	if args[0] == "inspect" {
+		return indexInspectCommand(ctx, cmdCtx, *root, *tagFilter, *fileFilter, output)
+	}
+
+	var stats *IndexStats
+	switch args[0] {
+	case "build":
+		stats, err = cmdCtx.manager.UpdateIndex(ctx, *root)
+	case "compact":
+		stats, err = cmdCtx.manager.CompactIndex(ctx, *root)
+	default:
+		return fmt.Errorf("unknown index subcommand: %s", args[0])
+	}
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, stats, nil); written || err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nIndexed files: %d\n", stats.Files)
+	if args[0] == "build" {
+		_, _ = fmt.Fprintf(cmdCtx.info, "  Updated: %d\n", stats.Updated)
+		_, _ = fmt.Fprintf(cmdCtx.info, "  Removed: %d\n", stats.Removed)
+	}
+	_, _ = fmt.Fprintf(cmdCtx.info, "Log entries: %d\n", stats.LogEntries)
+	if stats.Compacted {
+		_, _ = fmt.Fprintln(cmdCtx.info, "Index compacted")
+	}
+
+	return nil
+}
+
+func indexInspectCommand(ctx context.Context, cmdCtx *commandContext, root, tag, file string, output *output) error {
+	statuses, err := cmdCtx.manager.InspectIndex(ctx, root, tag, file)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, statuses, nil); written || err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		_, _ = fmt.Fprintln(cmdCtx.info, "\nNo matching index records")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nFound %d index records:\n", len(statuses))
+	for _, status := range statuses {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%s (%s)\n", status.Path, status.Status)
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Hash:     %s\n", status.Hash)
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Size:     %d\n", status.Size)
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Modified: %s\n", status.ModTime.Format(time.RFC3339))
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Indexed:  %s\n", status.IndexedAt.Format(time.RFC3339))
+		if status.Status == IndexStale {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Current hash:     %s\n", status.CurrentHash)
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Current size:     %d\n", status.CurrentSize)
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Current modified: %s\n", status.CurrentModTime.Format(time.RFC3339))
+		}
+		if len(status.Tags) > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Tags:     #%s\n", strings.Join(status.Tags, ", #"))
+		}
+	}
+
+	return nil
+}
+
+func changesCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("changes", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	since := fs.String("since", "", "Time to list changes since: RFC 3339, YYYY-MM-DD or a duration such as 24h")
+	outputFlags := addOutputFlags(fs, false)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
+	if err != nil {
+		return err
+	}
 
-	if err := ValidateUpdateParameters(*addTags, *removeTags, *files); err != nil {
+	if *since == "" {
+		return fmt.Errorf("--since is required")
+	}
+	sinceTime, err := parseSince(*since, time.Now())
+	if err != nil {
 		return err
 	}
 
-	addTagList := parseTagList(*addTags)
-	removeTagList := parseTagList(*removeTags)
-	filePaths, err := ParseFilePaths(*files, *root)
+	changes, err := cmdCtx.manager.WhatChanged(ctx, *root, sinceTime)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	if written, err := output.write(cmdCtx.stdout, changes, nil); written || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkEmpty(cmdCtx, len(changes))
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.info, "\nFiles with changed tags since %s: %d\n", sinceTime.Format(time.RFC3339), len(changes))
+	for _, change := range changes {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s (%s)\n", change.Path, change.Change)
+		if len(change.Added) > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "    + #%s\n", strings.Join(change.Added, ", #"))
+		}
+		if len(change.Removed) > 0 {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "    - #%s\n", strings.Join(change.Removed, ", #"))
+		}
+	}
+
+	return checkEmpty(cmdCtx, len(changes))
+}
+
+func historyCommand(ctx context.Context, cmdCtx *commandContext, args []string, verbose bool) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault, inside a git work tree")
+	tags := fs.String("tags", "", "Comma-separated list of tags, or - to read one per line from stdin")
+	outputFlags := addOutputFlags(fs, false)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	output, err := outputFlags.resolve(cmdCtx.jsonIndent)
 	if err != nil {
 		return err
 	}
 
-	dryRun := globalDryRun || *localDryRun
-	if dryRun {
-		_, _ = fmt.Fprintln(cmdCtx.stdout, "DRY RUN MODE - No files will be modified")
+	if *tags == "" {
+		return fmt.Errorf("--tags is required")
+	}
+	tagList, err := listFlag(cmdCtx, "tags", *tags)
+	if err != nil {
+		return err
 	}
 
-	result, err := cmdCtx.manager.UpdateTags(ctx, addTagList, removeTagList, *root, filePaths, dryRun)
+	histories, err := cmdCtx.manager.GetTagHistory(ctx, *root, tagList)
 	if err != nil {
-		return fmt.Errorf("failed to update tags: %w", err)
+		return failedOperation(err)
 	}
 
-	if *jsonOutput {
-		return json.NewEncoder(cmdCtx.stdout).Encode(result)
+	if written, err := output.write(cmdCtx.stdout, histories, nil); written || err != nil {
+		return err
 	}
 
-	if len(result.FilesMigrated) > 0 {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "Files with migrated hashtags: %d\n", len(result.FilesMigrated))
-		for _, file := range result.FilesMigrated {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file)
+	for _, history := range histories {
+		if history.FirstSeen.IsZero() {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "\n#%s: not in any commit\n", history.Tag)
+			continue
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n#%s: first seen %s, last used %s, in %d files\n", history.Tag,
+			history.FirstSeen.Format(time.DateOnly), history.LastUsed.Format(time.DateOnly), len(history.Files))
+		for _, event := range history.Events {
+			path := event.Path
+			if event.OldPath != "" {
+				path = event.OldPath + " -> " + event.Path
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s %.7s %-7s %s\n", event.Time.Format(time.DateOnly), event.Commit, event.Action, path)
 		}
+
+		points := make([]string, len(history.Trend))
+		for i, point := range history.Trend {
+			points[i] = fmt.Sprintf("%s %d", point.Month, point.Files)
+		}
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Files by month: %s\n", strings.Join(points, ", "))
 	}
+	return nil
+}
 
-	if len(result.ModifiedFiles) > 0 {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "Modified files: %d\n", len(result.ModifiedFiles))
-		for _, file := range result.ModifiedFiles {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", file)
+const occurrenceHelp = `  y          rename this occurrence
+  n, Enter   leave this occurrence
+  a          rename the rest in this file
+  s          leave the rest in this file
+  q          leave every remaining occurrence`
+
+// occurrencePrompt asks whether to rename each occurrence a replace finds,
+// like an interactive rebase
+type occurrencePrompt struct {
+	cmdCtx *commandContext
+	reader *bufio.Reader
+	root   string
+	// file is the file of the last occurrence, and rest the answer given for
+	// the rest of it with a or s
+	file string
+	rest *bool
+	quit bool
+}
+
+func (p *occurrencePrompt) decide(occurrence Occurrence) bool {
+	if p.quit {
+		return false
+	}
+	if occurrence.Path != p.file {
+		p.file = occurrence.Path
+		p.rest = nil
+		name := occurrence.Path
+		if relPath, err := filepath.Rel(p.root, occurrence.Path); err == nil {
+			name = relPath
+		}
+		_, _ = fmt.Fprintf(p.cmdCtx.stdout, "\n%s\n", name)
+	}
+	if p.rest != nil {
+		return *p.rest
+	}
+
+	text := occurrence.Text
+	prefix := fmt.Sprintf("  %d: ", occurrence.Line)
+	_, _ = fmt.Fprintf(p.cmdCtx.stdout, "%s%s%s%s\n", prefix, text[:occurrence.Start],
+		p.cmdCtx.color.tag(text[occurrence.Start:occurrence.End]), text[occurrence.End:])
+	// A caret line tells apart occurrences on the same line without color
+	_, _ = fmt.Fprintf(p.cmdCtx.stdout, "%s%s\n", strings.Repeat(" ", len(prefix)+utf8.RuneCountInString(text[:occurrence.Start])),
+		strings.Repeat("^", utf8.RuneCountInString(text[occurrence.Start:occurrence.End])))
+	for {
+		_, _ = fmt.Fprintf(p.cmdCtx.stdout, "  Rename #%s to #%s? [y/n/a/s/q]: ", occurrence.OldTag, occurrence.NewTag)
+		line, err := p.reader.ReadString('\n')
+		if err != nil && line == "" {
+			// End the prompt's line, as pressing Enter would have
+			_, _ = fmt.Fprintln(p.cmdCtx.stdout)
+			p.quit = true
+			return false
+		}
+
+		answer := strings.TrimSpace(line)
+		switch answer {
+		case "y":
+			return true
+		case "", "n":
+			return false
+		case "a", "s":
+			rename := answer == "a"
+			p.rest = &rename
+			return rename
+		case "q":
+			p.quit = true
+			return false
 		}
+		_, _ = fmt.Fprintln(p.cmdCtx.stdout, occurrenceHelp)
 	}
+}
 
-	if len(result.TagsAdded) > 0 {
-		_, _ = fmt.Fprintln(cmdCtx.stdout, "Tags added:")
-		for tag, count := range result.TagsAdded {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %d files\n", tag, count)
+const triageHelp = `  a          apply all suggested tags
+  1 3        apply the numbered suggestions
+  t TAGS     apply comma separated TAGS
+  s, Enter   skip this file
+  e          open the file in $EDITOR
+  q          quit, the session resumes next time`
+
+// tuiCommand runs the vault browser until it's quit
+func tuiCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool) error {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	localDryRun := fs.Bool("dry-run", false, "Preview renames and merges without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkVault(*root); err != nil {
+		return err
+	}
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun, *apply)
+
+	tags, err := cmdCtx.manager.ListAllTags(ctx, *root, 1)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	model := newTUIModel(ctx, cmdCtx.manager, *root, dryRun, cmdCtx.color, tags)
+	program := tea.NewProgram(model, tea.WithContext(ctx), tea.WithInput(cmdCtx.stdin),
+		tea.WithOutput(cmdCtx.stdout), tea.WithAltScreen())
+	_, err = program.Run()
+	return err
+}
+
+func triageCommand(ctx context.Context, cmdCtx *commandContext, args []string, globalDryRun bool, verbose bool) error {
+	fs := flag.NewFlagSet("triage", flag.ContinueOnError)
+
+	root := fs.String("root", cmdCtx.root, "Root directory of the vault")
+	reset := fs.Bool("reset", false, "Forget previous decisions and triage every untagged file")
+	localDryRun := fs.Bool("dry-run", false, "Show what would be changed without making changes")
+	apply := fs.Bool("apply", false, "Modify files even when default_dry_run is set")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dryRun := resolveDryRun(cmdCtx, globalDryRun || *localDryRun, *apply)
+
+	session, err := LoadTriageSession(*root)
+	if err != nil {
+		return err
+	}
+	session.perm = newFilePerm(cmdCtx.config)
+	if *reset {
+		if err := session.Reset(); err != nil {
+			return err
 		}
 	}
 
-	if len(result.TagsRemoved) > 0 {
-		_, _ = fmt.Fprintln(cmdCtx.stdout, "Tags removed:")
-		for tag, count := range result.TagsRemoved {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s: %d files\n", tag, count)
+	items, err := cmdCtx.manager.TriageUntagged(ctx, *root)
+	if err != nil {
+		return failedOperation(err)
+	}
+
+	var pending []TriageItem
+	for _, item := range items {
+		if !session.Decided(item.Path) {
+			pending = append(pending, item)
 		}
 	}
 
-	if len(result.Errors) > 0 {
-		_, _ = fmt.Fprintf(cmdCtx.stdout, "Errors: %d\n", len(result.Errors))
-		for _, errMsg := range result.Errors {
-			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %s\n", errMsg)
+	if len(pending) == 0 {
+		_, _ = fmt.Fprintln(cmdCtx.stdout, "\nNo untagged files left to triage")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(cmdCtx.stdout, "\n%d untagged files to triage, %d handled in earlier sessions\n%s\n",
+		len(pending), len(session.Decisions), triageHelp)
+
+	reader := bufio.NewReader(cmdCtx.stdin)
+	for i, item := range pending {
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "\n[%d/%d] %s\n", i+1, len(pending), item.Path)
+		_, _ = fmt.Fprintf(cmdCtx.stdout, "  Title: %s\n", item.Title)
+		for _, line := range strings.Split(item.Preview, "\n") {
+			if line != "" {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  | %s\n", line)
+			}
+		}
+		if len(item.Suggestions) == 0 {
+			_, _ = fmt.Fprintln(cmdCtx.stdout, "  No suggested tags")
+		}
+		for n, tag := range item.Suggestions {
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  %d) #%s\n", n+1, tag)
+		}
+
+		decision, tags, err := promptTriage(cmdCtx, reader, item)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		switch decision {
+		case "quit":
+			_, _ = fmt.Fprintln(cmdCtx.stdout, "Triage paused, run triage again to resume")
+			return nil
+		case TriageTagged:
+			result, err := cmdCtx.manager.UpdateTags(ctx, tags, nil, *root, []string{item.Path}, dryRun)
+			if err != nil {
+				return failedOperation(fmt.Errorf("failed to update tags: %w", err))
+			}
+			if len(result.Errors) > 0 {
+				_, _ = fmt.Fprintf(cmdCtx.stdout, "  Error: %s\n", strings.Join(result.Errors, "; "))
+				continue
+			}
+			_, _ = fmt.Fprintf(cmdCtx.stdout, "  Tagged with #%s\n", strings.Join(tags, ", #"))
+		case TriageEdited:
+			if err := openEditor(ctx, cmdCtx, filepath.Join(*root, item.Path)); err != nil {
+				return err
+			}
+		}
+
+		if !dryRun {
+			if err := session.Record(item.Path, decision); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintln(cmdCtx.stdout, "\nTriage complete")
+	return nil
+}
+
+// promptTriage reads keystrokes until a valid choice is made for item, returning
+// the decision and, for TriageTagged, the tags to apply.
+func promptTriage(cmdCtx *commandContext, reader *bufio.Reader, item TriageItem) (string, []string, error) {
+	for {
+		_, _ = fmt.Fprint(cmdCtx.stdout, "  Choice [a/1-9/t/s/e/q]: ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", nil, err
+		}
+		line = strings.TrimSpace(line)
+		command, rest, _ := strings.Cut(line, " ")
+
+		switch command {
+		case "", "s":
+			return TriageSkipped, nil, nil
+		case "q":
+			return "quit", nil, nil
+		case "e":
+			return TriageEdited, nil, nil
+		case "a":
+			if len(item.Suggestions) > 0 {
+				return TriageTagged, item.Suggestions, nil
+			}
+			_, _ = fmt.Fprintln(cmdCtx.stdout, "  No suggestions to apply")
+			continue
+		case "t":
+			if tags := parseTagList(rest); len(tags) > 0 {
+				return TriageTagged, tags, nil
+			}
+			_, _ = fmt.Fprintln(cmdCtx.stdout, "  Usage: t tag1,tag2")
+			continue
+		}
+
+		if tags, ok := pickSuggestions(line, item.Suggestions); ok {
+			return TriageTagged, tags, nil
+		}
+		_, _ = fmt.Fprintln(cmdCtx.stdout, triageHelp)
+	}
+}
+
+// pickSuggestions resolves a list of 1-based suggestion numbers such as "1 3" or "1,3"
+func pickSuggestions(input string, suggestions []string) ([]string, bool) {
+	fields := strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	var tags []string
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(suggestions) {
+			return nil, false
 		}
-		return fmt.Errorf("completed with %d errors", len(result.Errors))
+		tags = append(tags, suggestions[n-1])
+	}
+	return tags, true
+}
+
+// openEditor opens path in $EDITOR, falling back to vi
+func openEditor(ctx context.Context, cmdCtx *commandContext, path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
 	}
 
+	cmd := exec.CommandContext(ctx, editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = cmdCtx.stdout
+	cmd.Stderr = cmdCtx.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run editor: %w", err)
+	}
+	return nil
+}
+
+// resolveDryRun decides whether a mutating command only previews its changes,
+// and announces it when it does. With default_dry_run set in the config, files
+// are only modified when --apply is given.
+func resolveDryRun(cmdCtx *commandContext, dryRun, apply bool) bool {
+	if !dryRun && cmdCtx.config.DefaultDryRun && !apply {
+		_, _ = fmt.Fprintln(cmdCtx.info, "DRY RUN MODE - No files will be modified (default_dry_run is set, pass --apply to modify files)")
+		return true
+	}
+
+	if dryRun {
+		_, _ = fmt.Fprintln(cmdCtx.info, "DRY RUN MODE - No files will be modified")
+	}
+	return dryRun
+}
+
+// checkVault fails when root isn't a directory, so a mistyped vault path
+// isn't mistaken for a vault without matching files
+func checkVault(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("invalid root path: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid root path: %s is not a directory", root)
+	}
 	return nil
 }
 
+// scopedManager returns the command's manager, scanning the files with
+// extensions, a comma-separated list, and limited to the files changed since
+// the git ref, when they are given.
+func scopedManager(ctx context.Context, cmdCtx *commandContext, root, changedSince, extensions string) (TagManager, error) {
+	if err := checkVault(root); err != nil {
+		return nil, err
+	}
+
+	manager := cmdCtx.manager
+	if extensions != "" {
+		parsed, err := parseExtensions(extensions)
+		if err != nil {
+			return nil, err
+		}
+		manager = manager.WithExtensions(parsed)
+	}
+	if changedSince == "" {
+		return manager, nil
+	}
+
+	filter, err := ChangedSinceFilter(ctx, root, changedSince)
+	if err != nil {
+		return nil, err
+	}
+	return manager.WithFilter(filter), nil
+}
+
+// printScanErrors lists the files skipped because they couldn't be scanned,
+// and the files scanned without some of their frontmatter tags
+func printScanErrors(w io.Writer, report *ScanReport) {
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		_, _ = fmt.Fprintf(w, "\n%d files scanned with warnings:\n", len(warnings))
+		for _, warning := range warnings {
+			_, _ = fmt.Fprintf(w, "  [%s] %s: %v\n", warning.Phase, warning.Path, warning.Err)
+		}
+	}
+
+	scanErrors := report.Errors()
+	if len(scanErrors) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "\n%d files skipped because of scan errors:\n", len(scanErrors))
+	for _, scanErr := range scanErrors {
+		_, _ = fmt.Fprintf(w, "  [%s] %s: %v\n", scanErr.Phase, scanErr.Path, scanErr.Err)
+	}
+}
+
+// confirm reads a single line from r and reports whether it is a yes answer
+func confirm(r io.Reader) bool {
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func ValidateUpdateParameters(addTags, removeTags, files string) error {
 	if addTags == "" && removeTags == "" {
 		return fmt.Errorf("at least one of --add or --remove must be specified")
@@ -611,6 +3322,95 @@ func parseTagList(tagStr string) []string {
 	return tags
 }
 
+// listFlag returns the values of the comma-separated list flag name, or when
+// it is -, the trimmed lines of stdin, so long lists can be piped in rather
+// than passed as arguments
+func listFlag(cmdCtx *commandContext, name, value string) ([]string, error) {
+	return readListFlag(cmdCtx, name, value, strings.TrimSpace)
+}
+
+// fileListFlag returns the file paths of the list flag name as listFlag does,
+// except that lines read from stdin keep their spaces, which file names can
+// start or end with
+func fileListFlag(cmdCtx *commandContext, name, value string) ([]string, error) {
+	return readListFlag(cmdCtx, name, value, trimLineEnd)
+}
+
+func readListFlag(cmdCtx *commandContext, name, value string, trim func(string) string) ([]string, error) {
+	if value == "-" {
+		stdin, err := cmdCtx.stdinFor(name)
+		if err != nil {
+			return nil, err
+		}
+		return readStdinList(stdin, trim)
+	}
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values, nil
+}
+
+// stdinFor returns stdin for the flag name to read, failing when another flag
+// already read it, since it would find stdin empty
+func (c *commandContext) stdinFor(name string) (io.Reader, error) {
+	if c.stdinFlag != "" {
+		return nil, fmt.Errorf("--%s and --%s both read stdin; only one of them can", c.stdinFlag, name)
+	}
+	c.stdinFlag = name
+	return c.stdin, nil
+}
+
+// trimLineEnd trims the carriage return of a CRLF line
+func trimLineEnd(line string) string {
+	return strings.TrimSuffix(line, "\r")
+}
+
+// readStdinList returns the non-blank lines of r, trimmed with trim
+func readStdinList(r io.Reader, trim func(string) string) ([]string, error) {
+	var values []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if value := trim(scanner.Text()); strings.TrimSpace(value) != "" {
+			values = append(values, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no values on stdin")
+	}
+	return values, nil
+}
+
+// readFilePaths returns the file paths on the lines of r, relative to root.
+// Unlike --files, absolute paths inside root are accepted, so the output of
+// find can be piped in.
+func readFilePaths(r io.Reader, root string) ([]string, error) {
+	lines, err := readStdinList(r, trimLineEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root: %w", err)
+	}
+	filePaths := make([]string, 0, len(lines))
+	for _, path := range lines {
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(absRoot, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil, fmt.Errorf("file path is outside root: %s", path)
+			}
+			path = rel
+		}
+		filePaths = append(filePaths, path)
+	}
+	return filePaths, nil
+}
+
 func ParseFilePaths(filesStr, root string) ([]string, error) {
 	if filesStr == "" {
 		return nil, fmt.Errorf("files parameter cannot be empty")
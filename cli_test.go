@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
@@ -215,14 +217,18 @@ func TestMCPServerCapabilities(t *testing.T) {
 
 		// Verify all expected tools are available with correct descriptions
 		expectedTools := map[string]string{
-			"find_files_by_tags": "Find files containing specific tags",
-			"get_tags_info":      "Get detailed information about specific tags including file lists",
-			"list_all_tags":      "List all tags with usage statistics and optional filtering",
-			"replace_tags_batch": "Replace/rename tags across multiple files with batch operation",
-			"get_untagged_files": "Find files that don't have any tags",
-			"validate_tags":      "Validate tag syntax and get suggestions for invalid tags",
-			"get_files_tags":     "Get all tags associated with specific files",
-			"update_tags":        "Add and remove tags from specific files with automatic hashtag migration",
+			"find_files_by_tags":   "Find files containing specific tags",
+			"get_tags_info":        "Get detailed information about specific tags including file lists",
+			"list_all_tags":        "List all tags with usage statistics and optional filtering",
+			"replace_tags_batch":   "Replace/rename tags across multiple files with batch operation",
+			"get_untagged_files":   "Find files that don't have any tags",
+			"validate_tags":        "Validate tag syntax and get suggestions for invalid tags",
+			"get_files_tags":       "Get all tags associated with specific files",
+			"update_tags":          "Add and remove tags from specific files with automatic hashtag migration",
+			"update_tags_per_file": "Add and remove a different set of tags in each file in one batch, with automatic hashtag migration",
+			"preview_update_tags":  "Preview update_tags without modifying files: the diff of each file, hashtag migrations and conflicting tags",
+			"list_roots":           "List the named vaults other tools accept as root_name",
+			"get_changes":          "List files whose tags changed since a time, with the tags added and removed",
 		}
 
 		foundTools := make(map[string]bool)
@@ -230,6 +236,10 @@ func TestMCPServerCapabilities(t *testing.T) {
 			if expectedDesc, expected := expectedTools[tool.Name]; expected {
 				foundTools[tool.Name] = true
 				assert.Equal(t, expectedDesc, tool.Description)
+				if tool.Name == "preview_update_tags" || tool.Name == "list_roots" {
+					require.NotNil(t, tool.Annotations)
+					assert.True(t, tool.Annotations.ReadOnlyHint)
+				}
 			} else {
 				t.Errorf("Unexpected tool found: %s", tool.Name)
 			}
@@ -241,7 +251,7 @@ func TestMCPServerCapabilities(t *testing.T) {
 		}
 
 		// Verify we have exactly 8 tools
-		assert.Len(t, tools.Tools, 8)
+		assert.Len(t, tools.Tools, 12)
 
 	})
 }
@@ -823,7 +833,10 @@ func TestDryRunOutputMessages(t *testing.T) {
 			Stderr: &stderr,
 		})
 		assert.NoError(t, err)
-		assertOutputContains(t, stdout.String(), []string{"DRY RUN MODE", "Modified files"})
+		assertOutputContains(t, stdout.String(), []string{"DRY RUN MODE", "Modified files",
+			"--- a/test.md", "+++ b/test.md", "-#golang", "+#go"})
+		// Output which isn't a terminal is never colorized
+		assert.NotContains(t, stdout.String(), "\x1b[")
 	})
 
 	t.Run("UpdateCommandDryRun", func(t *testing.T) {
@@ -835,7 +848,7 @@ func TestDryRunOutputMessages(t *testing.T) {
 			Stderr: &stderr,
 		})
 		assert.NoError(t, err)
-		assertOutputContains(t, stdout.String(), []string{"DRY RUN MODE"})
+		assertOutputContains(t, stdout.String(), []string{"DRY RUN MODE", "--- a/test.md", "+++ b/test.md", "+---", "- new-tag"})
 	})
 }
 
@@ -921,8 +934,8 @@ Content with #simple-tag in body`,
 				"existing-tag": 1,
 			},
 			verifyFile:        "frontmatter.md",
-			verifyContains:    []string{"title: Existing Frontmatter", "author: Test Author", "- top-tag", "- added-tag", "#body-tag"},
-			verifyNotContains: []string{"- existing-tag", "#top-tag"},
+			verifyContains:    []string{"title: \"Existing Frontmatter\"", "author: \"Test Author\"", "tags: [\"added-tag\", \"top-tag\"]", "#body-tag"},
+			verifyNotContains: []string{"existing-tag", "#top-tag"},
 		},
 	}
 
@@ -1099,8 +1112,9 @@ Content`,
 			contentStr := string(modifiedContent)
 
 			// Should have frontmatter with migrated tags
+			frontmatter, _, _ := strings.Cut(strings.TrimPrefix(contentStr, "---\n"), "\n---\n")
 			for _, tag := range test.expectedMigrated {
-				assert.Contains(t, contentStr, "- "+tag, "Migrated tag %s should be in frontmatter", tag)
+				assert.Contains(t, frontmatter, tag, "Migrated tag %s should be in frontmatter", tag)
 				assert.NotContains(t, contentStr, "#"+tag, "Migrated tag %s should not remain as hashtag", tag)
 			}
 
@@ -1110,7 +1124,7 @@ Content`,
 			}
 
 			// Should have trigger tag added
-			assert.Contains(t, contentStr, "- trigger-tag")
+			assert.Contains(t, frontmatter, "trigger-tag")
 
 			assert.Empty(t, stderr.String())
 		})
@@ -1416,7 +1430,7 @@ Content here`,
 				"root":        tempDir,
 			},
 			verifyFile:     "mcp2.md",
-			verifyContains: []string{"- top-tag", "- new-tag"},
+			verifyContains: []string{"tags: [\"new-tag\", \"top-tag\"]"},
 		},
 		{
 			name: "MCPBatchOperation",
@@ -1719,3 +1733,831 @@ Content here`,
 		})
 	}
 }
+
+func TestAuditFlatTagsCommand(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testFiles := map[string]string{
+		"k8s1.md": "#kubernetes #work/infra",
+		"k8s2.md": "#kubernetes #work/oncall",
+	}
+
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "audit", "flat-tags", "--root=" + tempDir, "--min-count=2"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+
+	assertOutputContains(t, stdout.String(), []string{
+		"Found 1 flat tags to move into a namespace",
+		"#work/kubernetes (2/2 files)",
+		`--replacements="kubernetes:work/kubernetes"`,
+	})
+
+	err = tagmanager.RunCmd([]string{"tag-manager", "audit", "unknown"}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	assert.Error(t, err)
+}
+
+func TestInitCommand(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testFiles := map[string]string{
+		"note1.md":              "---\ntags: [web-dev, golang]\n---\n# Note 1",
+		"note2.md":              "---\ntags: [web-dev]\n---\n# Note 2",
+		"note3.md":              "# Note 3\n#golang #data-science",
+		"Templates/template.md": "# Template\n#placeholder-tag",
+		".obsidian/app.md":      "#ignored",
+	}
+
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	configPath := filepath.Join(tempDir, tagmanager.VaultConfigFile)
+
+	t.Run("Declined", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "init", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stdin:  strings.NewReader("n\n"),
+		})
+		require.NoError(t, err)
+
+		assertOutputContains(t, stdout.String(), []string{
+			"Tag style:        mixed (2 files with frontmatter tags, 1 with inline hashtags)",
+			"Frontmatter tags: array",
+			"Casing:           kebab-case",
+			"Excluded folders: .obsidian, Templates",
+			"Aborted",
+		})
+		assert.NoFileExists(t, configPath)
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "init", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stdin:  strings.NewReader("y\n"),
+		})
+		require.NoError(t, err)
+
+		config, err := tagmanager.LoadConfig(configPath)
+		require.NoError(t, err)
+		assert.Contains(t, config.ExcludeDirs, "Templates")
+		assert.ElementsMatch(t, []string{"golang", "web-dev"}, config.ProtectedTags)
+		assert.Equal(t, tagmanager.TagsStyleArray, config.TagsStyle)
+		assert.Equal(t, tagmanager.TagCaseKebab, config.TagCase)
+	})
+
+	t.Run("ExistingFileRequiresForce", func(t *testing.T) {
+		err := tagmanager.RunCmd([]string{"tag-manager", "init", "--root=" + tempDir, "--yes"}, &tagmanager.RunCmdOptions{
+			Stdout: &bytes.Buffer{},
+		})
+		assert.ErrorContains(t, err, "already exists")
+	})
+}
+
+func TestListPinnedTags(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testFiles := map[string]string{
+		"note1.md": "#golang #programming",
+		"note2.md": "#golang #programming",
+		"note3.md": "#golang #reading",
+	}
+
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("pinned_tags: [reading, someday]\n"), tagmanager.DefaultFilePermissions))
+
+	t.Run("PinnedFirst", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "--config=" + configFile, "list", "--root=" + tempDir, "--min-count=2", "--json"},
+			&tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+
+		var tags []tagmanager.TagInfo
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &tags))
+
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		assert.Equal(t, []string{"reading", "someday", "golang", "programming"}, names)
+		assert.True(t, tags[0].Pinned)
+		assert.Equal(t, 1, tags[0].Count)
+		assert.Equal(t, 0, tags[1].Count)
+	})
+
+	t.Run("PinnedOnly", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "--config=" + configFile, "list", "--root=" + tempDir, "--pinned-only"},
+			&tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+
+		output := stdout.String()
+		assertOutputContains(t, output, []string{"Found 2 tags", "#reading", "(pinned)", "#someday"})
+		assert.NotContains(t, output, "#golang")
+	})
+}
+
+func TestListTagMetadata(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"note1.md": "#golang #python",
+		"note2.md": "#golang",
+	})
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("pinned_tags: [golang]\ntag_metadata:\n  golang:\n    icon: gopher\n    color: \"#00ADD8\"\n"), tagmanager.DefaultFilePermissions))
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "--config=" + configFile, "list", "--root=" + root},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+
+	output := stdout.String()
+	assert.Regexp(t, `#golang\s+2 files \(pinned\) color=#00ADD8, icon=gopher\n`, output)
+	assert.Regexp(t, `#python\s+1 files\n`, output)
+}
+
+func TestTriageCommand(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testFiles := map[string]string{
+		"tagged.md": "# Tagged\n#golang #python",
+		"go.md":     "# Go Notes\nWriting golang services, not python ones.",
+		"misc.md":   "Nothing to see here",
+	}
+	for path, content := range testFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, path), []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	runTriage := func(t *testing.T, input string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "triage", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stdin:  strings.NewReader(input),
+		})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	t.Run("ApplyThenQuit", func(t *testing.T) {
+		output := runTriage(t, "2\nq\n")
+		assertOutputContains(t, output, []string{
+			"2 untagged files to triage",
+			"[1/2] go.md",
+			"Title: Go Notes",
+			"| Writing golang services",
+			"1) #golang",
+			"2) #python",
+			"Tagged with #python",
+			"[2/2] misc.md",
+			"No suggested tags",
+			"Triage paused",
+		})
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "go.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "tags:\n    - python")
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		t.Setenv("EDITOR", "true")
+		output := runTriage(t, "a\nbogus\ne\n")
+		assertOutputContains(t, output, []string{
+			"1 untagged files to triage, 1 handled in earlier sessions",
+			"[1/1] misc.md",
+			"No suggestions to apply",
+			"apply all suggested tags",
+			"Triage complete",
+		})
+
+		session, err := tagmanager.LoadTriageSession(tempDir)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"go.md":   tagmanager.TriageTagged,
+			"misc.md": tagmanager.TriageEdited,
+		}, session.Decisions)
+	})
+
+	t.Run("Finished", func(t *testing.T) {
+		assert.Contains(t, runTriage(t, ""), "No untagged files left to triage")
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		output := runTriage(t, "s\n")
+		assert.Contains(t, output, "No untagged files left")
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "triage", "--reset", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stdin:  strings.NewReader("s\n"),
+		})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{"[1/1] misc.md", "Triage complete"})
+	})
+}
+
+func TestChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tempDir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", tempDir}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "committed.md"), []byte("#golang"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "modified.md"), []byte("#python"), tagmanager.DefaultFilePermissions))
+	git("init", "-q")
+	git("add", ".")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "modified.md"), []byte("#python #rust"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "untracked.md"), []byte("Nothing tagged"), tagmanager.DefaultFilePermissions))
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "list", "--root=" + tempDir, "--changed-since=HEAD", "--json"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+
+	var tags []tagmanager.TagInfo
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &tags))
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	assert.ElementsMatch(t, []string{"python", "rust"}, names)
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "untagged", "--root=" + tempDir, "--changed-since=HEAD"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"Found 1 untagged files", "untracked.md"})
+
+	err = tagmanager.RunCmd([]string{"tag-manager", "list", "--root=" + tempDir, "--changed-since=no-such-ref"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	assert.ErrorContains(t, err, "failed to list files changed since no-such-ref")
+}
+
+func TestDefaultDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("default_dry_run: true\n"), tagmanager.DefaultFilePermissions))
+
+	testFile := filepath.Join(tempDir, "note.md")
+	const content = "# Note\nSome content"
+	writeNote := func() {
+		require.NoError(t, os.WriteFile(testFile, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+	readNote := func() string {
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("CLI", func(t *testing.T) {
+		writeNote()
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "--config=" + configPath, "update", "--add=golang",
+			"--files=note.md", "--root=" + tempDir}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+		assert.Contains(t, stdout.String(), "pass --apply to modify files")
+		assert.Equal(t, content, readNote())
+
+		stdout.Reset()
+		err = tagmanager.RunCmd([]string{"tag-manager", "--config=" + configPath, "update", "--add=golang",
+			"--files=note.md", "--root=" + tempDir, "--apply"}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+		assert.NotContains(t, stdout.String(), "DRY RUN")
+		assert.Contains(t, readNote(), "golang")
+	})
+
+	t.Run("MCP", func(t *testing.T) {
+		writeNote()
+		ctx := context.Background()
+
+		clientTransport, serverTransport := mcp.NewInMemoryTransports()
+		go func() {
+			_ = tagmanager.RunCmd([]string{"tag-manager", "--config=" + configPath, "-mcp"},
+				&tagmanager.RunCmdOptions{MCPTransport: serverTransport})
+		}()
+
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v1.0.0"}, nil).
+			Connect(ctx, clientTransport, nil)
+		require.NoError(t, err)
+		defer func() {
+			_ = session.Close()
+		}()
+
+		params := map[string]interface{}{
+			"add_tags":   []string{"golang"},
+			"file_paths": []string{"note.md"},
+			"root":       tempDir,
+		}
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "update_tags", Arguments: params})
+		require.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Equal(t, content, readNote())
+
+		params["dry_run"] = false
+		result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "update_tags", Arguments: params})
+		require.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Contains(t, readNote(), "golang")
+	})
+}
+
+func TestQuietOutput(t *testing.T) {
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	t.Run("List", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		output := run(t, "-q", "list", "--root", root)
+		assert.NotContains(t, output, "Found")
+		assert.Contains(t, output, "#golang")
+	})
+
+	t.Run("DryRunKeepsDiffs", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		output := run(t, "--quiet", "replace", "--old", "golang", "--new", "go", "--root", root, "--dry-run")
+		assert.NotContains(t, output, "DRY RUN MODE")
+		assert.NotContains(t, output, "Modified files")
+		assert.Contains(t, output, "+#go")
+	})
+
+	t.Run("ReplaceAndUpdatePrintNothing", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		assert.Empty(t, run(t, "-q", "replace", "--old", "golang", "--new", "go", "--root", root))
+		assert.Empty(t, run(t, "-q", "update", "--add", "reviewed", "--files", "a.md", "--root", root))
+	})
+
+	t.Run("JSONUnaffected", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		output := run(t, "-q", "update", "--add", "reviewed", "--files", "a.md", "--root", root, "--dry-run", "--json")
+		assert.True(t, json.Valid([]byte(output)), output)
+	})
+}
+
+func TestStdinLists(t *testing.T) {
+	run := func(t *testing.T, stdin string, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(stdin)})
+		return stdout.String(), err
+	}
+
+	t.Run("UpdateFiles", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md":          "# A",
+			"my notes/b.md": "# B",
+			"untouched.md":  "# C",
+		})
+		stdin := "a.md\n\n" + filepath.Join(root, "my notes", "b.md") + "\n"
+		_, err := run(t, stdin, "update", "--add", "reviewed", "--files", "-", "--root", root)
+		require.NoError(t, err)
+
+		for name, expected := range map[string]bool{"a.md": true, "my notes/b.md": true, "untouched.md": false} {
+			content, err := os.ReadFile(filepath.Join(root, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, strings.Contains(string(content), "reviewed"), name)
+		}
+	})
+
+	t.Run("UpdateFilesOutsideRoot", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "# A"})
+		_, err := run(t, "/etc/passwd\n", "update", "--add", "reviewed", "--files", "-", "--root", root)
+		assert.ErrorContains(t, err, "outside root")
+	})
+
+	t.Run("FindTags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang", "b.md": "#python", "c.md": "#rust"})
+		output, err := run(t, "golang\npython\n", "find", "--tags", "-", "--root", root, "--json")
+		require.NoError(t, err)
+		var results map[string][]string
+		require.NoError(t, json.Unmarshal([]byte(output), &results))
+		assert.Equal(t, map[string][]string{
+			"golang": {filepath.Join(root, "a.md")},
+			"python": {filepath.Join(root, "b.md")},
+		}, results)
+	})
+
+	t.Run("ValidateTags", func(t *testing.T) {
+		output, err := run(t, "golang\n12\n", "validate", "--tags", "-", "--json")
+		require.NoError(t, err)
+		var results map[string]tagmanager.ValidationResult
+		require.NoError(t, json.Unmarshal([]byte(output), &results))
+		assert.True(t, results["golang"].IsValid)
+		assert.False(t, results["12"].IsValid)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := run(t, "\n", "validate", "--tags", "-")
+		assert.ErrorContains(t, err, "no values on stdin")
+	})
+
+	t.Run("TwoFlags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		_, err := run(t, "golang\n", "find", "--tags", "-", "--not-tags", "-", "--root", root)
+		assert.ErrorContains(t, err, "--tags and --not-tags both read stdin")
+	})
+
+	t.Run("FilesKeepSpaces", func(t *testing.T) {
+		root := writeVault(t, map[string]string{" spaced.md": "# A", "spaced.md": "# B"})
+		_, err := run(t, " spaced.md\r\n", "update", "--add", "reviewed", "--files", "-", "--root", root)
+		require.NoError(t, err)
+
+		for name, expected := range map[string]bool{" spaced.md": true, "spaced.md": false} {
+			content, err := os.ReadFile(filepath.Join(root, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, strings.Contains(string(content), "reviewed"), name)
+		}
+	})
+}
+
+func TestPrint0(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"my notes/a.md": "#golang #python",
+		"b c.md":        "#python",
+		"d.md":          "No tags",
+		"e f.md":        "Nothing here",
+	})
+
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	t.Run("Find", func(t *testing.T) {
+		output, err := run(t, "find", "--tags", "golang,python", "--root", root, "--print0")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "my notes/a.md")+"\x00"+filepath.Join(root, "b c.md")+"\x00", output)
+	})
+
+	t.Run("Untagged", func(t *testing.T) {
+		output, err := run(t, "untagged", "--root", root, "--print0")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "d.md")+"\x00"+filepath.Join(root, "e f.md")+"\x00", output)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		output, err := run(t, "--fail-if-empty", "find", "--tags", "rust", "--root", root, "--print0")
+		assert.Equal(t, tagmanager.ExitEmpty, tagmanager.ExitCode(err))
+		assert.Empty(t, output)
+	})
+
+	t.Run("WithFormat", func(t *testing.T) {
+		_, err := run(t, "untagged", "--root", root, "--print0", "--json")
+		assert.ErrorContains(t, err, "--print0 cannot be combined with --format=json")
+	})
+}
+
+func TestFileTagsGlobs(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"Daily/2025-03-01.md":      "#march",
+		"Daily/2025-03-02.md":      "#march #review",
+		"Daily/2025-04-01.md":      "#april",
+		"Projects/a/notes.md":      "#project",
+		"Projects/a/deep/notes.md": "#project",
+	})
+
+	run := func(t *testing.T, args ...string) ([]tagmanager.FileTagInfo, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager", "file-tags", "--json"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		if err != nil {
+			return nil, err
+		}
+		var files []tagmanager.FileTagInfo
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &files))
+		return files, nil
+	}
+	paths := func(files []tagmanager.FileTagInfo) []string {
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		return paths
+	}
+
+	files, err := run(t, "--files", "Daily/2025-03-*.md", "--root", root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "Daily/2025-03-01.md"), filepath.Join(root, "Daily/2025-03-02.md")}, paths(files))
+
+	files, err = run(t, "--files", "Projects/**/*.md,Daily/2025-04-01.md", "--root", root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "Projects/a/deep/notes.md"),
+		filepath.Join(root, "Projects/a/notes.md"),
+		filepath.Join(root, "Daily/2025-04-01.md"),
+	}, paths(files))
+
+	_, err = run(t, "--files", "Daily/2025-05-*.md", "--root", root)
+	assert.ErrorContains(t, err, "no files match")
+
+	// Without --root the glob is relative to the current directory's root,
+	// which has no Daily folder
+	_, err = run(t, "--files", "Daily/*.md")
+	assert.ErrorContains(t, err, "no files match")
+}
+
+func TestExcludeFlags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":                "#kept",
+		"Templates/t.md":      "#template",
+		"Drafts/d.md":         "#draft",
+		"board.canvas.md":     "#canvas",
+		"Attachments/x.md":    "#attachment",
+		"scene.excalidraw.md": "#excalidraw",
+		"Configured/c.md":     "#configured",
+	})
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte("exclude_dirs: [Configured, Attachments]\n"), 0644))
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "Defaults",
+			expected: []string{"canvas", "configured", "draft", "kept", "template"},
+		},
+		{
+			name:     "Repeated",
+			args:     []string{"--exclude-dir", "Templates", "--exclude-dir=Drafts", "--exclude-pattern", "*.canvas.md"},
+			expected: []string{"configured", "kept"},
+		},
+		{
+			name:     "NoDefaultExcludes",
+			args:     []string{"--no-default-excludes"},
+			expected: []string{"attachment", "canvas", "configured", "draft", "excalidraw", "kept", "template"},
+		},
+		{
+			name:     "NoDefaultExcludesKeepsConfigured",
+			args:     []string{"--config", config, "--no-default-excludes", "--exclude-dir", "Drafts"},
+			expected: []string{"attachment", "canvas", "excalidraw", "kept", "template"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			args := append(append([]string{"tag-manager"}, test.args...), "list", "--root", root, "--json")
+			require.NoError(t, tagmanager.RunCmd(args, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}}))
+
+			var tags []tagmanager.TagInfo
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &tags))
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+			assert.ElementsMatch(t, test.expected, names)
+		})
+	}
+}
+
+func TestExtensionsFlag(t *testing.T) {
+	root := writeVault(t, map[string]string{"a.md": "#markdown", "b.mdx": "#mdx", "c.txt": "#text"})
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "list", "--root", root, "--extensions=md,mdx,txt", "--json"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	var tags []tagmanager.TagInfo
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &tags))
+	assert.Len(t, tags, 3)
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "untagged", "--root", root, "--extensions=txt"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Found 0 untagged files")
+
+	err = tagmanager.RunCmd([]string{"tag-manager", "list", "--root", root, "--extensions=md/x"},
+		&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	assert.ErrorContains(t, err, `invalid extension "md/x"`)
+}
+
+func TestTagSortFlags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "#beta #gamma #alpha",
+		"b.md": "#beta #gamma",
+		"c.md": "#gamma",
+	})
+
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager", "list", "--root", root, "--json"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      string
+	}{
+		{name: "Default", expected: []string{"gamma", "beta", "alpha"}},
+		{name: "Name", args: []string{"--sort=name"}, expected: []string{"alpha", "beta", "gamma"}},
+		{name: "NameDesc", args: []string{"--sort=name", "--order=desc"}, expected: []string{"gamma", "beta", "alpha"}},
+		{name: "CountAsc", args: []string{"--sort=count", "--order=asc"}, expected: []string{"alpha", "beta", "gamma"}},
+		{name: "Files", args: []string{"--sort=files"}, expected: []string{"gamma", "beta", "alpha"}},
+		{name: "InvalidSort", args: []string{"--sort=size"}, err: `invalid sort "size": must be name, count or files`},
+		{name: "InvalidOrder", args: []string{"--sort=name", "--order=up"}, err: `invalid order "up": must be asc or desc`},
+		{name: "OrderWithoutSort", args: []string{"--order=asc"}, err: "--order requires --sort"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, test.args...)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var tags []tagmanager.TagInfo
+			require.NoError(t, json.Unmarshal([]byte(output), &tags))
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestUntaggedScoping(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"Inbox/old.md":       "no tags",
+		"Inbox/new.md":       "no tags either",
+		"Inbox/2025/mid.md":  "still none",
+		"Inbox-old/skip.md":  "none",
+		"Projects/tagged.md": "#golang",
+		"Projects/plain.md":  "none",
+	})
+	for i, name := range []string{"Inbox/old.md", "Inbox/2025/mid.md", "Inbox/new.md"} {
+		modTime := time.Date(2025, 1, i+1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, os.Chtimes(filepath.Join(root, name), modTime, modTime))
+	}
+
+	run := func(t *testing.T, args ...string) ([]string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager", "untagged", "--root", root, "--json"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		if err != nil {
+			return nil, err
+		}
+		var files []tagmanager.FileTagInfo
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &files))
+		var paths []string
+		for _, file := range files {
+			rel, err := filepath.Rel(root, file.Path)
+			require.NoError(t, err)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return paths, nil
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      string
+	}{
+		{name: "Prefix", args: []string{"--path-prefix=Inbox"}, expected: []string{"Inbox/2025/mid.md", "Inbox/new.md", "Inbox/old.md"}},
+		{name: "PrefixSlash", args: []string{"--path-prefix=Inbox/2025/"}, expected: []string{"Inbox/2025/mid.md"}},
+		{name: "SortMtime", args: []string{"--path-prefix=Inbox", "--sort=mtime"}, expected: []string{"Inbox/new.md", "Inbox/2025/mid.md", "Inbox/old.md"}},
+		{name: "SortPath", args: []string{"--path-prefix=Projects", "--sort=path"}, expected: []string{"Projects/plain.md"}},
+		{name: "OutsideRoot", args: []string{"--path-prefix=../elsewhere"}, err: `invalid path prefix "../elsewhere": must be a folder relative to the root`},
+		{name: "InvalidSort", args: []string{"--sort=name"}, err: `invalid sort "name": must be path, mtime or size`},
+		{name: "SortAndOrder", args: []string{"--sort=mtime", "--order=path"}, err: "--sort cannot be combined with --order"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths, err := run(t, test.args...)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, paths)
+		})
+	}
+}
+
+func TestReplaceScope(t *testing.T) {
+	files := map[string]string{
+		"Projects/alpha/a.md":  "#todo alpha",
+		"Projects/alpha/b.md":  "#todo beta",
+		"Projects/alphabet.md": "#todo gamma",
+		"Projects/beta/c.md":   "#todo delta",
+		"Inbox/d.md":           "#todo epsilon",
+		"Daily/2025-03-01.md":  "#todo zeta",
+		"Daily/2025-04-01.md":  "#todo eta",
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		modified []string
+		err      string
+	}{
+		{
+			name:     "PathPrefix",
+			args:     []string{"--path-prefix=Projects/alpha"},
+			modified: []string{"Projects/alpha/a.md", "Projects/alpha/b.md"},
+		},
+		{
+			name:     "Files",
+			args:     []string{"--files=Daily/2025-03-*.md,Inbox,Projects/beta/c.md"},
+			modified: []string{"Daily/2025-03-01.md", "Inbox/d.md", "Projects/beta/c.md"},
+		},
+		{
+			name:     "Both",
+			args:     []string{"--files=Projects/**/*.md", "--path-prefix=Projects/beta"},
+			modified: []string{"Projects/beta/c.md"},
+		},
+		{name: "NoMatch", args: []string{"--files=Missing/*.md"}, err: `no files match --files "Missing/*.md"`},
+		{name: "InvalidPrefix", args: []string{"--path-prefix=/etc"}, err: `invalid path prefix "/etc": must be a folder relative to the root`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, files)
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager", "replace", "--old=todo", "--new=task", "--root", root, "--json"}, test.args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			modified := make(map[string]bool)
+			for _, name := range test.modified {
+				modified[name] = true
+			}
+			for name, content := range files {
+				data, err := os.ReadFile(filepath.Join(root, name))
+				require.NoError(t, err)
+				if modified[name] {
+					assert.Contains(t, string(data), "#task", name)
+				} else {
+					assert.Equal(t, content, string(data), name)
+				}
+			}
+		})
+	}
+}
+
+func TestFileTagsGlobalRoot(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"sub/a.md": "#alpha",
+		"sub/b.md": "#beta",
+		"other.md": "#other",
+	})
+
+	for _, files := range []string{"sub/*.md", "sub"} {
+		t.Run(files, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd([]string{"tag-manager", "--root", root, "file-tags", "--files", files, "--json"},
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			require.NoError(t, err)
+
+			var fileTags []tagmanager.FileTagInfo
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &fileTags))
+			var paths []string
+			for _, file := range fileTags {
+				paths = append(paths, file.Path)
+			}
+			assert.Equal(t, []string{filepath.Join(root, "sub/a.md"), filepath.Join(root, "sub/b.md")}, paths)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package tagmanager
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Scales for TagCloud, from tag counts to weights
+const (
+	// CloudScaleLog weighs tags by the logarithm of their counts, so a few
+	// very common tags don't dwarf the rest
+	CloudScaleLog    = "log"
+	CloudScaleLinear = "linear"
+)
+
+// maxCloudWeight is the weight of the most used tag of a cloud
+const maxCloudWeight = 100
+
+// CloudWord is a tag of a word cloud
+type CloudWord struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+	// Weight is from 1 for the least used tag to 100 for the most used
+	Weight int `json:"weight"`
+}
+
+// TagCloud weighs the used tags of tags for a word cloud by scale, keeping
+// the top most used, 0 for all. Words are sorted by weight, then by tag.
+func TagCloud(tags []TagInfo, scale string, top int) ([]CloudWord, error) {
+	var measure func(count int) float64
+	switch scale {
+	case CloudScaleLog:
+		measure = func(count int) float64 { return math.Log(float64(count)) }
+	case CloudScaleLinear:
+		measure = func(count int) float64 { return float64(count) }
+	default:
+		return nil, fmt.Errorf("invalid scale %q: must be %s or %s", scale, CloudScaleLog, CloudScaleLinear)
+	}
+
+	words := []CloudWord{}
+	for _, tag := range tags {
+		// Pinned tags are listed even when unused
+		if tag.Count > 0 {
+			words = append(words, CloudWord{Tag: tag.Name, Count: tag.Count})
+		}
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Tag < words[j].Tag
+	})
+	if top > 0 && len(words) > top {
+		words = words[:top]
+	}
+	if len(words) == 0 {
+		return words, nil
+	}
+
+	highest, lowest := measure(words[0].Count), measure(words[len(words)-1].Count)
+	for i := range words {
+		words[i].Weight = maxCloudWeight
+		if highest > lowest {
+			fraction := (measure(words[i].Count) - lowest) / (highest - lowest)
+			words[i].Weight = 1 + int(math.Round(fraction*(maxCloudWeight-1)))
+		}
+	}
+	return words, nil
+}
+
+// Layout of the SVG clouds cloudSVG draws
+const (
+	cloudWidth       = 800
+	cloudMargin      = 10
+	cloudMinFontSize = 12
+	cloudMaxFontSize = 48
+)
+
+// cloudSVG returns words as a standalone SVG image, the tags in alphabetical
+// order wrapped in rows, each sized by its weight
+func cloudSVG(words []CloudWord) string {
+	sorted := append([]CloudWord(nil), words...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Tag < sorted[j].Tag
+	})
+
+	type placed struct {
+		word     CloudWord
+		x        float64
+		fontSize float64
+	}
+	var rows [][]placed
+	var heights []float64
+	x := float64(cloudMargin)
+	for _, word := range sorted {
+		fontSize := cloudMinFontSize + float64(word.Weight-1)*(cloudMaxFontSize-cloudMinFontSize)/(maxCloudWeight-1)
+		// Text isn't measured, so widths are estimated from the font size
+		width := 0.6 * fontSize * float64(utf8.RuneCountInString(word.Tag)+1)
+		if len(rows) == 0 || (x+width > cloudWidth-cloudMargin && len(rows[len(rows)-1]) > 0) {
+			rows = append(rows, nil)
+			heights = append(heights, 0)
+			x = cloudMargin
+		}
+		last := len(rows) - 1
+		rows[last] = append(rows[last], placed{word: word, x: x, fontSize: fontSize})
+		heights[last] = math.Max(heights[last], fontSize*1.2)
+		x += width + fontSize/2
+	}
+
+	var body strings.Builder
+	y := float64(cloudMargin)
+	for i, row := range rows {
+		y += heights[i]
+		for _, p := range row {
+			fmt.Fprintf(&body, "  <text x=\"%.0f\" y=\"%.0f\" font-size=\"%.0f\"><title>%d files</title>#%s</text>\n",
+				p.x, y, p.fontSize, p.word.Count, html.EscapeString(p.word.Tag))
+		}
+	}
+	height := y + cloudMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%.0f\" viewBox=\"0 0 %d %.0f\" font-family=\"sans-serif\">\n",
+		cloudWidth, height, cloudWidth, height)
+	b.WriteString(body.String())
+	b.WriteString("</svg>\n")
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTagCloud(t *testing.T) {
+	tags := []tagmanager.TagInfo{
+		{Name: "rust", Count: 1},
+		{Name: "golang", Count: 100},
+		{Name: "notes", Count: 10},
+		{Name: "pinned", Count: 0, Pinned: true},
+	}
+
+	tests := []struct {
+		name     string
+		tags     []tagmanager.TagInfo
+		scale    string
+		top      int
+		expected []tagmanager.CloudWord
+	}{
+		{
+			name:  "Log",
+			tags:  tags,
+			scale: tagmanager.CloudScaleLog,
+			expected: []tagmanager.CloudWord{
+				{Tag: "golang", Count: 100, Weight: 100},
+				{Tag: "notes", Count: 10, Weight: 51},
+				{Tag: "rust", Count: 1, Weight: 1},
+			},
+		},
+		{
+			name:  "Linear",
+			tags:  tags,
+			scale: tagmanager.CloudScaleLinear,
+			expected: []tagmanager.CloudWord{
+				{Tag: "golang", Count: 100, Weight: 100},
+				{Tag: "notes", Count: 10, Weight: 10},
+				{Tag: "rust", Count: 1, Weight: 1},
+			},
+		},
+		{
+			name:  "Top",
+			tags:  tags,
+			scale: tagmanager.CloudScaleLinear,
+			top:   2,
+			expected: []tagmanager.CloudWord{
+				{Tag: "golang", Count: 100, Weight: 100},
+				{Tag: "notes", Count: 10, Weight: 1},
+			},
+		},
+		{
+			name:  "EqualCounts",
+			tags:  []tagmanager.TagInfo{{Name: "b", Count: 3}, {Name: "a", Count: 3}},
+			scale: tagmanager.CloudScaleLog,
+			expected: []tagmanager.CloudWord{
+				{Tag: "a", Count: 3, Weight: 100},
+				{Tag: "b", Count: 3, Weight: 100},
+			},
+		},
+		{
+			name:     "Empty",
+			scale:    tagmanager.CloudScaleLog,
+			expected: []tagmanager.CloudWord{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			words, err := tagmanager.TagCloud(test.tags, test.scale, test.top)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, words)
+		})
+	}
+
+	t.Run("InvalidScale", func(t *testing.T) {
+		_, err := tagmanager.TagCloud(tags, "sqrt", 0)
+		assert.EqualError(t, err, `invalid scale "sqrt": must be log or linear`)
+	})
+}
+
+func TestCloudCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"a.md": "#golang #rust",
+		"b.md": "#golang",
+		"c.md": "#golang",
+	})
+
+	output, err := run(t, "cloud", "--root", root, "--scale", "linear", "--format", "csv")
+	require.NoError(t, err)
+	assert.Equal(t, "tag,count,weight\ngolang,3,100\nrust,1,1\n", output)
+
+	svg := filepath.Join(t.TempDir(), "cloud.svg")
+	output, err = run(t, "cloud", "--root", root, "--svg", svg)
+	require.NoError(t, err)
+	assertOutputContains(t, output, []string{"Found 2 tags:", "3 files, weight 100", "Cloud drawn to " + svg})
+
+	content, err := os.ReadFile(svg)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "<svg xmlns=\"http://www.w3.org/2000/svg\""))
+	assert.Contains(t, string(content), `font-size="48"><title>3 files</title>#golang</text>`)
+	assert.Contains(t, string(content), `font-size="12"><title>1 files</title>#rust</text>`)
+
+	_, err = run(t, "cloud", "--root", root, "--scale", "sqrt")
+	assert.EqualError(t, err, `invalid scale "sqrt": must be log or linear`)
+}
@@ -10,6 +10,6 @@ import (
 func main() {
 	if err := tagmanager.RunCmd(os.Args, nil); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(tagmanager.ExitCode(err))
 	}
 }
@@ -0,0 +1,101 @@
+package tagmanager
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// codeFenceOpen matches the line opening a fenced code block
+	codeFenceOpen = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	// inlineCodeOrURL matches the parts of a line in which '#' never starts a
+	// tag: inline code, URLs and the targets of Markdown links
+	inlineCodeOrURL = regexp.MustCompile("``[^`]+``|`[^`]+`|" +
+		`<?[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s<>]*>?|\]\([^)\s]*\)`)
+)
+
+// codeFences tracks whether the lines of a note are inside a fenced code
+// block
+type codeFences struct {
+	fence string
+}
+
+// inBlock reports whether line is part of a fenced code block, including the
+// fences themselves. Lines must be passed in order.
+func (f *codeFences) inBlock(line string) bool {
+	if f.fence != "" {
+		closing := strings.TrimSpace(line)
+		if len(closing) >= len(f.fence) && strings.Trim(closing, f.fence[:1]) == "" {
+			f.fence = ""
+		}
+		return true
+	}
+	if match := codeFenceOpen.FindStringSubmatch(line); match != nil {
+		f.fence = match[1]
+		return true
+	}
+	return false
+}
+
+// splitCode splits content into segments inside code or URLs, marked
+// ignored, and the prose outside them.
+func splitCode(content string) []ignoreSegment {
+	var segments []ignoreSegment
+	add := func(text string, ignored bool) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].ignored == ignored {
+			segments[n-1].text += text
+			return
+		}
+		segments = append(segments, ignoreSegment{text: text, ignored: ignored})
+	}
+
+	var fences codeFences
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if fences.inBlock(strings.TrimSuffix(line, "\n")) {
+			add(line, true)
+			continue
+		}
+		pos := 0
+		for _, match := range inlineCodeOrURL.FindAllStringIndex(line, -1) {
+			add(line[pos:match[0]], false)
+			add(line[match[0]:match[1]], true)
+			pos = match[1]
+		}
+		add(line[pos:], false)
+	}
+	return segments
+}
+
+// stripInlineCode returns a line without its inline code and URLs, each part
+// left separated by a space so no hashtag can span a removed part
+func stripInlineCode(line string) string {
+	return inlineCodeOrURL.ReplaceAllString(line, " ")
+}
+
+// stripCode returns content without its code blocks, inline code and URLs
+func stripCode(content string) string {
+	var parts []string
+	for _, segment := range splitCode(content) {
+		if !segment.ignored {
+			parts = append(parts, segment.text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// mapOutsideCode applies fn to the prose of content, leaving code blocks,
+// inline code and URLs untouched
+func mapOutsideCode(content string, fn func(string) string) string {
+	var b strings.Builder
+	for _, segment := range splitCode(content) {
+		if segment.ignored {
+			b.WriteString(segment.text)
+			continue
+		}
+		b.WriteString(fn(segment.text))
+	}
+	return b.String()
+}
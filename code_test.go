@@ -0,0 +1,89 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestCodeAndURLs(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name     string
+		content  string
+		replaced string
+	}{
+		{
+			name:     "FencedCodeBlock",
+			content:  "#old\n```bash\necho #old\n```\nafter #old\n",
+			replaced: "#new\n```bash\necho #old\n```\nafter #new\n",
+		},
+		{
+			name:     "TildeFenceWithLongerClose",
+			content:  "~~~\n#old\n~~~~\n#old\n",
+			replaced: "~~~\n#old\n~~~~\n#new\n",
+		},
+		{
+			name:     "InlineCode",
+			content:  "Use `#old` or ``x #old`` for #old\n",
+			replaced: "Use `#old` or ``x #old`` for #new\n",
+		},
+		{
+			name:     "URL",
+			content:  "See https://example.com/?q=1&#old and <https://example.com/#old> #old\n",
+			replaced: "See https://example.com/?q=1&#old and <https://example.com/#old> #new\n",
+		},
+		{
+			name:     "LinkTarget",
+			content:  "[heading](#old) #old\n",
+			replaced: "[heading](#old) #new\n",
+		},
+		{
+			name:     "UnclosedFenceRunsToEnd",
+			content:  "#old\n```\n#old\n",
+			replaced: "#new\n```\n#old\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, map[string]string{"note.md": test.content})
+			path := filepath.Join(root, "note.md")
+			manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+			require.NoError(t, err)
+
+			_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "old", NewTag: "new"}}, root, false)
+			require.NoError(t, err)
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, test.replaced, string(content))
+
+			// The scanner sees the tags replace renames, and no others
+			files, err := manager.FindFilesByTags(ctx, []string{"old"}, root)
+			require.NoError(t, err)
+			assert.Empty(t, files["old"])
+
+			scanner, err := tagmanager.NewFilesystemScanner(tagmanager.DefaultConfig())
+			require.NoError(t, err)
+			assert.Equal(t, []string{"new"}, scanner.ExtractTags(test.replaced))
+		})
+	}
+
+	t.Run("UpdateRemovesOnlyTags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"note.md": "---\ntags: [old, keep]\n---\nBody #old and `#old`\n```\n#old\n```\n"})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		result, err := manager.UpdateTags(ctx, nil, []string{"old"}, root, []string{"note.md"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+
+		content, err := os.ReadFile(filepath.Join(root, "note.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "---\ntags: [keep]\n---\nBody  and `#old`\n```\n#old\n```\n", string(content))
+	})
+}
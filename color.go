@@ -0,0 +1,80 @@
+package tagmanager
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI escapes for colored text output
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// palette colors text output when it is enabled, and leaves it plain
+// otherwise
+type palette struct {
+	enabled bool
+}
+
+// newPalette enables color when w is a terminal and the NO_COLOR
+// environment variable isn't set. noColor turns it off and force on,
+// either way.
+func newPalette(w io.Writer, force, noColor bool) palette {
+	switch {
+	case noColor:
+		return palette{}
+	case force:
+		return palette{enabled: true}
+	}
+	return palette{enabled: os.Getenv("NO_COLOR") == "" && isTerminal(w)}
+}
+
+// isTerminal reports whether w writes to a terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p palette) paint(color, text string) string {
+	if !p.enabled || text == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// tag colors a tag name, already padded to its column
+func (p palette) tag(text string) string {
+	return p.paint(ansiYellow, text)
+}
+
+// count colors a count, or another figure worth scanning for
+func (p palette) count(text string) string {
+	return p.paint(ansiBold, text)
+}
+
+// ok colors a success
+func (p palette) ok(text string) string {
+	return p.paint(ansiGreen, text)
+}
+
+// fail colors an error or failure
+func (p palette) fail(text string) string {
+	return p.paint(ansiRed, text)
+}
+
+// diff colors the lines of a unified diff
+func (p palette) diff(diff string) string {
+	if !p.enabled {
+		return diff
+	}
+	return colorizeDiff(diff)
+}
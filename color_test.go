@@ -0,0 +1,66 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestColorOutput(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md": "Notes on #golang",
+	})
+
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	t.Run("NotATerminal", func(t *testing.T) {
+		assert.NotContains(t, run(t, "list", "--root", root), "\x1b[")
+	})
+
+	t.Run("Forced", func(t *testing.T) {
+		plain := run(t, "list", "--root", root)
+		colored := run(t, "--color", "list", "--root", root)
+		assert.Contains(t, colored, "\x1b[33m#golang")
+		assert.Contains(t, colored, "\x1b[1m2\x1b[0m files")
+		// Coloring keeps the columns aligned
+		assert.Equal(t, plain, ansiEscape.ReplaceAllString(colored, ""))
+	})
+
+	t.Run("ForcedOverNoColorEnv", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		assert.Contains(t, run(t, "--color", "list", "--root", root), "\x1b[")
+	})
+
+	t.Run("NoColorWins", func(t *testing.T) {
+		assert.NotContains(t, run(t, "--color", "--no-color", "list", "--root", root), "\x1b[")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		output := run(t, "--color", "validate", "--tags", "golang,123")
+		assert.Contains(t, output, "\x1b[32mVALID\x1b[0m")
+		assert.Contains(t, output, "\x1b[31mINVALID\x1b[0m")
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		output := run(t, "--color", "replace", "--old", "golang", "--new", "go", "--root", root, "--dry-run")
+		assert.Contains(t, output, "\x1b[31m-Notes on #golang\x1b[0m")
+		assert.Contains(t, output, "\x1b[32m+Notes on #go\x1b[0m")
+	})
+
+	t.Run("MachineFormatsStayPlain", func(t *testing.T) {
+		assert.NotContains(t, run(t, "--color", "list", "--root", root, "--json"), "\x1b[")
+	})
+}
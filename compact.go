@@ -0,0 +1,58 @@
+package tagmanager
+
+import (
+	"path/filepath"
+)
+
+// CompactTag is a TagInfo with abbreviated field names and empty fields
+// omitted, returned by MCP tools called with compact=true
+type CompactTag struct {
+	Name  string `json:"n"`
+	Count int    `json:"c"`
+	// Files are relative to the root, and omitted by list_all_tags
+	Files    []string          `json:"f,omitempty"`
+	Pinned   bool              `json:"p,omitempty"`
+	Metadata map[string]string `json:"m,omitempty"`
+}
+
+// compactTags abbreviates tags, listing their files relative to rootPath
+// only when withFiles is set
+func compactTags(tags []TagInfo, rootPath string, withFiles bool) []CompactTag {
+	compact := make([]CompactTag, len(tags))
+	for i, tag := range tags {
+		compact[i] = CompactTag{
+			Name:     tag.Name,
+			Count:    tag.Count,
+			Pinned:   tag.Pinned,
+			Metadata: tag.Metadata,
+		}
+		if withFiles {
+			compact[i].Files = relativePaths(rootPath, tag.Files)
+		}
+	}
+	return compact
+}
+
+// compactFilesByTags drops tags without files and makes paths relative to
+// rootPath
+func compactFilesByTags(filesByTag map[string][]string, rootPath string) map[string][]string {
+	compact := make(map[string][]string)
+	for tag, files := range filesByTag {
+		if len(files) > 0 {
+			compact[tag] = relativePaths(rootPath, files)
+		}
+	}
+	return compact
+}
+
+func relativePaths(rootPath string, paths []string) []string {
+	relative := make([]string, len(paths))
+	for i, path := range paths {
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			relPath = path
+		}
+		relative[i] = filepath.ToSlash(relPath)
+	}
+	return relative
+}
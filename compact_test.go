@@ -0,0 +1,60 @@
+package tagmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestCompactResults(t *testing.T) {
+	ctx := context.Background()
+	req := &mcp.CallToolRequest{}
+	root := writeVault(t, map[string]string{
+		"a.md":       "#golang #python",
+		"notes/b.md": "#golang",
+		"notes/c.md": "nothing here",
+	})
+	config := tagmanager.DefaultConfig()
+	config.TagMetadata = map[string]map[string]string{"golang": {"color": "#00ADD8"}}
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	encode := func(t *testing.T, data any) string {
+		encoded, err := json.Marshal(data)
+		require.NoError(t, err)
+		return string(encoded)
+	}
+
+	t.Run("ListAllTags", func(t *testing.T) {
+		_, data, err := tagmanager.ListAllTagsTool(ctx, req, tagmanager.ListAllTagsParams{Root: root, Compact: true}, manager)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"n":"golang","c":2,"m":{"color":"#00ADD8"}},{"n":"python","c":1}]`, encode(t, data))
+	})
+
+	t.Run("GetTagsInfo", func(t *testing.T) {
+		_, data, err := tagmanager.GetTagsInfoTool(ctx, req, tagmanager.GetTagsInfoParams{
+			Tags: []string{"golang"}, Root: root, Compact: true,
+		}, manager)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"n":"golang","c":2,"f":["a.md","notes/b.md"],"m":{"color":"#00ADD8"}}]`, encode(t, data))
+	})
+
+	t.Run("FindFilesByTags", func(t *testing.T) {
+		_, data, err := tagmanager.FindFilesByTagsTool(ctx, req, tagmanager.FindFilesByTagsParams{
+			Tags: []string{"python", "missing"}, Root: root, Compact: true,
+		}, manager)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"python":["a.md"]}`, encode(t, data))
+	})
+
+	t.Run("DefaultIsVerbose", func(t *testing.T) {
+		_, data, err := tagmanager.ListAllTagsTool(ctx, req, tagmanager.ListAllTagsParams{Root: root}, manager)
+		require.NoError(t, err)
+		assert.Contains(t, encode(t, data), `"files"`)
+	})
+}
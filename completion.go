@@ -0,0 +1,505 @@
+package tagmanager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Completion kinds, what the value of a flag completes to
+const (
+	completeAny  = "any"
+	completeTag  = "tag"
+	completeDir  = "dir"
+	completeFile = "file"
+	// completeWords prefixes the fixed words a value is one of
+	completeWords = "words:"
+)
+
+// completionEntry is a command, or a command and its subcommand, which
+// shells complete. Flags are "name" for booleans and "name=kind" for flags
+// taking a value. Output is nil for commands without the output flags.
+type completionEntry struct {
+	path        string
+	description string
+	flags       []string
+	output      *outputFlags
+}
+
+var (
+	orderWords     = completeWords + "path mtime size path:desc mtime:desc size:desc"
+	preflightWords = completeWords + PreflightAbort + " " + PreflightSkip
+)
+
+// completionGlobalFlags are the flags given before the command
+var completionGlobalFlags = []string{"verbose", "quiet", "fail-if-empty", "dry-run", "config=file", "root=dir", "profile=any",
+	"fail-on-scan-error", "strict", "backup=words:tree sibling", "json-indent", "color", "no-color", "mcp", "http=any", "http-mcp",
+	"exclude-dir=any", "exclude-pattern=any", "no-default-excludes"}
+
+// completionCommands are the commands shells complete, keep them in sync with
+// the flags each command defines
+var completionCommands = []completionEntry{
+	{path: "find", description: "Find files containing specific tags", output: &outputFlags{tabular: true},
+		flags: []string{"tags=tag", "root=dir", "max-results=any", "not-tags=tag", "match=words:any all", "changed-since=any", "extensions=any", "order=" + orderWords, "print0",
+			"limit=any", "offset=any"}},
+	{path: "info", description: "Get detailed information about tags", output: &outputFlags{tabular: true},
+		flags: []string{"tags=tag", "root=dir", "changed-since=any", "extensions=any"}},
+	{path: "tree", description: "Show nested tags as a tree", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir", "depth=any"}},
+	{path: "cloud", description: "Weigh tags for a word cloud", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir", "min-count=any", "top=any", "scale=words:log linear", "svg=file"}},
+	{path: "export", description: "Dump every tag of every file", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir"}},
+	{path: "report", description: "Write a standalone HTML report to share", output: &outputFlags{},
+		flags: []string{"root=dir", "top=any", "html=file"}},
+	{path: "graph", description: "Export tag relationships as a diagram", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir", "kind=words:cooccurrence hierarchy", "diagram=words:dot mermaid", "top=any", "min-weight=any"}},
+	{path: "list", description: "List all tags with usage statistics", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir", "min-count=any", "pattern=any", "pinned-only", "changed-since=any", "extensions=any",
+			"sort=words:name count files", "order=words:asc desc", "limit=any", "offset=any"}},
+	{path: "replace", description: "Replace/rename tags across files", output: &outputFlags{},
+		flags: []string{"replacements=any", "old=tag", "new=tag", "root=dir", "files=file", "path-prefix=any", "dry-run", "apply", "transactional",
+			"stream", "output-patch=file", "preflight=" + preflightWords, "timeout=any", "resume", "max-modified=any",
+			"interactive"}},
+	{path: "update", description: "Add or remove tags from specific files", output: &outputFlags{},
+		flags: []string{"add=tag", "remove=tag", "files=file", "root=dir", "dry-run", "apply", "stream",
+			"output-patch=file", "preflight=" + preflightWords, "migrate=any", "timeout=any", "resume",
+			"max-modified=any", "ops=file"}},
+	{path: "untagged", description: "Find files without any tags", output: &outputFlags{tabular: true},
+		flags: []string{"root=dir", "changed-since=any", "extensions=any", "order=" + orderWords, "print0",
+			"path-prefix=any", "sort=words:path mtime size", "tagged-with=tag", "missing=tag", "limit=any", "offset=any"}},
+	{path: "validate", description: "Validate tag syntax and suggest fixes", output: &outputFlags{},
+		flags: []string{"tags=tag", "root=dir"}},
+	{path: "file-tags", description: "Get tags for specific files", output: &outputFlags{tabular: true},
+		flags: []string{"files=file", "root=dir"}},
+	{path: "audit", description: "Audit the vault"},
+	{path: "audit flat-tags", description: "Find flat tags which belong in a namespace", output: &outputFlags{},
+		flags: []string{"root=dir", "min-count=any", "threshold=any"}},
+	{path: "audit frontmatter", description: "Find files whose frontmatter tags are malformed", output: &outputFlags{},
+		flags: []string{"root=dir"}},
+	{path: "fix", description: "Repair what an audit finds"},
+	{path: "fix frontmatter", description: "Repair malformed frontmatter tags", output: &outputFlags{},
+		flags: []string{"root=dir", "dry-run", "apply"}},
+	{path: "autotag", description: "Tag files by the rules in the config", output: &outputFlags{},
+		flags: []string{"root=dir", "apply"}},
+	{path: "clean", description: "Remove or fix invalid tags", output: &outputFlags{},
+		flags: []string{"root=dir", "apply"}},
+	{path: "import", description: "Add and remove tags per file from a manifest", output: &outputFlags{},
+		flags: []string{"root=dir", "manifest=file", "manifest-format=words:csv json", "dry-run", "apply"}},
+	{path: "init", description: "Scan a vault and write a starter .tag-manager.yaml", output: &outputFlags{},
+		flags: []string{"root=dir", "yes", "force", "build-index"}},
+	{path: "index", description: "Maintain the persistent tag index"},
+	{path: "index build", description: "Build or update the index", output: &outputFlags{},
+		flags: []string{"root=dir"}},
+	{path: "index compact", description: "Rewrite the index without stale records", output: &outputFlags{},
+		flags: []string{"root=dir"}},
+	{path: "index inspect", description: "Show the records in the index", output: &outputFlags{},
+		flags: []string{"root=dir", "tag=tag", "file=file"}},
+	{path: "changes", description: "List files whose tags changed since a time", output: &outputFlags{},
+		flags: []string{"root=dir", "since=any"}},
+	{path: "history", description: "Show when tags were added, removed and moved in the git history", output: &outputFlags{},
+		flags: []string{"root=dir", "tags=tag"}},
+	{path: "triage", description: "Interactively tag untagged files",
+		flags: []string{"root=dir", "reset", "dry-run", "apply"}},
+	{path: "tui", description: "Browse tags and their files, renaming and merging tags in place",
+		flags: []string{"root=dir", "dry-run", "apply"}},
+	{path: "backup", description: "Manage backups made by --backup"},
+	{path: "backup prune", description: "Remove old backups", output: &outputFlags{},
+		flags: []string{"root=dir", "keep=any", "older-than=any"}},
+	{path: "undo", description: "Roll back a journaled replace or update", output: &outputFlags{},
+		flags: []string{"root=dir", "last", "op-id=any", "list", "dry-run", "apply"}},
+	{path: "selftest", description: "Try replace and update on a temporary copy of part of the vault", output: &outputFlags{},
+		flags: []string{"root=dir", "sample=any"}},
+	{path: "doctor", description: "Check the vault's health", output: &outputFlags{},
+		flags: []string{"root=dir"}},
+	{path: "version", description: "Print the version and build info", output: &outputFlags{}},
+	{path: "config", description: "Manage configuration files"},
+	{path: "config init", description: "Write the effective configuration with every option commented",
+		flags: []string{"path=file", "force"}},
+	{path: "config validate", description: "Report every problem of a config file with its location", output: &outputFlags{},
+		flags: []string{"path=file"}},
+	{path: "config show", description: "Print the effective configuration", output: &outputFlags{}},
+	{path: "completion", description: "Print a shell completion script"},
+	{path: "completion bash", description: "Print the bash completion script"},
+	{path: "completion zsh", description: "Print the zsh completion script"},
+	{path: "completion fish", description: "Print the fish completion script"},
+}
+
+// completionFlag is a flag and the kind of its value, empty for booleans
+type completionFlag struct {
+	name string
+	kind string
+}
+
+// flagsOf returns the flags of the command at path, "" for the global flags
+func flagsOf(path string) []completionFlag {
+	specs := completionGlobalFlags
+	if path != "" {
+		specs = nil
+		for _, command := range completionCommands {
+			if command.path != path {
+				continue
+			}
+			specs = command.flags
+			if command.output != nil {
+				formats := completeWords + strings.Join(command.output.names(), " ")
+				specs = append(append([]string(nil), specs...), "json", "format="+formats, "output="+formats, "template=any")
+			}
+		}
+	}
+
+	flags := make([]completionFlag, len(specs))
+	for i, spec := range specs {
+		name, kind, _ := strings.Cut(spec, "=")
+		flags[i] = completionFlag{name: name, kind: kind}
+	}
+	return flags
+}
+
+// subcommandsOf returns the subcommands of the command at path, or the
+// commands when path is ""
+func subcommandsOf(path string) []completionEntry {
+	var subcommands []completionEntry
+	for _, command := range completionCommands {
+		parent, _, found := strings.Cut(command.path, " ")
+		switch {
+		case path == "" && !found:
+			subcommands = append(subcommands, command)
+		case path != "" && found && parent == path:
+			subcommands = append(subcommands, command)
+		}
+	}
+	return subcommands
+}
+
+// completionPaths are every command path which takes flags, with "" for the
+// global flags first
+func completionPaths() []string {
+	paths := []string{""}
+	for _, command := range completionCommands {
+		if len(flagsOf(command.path)) > 0 {
+			paths = append(paths, command.path)
+		}
+	}
+	return paths
+}
+
+// writeCompletion writes the completion script of shell
+func writeCompletion(w io.Writer, shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = "# bash completion for tag-manager\n# Load with: source <(tag-manager completion bash)\n\n" +
+			shellCompletionHelpers() + bashCompletion
+	case "zsh":
+		script = "#compdef tag-manager\n# zsh completion for tag-manager\n# Load with: source <(tag-manager completion zsh)\n\n" +
+			shellCompletionHelpers() + zshCompletion
+	case "fish":
+		script = fishCompletion()
+	default:
+		return fmt.Errorf("invalid shell %q: must be bash, zsh or fish", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// shellCompletionHelpers are the functions the bash and zsh scripts share,
+// describing the commands and flags
+func shellCompletionHelpers() string {
+	var b strings.Builder
+
+	b.WriteString("# _tag_manager_subcommands prints the subcommands of a command, or the commands\n")
+	b.WriteString("_tag_manager_subcommands() {\n    case \"$1\" in\n")
+	for _, path := range append([]string{""}, parentPaths()...) {
+		var names []string
+		for _, command := range subcommandsOf(path) {
+			names = append(names, lastWord(command.path))
+		}
+		fmt.Fprintf(&b, "        %q) echo %q ;;\n", path, strings.Join(names, " "))
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("# _tag_manager_flags prints the flags of a command, with = after those taking a value\n")
+	b.WriteString("_tag_manager_flags() {\n    case \"$1\" in\n")
+	for _, path := range completionPaths() {
+		var words []string
+		for _, flag := range flagsOf(path) {
+			word := "--" + flag.name
+			if flag.kind != "" {
+				word += "="
+			}
+			words = append(words, word)
+		}
+		fmt.Fprintf(&b, "        %q) echo %q ;;\n", path, strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("# _tag_manager_value prints what the value of a command's flag completes to:\n")
+	b.WriteString("# tag, dir, file, any, or words: and the words to choose from\n")
+	b.WriteString("_tag_manager_value() {\n    case \"$1:$2\" in\n")
+	for _, path := range completionPaths() {
+		for _, flag := range flagsOf(path) {
+			if flag.kind != "" {
+				fmt.Fprintf(&b, "        %q) echo %q ;;\n", path+":"+flag.name, flag.kind)
+			}
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("# _tag_manager_name strips the dashes from a flag\n")
+	b.WriteString("_tag_manager_name() {\n    local name=\"${1#-}\"\n    echo \"${name#-}\"\n}\n\n")
+
+	// Parsing the command line is the same in both shells, which only
+	// differ in how they split it into words
+	b.WriteString(`# _tag_manager_parse sets cmd, sub, root and expect, the flag whose value
+# comes next, from the words before the one being completed
+_tag_manager_parse() {
+    local word name
+    for word in "$@"; do
+        if [[ -n "$expect" ]]; then
+            [[ "$expect" == root ]] && root="$word"
+            expect=""
+            continue
+        fi
+        case "$word" in
+            -*=*)
+                name="$(_tag_manager_name "${word%%=*}")"
+                [[ "$name" == root ]] && root="${word#*=}"
+                ;;
+            -*)
+                name="$(_tag_manager_name "$word")"
+                [[ -n "$(_tag_manager_value "$cmd${sub:+ $sub}" "$name")" ]] && expect="$name"
+                ;;
+            *)
+                if [[ -z "$cmd" ]]; then
+                    cmd="$word"
+                elif [[ -z "$sub" && -n "$(_tag_manager_subcommands "$cmd")" ]]; then
+                    sub="$word"
+                fi
+                ;;
+        esac
+    done
+    root="${root/#\~/$HOME}"
+}
+
+`)
+	return b.String()
+}
+
+// parentPaths are the commands which have subcommands
+func parentPaths() []string {
+	var paths []string
+	for _, command := range completionCommands {
+		if parent, _, found := strings.Cut(command.path, " "); found && (len(paths) == 0 || paths[len(paths)-1] != parent) {
+			paths = append(paths, parent)
+		}
+	}
+	return paths
+}
+
+func lastWord(path string) string {
+	return path[strings.LastIndex(path, " ")+1:]
+}
+
+const bashCompletion = `_tag_manager() {
+    local line="${COMP_LINE:0:COMP_POINT}" cur="" cmd="" sub="" root="" expect=""
+    local -a words
+    read -ra words <<< "$line"
+    if [[ "$line" != *[[:space:]] ]]; then
+        cur="${words[${#words[@]}-1]}"
+        unset 'words[${#words[@]}-1]'
+    fi
+    _tag_manager_parse "${words[@]:1}"
+    local cmdpath="$cmd${sub:+ $sub}" flag="" value="" prefix="" kind
+
+    if [[ "$cur" == -*=* ]]; then
+        flag="$(_tag_manager_name "${cur%%=*}")"
+        value="${cur#*=}"
+        # Without = in COMP_WORDBREAKS the reply replaces the whole --flag=value
+        [[ "$COMP_WORDBREAKS" == *=* ]] || prefix="${cur%%=*}="
+    elif [[ -n "$expect" ]]; then
+        flag="$expect"
+        value="$cur"
+    fi
+    if [[ -n "$flag" ]]; then
+        kind="$(_tag_manager_value "$cmdpath" "$flag")"
+        case "$kind" in
+            tag)
+                # Lists of tags complete the tag after the last comma
+                if [[ "$value" == *,* ]]; then
+                    prefix="$prefix${value%,*},"
+                    value="${value##*,}"
+                fi
+                COMPREPLY=($(compgen -P "$prefix" -W "$("${words[0]}" completion tags ${root:+--root="$root"} 2>/dev/null)" -- "$value"))
+                ;;
+            dir)
+                compopt -o filenames 2>/dev/null
+                COMPREPLY=($(compgen -P "$prefix" -d -- "$value"))
+                ;;
+            file)
+                compopt -o filenames 2>/dev/null
+                COMPREPLY=($(compgen -P "$prefix" -f -- "$value"))
+                ;;
+            words:*)
+                COMPREPLY=($(compgen -P "$prefix" -W "${kind#words:}" -- "$value"))
+                ;;
+        esac
+        return
+    fi
+
+    if [[ -z "$cmd" && "$cur" != -* ]]; then
+        COMPREPLY=($(compgen -W "$(_tag_manager_subcommands "")" -- "$cur"))
+    elif [[ -z "$sub" && "$cur" != -* && -n "$(_tag_manager_subcommands "$cmd")" ]]; then
+        COMPREPLY=($(compgen -W "$(_tag_manager_subcommands "$cmd")" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -W "$(_tag_manager_flags "$cmdpath")" -- "$cur"))
+        [[ "${COMPREPLY[0]}" == *= ]] && compopt -o nospace 2>/dev/null
+    fi
+}
+
+complete -F _tag_manager tag-manager
+`
+
+const zshCompletion = `_tag_manager() {
+    local cur="${words[CURRENT]}" cmd="" sub="" root="" expect="" cmdpath flag kind
+    local -a candidates
+    _tag_manager_parse "${(@)words[2,CURRENT-1]}"
+    cmdpath="$cmd${sub:+ $sub}"
+
+    if [[ "$cur" == -*=* || -n "$expect" ]]; then
+        if [[ -n "$expect" ]]; then
+            flag="$expect"
+        else
+            flag="$(_tag_manager_name "${cur%%=*}")"
+            compset -P '*='
+        fi
+        kind="$(_tag_manager_value "$cmdpath" "$flag")"
+        case "$kind" in
+            tag)
+                # Lists of tags complete the tag after the last comma
+                compset -P '*,'
+                candidates=(${(f)"$("${words[1]}" completion tags ${root:+--root=$root} 2>/dev/null)"})
+                compadd -- "${candidates[@]}"
+                ;;
+            dir) _files -/ ;;
+            file) _files ;;
+            words:*) compadd -- ${=kind#words:} ;;
+        esac
+        return
+    fi
+
+    if [[ -z "$cmd" && "$cur" != -* ]]; then
+        compadd -- ${=$(_tag_manager_subcommands "")}
+    elif [[ -z "$sub" && "$cur" != -* && -n "$(_tag_manager_subcommands "$cmd")" ]]; then
+        compadd -- ${=$(_tag_manager_subcommands "$cmd")}
+    else
+        candidates=(${=$(_tag_manager_flags "$cmdpath")})
+        compadd -S '' -- ${(M)candidates:#*=}
+        compadd -- ${candidates:#*=}
+    fi
+}
+
+compdef _tag_manager tag-manager
+`
+
+// fishCompletion returns the fish script, which declares each flag with
+// complete and finds the command being completed with __tag_manager_path
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString("# fish completion for tag-manager\n# Load with: tag-manager completion fish | source\n\n")
+
+	b.WriteString("# __tag_manager_value prints the kind of a command's flag value, nothing for booleans\n")
+	b.WriteString("function __tag_manager_value\n    switch \"$argv[1]:$argv[2]\"\n")
+	for _, path := range completionPaths() {
+		for _, flag := range flagsOf(path) {
+			if flag.kind != "" {
+				fmt.Fprintf(&b, "        case %s\n            echo %s\n", fishQuote(path+":"+flag.name), fishQuote(flag.kind))
+			}
+		}
+	}
+	b.WriteString("    end\nend\n\n")
+
+	fmt.Fprintf(&b, `# __tag_manager_path prints the command and subcommand on the command line
+function __tag_manager_path
+    set -l path
+    set -l expect 0
+    for token in (commandline -opc)[2..-1]
+        if test $expect = 1
+            set expect 0
+            continue
+        end
+        switch $token
+            case '-*=*'
+            case '-*'
+                set -l kind (__tag_manager_value "$path" (string replace -r -- '^--?' '' $token))
+                if test -n "$kind"
+                    set expect 1
+                end
+            case '*'
+                if test (count $path) -eq 0; or begin; test (count $path) -eq 1; and contains -- $path[1] %s; end
+                    set -a path $token
+                end
+        end
+    end
+    printf '%%s\n' $path
+end
+
+# __tag_manager_using succeeds when the command line is at the command path
+function __tag_manager_using
+    set -l path (__tag_manager_path)
+    test "$path" = "$argv"
+end
+
+# __tag_manager_tags prints the tags of the vault, after the tags already
+# listed before the last comma
+function __tag_manager_tags
+    set -l tokens (commandline -opc)
+    set -l value (string replace -r -- '^-[^=]*=' '' (commandline -ct))
+    set -l prefix (string match -r -- '^.*,' $value)
+    set -l args completion tags
+    for i in (seq (count $tokens))
+        switch $tokens[$i]
+            case '--root=*' '-root=*'
+                set -a args --root=(string replace -r -- '^[^=]*=' '' $tokens[$i])
+            case '--root' '-root'
+                set -q tokens[(math $i + 1)]; and set -a args --root=$tokens[(math $i + 1)]
+        end
+    end
+    $tokens[1] $args 2>/dev/null | string replace -r -- '^' "$prefix"
+end
+
+complete -c tag-manager -f
+`, strings.Join(parentPaths(), " "))
+
+	for _, path := range append([]string{""}, parentPaths()...) {
+		for _, command := range subcommandsOf(path) {
+			fmt.Fprintf(&b, "complete -c tag-manager -n %s -a %s -d %s\n",
+				fishQuote(strings.TrimSpace("__tag_manager_using "+path)), lastWord(command.path), fishQuote(command.description))
+		}
+	}
+	for _, path := range completionPaths() {
+		for _, flag := range flagsOf(path) {
+			line := fmt.Sprintf("complete -c tag-manager -n %s -l %s",
+				fishQuote(strings.TrimSpace("__tag_manager_using "+path)), flag.name)
+			switch {
+			case flag.kind == completeTag:
+				line += " -x -a '(__tag_manager_tags)'"
+			case flag.kind == completeDir:
+				line += " -x -a '(__fish_complete_directories)'"
+			case flag.kind == completeFile:
+				line += " -r -F"
+			case strings.HasPrefix(flag.kind, completeWords):
+				line += " -x -a " + fishQuote(strings.TrimPrefix(flag.kind, completeWords))
+			case flag.kind == completeAny:
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// fishQuote single quotes s for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,79 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestCompletion(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	tests := []struct {
+		shell    string
+		expected []string
+	}{
+		{
+			shell:    "bash",
+			expected: []string{"complete -F _tag_manager tag-manager", `"audit") echo "flat-tags frontmatter"`, `"find:tags") echo "tag"`},
+		},
+		{
+			shell:    "zsh",
+			expected: []string{"#compdef tag-manager", "compdef _tag_manager tag-manager", `"update:add") echo "tag"`},
+		},
+		{
+			shell:    "fish",
+			expected: []string{"complete -c tag-manager -n '__tag_manager_using' -a find", "-l tags -x -a '(__tag_manager_tags)'"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.shell, func(t *testing.T) {
+			script, err := run(t, "completion", test.shell)
+			require.NoError(t, err)
+			for _, expected := range test.expected {
+				assert.Contains(t, script, expected)
+			}
+		})
+	}
+
+	t.Run("InvalidShell", func(t *testing.T) {
+		_, err := run(t, "completion", "powershell")
+		assert.ErrorContains(t, err, `invalid shell "powershell": must be bash, zsh or fish`)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md": "---\ntags: [golang, python]\n---\nBody\n",
+			"b.md": "Notes on #golang",
+		})
+		output, err := run(t, "completion", "tags", "--root", root)
+		require.NoError(t, err)
+		assert.Equal(t, "golang\npython\n", output)
+	})
+
+	// Every flag the scripts complete must be one the command defines
+	t.Run("FlagsDefined", func(t *testing.T) {
+		script, err := run(t, "completion", "fish")
+		require.NoError(t, err)
+
+		flags := regexp.MustCompile(`-n '__tag_manager_using ?([a-z -]*)' -l ([a-z0-9-]+)`).FindAllStringSubmatch(script, -1)
+		require.NotEmpty(t, flags)
+		for _, match := range flags {
+			args := append(strings.Fields(match[1]), "--"+match[2]+"=1", "--zzz-undefined")
+			_, err := run(t, args...)
+			require.Error(t, err, args)
+			assert.NotContains(t, err.Error(), "provided but not defined: -"+match[2], args)
+		}
+	})
+}
@@ -1,7 +1,10 @@
 package tagmanager
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"regexp"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,23 +13,121 @@ type Config struct {
 	ExcludeDirs     []string `yaml:"exclude_dirs"`
 	ExcludePatterns []string `yaml:"exclude_patterns"`
 	HashtagPattern  string   `yaml:"hashtag_pattern"`
-	YAMLTagPattern  string   `yaml:"yaml_tag_pattern"`
-	YAMLListPattern string   `yaml:"yaml_list_pattern"`
 	MinTagLength    int      `yaml:"min_tag_length"`
 	MaxDigitRatio   float64  `yaml:"max_digit_ratio"`
 	ExcludeKeywords []string `yaml:"exclude_keywords"`
+	// Extensions are the extensions of the files scanned as notes; empty
+	// scans only md files
+	Extensions []string `yaml:"extensions"`
+	// ExcludeKeywordMode is how ExcludeKeywords match tags: substring (the
+	// default), word or anchored. See the KeywordMatch constants.
+	ExcludeKeywordMode string `yaml:"exclude_keyword_mode"`
+	UnicodeTags        bool   `yaml:"unicode_tags"`
+	// ProtectedTags are tags clean never removes or renames
+	ProtectedTags []string `yaml:"protected_tags"`
+	// TagCase is the casing convention validation holds tags to, such as
+	// kebab-case; see the TagCase constants. Empty accepts any case.
+	TagCase    string   `yaml:"tag_case"`
+	PinnedTags []string `yaml:"pinned_tags"`
+	// TagMetadata attaches display metadata such as color, icon or group to
+	// tags. It is passed through unchanged to list and info results.
+	TagMetadata map[string]map[string]string `yaml:"tag_metadata"`
+	// DefaultDryRun makes every mutating operation a dry run unless the caller
+	// explicitly passes --apply on the CLI or dry_run=false over MCP.
+	DefaultDryRun bool `yaml:"default_dry_run"`
+	// MaxReaderBytes bounds how much ExtractTagsFromReader reads; 0 is unlimited
+	MaxReaderBytes int64 `yaml:"max_reader_bytes"`
+	// FailOnScanError stops scans at the first unreadable file instead of
+	// skipping it
+	FailOnScanError bool `yaml:"fail_on_scan_error"`
+	// Strict fails instead of skipping files, scanning ambiguous frontmatter
+	// as content, truncating input or merging colliding tags. It implies
+	// FailOnScanError.
+	Strict bool `yaml:"strict"`
+	// CryptHooks decrypt and encrypt selected notes through external commands
+	CryptHooks []CryptHook `yaml:"crypt_hooks"`
+	// DetectPlugins enables integrations for the community plugins enabled in
+	// each vault's .obsidian settings
+	DetectPlugins bool `yaml:"detect_plugins"`
+	// Plugins lists plugin integrations to enable whether or not detected, by
+	// plugin id such as dataview
+	Plugins []string `yaml:"plugins"`
+	// Backup copies files before they are modified: "tree" or "sibling", see
+	// the Backup constants. Empty disables backups.
+	Backup string `yaml:"backup"`
+	// Journal records every change replace and update make in the vault's
+	// journal, so undo can roll them back
+	Journal bool `yaml:"journal"`
+	// Transactional makes ReplaceTagsBatch modify every file or none
+	Transactional bool `yaml:"transactional"`
+	// ChunkSize is the number of files replace and update modify between
+	// journal checkpoints and deadline checks. Zero checks only for
+	// cancellation, between every file.
+	ChunkSize int `yaml:"chunk_size"`
+	// MaxModified aborts a replace or update, before it modifies anything,
+	// which would modify more files than this. Zero is no limit.
+	MaxModified int `yaml:"max_modified"`
+	// Preflight checks every file replace and update target can be modified
+	// before modifying any: "abort" or "skip", see the Preflight constants.
+	// Empty disables the check.
+	Preflight string `yaml:"preflight"`
+	// FileMode is the octal permission bits of files tag-manager creates,
+	// such as "0664"; directories also get search permission. Modified
+	// notes keep their own permissions.
+	FileMode string `yaml:"file_mode"`
+	// IgnoreUmask gives created files exactly FileMode. By default the
+	// process umask clears bits of it, as for any created file.
+	IgnoreUmask bool `yaml:"ignore_umask"`
+	// TagsStyle is how updates write frontmatter tags: "array" or "list", see
+	// the TagsStyle constants. Empty keeps the style each note already uses.
+	TagsStyle string `yaml:"tags_style"`
+	// TagsPlacement is where updates add a tags property to frontmatter
+	// without one: "top", "after_title" or "alphabetical", see the
+	// TagsPlacement constants. Empty adds it after the last property.
+	TagsPlacement string `yaml:"tags_placement"`
+	// FrontmatterScaffold are keys, such as aliases, which updates add empty
+	// alongside tags when they create a note's frontmatter
+	FrontmatterScaffold []string `yaml:"frontmatter_scaffold"`
+	// EmptyFrontmatter is what updates do with a frontmatter block once its
+	// last tag is removed: "keep" or "prune", see the EmptyFrontmatter
+	// constants. Empty drops the block when no property is left in it.
+	EmptyFrontmatter string `yaml:"empty_frontmatter"`
+	// Migrate are glob patterns, such as project/*, of the top-of-file
+	// hashtags updates move to frontmatter. Empty migrates them all.
+	Migrate []string `yaml:"migrate"`
+	// Roots names vaults, by absolute path, which MCP tools accept as
+	// root_name instead of a root path
+	Roots map[string]string `yaml:"roots"`
+	// AutoTag are the rules autotag applies, tagging notes by their path
+	// and content
+	AutoTag []AutoTagRule `yaml:"autotag"`
+	// Profiles name vaults, each with a root and the options which differ
+	// for it, selected on the CLI with --profile
+	Profiles map[string]Profile `yaml:"profiles"`
+	// PreserveMtime keeps the modification time of the files updates modify
+	PreserveMtime bool `yaml:"preserve_mtime"`
+
+	// Deprecated: frontmatter is parsed as YAML; these patterns are ignored.
+	YAMLTagPattern  string `yaml:"yaml_tag_pattern"`
+	YAMLListPattern string `yaml:"yaml_list_pattern"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		ExcludeKeywords: []string{"bibr", "ftn", "issuecomment", "discussion", "diff-"},
-		YAMLListPattern: `(?m)^tags:\s*$\n((?:\s+-\s+.+\n?)+)`,
-		ExcludeDirs:     []string{"100 Archive", "Attachments", ".git"},
-		ExcludePatterns: []string{"*.excalidraw.md"},
-		YAMLTagPattern:  `(?m)^tags:\s*\[([^\]]+)\]`,
-		HashtagPattern:  `#[a-zA-Z][\w\-]*`,
-		MaxDigitRatio:   0.5,
-		MinTagLength:    3,
+		ExcludeKeywords:    []string{"bibr", "ftn", "issuecomment", "discussion", "diff-"},
+		ExcludeKeywordMode: KeywordMatchSubstring,
+		ExcludeDirs:        []string{"100 Archive", "Attachments", ".git"},
+		ExcludePatterns:    []string{"*.excalidraw.md"},
+		Extensions:         []string{"md"},
+		HashtagPattern:     `#\p{L}[\p{L}\p{M}\p{N}_\-/]*`,
+		MaxDigitRatio:      0.5,
+		MinTagLength:       3,
+		UnicodeTags:        true,
+		MaxReaderBytes:     16 << 20,
+		DetectPlugins:      true,
+		Journal:            true,
+		ChunkSize:          DefaultChunkSize,
+		FileMode:           "0644",
 	}
 }
 
@@ -47,3 +148,59 @@ func LoadConfig(path string) (*Config, error) {
 
 	return config, nil
 }
+
+// ConfigError is an invalid option of a configuration
+type ConfigError struct {
+	// Key is the YAML key of the option, such as backup
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateConfig checks every option of config, returning a *ConfigError for
+// each invalid one, joined with errors.Join
+func ValidateConfig(config *Config) error {
+	var errs []error
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, &ConfigError{Key: key, Err: err})
+		}
+	}
+
+	if _, err := regexp.Compile(config.HashtagPattern); err != nil {
+		check("hashtag_pattern", fmt.Errorf("invalid hashtag pattern: %w", err))
+	}
+	if err := validateKeywordMode(config.ExcludeKeywordMode); err != nil {
+		check("exclude_keyword_mode", err)
+	} else {
+		_, err := compileExcludeKeywords(config)
+		check("exclude_keywords", err)
+	}
+	check("extensions", validateExtensions(config.Extensions))
+	check("crypt_hooks", validateCryptHooks(config.CryptHooks))
+	check("backup", validateBackupMode(config.Backup))
+	check("tag_case", validateTagCase(config.TagCase))
+	check("tags_style", validateTagsStyle(config.TagsStyle))
+	check("tags_placement", validateTagsPlacement(config.TagsPlacement))
+	check("empty_frontmatter", validateEmptyFrontmatter(config.EmptyFrontmatter))
+	check("chunk_size", validateChunkSize(config.ChunkSize))
+	check("max_modified", validateMaxModified(config.MaxModified))
+	check("preflight", validatePreflightMode(config.Preflight))
+	check("file_mode", validateFileMode(config.FileMode))
+	check("migrate", validateMigratePatterns(config.Migrate))
+	check("roots", validateRoots(config.Roots))
+	check("autotag", validateAutoTagRules(config.AutoTag))
+	if joined, ok := validateProfiles(config).(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			check("profiles", err)
+		}
+	}
+	return errors.Join(errs...)
+}
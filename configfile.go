@@ -0,0 +1,270 @@
+package tagmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configComments document each configuration option in the files config init
+// writes, by YAML key
+var configComments = map[string]string{
+	"exclude_dirs":         "Directories which are never scanned, by name",
+	"exclude_patterns":     "File name patterns which are never scanned",
+	"extensions":           "Extensions of the files scanned as notes, such as md, mdx or txt",
+	"hashtag_pattern":      "Regular expression matching inline hashtags (advanced users only)",
+	"min_tag_length":       "Minimum characters in a tag",
+	"max_digit_ratio":      "Largest fraction of a tag which may be digits",
+	"exclude_keywords":     "Tags containing these keywords are ignored, such as footnote and diff anchors",
+	"exclude_keyword_mode": "How exclude_keywords match tags: substring, word or anchored",
+	"unicode_tags":         "Accept non-ASCII letters in tags; false only accepts ASCII letters and digits",
+	"protected_tags":       "Tags which clean never removes or renames",
+	"tag_case":             "Casing validate holds tags to: lowercase, kebab-case, snake_case, camelCase, or empty for any",
+	"pinned_tags":          "Tags always shown first by list, with their counts, even if unused",
+	"tag_metadata":         "Display metadata, such as color, icon or group, passed through to list and info results",
+	"default_dry_run":      "Make commands and MCP tools which modify files dry runs unless --apply or dry_run: false is passed",
+	"max_reader_bytes":     "Most bytes read when extracting tags from a stream; 0 is unlimited",
+	"fail_on_scan_error":   "Stop at the first file which can't be read instead of skipping it",
+	"strict":               "Fail on skipped files, ambiguous frontmatter, truncated input and tag collisions",
+	"crypt_hooks":          "Commands which decrypt and encrypt selected notes",
+	"detect_plugins":       "Enable integrations for the community plugins enabled in each vault",
+	"plugins":              "Plugin integrations always enabled, such as dataview",
+	"backup":               "Back up files before modifying them: tree, sibling, or empty for no backups",
+	"journal":              "Journal every change replace and update make, so undo can roll them back",
+	"transactional":        "Make replace modify every file or none",
+	"chunk_size":           "Files replace and update modify between journal checkpoints and deadline checks; 0 checks only for cancellation",
+	"max_modified":         "Abort replace and update before modifying anything if they would modify more files than this; 0 is no limit",
+	"preflight":            "Check every file replace and update target can be modified first: abort, skip, or empty",
+	"file_mode":            "Permissions of the files tag-manager creates, less the umask",
+	"ignore_umask":         "Give created files exactly file_mode",
+	"tags_style":           "How update writes frontmatter tags: array, list, or empty to keep each note's style",
+	"tags_placement":       "Where update adds a tags property: top, after_title, alphabetical, or empty for after the last property",
+	"frontmatter_scaffold": "Empty keys update adds with tags when it creates a note's frontmatter, such as aliases",
+	"empty_frontmatter":    "What update does with frontmatter once its last tag is removed: keep, prune, or empty to drop it when no property is left",
+	"migrate":              "Patterns of the top-of-file hashtags update migrates to frontmatter, such as project/*; empty migrates all",
+	"roots":                "Vaults MCP tools accept by name as root_name, mapped to absolute paths",
+	"autotag":              "Rules autotag applies: notes matching a path glob, a content regular expression or both get the rule's tags",
+	"profiles":             "Vaults selected with --profile, each with a root and the options which differ for it",
+	"preserve_mtime":       "Keep the modification time of the files replace and update modify",
+}
+
+// deprecatedConfigKeys are options which are still read but no longer have
+// any effect, so config init leaves them out
+var deprecatedConfigKeys = []string{"yaml_tag_pattern", "yaml_list_pattern"}
+
+// configNode returns config as a YAML mapping of every option in effect,
+// leaving out the deprecated ones
+func configNode(config *Config) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return nil, fmt.Errorf("YAML marshal error: %w", err)
+	}
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !slices.Contains(deprecatedConfigKeys, node.Content[i].Value) {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+	return &node, nil
+}
+
+func encodeConfigNode(node *yaml.Node) (string, error) {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return "", fmt.Errorf("YAML marshal error: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderConfig returns config as a YAML file listing every option with its
+// value and a comment describing it
+func renderConfig(config *Config) (string, error) {
+	node, err := configNode(config)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		node.Content[i].HeadComment = configComments[node.Content[i].Value]
+	}
+	body, err := encodeConfigNode(node)
+	if err != nil {
+		return "", err
+	}
+
+	// A blank line before each option's comment sets the options apart
+	body = strings.ReplaceAll(body, "\n# ", "\n\n# ")
+	return "# tag-manager configuration, written by tag-manager config init\n" +
+		"# Every option is listed with its current value; remove any to use the default.\n\n" + body, nil
+}
+
+// mergeConfigFile returns config with the options set in the file at path
+// layered on top, or config itself when there is no such file
+func mergeConfigFile(config *Config, path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoding into a copy made through YAML leaves the maps of config alone
+	base, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("YAML marshal error: %w", err)
+	}
+	merged := &Config{}
+	if err := yaml.Unmarshal(base, merged); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, merged); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return merged, nil
+}
+
+// configValue returns config as the generic value of its YAML, so JSON names
+// the options by their YAML keys
+func configValue(config *Config) (map[string]any, error) {
+	node, err := configNode(config)
+	if err != nil {
+		return nil, err
+	}
+	var value map[string]any
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// ConfigProblem is an invalid option, or a key which isn't an option, in a
+// config file
+type ConfigProblem struct {
+	// Line and Column locate the problem in the file, when it's known
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Key is the option, when the problem is an invalid value
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	// yamlLine splits the line number from the messages of YAML errors
+	yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// unknownField matches the YAML error for a key which isn't an option
+	unknownField = regexp.MustCompile(`field (\S+) not found in type`)
+)
+
+// checkConfigFile validates the config file at path with ValidateConfig,
+// and reports unknown keys and values of the wrong type, locating each
+// problem in the file
+func checkConfigFile(path string) ([]ConfigProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return []ConfigProblem{yamlProblem(err.Error())}, nil
+	}
+
+	var problems []ConfigProblem
+	config := DefaultConfig()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// Type errors don't stop decoding, so the rest of the options are
+	// still validated
+	var typeErr *yaml.TypeError
+	switch err := decoder.Decode(config); {
+	case errors.As(err, &typeErr):
+		for _, message := range typeErr.Errors {
+			problem := yamlProblem(message)
+			if match := unknownField.FindStringSubmatch(problem.Message); match != nil {
+				problem.Message = fmt.Sprintf("unknown option %q", match[1])
+			}
+			// Errors only have a line, which has the key of the option at
+			// the top level
+			if key := findConfigLine(&document, problem.Line); key != nil {
+				problem.Column = key.Column
+				if match := unknownField.FindStringSubmatch(message); match == nil {
+					problem.Key = key.Value
+				}
+			}
+			problems = append(problems, problem)
+		}
+	case err != nil && !errors.Is(err, io.EOF):
+		return []ConfigProblem{yamlProblem(err.Error())}, nil
+	}
+
+	var errs []error
+	if joined, ok := ValidateConfig(config).(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		problem := ConfigProblem{Message: err.Error()}
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			problem.Key = configErr.Key
+			if key := findConfigKey(&document, configErr.Key); key != nil {
+				problem.Line, problem.Column = key.Line, key.Column
+			}
+		}
+		problems = append(problems, problem)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+// yamlProblem turns the message of a YAML error into a problem on its line
+func yamlProblem(message string) ConfigProblem {
+	match := yamlLine.FindStringSubmatch(message)
+	if match == nil {
+		return ConfigProblem{Message: strings.TrimPrefix(message, "yaml: ")}
+	}
+	line, _ := strconv.Atoi(match[1])
+	return ConfigProblem{Line: line, Message: match[2]}
+}
+
+// findConfigKey returns the node of the top-level key in document, or nil
+func findConfigKey(document *yaml.Node, key string) *yaml.Node {
+	return findTopLevelKey(document, func(node *yaml.Node) bool { return node.Value == key })
+}
+
+// findConfigLine returns the node of the top-level key on line, or nil
+func findConfigLine(document *yaml.Node, line int) *yaml.Node {
+	return findTopLevelKey(document, func(node *yaml.Node) bool { return node.Line == line })
+}
+
+func findTopLevelKey(document *yaml.Node, match func(*yaml.Node) bool) *yaml.Node {
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	mapping := document.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if match(mapping.Content[i]) {
+			return mapping.Content[i]
+		}
+	}
+	return nil
+}
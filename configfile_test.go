@@ -0,0 +1,196 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestConfigInit(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	t.Run("EveryOptionCommented", func(t *testing.T) {
+		output, err := run(t, "config", "init", "--path", "-")
+		require.NoError(t, err)
+
+		lines := strings.Split(output, "\n")
+		key := regexp.MustCompile(`^[a-z_]+:`)
+		keys := 0
+		for i, line := range lines {
+			if key.MatchString(line) {
+				keys++
+				assert.True(t, strings.HasPrefix(lines[i-1], "# "), "%s has no comment", line)
+			}
+		}
+		assert.Greater(t, keys, 30)
+		assert.Contains(t, output, "min_tag_length: 3\n")
+		assert.NotContains(t, output, "yaml_tag_pattern")
+	})
+
+	t.Run("RoundTrips", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		_, err := run(t, "config", "init", "--path", path)
+		require.NoError(t, err)
+		written, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		output, err := run(t, "--config", path, "config", "init", "--path", "-")
+		require.NoError(t, err)
+		assert.Equal(t, string(written), output)
+	})
+
+	t.Run("EffectiveConfig", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("min_tag_length: 5\n"), 0644))
+
+		output, err := run(t, "--config", path, "--backup", "tree", "config", "init", "--path", "-")
+		require.NoError(t, err)
+		assert.Contains(t, output, "min_tag_length: 5\n")
+		assert.Contains(t, output, "backup: tree\n")
+		assert.Contains(t, output, "unicode_tags: true\n")
+	})
+
+	t.Run("ExistingFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("pinned_tags: [work]\n"), 0644))
+
+		_, err := run(t, "config", "init", "--path", path)
+		assert.ErrorContains(t, err, "already exists")
+
+		_, err = run(t, "config", "init", "--path", path, "--force")
+		require.NoError(t, err)
+		config, err := tagmanager.LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, config.PinnedTags)
+		assert.Equal(t, 3, config.MinTagLength)
+
+		written, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(written), "# Tags always shown first by list")
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	require.NoError(t, tagmanager.ValidateConfig(tagmanager.DefaultConfig()))
+
+	config := tagmanager.DefaultConfig()
+	config.Backup = "weekly"
+	config.ChunkSize = -1
+	err := tagmanager.ValidateConfig(config)
+	require.Error(t, err)
+
+	var keys []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var configErr *tagmanager.ConfigError
+		require.ErrorAs(t, err, &configErr)
+		keys = append(keys, configErr.Key)
+	}
+	assert.Equal(t, []string{"backup", "chunk_size"}, keys)
+
+	_, err = tagmanager.NewDefaultTagManager(config)
+	assert.ErrorContains(t, err, `invalid backup mode "weekly"`)
+}
+
+func TestConfigValidate(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		path := write(t, "min_tag_length: 2\nbackup: tree\n")
+		output, err := run(t, "config", "validate", "--path", path)
+		require.NoError(t, err)
+		assert.Contains(t, output, path+" is valid")
+	})
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "InvalidValues",
+			content:  "min_tag_length: 2\nbackup: weekly\n  # comment\npreflight: maybe\n",
+			expected: []string{":2:1: backup: invalid backup mode \"weekly\"", ":4:1: preflight: invalid preflight mode \"maybe\""},
+		},
+		{
+			name:     "UnknownOption",
+			content:  "exclude_dir: [Archive]\n",
+			expected: []string{`:1:1: unknown option "exclude_dir"`},
+		},
+		{
+			name:     "WrongType",
+			content:  "backup: tree\nmin_tag_length: three\n",
+			expected: []string{":2:1: min_tag_length: cannot unmarshal"},
+		},
+		{
+			name:     "Syntax",
+			content:  "exclude_dirs: [\n",
+			expected: []string{":1: did not find expected node content"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := write(t, test.content)
+			output, err := run(t, "config", "validate", "--path", path)
+			require.Error(t, err)
+			assert.Equal(t, tagmanager.ExitErrors, tagmanager.ExitCode(err))
+			assert.Len(t, strings.Split(strings.TrimSpace(output), "\n"), len(test.expected))
+			for _, expected := range test.expected {
+				assert.Contains(t, output, path+expected)
+			}
+		})
+	}
+
+	t.Run("ConfigFlag", func(t *testing.T) {
+		path := write(t, "min_tag_length: three\n")
+		output, err := run(t, "--config", path, "config", "validate", "--json")
+		require.Error(t, err)
+		assert.JSONEq(t, `[{"line":1,"column":1,"key":"min_tag_length","message":"cannot unmarshal !!str `+"`three`"+` into int"}]`, output)
+	})
+}
+
+func TestConfigShow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("exclude_dirs: [Private]\n"), 0644))
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "--config", path, "--strict", "config", "show"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "# Defaults, with "+path+" and flags applied\n")
+	assert.Contains(t, stdout.String(), "exclude_dirs:\n  - Private\n")
+	assert.Contains(t, stdout.String(), "strict: true\n")
+	assert.NotContains(t, stdout.String(), "yaml_tag_pattern")
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "--config", path, "config", "show", "--json"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	var config map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &config))
+	assert.Equal(t, []any{"Private"}, config["exclude_dirs"])
+	assert.Equal(t, float64(3), config["min_tag_length"])
+}
@@ -0,0 +1,107 @@
+package tagmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named vault, selected with --profile, and the options which
+// differ for it
+type Profile struct {
+	// Root is the absolute path of the vault, the default root of every
+	// command under the profile
+	Root string `yaml:"root,omitempty"`
+	// Options override the config's options, by YAML key
+	Options map[string]any `yaml:",inline"`
+}
+
+// applyProfile returns config with the options of the profile named name
+// layered on top, and the profile's root
+func applyProfile(config *Config, name string) (*Config, string, error) {
+	profile, ok := config.Profiles[name]
+	if !ok && len(config.Profiles) == 0 {
+		return nil, "", fmt.Errorf("unknown profile %q: the config has no profiles", name)
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("unknown profile %q: must be %s", name, joinOr(profileNames(config.Profiles)))
+	}
+	if _, ok := profile.Options["profiles"]; ok {
+		return nil, "", fmt.Errorf("profiles.%s: profiles cannot be nested", name)
+	}
+
+	// Decoding into a copy made through YAML leaves the maps of config alone
+	base, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, "", fmt.Errorf("YAML marshal error: %w", err)
+	}
+	merged := &Config{}
+	if err := yaml.Unmarshal(base, merged); err != nil {
+		return nil, "", err
+	}
+	if len(profile.Options) > 0 {
+		options, err := yaml.Marshal(profile.Options)
+		if err != nil {
+			return nil, "", fmt.Errorf("YAML marshal error: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(options))
+		decoder.KnownFields(true)
+		var typeErr *yaml.TypeError
+		switch err := decoder.Decode(merged); {
+		case errors.As(err, &typeErr):
+			// The lines of the errors are those of the re-encoded options,
+			// so only the message is kept
+			problem := yamlProblem(typeErr.Errors[0])
+			if match := unknownField.FindStringSubmatch(problem.Message); match != nil {
+				problem.Message = fmt.Sprintf("unknown option %q", match[1])
+			}
+			return nil, "", fmt.Errorf("profiles.%s: %s", name, problem.Message)
+		case err != nil && !errors.Is(err, io.EOF):
+			return nil, "", fmt.Errorf("profiles.%s: %w", name, err)
+		}
+	}
+	return merged, profile.Root, nil
+}
+
+// profileNames returns the names of profiles, sorted
+func profileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateProfiles reports profiles which can't be used, and the invalid
+// options of each
+func validateProfiles(config *Config) error {
+	var errs []error
+	for _, name := range profileNames(config.Profiles) {
+		profile := config.Profiles[name]
+		if name == "" {
+			errs = append(errs, fmt.Errorf("profiles: name cannot be empty"))
+			continue
+		}
+		if profile.Root != "" && !filepath.IsAbs(profile.Root) {
+			errs = append(errs, fmt.Errorf("profiles.%s: root %q must be absolute", name, profile.Root))
+		}
+		merged, _, err := applyProfile(config, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		merged.Profiles = nil
+		if joined, ok := ValidateConfig(merged).(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				errs = append(errs, fmt.Errorf("profiles.%s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
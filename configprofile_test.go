@@ -0,0 +1,109 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+	"gopkg.in/yaml.v3"
+)
+
+func TestProfiles(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	work := writeVault(t, map[string]string{"a.md": "#javascript #ab"})
+	home := writeVault(t, map[string]string{"b.md": "#personal"})
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	config := "profiles:\n" +
+		"  work:\n    root: " + work + "\n    min_tag_length: 2\n" +
+		"  home:\n    root: " + home + "\n"
+	require.NoError(t, os.WriteFile(configFile, []byte(config), 0644))
+
+	t.Run("RootAndOptions", func(t *testing.T) {
+		output, err := run(t, "--config", configFile, "--profile", "work", "list")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"#javascript", "#ab"})
+		assert.NotContains(t, output, "personal")
+
+		output, err = run(t, "--config", configFile, "--profile", "home", "list")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"#personal"})
+	})
+
+	t.Run("RootOverrides", func(t *testing.T) {
+		output, err := run(t, "--config", configFile, "--profile", "work", "--root", home, "list")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"#personal"})
+
+		output, err = run(t, "--config", configFile, "--profile", "work", "list", "--root", home)
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"#personal"})
+	})
+
+	t.Run("UnknownProfile", func(t *testing.T) {
+		_, err := run(t, "--config", configFile, "--profile", "play", "list")
+		assert.EqualError(t, err, `unknown profile "play": must be home or work`)
+
+		_, err = run(t, "--profile", "work", "list")
+		assert.EqualError(t, err, `unknown profile "work": the config has no profiles`)
+	})
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles string
+		expected []string
+	}{
+		{name: "Valid", profiles: "work:\n  root: /vaults/work\n  backup: tree\n"},
+		{name: "OptionsOnly", profiles: "strict:\n  strict: true\n"},
+		{
+			name:     "RelativeRoot",
+			profiles: "work:\n  root: vaults/work\n",
+			expected: []string{`profiles.work: root "vaults/work" must be absolute`},
+		},
+		{
+			name:     "UnknownOption",
+			profiles: "work:\n  min_tag_lenth: 2\n",
+			expected: []string{`profiles.work: unknown option "min_tag_lenth"`},
+		},
+		{
+			name:     "InvalidOption",
+			profiles: "home:\n  backup: nope\nwork:\n  preflight: maybe\n",
+			expected: []string{
+				`profiles.home: invalid backup mode "nope": must be tree or sibling`,
+				`profiles.work: invalid preflight mode "maybe"`,
+			},
+		},
+		{
+			name:     "Nested",
+			profiles: "work:\n  profiles:\n    home: {}\n",
+			expected: []string{"profiles.work: profiles cannot be nested"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			require.NoError(t, yaml.Unmarshal([]byte(test.profiles), &config.Profiles))
+
+			err := tagmanager.ValidateConfig(config)
+			if len(test.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, expected := range test.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ConsoleFile is a file a console query found, with the obsidian:// URI
+// which opens it
+type ConsoleFile struct {
+	// Path is relative to the root, with / separators
+	Path string `json:"path"`
+	URI  string `json:"uri"`
+}
+
+// ServeMCPHTTP serves the query console over HTTP on addr until interrupted,
+// as NewMCPHTTPHandler describes
+func ServeMCPHTTP(configPath, addr, root string, serveMCP bool) error {
+	handler, err := NewMCPHTTPHandler(configPath, root, serveMCP)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	if serveMCP {
+		_, _ = fmt.Fprintf(os.Stderr, "Serving MCP at http://%s/mcp\n", addr)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Serving the query console at http://%s/\n", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
+
+// NewMCPHTTPHandler returns a handler serving a query console for the vault
+// at root at /. The console only reads: it lists tags and finds files through
+// the MCP tools, linking each file to Obsidian. With serveMCP, the MCP server
+// is also served over streamable HTTP at /mcp, its tools limited to root and
+// the configured roots.
+func NewMCPHTTPHandler(configPath, root string, serveMCP bool) (http.Handler, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root: %w", err)
+	}
+	server, _, manager, err := newMCPServer(configPath, root)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	if serveMCP {
+		mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil))
+	}
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, consolePage)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, tags, err := ListAllTagsTool(r.Context(), nil, ListAllTagsParams{Root: root, MinCount: 1, Sort: TagSortName}, manager)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeConsoleJSON(w, tags)
+	})
+	mux.HandleFunc("GET /api/files", func(w http.ResponseWriter, r *http.Request) {
+		tags, notTags := parseConsoleQuery(r.URL.Query().Get("q"))
+		if len(tags) == 0 {
+			http.Error(w, "query needs at least one tag", http.StatusBadRequest)
+			return
+		}
+		_, paths, err := FindFilesByTagsTool(r.Context(), nil, FindFilesByTagsParams{
+			Tags: tags, NotTags: notTags, Root: root, Match: MatchAll, Order: OrderByPath, Compact: true,
+		}, manager)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files := []ConsoleFile{}
+		for _, path := range paths.([]string) {
+			files = append(files, ConsoleFile{Path: path, URI: obsidianURI(filepath.Base(root), path)})
+		}
+		writeConsoleJSON(w, files)
+	})
+	return mux, nil
+}
+
+// parseConsoleQuery splits a console query such as "project -archived" into
+// the tags files must have and, prefixed with -, the tags they must not
+func parseConsoleQuery(query string) (tags, notTags []string) {
+	for _, term := range strings.FieldsFunc(query, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if tag, ok := strings.CutPrefix(term, "-"); ok {
+			if tag != "" {
+				notTags = append(notTags, tag)
+			}
+			continue
+		}
+		tags = append(tags, term)
+	}
+	return tags, notTags
+}
+
+// obsidianURI returns the obsidian:// URI opening path in the named vault
+func obsidianURI(vault, path string) string {
+	// Obsidian decodes the values as URI components, which don't turn + into
+	// a space
+	escape := func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") }
+	return "obsidian://open?vault=" + escape(vault) + "&file=" + escape(path)
+}
+
+func writeConsoleJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+const consolePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Tag Manager</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 0; display: flex; height: 100vh; }
+aside { width: 16rem; overflow-y: auto; border-right: 1px solid #ddd; padding: 1rem; }
+main { flex: 1; overflow-y: auto; padding: 1rem; }
+input { width: 100%; box-sizing: border-box; padding: 0.5rem; font-size: 1rem; }
+ul { list-style: none; padding: 0; }
+li { padding: 0.2rem 0; }
+aside a { cursor: pointer; color: #7c3aed; }
+.count, .status { color: #888; }
+</style>
+</head>
+<body>
+<aside>
+<ul id="tags"></ul>
+</aside>
+<main>
+<form id="search"><input id="query" placeholder="Tags, such as project/alpha -archived" autofocus></form>
+<p class="status" id="status"></p>
+<ul id="files"></ul>
+</main>
+<script>
+const query = document.getElementById("query");
+const statusLine = document.getElementById("status");
+
+async function fetchJSON(url) {
+  const response = await fetch(url);
+  if (!response.ok) throw new Error(await response.text());
+  return response.json();
+}
+
+async function search() {
+  const files = document.getElementById("files");
+  files.replaceChildren();
+  try {
+    const found = await fetchJSON("api/files?q=" + encodeURIComponent(query.value));
+    statusLine.textContent = found.length + " files";
+    for (const file of found) {
+      const link = document.createElement("a");
+      link.href = file.uri;
+      link.textContent = file.path;
+      const item = document.createElement("li");
+      item.append(link);
+      files.append(item);
+    }
+  } catch (err) {
+    statusLine.textContent = err.message;
+  }
+}
+
+document.getElementById("search").addEventListener("submit", event => {
+  event.preventDefault();
+  search();
+});
+
+fetchJSON("api/tags").then(tags => {
+  const list = document.getElementById("tags");
+  for (const tag of tags) {
+    const link = document.createElement("a");
+    link.textContent = "#" + tag.name;
+    link.addEventListener("click", () => {
+      query.value = tag.name;
+      search();
+    });
+    const count = document.createElement("span");
+    count.className = "count";
+    count.textContent = " " + tag.count;
+    const item = document.createElement("li");
+    item.append(link, count);
+    list.append(item);
+  }
+}).catch(err => { statusLine.textContent = err.message; });
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,129 @@
+package tagmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestQueryConsole(t *testing.T) {
+	// The vault's folder name is the name Obsidian knows it by
+	root := filepath.Join(writeVault(t, map[string]string{
+		"My Vault/Projects/alpha plan.md": "#project #golang",
+		"My Vault/Projects/beta.md":       "#project #archived",
+		"My Vault/notes.md":               "#golang",
+	}), "My Vault")
+
+	handler, err := tagmanager.NewMCPHTTPHandler("", root, false)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(t *testing.T, path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("Page", func(t *testing.T) {
+		status, body := get(t, "/")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `<input id="query"`)
+
+		status, _ = get(t, "/missing")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		// MCP isn't served without serveMCP
+		status, _ = get(t, "/mcp")
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		status, body := get(t, "/api/tags")
+		require.Equal(t, http.StatusOK, status)
+
+		var tags []tagmanager.TagInfo
+		require.NoError(t, json.Unmarshal([]byte(body), &tags))
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		assert.Equal(t, []string{"archived", "golang", "project"}, names)
+	})
+
+	t.Run("Files", func(t *testing.T) {
+		status, body := get(t, "/api/files?q="+url.QueryEscape("project -archived"))
+		require.Equal(t, http.StatusOK, status)
+
+		var files []tagmanager.ConsoleFile
+		require.NoError(t, json.Unmarshal([]byte(body), &files))
+		assert.Equal(t, []tagmanager.ConsoleFile{{
+			Path: "Projects/alpha plan.md",
+			URI:  "obsidian://open?vault=My%20Vault&file=Projects%2Falpha%20plan.md",
+		}}, files)
+
+		status, _ = get(t, "/api/files?q=-archived")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("MCP", func(t *testing.T) {
+		handler, err := tagmanager.NewMCPHTTPHandler("", root, true)
+		require.NoError(t, err)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		ctx := context.Background()
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v1.0.0"}, nil).
+			Connect(ctx, mcp.NewStreamableClientTransport(server.URL+"/mcp", nil), nil)
+		require.NoError(t, err)
+		defer func() { _ = session.Close() }()
+
+		call := func(t *testing.T, name string, args map[string]any) *mcp.CallToolResult {
+			result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+			require.NoError(t, err)
+			return result
+		}
+
+		// Tools given no root work on the served root
+		result := call(t, "find_files_by_tags", map[string]any{"tags": []string{"golang"}, "compact": true})
+		assert.False(t, result.IsError)
+		result = call(t, "find_files_by_tags", map[string]any{"tags": []string{"golang"}, "root": root, "compact": true})
+		assert.False(t, result.IsError)
+
+		outside := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(outside, "note.md"), []byte("#golang"), 0644))
+		result = call(t, "find_files_by_tags", map[string]any{"tags": []string{"golang"}, "root": outside})
+		assert.True(t, result.IsError)
+		result = call(t, "update_tags", map[string]any{
+			"add_tags": []string{"pwned"}, "file_paths": []string{filepath.Join(outside, "note.md")},
+		})
+		assert.True(t, result.IsError)
+		content, err := os.ReadFile(filepath.Join(outside, "note.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#golang", string(content))
+		result = call(t, "get_files_tags", map[string]any{"file_paths": []string{"../escape.md"}})
+		assert.True(t, result.IsError)
+	})
+}
+
+func TestHTTPRequiresMCP(t *testing.T) {
+	err := tagmanager.RunCmd([]string{"tag-manager", "--http", "127.0.0.1:0"}, &tagmanager.RunCmdOptions{})
+	assert.ErrorContains(t, err, "--http requires -mcp")
+
+	err = tagmanager.RunCmd([]string{"tag-manager", "-mcp", "--http-mcp"}, &tagmanager.RunCmdOptions{})
+	assert.ErrorContains(t, err, "--http-mcp requires --http")
+}
@@ -0,0 +1,170 @@
+package tagmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CryptHook pipes the notes matching Pattern through external commands, so
+// encrypted notes are decrypted when read and encrypted again when written.
+// Content is passed to each command on stdin and read back from its stdout;
+// the plaintext is never written to disk.
+type CryptHook struct {
+	// Pattern is matched against the file name, like ExcludePatterns. Files
+	// it matches are scanned as notes whatever their extension.
+	Pattern string `yaml:"pattern"`
+	// Decrypt is the command and arguments which decrypt a note
+	Decrypt []string `yaml:"decrypt"`
+	// Encrypt is the command and arguments which encrypt a note. Without one,
+	// matching notes are scanned but never modified.
+	Encrypt []string `yaml:"encrypt,omitempty"`
+}
+
+// cryptHook returns the first hook whose pattern matches path, or nil
+func cryptHook(config *Config, path string) *CryptHook {
+	for i := range config.CryptHooks {
+		if matched, _ := filepath.Match(config.CryptHooks[i].Pattern, filepath.Base(path)); matched {
+			return &config.CryptHooks[i]
+		}
+	}
+	return nil
+}
+
+// ErrNoteChanged is returned when a note changed on disk between being read
+// and rewritten, typically because an editor saved it in the meantime
+var ErrNoteChanged = errors.New("file changed on disk since it was read")
+
+// noteChangedRetries is how many more times a rewrite is attempted when the
+// note changes on disk underneath it
+const noteChangedRetries = 2
+
+// readNote reads a note, decrypting it when a CryptHook matches
+func readNote(ctx context.Context, config *Config, path string) ([]byte, error) {
+	_, content, err := readStoredNote(ctx, config, path)
+	return content, err
+}
+
+// readStoredNote reads a note, returning both the bytes stored on disk, which
+// writeNote needs to detect concurrent changes, and the decrypted content
+func readStoredNote(ctx context.Context, config *Config, path string) (stored, content []byte, err error) {
+	stored, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err = decryptNote(ctx, config, path, stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stored, content, nil
+}
+
+// decryptNote decrypts content read from path when a CryptHook matches it
+func decryptNote(ctx context.Context, config *Config, path string, content []byte) ([]byte, error) {
+	hook := cryptHook(config, path)
+	if hook == nil {
+		return content, nil
+	}
+	return runCryptCommand(ctx, hook.Decrypt, content)
+}
+
+// writeNote writes a note read as stored, encrypting it first when a
+// CryptHook matches. It returns ErrNoteChanged, writing nothing, if the note
+// on disk no longer holds stored.
+func writeNote(ctx context.Context, config *Config, path string, stored, content []byte) error {
+	if hook := cryptHook(config, path); hook != nil {
+		if len(hook.Encrypt) == 0 {
+			return fmt.Errorf("no encrypt command configured for %s files", hook.Pattern)
+		}
+
+		encrypted, err := runCryptCommand(ctx, hook.Encrypt, content)
+		if err != nil {
+			return err
+		}
+		content = encrypted
+	}
+
+	// Checked as late as possible, after any slow encrypt command
+	if err := checkUnchanged(path, stored); err != nil {
+		return err
+	}
+	return overwriteFile(path, content, config.PreserveMtime)
+}
+
+// overwriteFile replaces the content of the existing file at path, keeping
+// its permission bits and, if keepMtime is set, its modification time. The
+// file is rewritten in place, so its owner and group are unchanged too.
+func overwriteFile(path string, content []byte, keepMtime bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	// Writing clears the setuid and setgid bits of files owned by others
+	mode := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	if keepMtime {
+		if err := os.Chtimes(path, time.Time{}, info.ModTime()); err != nil {
+			return err
+		}
+	}
+	if err := invalidateIndexed(path); err != nil {
+		return fmt.Errorf("failed to invalidate index: %w", err)
+	}
+	return nil
+}
+
+// checkUnchanged returns ErrNoteChanged if path no longer holds stored
+func checkUnchanged(path string, stored []byte) error {
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, stored) {
+		return ErrNoteChanged
+	}
+	return nil
+}
+
+func runCryptCommand(ctx context.Context, command []string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", command[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", command[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// validateCryptHooks reports hooks which can't be used
+func validateCryptHooks(hooks []CryptHook) error {
+	for i, hook := range hooks {
+		if hook.Pattern == "" {
+			return fmt.Errorf("crypt_hooks[%d]: pattern cannot be empty", i)
+		}
+		if _, err := filepath.Match(hook.Pattern, ""); err != nil {
+			return fmt.Errorf("crypt_hooks[%d]: invalid pattern %q: %w", i, hook.Pattern, err)
+		}
+		if len(hook.Decrypt) == 0 {
+			return fmt.Errorf("crypt_hooks[%d]: decrypt command cannot be empty", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+// rot13 stands in for a real cipher such as age or gpg
+var rot13Command = []string{"tr", "A-Za-z", "N-ZA-Mn-za-m"}
+
+func rot13(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, s)
+}
+
+func TestCryptHooks(t *testing.T) {
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr is not installed")
+	}
+
+	tempDir := t.TempDir()
+	secret := filepath.Join(tempDir, "secret.md")
+	require.NoError(t, os.WriteFile(secret, []byte(rot13("---\ntags: [private]\n---\n#journal entry\n")), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "plain.md"), []byte("#journal"), tagmanager.DefaultFilePermissions))
+
+	config := tagmanager.DefaultConfig()
+	config.CryptHooks = []tagmanager.CryptHook{{
+		Pattern: "secret*.md",
+		Decrypt: rot13Command,
+		Encrypt: rot13Command,
+	}}
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("Scan", func(t *testing.T) {
+		found, err := manager.FindFilesByTags(ctx, []string{"private", "journal"}, tempDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{secret}, found["private"])
+		assert.Len(t, found["journal"], 2)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		result, err := manager.UpdateTags(ctx, []string{"diary"}, []string{"private"}, tempDir, []string{"secret.md"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+
+		content, err := os.ReadFile(secret)
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "diary")
+		assert.Contains(t, rot13(string(content)), "tags: [diary]")
+		assert.NotContains(t, rot13(string(content)), "private")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		_, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "journal", NewTag: "logbook"}}, tempDir, false)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(secret)
+		require.NoError(t, err)
+		assert.Contains(t, rot13(string(content)), "#logbook entry")
+	})
+
+	t.Run("OtherExtension", func(t *testing.T) {
+		root := t.TempDir()
+		journal := filepath.Join(root, "journal.md.age")
+		require.NoError(t, os.WriteFile(journal, []byte(rot13("#diary")), tagmanager.DefaultFilePermissions))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "key.txt"), []byte("#diary"), tagmanager.DefaultFilePermissions))
+
+		config := tagmanager.DefaultConfig()
+		config.CryptHooks = []tagmanager.CryptHook{{Pattern: "*.age", Decrypt: rot13Command}}
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		found, err := manager.FindFilesByTags(ctx, []string{"diary"}, root)
+		require.NoError(t, err)
+		assert.Equal(t, []string{journal}, found["diary"])
+	})
+
+	t.Run("ReadOnlyWithoutEncrypt", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.CryptHooks = []tagmanager.CryptHook{{Pattern: "secret*.md", Decrypt: rot13Command}}
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		before, err := os.ReadFile(secret)
+		require.NoError(t, err)
+
+		result, err := manager.UpdateTags(ctx, []string{"extra"}, nil, tempDir, []string{"secret.md"}, false)
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0], "no encrypt command configured")
+
+		after, err := os.ReadFile(secret)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("DecryptFailure", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.CryptHooks = []tagmanager.CryptHook{{Pattern: "secret*.md", Decrypt: []string{"false"}}}
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		report := &tagmanager.ScanReport{}
+		_, err = manager.WithScanReport(report).ListAllTags(ctx, tempDir, 1)
+		require.NoError(t, err)
+
+		scanErrors := report.Errors()
+		require.Len(t, scanErrors, 1)
+		assert.Equal(t, secret, scanErrors[0].Path)
+		assert.Equal(t, tagmanager.ScanPhaseDecrypt, scanErrors[0].Phase)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		for _, hook := range []tagmanager.CryptHook{
+			{Pattern: "", Decrypt: rot13Command},
+			{Pattern: "[*.md", Decrypt: rot13Command},
+			{Pattern: "*.md"},
+		} {
+			config := tagmanager.DefaultConfig()
+			config.CryptHooks = []tagmanager.CryptHook{hook}
+
+			_, err := tagmanager.NewDefaultTagManager(config)
+			assert.ErrorContains(t, err, "crypt_hooks[0]")
+		}
+	})
+}
+
+func TestConcurrentModification(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	ctx := context.Background()
+
+	// editorSaves returns a manager whose first saves writes to note.md are
+	// each raced by an editor saving it
+	editorSaves := func(t *testing.T, root string, saves int) tagmanager.TagManager {
+		script := `n=$(cat "$0" 2>/dev/null || echo 0)
+if [ "$n" -lt "$1" ]; then echo $((n+1)) > "$0"; echo " #edited$n" >> "$2"; fi
+cat`
+		config := tagmanager.DefaultConfig()
+		config.CryptHooks = []tagmanager.CryptHook{{
+			Pattern: "note.md",
+			Decrypt: []string{"cat"},
+			Encrypt: []string{"sh", "-c", script, filepath.Join(t.TempDir(), "count"), strconv.Itoa(saves), filepath.Join(root, "note.md")},
+		}}
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+		return manager
+	}
+
+	readNote := func(t *testing.T, root string) string {
+		content, err := os.ReadFile(filepath.Join(root, "note.md"))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("ReplaceRetries", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"note.md": "#draft"})
+		manager := editorSaves(t, root, 1)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.FailedFiles)
+		assert.Equal(t, "#wip #edited0\n", readNote(t, root))
+	})
+
+	t.Run("UpdateRetries", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"note.md": "body"})
+		manager := editorSaves(t, root, 1)
+
+		result, err := manager.UpdateTags(ctx, []string{"golang"}, nil, root, []string{"note.md"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Contains(t, readNote(t, root), "- golang")
+		assert.Contains(t, readNote(t, root), "body #edited0")
+	})
+
+	t.Run("RefusesWhenAlwaysChanging", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"note.md": "#draft"})
+		manager := editorSaves(t, root, 100)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0], "changed on disk since it was read")
+
+		// Only the editor's saves are in the file
+		assert.Equal(t, "#draft #edited0\n #edited1\n #edited2\n", readNote(t, root))
+	})
+}
+
+func TestPreservesFileAttributes(t *testing.T) {
+	ctx := context.Background()
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, test := range []struct {
+		name          string
+		preserveMtime bool
+	}{
+		{name: "ModeOnly"},
+		{name: "ModeAndMtime", preserveMtime: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, map[string]string{"script.md": "#draft", "private.md": "#draft"})
+			require.NoError(t, os.Chmod(filepath.Join(root, "script.md"), 0755))
+			require.NoError(t, os.Chmod(filepath.Join(root, "private.md"), 0600))
+			for _, name := range []string{"script.md", "private.md"} {
+				require.NoError(t, os.Chtimes(filepath.Join(root, name), modified, modified))
+			}
+
+			config := tagmanager.DefaultConfig()
+			config.PreserveMtime = test.preserveMtime
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+			require.NoError(t, err)
+			require.Len(t, result.ModifiedFiles, 2)
+
+			for name, mode := range map[string]os.FileMode{"script.md": 0755, "private.md": 0600} {
+				info, err := os.Stat(filepath.Join(root, name))
+				require.NoError(t, err)
+				assert.Equal(t, mode, info.Mode().Perm(), name)
+				assert.Equal(t, test.preserveMtime, info.ModTime().Equal(modified), name)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package tagmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// noNewlineMarker follows a diff line which has no trailing newline
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits content after each newline, so joining the lines gives
+// back content exactly. The last line has no newline if content doesn't end
+// with one.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning a into b, using Myers'
+// algorithm so files with few changes are diffed in near linear time.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	trace = append(trace, v)
+
+	// Walk the trace backwards from the end to recover the edit script
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 2; d >= 0 && (x > 0 || y > 0); d-- {
+		prev := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && prev[offset+k-1] < prev[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: ' ', line: a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{kind: '+', line: b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{kind: '-', line: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{kind: ' ', line: a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff returns the changes from oldContent to newContent as a unified
+// diff with the given file names, or "" when they are the same.
+func unifiedDiff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// oldLine and newLine are the 1-based numbers of the next line in each file
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// A hunk starts diffContext lines before the change and runs until
+		// more than 2*diffContext unchanged lines separate it from the next
+		start := i
+		for start > 0 && i-start < diffContext && ops[start-1].kind == ' ' {
+			start--
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += min(run-end, diffContext)
+				break
+			}
+			end = run
+		}
+
+		hunkOld, hunkNew := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case ' ':
+				oldCount++
+				newCount++
+			case '-':
+				oldCount++
+			case '+':
+				newCount++
+			}
+			body.WriteByte(op.kind)
+			body.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				body.WriteString("\n" + noNewlineMarker)
+			}
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount))
+		b.WriteString(body.String())
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+
+	return b.String()
+}
+
+// hunkRange formats a hunk's start line and line count; an empty range
+// starts at the line before it, as in diff -u.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// reverseDiff undoes a unified diff made by unifiedDiff, turning the new
+// content back into the old. It fails if content doesn't match the diff.
+func reverseDiff(content, diff string) (string, error) {
+	lines := splitLines(content)
+	diffLines := splitLines(diff)
+
+	var out strings.Builder
+	pos := 0
+	for i := 0; i < len(diffLines); i++ {
+		header := diffLines[i]
+		if !strings.HasPrefix(header, "@@ ") {
+			continue
+		}
+
+		var oldStart, oldCount, newStart, newCount int
+		if err := parseHunkHeader(header, &oldStart, &oldCount, &newStart, &newCount); err != nil {
+			return "", err
+		}
+
+		// Lines are numbered from 1, and an empty range names the line before
+		start := newStart - 1
+		if newCount == 0 {
+			start = newStart
+		}
+		if start < pos || start > len(lines) {
+			return "", fmt.Errorf("diff does not match content at line %d", newStart)
+		}
+		for _, line := range lines[pos:start] {
+			out.WriteString(line)
+		}
+		pos = start
+
+		for i+1 < len(diffLines) && !strings.HasPrefix(diffLines[i+1], "@@ ") {
+			i++
+			line := diffLines[i]
+			if line == noNewlineMarker {
+				continue
+			}
+			kind, text := line[0], line[1:]
+			if i+1 < len(diffLines) && diffLines[i+1] == noNewlineMarker {
+				text = strings.TrimSuffix(text, "\n")
+			}
+
+			switch kind {
+			case ' ', '+':
+				if pos >= len(lines) || lines[pos] != text {
+					return "", fmt.Errorf("diff does not match content at line %d", pos+1)
+				}
+				pos++
+				if kind == ' ' {
+					out.WriteString(text)
+				}
+			case '-':
+				out.WriteString(text)
+			default:
+				return "", fmt.Errorf("invalid diff line %q", line)
+			}
+		}
+	}
+
+	for _, line := range lines[pos:] {
+		out.WriteString(line)
+	}
+	return out.String(), nil
+}
+
+func parseHunkHeader(header string, oldStart, oldCount, newStart, newCount *int) error {
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return fmt.Errorf("invalid hunk header %q", strings.TrimSpace(header))
+	}
+	if err := parseHunkRange(strings.TrimPrefix(fields[1], "-"), oldStart, oldCount); err != nil {
+		return fmt.Errorf("invalid hunk header %q: %w", strings.TrimSpace(header), err)
+	}
+	if err := parseHunkRange(strings.TrimPrefix(fields[2], "+"), newStart, newCount); err != nil {
+		return fmt.Errorf("invalid hunk header %q: %w", strings.TrimSpace(header), err)
+	}
+	return nil
+}
+
+func parseHunkRange(s string, start, count *int) error {
+	startText, countText, hasCount := strings.Cut(s, ",")
+	var err error
+	if *start, err = strconv.Atoi(startText); err != nil {
+		return err
+	}
+	*count = 1
+	if hasCount {
+		if *count, err = strconv.Atoi(countText); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dryRunDiff describes the change a dry run would make to the file at path,
+// given its content before and after
+func dryRunDiff(rootPath, path, before, after string) PlannedChange {
+	relPath, err := filepath.Rel(rootPath, path)
+	if err != nil {
+		relPath = path
+	}
+	relPath = filepath.ToSlash(relPath)
+	return PlannedChange{Path: relPath, Diff: unifiedDiff("a/"+relPath, "b/"+relPath, before, after)}
+}
+
+// writePatch writes changes to path as one patch, which git apply applies
+// from the vault root. Encrypted notes are diffed as plaintext, so a patch
+// can't change them.
+func writePatch(config *Config, rootPath, path string, changes []PlannedChange) error {
+	var patch strings.Builder
+	for _, change := range changes {
+		if cryptHook(config, filepath.Join(rootPath, filepath.FromSlash(change.Path))) != nil {
+			return fmt.Errorf("cannot write a patch modifying encrypted note %s", change.Path)
+		}
+		patch.WriteString(change.Diff)
+	}
+	if err := newFilePerm(config).writeFile(path, []byte(patch.String())); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	return nil
+}
+
+func sortPlannedChanges(changes []PlannedChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+}
+
+// colorizeDiff colors the lines of a unified diff the way git does
+func colorizeDiff(diff string) string {
+	var b strings.Builder
+	for _, line := range splitLines(diff) {
+		text := strings.TrimSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			color = ansiBold
+		case strings.HasPrefix(text, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(text, "-"):
+			color = ansiRed
+		case strings.HasPrefix(text, "+"):
+			color = ansiGreen
+		}
+		if color == "" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(color + text + ansiReset + line[len(text):])
+	}
+	return b.String()
+}
@@ -0,0 +1,289 @@
+package tagmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Checks run by Doctor, by DoctorCheck.ID
+const (
+	DoctorUnreadable  = "unreadable"
+	DoctorFrontmatter = "frontmatter"
+	DoctorCase        = "case"
+	DoctorInvalid     = "invalid"
+	DoctorEmpty       = "empty"
+	DoctorConfig      = "config"
+)
+
+// DoctorCheck is the outcome of one of the checks Doctor runs
+type DoctorCheck struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	// Items describe each problem found, prefixed with the file's path
+	Items []string `json:"items,omitempty"`
+	// Suggestion is what to do about the problems, when there are any
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// DoctorReport is the outcome of Doctor
+type DoctorReport struct {
+	Files  int           `json:"files"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// Problems returns the number of problems found by every check
+func (r *DoctorReport) Problems() int {
+	problems := 0
+	for _, check := range r.Checks {
+		problems += check.Count
+	}
+	return problems
+}
+
+// AddConfigFile adds the problems in the config file at path to the config
+// check, such as the file given with --config
+func (r *DoctorReport) AddConfigFile(path string) error {
+	problems, err := checkConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for i := range r.Checks {
+		if r.Checks[i].ID == DoctorConfig {
+			r.Checks[i].Items = append(configProblemItems(path, problems), r.Checks[i].Items...)
+			r.Checks[i].Count = len(r.Checks[i].Items)
+			r.Checks[i].Suggestion = doctorSuggestion(DoctorConfig, path, r.Checks[i].Count)
+		}
+	}
+	return nil
+}
+
+// Doctor checks the health of the vault at rootPath: files which can't be
+// read, malformed frontmatter, tags which differ only in case, frontmatter
+// tags the validator rejects, empty frontmatter blocks, and problems with the
+// vault's config file and tag rules. The vault is only read.
+func (m *DefaultTagManager) Doctor(ctx context.Context, rootPath string) (*DoctorReport, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	relPath := func(path string) string {
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return path
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	report := &DoctorReport{}
+	var unreadable, malformed, invalid, empty []string
+	// counts are the files using each tag
+	counts := make(map[string]int)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		var scanErr *ScanError
+		if err != nil && (!errors.As(err, &scanErr) || scanErr.Phase != ScanPhaseFrontmatter) {
+			if fileInfo.Path == "" {
+				unreadable = append(unreadable, err.Error())
+			} else {
+				unreadable = append(unreadable, fmt.Sprintf("%s: %v", relPath(fileInfo.Path), errors.Unwrap(err)))
+			}
+			continue
+		}
+		report.Files++
+
+		content, err := readNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			unreadable = append(unreadable, fmt.Sprintf("%s: %v", relPath(fileInfo.Path), err))
+			continue
+		}
+		text, _ := normalizeText(string(content))
+		if hasIgnoreFileDirective(text) {
+			continue
+		}
+
+		tags := fileInfo.Tags
+		if scanErr != nil {
+			// Strict scans fail on ambiguous frontmatter, which is reported
+			// below, rather than returning the file's tags
+			tags = m.scanner.ExtractTags(text)
+		}
+		for _, tag := range tags {
+			counts[tag]++
+		}
+
+		frontmatter, _, ok := splitFrontmatter(text)
+		if !ok {
+			continue
+		}
+		var nodes map[string]yaml.Node
+		if err := yaml.Unmarshal([]byte(frontmatter), &nodes); err != nil {
+			problem := diagnoseFrontmatter(relPath(fileInfo.Path), frontmatter)
+			item := problem.Path
+			if problem.Line > 0 {
+				item = fmt.Sprintf("%s:%d", problem.Path, problem.Line)
+			}
+			item += ": " + problem.Error
+			if problem.Hint != "" {
+				item += " (" + problem.Hint + ")"
+			}
+			malformed = append(malformed, item)
+			continue
+		}
+		if len(nodes) == 0 {
+			empty = append(empty, relPath(fileInfo.Path))
+			continue
+		}
+
+		data, _, _ := m.parseFrontmatter(text)
+		for _, tag := range frontmatterTags(data) {
+			if !m.rules.isValidTag(tag) {
+				invalid = append(invalid, fmt.Sprintf("%s: %s: %s", relPath(fileInfo.Path), tag, m.tagIssue(tag)))
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	configItems, err := m.configItems(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(id, name string, items []string, suggestion string) {
+		check := DoctorCheck{ID: id, Name: name, Count: len(items), Items: items}
+		if len(items) > 0 {
+			check.Suggestion = suggestion
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	sort.Strings(unreadable)
+	sort.Strings(malformed)
+	sort.Strings(invalid)
+	sort.Strings(empty)
+	caseItems, replacements := caseVariants(counts)
+
+	add(DoctorUnreadable, "Unreadable files", unreadable, doctorSuggestion(DoctorUnreadable, rootPath, len(unreadable)))
+	add(DoctorFrontmatter, "Malformed frontmatter", malformed, doctorSuggestion(DoctorFrontmatter, rootPath, len(malformed)))
+	add(DoctorCase, "Tags differing only in case", caseItems,
+		fmt.Sprintf("merge each into its most used spelling: tag-manager replace --replacements=%q --root=%q --dry-run",
+			strings.Join(replacements, ","), rootPath))
+	add(DoctorInvalid, "Invalid frontmatter tags", invalid, doctorSuggestion(DoctorInvalid, rootPath, len(invalid)))
+	add(DoctorEmpty, "Empty frontmatter blocks", empty, doctorSuggestion(DoctorEmpty, rootPath, len(empty)))
+	add(DoctorConfig, "Configuration", configItems, doctorSuggestion(DoctorConfig, filepath.Join(rootPath, VaultConfigFile), len(configItems)))
+	return report, nil
+}
+
+// tagIssue returns why the validator rejects tag, with its suggested fix
+func (m *DefaultTagManager) tagIssue(tag string) string {
+	result := m.validator.ValidateTag(tag)
+	if len(result.Issues) == 0 {
+		return "not accepted as a tag"
+	}
+	issue := strings.Join(result.Issues, "; ")
+	if len(result.Suggestions) > 0 {
+		issue += " (" + strings.Join(result.Suggestions, "; ") + ")"
+	}
+	return issue
+}
+
+// configItems returns the conflicts between tag rules and the problems in
+// the vault's config file, when it has one
+func (m *DefaultTagManager) configItems(rootPath string) ([]string, error) {
+	var items []string
+	path := filepath.Join(rootPath, VaultConfigFile)
+	if _, err := os.Stat(path); err == nil {
+		problems, err := checkConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		items = configProblemItems(VaultConfigFile, problems)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, conflict := range m.rules.Conflicts() {
+		items = append(items, conflict.String())
+	}
+	return items, nil
+}
+
+func configProblemItems(path string, problems []ConfigProblem) []string {
+	items := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		location := path
+		if problem.Line > 0 {
+			location += fmt.Sprintf(":%d", problem.Line)
+		}
+		message := problem.Message
+		if problem.Key != "" && !strings.HasPrefix(message, problem.Key) {
+			message = problem.Key + ": " + message
+		}
+		items = append(items, location+": "+message)
+	}
+	return items
+}
+
+// caseVariants returns the groups of tags in counts which differ only in
+// case, which Obsidian treats as one tag, along with replacements merging
+// each group into its most used spelling
+func caseVariants(counts map[string]int) ([]string, []string) {
+	spellings := make(map[string][]string)
+	for tag := range counts {
+		folded := strings.ToLower(tag)
+		spellings[folded] = append(spellings[folded], tag)
+	}
+
+	var items, replacements []string
+	for _, names := range spellings {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if counts[names[i]] != counts[names[j]] {
+				return counts[names[i]] > counts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		described := make([]string, len(names))
+		for i, name := range names {
+			described[i] = fmt.Sprintf("%s (%d files)", name, counts[name])
+			if i > 0 {
+				replacements = append(replacements, name+":"+names[0])
+			}
+		}
+		items = append(items, strings.Join(described, ", "))
+	}
+	sort.Strings(items)
+	sort.Strings(replacements)
+	return items, replacements
+}
+
+// doctorSuggestion returns what to do about count problems found by the
+// check id, for the vault or config file at path
+func doctorSuggestion(id, path string, count int) string {
+	if count == 0 {
+		return ""
+	}
+	switch id {
+	case DoctorUnreadable:
+		return "scans skip these files; check their permissions, or the crypt_hooks which decrypt them"
+	case DoctorFrontmatter:
+		return fmt.Sprintf("update skips these files; repair what can be repaired with: tag-manager fix frontmatter --root=%q --dry-run", path)
+	case DoctorInvalid:
+		return "scans ignore these tags; correct them in each note, or relax min_tag_length or max_digit_ratio in the config"
+	case DoctorEmpty:
+		return "delete the empty --- block at the top of each note"
+	case DoctorConfig:
+		return fmt.Sprintf("correct the config, then check it with: tag-manager config validate --path=%q", path)
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestDoctor(t *testing.T) {
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	t.Run("Healthy", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md": "---\ntags: [golang]\n---\nBody\n",
+			"b.md": "Notes on #golang",
+		})
+		report, err := manager.Doctor(context.Background(), root)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Files)
+		assert.Equal(t, 0, report.Problems())
+		assert.Len(t, report.Checks, 6)
+	})
+
+	root := writeVault(t, map[string]string{
+		"broken.md":                "---\ntitle: a: b\n---\nBody\n",
+		"empty.md":                 "---\n---\nBody\n",
+		"invalid.md":               "---\ntags: [golang, \"12\"]\n---\nBody\n",
+		"upper.md":                 "Notes on #Golang",
+		"ignored.md":               "<!-- tag-manager:ignore -->\n#GOLANG",
+		tagmanager.VaultConfigFile: "backup: weekly\n",
+	})
+
+	report, err := manager.Doctor(context.Background(), root)
+	require.NoError(t, err)
+
+	checks := make(map[string]tagmanager.DoctorCheck)
+	for _, check := range report.Checks {
+		checks[check.ID] = check
+	}
+
+	tests := []struct {
+		id         string
+		items      []string
+		suggestion string
+	}{
+		{id: tagmanager.DoctorUnreadable},
+		{
+			id:         tagmanager.DoctorFrontmatter,
+			items:      []string{"broken.md:2: mapping values are not allowed in this context (a value contains ': '; put the whole value in quotes)"},
+			suggestion: "tag-manager fix frontmatter",
+		},
+		{
+			id:         tagmanager.DoctorCase,
+			items:      []string{"Golang (1 files), golang (1 files)"},
+			suggestion: `--replacements="golang:Golang"`,
+		},
+		{
+			id:         tagmanager.DoctorInvalid,
+			items:      []string{"invalid.md: 12: Tag must be at least 3 characters long; Tag must start with a letter; Tag contains too many digits (100% digits, max allowed: 50%) (Consider: tag-12; Consider using more descriptive text instead of numbers)"},
+			suggestion: "scans ignore these tags",
+		},
+		{id: tagmanager.DoctorEmpty, items: []string{"empty.md"}, suggestion: "delete the empty"},
+		{
+			id:         tagmanager.DoctorConfig,
+			items:      []string{`.tag-manager.yaml:1: backup: invalid backup mode "weekly": must be tree or sibling`},
+			suggestion: "tag-manager config validate",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			check, ok := checks[test.id]
+			require.True(t, ok)
+			assert.Equal(t, len(test.items), check.Count)
+			assert.Equal(t, test.items, check.Items)
+			if test.suggestion == "" {
+				assert.Empty(t, check.Suggestion)
+			} else {
+				assert.Contains(t, check.Suggestion, test.suggestion)
+			}
+		})
+	}
+}
+
+func TestDoctorCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "Notes on #golang"})
+		output, err := run(t, "doctor", "--root", root)
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Checked 1 files", "[ OK ] Unreadable files", "No problems found"})
+	})
+
+	t.Run("Problems", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "---\n---\n#golang", "b.md": "#GoLang"})
+		output, err := run(t, "doctor", "--root", root)
+		require.Error(t, err)
+		assert.Equal(t, tagmanager.ExitErrors, tagmanager.ExitCode(err))
+		assertOutputContains(t, output, []string{
+			"[FAIL] Tags differing only in case: 1",
+			"GoLang (1 files), golang (1 files)",
+			"[FAIL] Empty frontmatter blocks: 1",
+			"-> delete the empty",
+			"Found 2 problems",
+		})
+	})
+
+	t.Run("ConfigFlag", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "Notes on #golang"})
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("exclude_dir: [Archive]\n"), 0644))
+
+		output, err := run(t, "--config", path, "doctor", "--root", root, "--json")
+		require.Error(t, err)
+		var report tagmanager.DoctorReport
+		require.NoError(t, json.Unmarshal([]byte(output), &report))
+		for _, check := range report.Checks {
+			if check.ID == tagmanager.DoctorConfig {
+				assert.Equal(t, []string{path + `:1: unknown option "exclude_dir"`}, check.Items)
+			}
+		}
+	})
+}
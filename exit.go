@@ -0,0 +1,98 @@
+package tagmanager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of the tag-manager command, from ExitCode
+const (
+	ExitOK = 0
+	// ExitUsage is for bad flags, a wrong vault path and any other failure
+	// before the operation ran
+	ExitUsage = 1
+	// ExitErrors is for an operation which ran but failed for some files,
+	// or stopped before finishing
+	ExitErrors = 2
+	// ExitEmpty is for a search which found nothing, with --fail-if-empty
+	ExitEmpty = 3
+)
+
+// ErrNoResults is returned, with --fail-if-empty, by a command which found
+// nothing
+var ErrNoResults = errors.New("no results found")
+
+// exitError carries the exit code for err
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err, exiting the command with code
+func withExitCode(code int, err error) error {
+	return &exitError{err: err, code: code}
+}
+
+// usageError is a refusal of what the command was asked to do, such as an
+// invalid vault path, made before the operation ran
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// failedOperation returns err, from an operation the command started,
+// exiting the command with ExitErrors unless the operation refused to run
+// because of its arguments
+func failedOperation(err error) error {
+	var usage *usageError
+	if err == nil || errors.As(err, &usage) {
+		return err
+	}
+	return withExitCode(ExitErrors, err)
+}
+
+// ExitCode returns the code the command should exit with for the error
+// RunCmd returned
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return ExitUsage
+}
+
+// failedFiles fails a run in which failures files failed, or nothing when
+// none did
+func failedFiles(failures int) error {
+	if failures == 0 {
+		return nil
+	}
+	return withExitCode(ExitErrors, fmt.Errorf("completed with %d errors", failures))
+}
+
+// checkEmpty fails with ErrNoResults when --fail-if-empty is set and a
+// command found no results
+func checkEmpty(cmdCtx *commandContext, results int) error {
+	if cmdCtx.failIfEmpty && results == 0 {
+		return withExitCode(ExitEmpty, ErrNoResults)
+	}
+	return nil
+}
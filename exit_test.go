@@ -0,0 +1,110 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestExitCode(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":     "#golang",
+		"plain.md": "---\ntags: [draft]\n---\nBody",
+	})
+	// Failures once the operation ran
+	failing := writeVault(t, map[string]string{
+		"a.md":      "#golang",
+		"b.md":      "#golang",
+		"broken.md": "---\ntags: [unclosed\n---\n#golang",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+		output   string
+	}{
+		{
+			name:     "Success",
+			args:     []string{"list", "--root", root},
+			expected: tagmanager.ExitOK,
+		},
+		{
+			name:     "BadFlag",
+			args:     []string{"list", "--no-such-flag"},
+			expected: tagmanager.ExitUsage,
+		},
+		{
+			name:     "WrongVaultPath",
+			args:     []string{"find", "--tags", "golang", "--root", root + "/missing"},
+			expected: tagmanager.ExitUsage,
+		},
+		{
+			name:     "NotFoundIsSuccessByDefault",
+			args:     []string{"find", "--tags", "python", "--root", root},
+			expected: tagmanager.ExitOK,
+		},
+		{
+			name:     "NotFound",
+			args:     []string{"--fail-if-empty", "find", "--tags", "python", "--root", root},
+			expected: tagmanager.ExitEmpty,
+		},
+		{
+			name:     "NotFoundStillWritesJSON",
+			args:     []string{"--fail-if-empty", "untagged", "--root", root, "--json"},
+			expected: tagmanager.ExitEmpty,
+			output:   "null\n",
+		},
+		{
+			name:     "Found",
+			args:     []string{"--fail-if-empty", "find", "--tags", "golang", "--root", root},
+			expected: tagmanager.ExitOK,
+		},
+		{
+			name:     "FailedFiles",
+			args:     []string{"update", "--add", "reviewed", "--files", "missing.md", "--root", root},
+			expected: tagmanager.ExitErrors,
+		},
+		{
+			name:     "WrongVaultPathForReplace",
+			args:     []string{"replace", "--old", "golang", "--new", "go", "--root", root + "/missing"},
+			expected: tagmanager.ExitUsage,
+		},
+		{
+			name:     "MaxModified",
+			args:     []string{"replace", "--old", "golang", "--new", "go", "--root", failing, "--max-modified", "1"},
+			expected: tagmanager.ExitErrors,
+		},
+		{
+			name:     "StrictScan",
+			args:     []string{"--strict", "find", "--tags", "golang", "--root", failing},
+			expected: tagmanager.ExitErrors,
+		},
+		{
+			name:     "StrictConflictIsUsage",
+			args:     []string{"--strict", "update", "--add", "golang", "--remove", "golang", "--files", "a.md", "--root", failing},
+			expected: tagmanager.ExitUsage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager"}, test.args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			assert.Equal(t, test.expected, tagmanager.ExitCode(err), "%v", err)
+			if test.output != "" {
+				assert.Equal(t, test.output, stdout.String())
+			}
+		})
+	}
+
+	t.Run("NoResults", func(t *testing.T) {
+		err := tagmanager.RunCmd([]string{"tag-manager", "--fail-if-empty", "list", "--root", root, "--min-count", "5"},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.True(t, errors.Is(err, tagmanager.ErrNoResults))
+	})
+}
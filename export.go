@@ -0,0 +1,132 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Where a tag is written in a file, for ExportEntry.Source
+const (
+	TagSourceFrontmatter = "frontmatter"
+	TagSourceBody        = "body"
+	TagSourceBoth        = "both"
+)
+
+// ExportEntry is a tag of a file in a TagExport
+type ExportEntry struct {
+	// Path is relative to the root, with / separators
+	Path   string `json:"path"`
+	Tag    string `json:"tag"`
+	Source string `json:"source"`
+	// Occurrences is how many times the tag is written in the file, and
+	// Lines the 1-based line of each. Tags written in a way replace can't
+	// rename, such as a tags value folded over several lines, have none.
+	Occurrences int   `json:"occurrences"`
+	Lines       []int `json:"lines"`
+}
+
+// TagExport is every tag of a vault and the files it is in, as a snapshot
+// for other tools
+type TagExport struct {
+	Root  string `json:"root"`
+	Files int    `json:"files"`
+	// Tags are the tags with the number of files they are in, most first
+	Tags    []TagCount    `json:"tags"`
+	Entries []ExportEntry `json:"entries"`
+}
+
+// ExportTags returns every tag under rootPath with each file it is in, where
+// in the file it is written and on which lines. Entries are sorted by path,
+// then tag.
+func (m *DefaultTagManager) ExportTags(ctx context.Context, rootPath string) (*TagExport, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	export := &TagExport{Root: rootPath, Tags: []TagCount{}, Entries: []ExportEntry{}}
+	counts := make(map[string]int)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		export.Files++
+		if len(fileInfo.Tags) == 0 {
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootPath, fileInfo.Path)
+		if err != nil {
+			continue
+		}
+		content, err := readNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			if err := m.scanFailed(&ScanError{Path: fileInfo.Path, Phase: ScanPhaseRead, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		text, _ := normalizeText(string(content))
+		inFrontmatter, inBody := m.tagPlacement(ctx, fileInfo.Path)
+
+		seen := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			normalized := m.normalizeTag(tag)
+			if seen[normalized] {
+				continue
+			}
+			seen[normalized] = true
+			counts[normalized]++
+
+			entry := ExportEntry{Path: filepath.ToSlash(relPath), Tag: normalized, Lines: m.tagLines(text, normalized)}
+			entry.Occurrences = len(entry.Lines)
+			// A tag found in neither is counted as in the body, as ProfileTags does
+			switch {
+			case inFrontmatter[normalized] && inBody[normalized]:
+				entry.Source = TagSourceBoth
+			case inFrontmatter[normalized]:
+				entry.Source = TagSourceFrontmatter
+			default:
+				entry.Source = TagSourceBody
+			}
+			export.Entries = append(export.Entries, entry)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	export.Tags = topTagCounts(counts, len(counts))
+	sort.Slice(export.Entries, func(i, j int) bool {
+		a, b := export.Entries[i], export.Entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Tag < b.Tag
+	})
+	return export, nil
+}
+
+// tagLines returns the line of each occurrence of tag in the normalized
+// content of a note, in order, finding them as replace does
+func (m *DefaultTagManager) tagLines(content, tag string) []int {
+	marked := m.replaceOccurrences(content, []TagReplacement{{OldTag: tag, NewTag: tag}}, func(n int, oldText, _ string) string {
+		return occurrenceStart + strconv.Itoa(n) + occurrenceText + oldText + occurrenceEnd
+	})
+	if !strings.Contains(marked, occurrenceStart) {
+		return []int{}
+	}
+
+	lines := []int{}
+	for _, occurrence := range locateOccurrences(marked) {
+		lines = append(lines, occurrence.Line)
+	}
+	sort.Ints(lines)
+	return lines
+}
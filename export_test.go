@@ -0,0 +1,85 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestExportTags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":     "---\ntags: [golang, notes]\n---\nLearning #golang today.\n\n```\n#golang in code\n```\nMore #golang and #project/alpha\n",
+		"sub/b.md": "---\ntags: golang\n---\nbody\n",
+		"c.md":     "No tags here",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	export, err := manager.ExportTags(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, root, export.Root)
+	assert.Equal(t, 3, export.Files)
+	assert.Equal(t, []tagmanager.TagCount{
+		{Tag: "golang", Count: 2}, {Tag: "notes", Count: 1}, {Tag: "project/alpha", Count: 1},
+	}, export.Tags)
+	assert.Equal(t, []tagmanager.ExportEntry{
+		// The hashtag in the code block isn't a tag
+		{Path: "a.md", Tag: "golang", Source: tagmanager.TagSourceBoth, Occurrences: 3, Lines: []int{2, 4, 9}},
+		{Path: "a.md", Tag: "notes", Source: tagmanager.TagSourceFrontmatter, Occurrences: 1, Lines: []int{2}},
+		{Path: "a.md", Tag: "project/alpha", Source: tagmanager.TagSourceBody, Occurrences: 1, Lines: []int{9}},
+		{Path: "sub/b.md", Tag: "golang", Source: tagmanager.TagSourceFrontmatter, Occurrences: 1, Lines: []int{2}},
+	}, export.Entries)
+}
+
+func TestExportCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"a.md": "---\ntags: [golang]\n---\n#golang and #rust\n#rust again\n",
+		"b.md": "Nothing",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "Text",
+			args: []string{"export"},
+			expected: "Exported 2 tags in 1 of 2 files:\n" +
+				"  a.md: #golang (both, lines 2, 4)\n" +
+				"  a.md: #rust (body, lines 4, 5)\n",
+		},
+		{
+			name: "CSV",
+			args: []string{"export", "--format", "csv"},
+			expected: "path,tag,source,occurrences,lines\n" +
+				"a.md,golang,both,2,2;4\n" +
+				"a.md,rust,body,2,4;5\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, append(test.args, "--root", root)...)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, output)
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		output, err := run(t, "export", "--root", root, "--json")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{`"files":2`, `"tags":[{"tag":"golang","count":1},{"tag":"rust","count":1}]`,
+			`{"path":"a.md","tag":"rust","source":"body","occurrences":2,"lines":[4,5]}`})
+	})
+}
@@ -0,0 +1,108 @@
+package tagmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// filePerm is how tag-manager creates the files it owns: indexes, journals,
+// backups, patches and configs. The zero filePerm creates files with
+// DefaultFilePermissions, less the umask.
+type filePerm struct {
+	mode os.FileMode
+	// exact ignores the umask, giving created files exactly mode
+	exact bool
+}
+
+// newFilePerm returns the filePerm of config, which validateFileMode accepted
+func newFilePerm(config *Config) filePerm {
+	mode, err := parseFileMode(config.FileMode)
+	if err != nil {
+		mode = DefaultFilePermissions
+	}
+	return filePerm{mode: mode, exact: config.IgnoreUmask}
+}
+
+// parseFileMode parses an octal file mode such as 0664; "" is the default
+func parseFileMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return DefaultFilePermissions, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file_mode %q: must be octal permission bits such as 0644", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+func validateFileMode(value string) error {
+	_, err := parseFileMode(value)
+	return err
+}
+
+func (p filePerm) fileMode() os.FileMode {
+	if p.mode == 0 {
+		return DefaultFilePermissions
+	}
+	return p.mode
+}
+
+// dirMode is the file mode with search permission wherever it grants read
+func (p filePerm) dirMode() os.FileMode {
+	mode := p.fileMode()
+	return mode | (mode&0444)>>2
+}
+
+// mkdirAll creates dir and any missing parents
+func (p filePerm) mkdirAll(dir string) error {
+	var created []string
+	for parent := dir; ; parent = filepath.Dir(parent) {
+		if _, err := os.Stat(parent); err == nil || filepath.Dir(parent) == parent {
+			break
+		}
+		created = append(created, parent)
+	}
+
+	if err := os.MkdirAll(dir, p.dirMode()); err != nil {
+		return err
+	}
+	if p.exact {
+		for _, path := range created {
+			if err := os.Chmod(path, p.dirMode()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile writes content to path, creating it with the file mode
+func (p filePerm) writeFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, p.fileMode()); err != nil {
+		return err
+	}
+	return p.chmod(path)
+}
+
+// openAppend opens path for appending, creating it with the file mode
+func (p filePerm) openAppend(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, p.fileMode())
+	if err != nil {
+		return nil, err
+	}
+	if err := p.chmod(path); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// chmod gives path exactly the file mode when the umask is ignored
+func (p filePerm) chmod(path string) error {
+	if !p.exact {
+		return nil
+	}
+	return os.Chmod(path, p.fileMode())
+}
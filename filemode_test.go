@@ -0,0 +1,59 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestFileMode(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ExactMode", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang"})
+		config := tagmanager.DefaultConfig()
+		config.FileMode = "0660"
+		config.IgnoreUmask = true
+		config.Backup = tagmanager.BackupTree
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		require.NoError(t, err)
+		_, err = manager.UpdateIndex(ctx, root)
+		require.NoError(t, err)
+
+		mode := func(path string) os.FileMode {
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			return info.Mode().Perm()
+		}
+		assert.Equal(t, os.FileMode(0770), mode(filepath.Join(root, tagmanager.IndexDir)))
+		assert.Equal(t, os.FileMode(0660), mode(filepath.Join(root, tagmanager.IndexDir, tagmanager.JournalFileName)))
+		assert.Equal(t, os.FileMode(0660), mode(filepath.Join(root, tagmanager.IndexDir, tagmanager.IndexFileName)))
+		assert.Equal(t, os.FileMode(0770), mode(result.Backup))
+
+		// The modified note keeps its own mode
+		assert.Equal(t, os.FileMode(tagmanager.DefaultFilePermissions), mode(filepath.Join(root, "a.md")))
+
+		// Compaction keeps the index's mode
+		_, err = manager.CompactIndex(ctx, root)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0660), mode(filepath.Join(root, tagmanager.IndexDir, tagmanager.IndexFileName)))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, value := range []string{"rw-r--r--", "0999", "01777"} {
+			config := tagmanager.DefaultConfig()
+			config.FileMode = value
+			_, err := tagmanager.NewDefaultTagManager(config)
+			require.Error(t, err, value)
+			assert.Contains(t, err.Error(), "invalid file_mode")
+		}
+	})
+}
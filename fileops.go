@@ -0,0 +1,94 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileTagOp is the tags to add and remove in one file, relative to the root
+type FileTagOp struct {
+	Path   string   `json:"path"`
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// UpdateTagsPerFile adds and removes a different set of tags in each file,
+// in one pass with one backup and journal entry, as UpdateTags does for a
+// single set. Every op is checked before any file is modified, so a conflict
+// or a file listed twice fails the whole call.
+func (m *DefaultTagManager) UpdateTagsPerFile(ctx context.Context, rootPath string, ops []FileTagOp, dryRun bool) (*TagUpdateResult, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	result := &TagUpdateResult{
+		FilesMigrated: make([]string, 0),
+		ModifiedFiles: make([]string, 0),
+		TagsRemoved:   make(map[string]int),
+		TagsAdded:     make(map[string]int),
+		Errors:        make([]string, 0),
+	}
+
+	resolved := make([]FileTagOp, 0, len(ops))
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		if op.Path == "" {
+			return nil, fmt.Errorf("operation without a path")
+		}
+		if seen[op.Path] {
+			return nil, fmt.Errorf("%s: listed in more than one operation", op.Path)
+		}
+		seen[op.Path] = true
+
+		add, remove := []string{}, []string{}
+		if len(op.Add) > 0 || len(op.Remove) > 0 {
+			var err error
+			if add, remove, err = m.resolveTagConflicts(op.Add, op.Remove); err != nil {
+				return nil, fmt.Errorf("%s: tag conflict resolution failed: %w", op.Path, err)
+			}
+		}
+
+		resolved = append(resolved, FileTagOp{Path: op.Path, Add: m.normalizeTags(add), Remove: remove})
+	}
+
+	if err := m.applyFileTagOps(ctx, rootPath, resolved, dryRun, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// opPaths returns the path of each op
+func opPaths(ops []FileTagOp) []string {
+	paths := make([]string, len(ops))
+	for i, op := range ops {
+		paths[i] = op.Path
+	}
+	return paths
+}
+
+// readFileTagOps reads a JSON array of FileTagOp from path, or from stdin
+// when path is "-"
+func readFileTagOps(path string, stdin io.Reader) ([]FileTagOp, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operations: %w", err)
+	}
+
+	var ops []FileTagOp
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("invalid operations in %s: %w", path, err)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operations in %s", path)
+	}
+	return ops, nil
+}
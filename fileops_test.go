@@ -0,0 +1,88 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestUpdateTagsPerFile(t *testing.T) {
+	ctx := context.Background()
+	note := "---\ntags: [golang, draft]\n---\nBody\n"
+
+	t.Run("DifferentTagsPerFile", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note, "b.md": note, "c.md": note})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		result, err := manager.UpdateTagsPerFile(ctx, root, []tagmanager.FileTagOp{
+			{Path: "a.md", Add: []string{"reviewed"}},
+			{Path: "b.md", Remove: []string{"draft"}},
+			{Path: "c.md", Add: []string{"python"}, Remove: []string{"golang"}},
+		}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Equal(t, []string{"a.md", "b.md", "c.md"}, result.ModifiedFiles)
+		assert.Equal(t, map[string]int{"reviewed": 1, "python": 1}, result.TagsAdded)
+		assert.Equal(t, map[string]int{"draft": 1, "golang": 1}, result.TagsRemoved)
+		assert.NotEmpty(t, result.Operation)
+
+		read := func(name string) string {
+			content, err := os.ReadFile(filepath.Join(root, name))
+			require.NoError(t, err)
+			return string(content)
+		}
+		assert.Equal(t, "---\ntags: [draft, golang, reviewed]\n---\nBody\n", read("a.md"))
+		assert.Equal(t, "---\ntags: [golang]\n---\nBody\n", read("b.md"))
+		assert.Equal(t, "---\ntags: [draft, python]\n---\nBody\n", read("c.md"))
+	})
+
+	t.Run("ChecksEveryOpFirst", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note, "b.md": note})
+		config := tagmanager.DefaultConfig()
+		config.Strict = true
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		_, err = manager.UpdateTagsPerFile(ctx, root, []tagmanager.FileTagOp{
+			{Path: "a.md", Add: []string{"reviewed"}},
+			{Path: "b.md", Add: []string{"draft"}, Remove: []string{"draft"}},
+		}, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "b.md")
+
+		_, err = manager.UpdateTagsPerFile(ctx, root, []tagmanager.FileTagOp{
+			{Path: "a.md", Add: []string{"reviewed"}},
+			{Path: "a.md", Remove: []string{"draft"}},
+		}, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "more than one operation")
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, note, string(content))
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note, "b.md": note})
+		ops := `[{"path": "a.md", "add": ["reviewed"]}, {"path": "b.md", "remove": ["draft"]}]`
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "update", "--ops", "-", "--root", root, "--dry-run"},
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(ops)})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{"+tags: [draft, golang, reviewed]", "+tags: [golang]", "Modified files: 2"})
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "update", "--ops", "-", "--add", "x", "--root", root},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(ops)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--ops cannot be combined")
+	})
+}
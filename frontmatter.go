@@ -0,0 +1,707 @@
+package tagmanager
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const utf8BOM = "\ufeff"
+
+// textFormat records the byte order mark and line endings of a file so they
+// can be restored after its normalized content has been edited.
+type textFormat struct {
+	bom  bool
+	crlf bool
+	// lines are the normalized lines of a file mixing CRLF and LF endings,
+	// and crlfLines which of them ended in CRLF
+	lines     []string
+	crlfLines []bool
+}
+
+// normalizeText strips a UTF-8 byte order mark and converts CRLF line endings
+// to LF, returning the format needed to restore them. A file mixing CRLF and
+// LF endings keeps the ending of each line.
+func normalizeText(content string) (string, textFormat) {
+	var format textFormat
+	if strings.HasPrefix(content, utf8BOM) {
+		format.bom = true
+		content = content[len(utf8BOM):]
+	}
+	if strings.Contains(content, "\r\n") {
+		raw := splitLines(content)
+		crlfLines := make([]bool, len(raw))
+		crlf, lf := 0, 0
+		for i, line := range raw {
+			switch {
+			case strings.HasSuffix(line, "\r\n"):
+				crlfLines[i] = true
+				crlf++
+			case strings.HasSuffix(line, "\n"):
+				lf++
+			}
+		}
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+		if lf == 0 {
+			format.crlf = true
+		} else {
+			format.lines, format.crlfLines = splitLines(content), crlfLines
+		}
+	}
+	return content, format
+}
+
+// restore converts normalized content back to the original file format
+func (f textFormat) restore(content string) string {
+	switch {
+	case f.lines != nil:
+		content = f.restoreLineEndings(content)
+	case f.crlf:
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if f.bom {
+		content = utf8BOM + content
+	}
+	return content
+}
+
+// restoreLineEndings gives each line of content the ending of the original
+// line it was kept from, so untouched lines are written back as they were. An
+// added line takes the ending of the original line before it.
+func (f textFormat) restoreLineEndings(content string) string {
+	var b strings.Builder
+	i := 0
+	for _, op := range diffLines(f.lines, splitLines(content)) {
+		var crlf bool
+		switch op.kind {
+		case '-':
+			i++
+			continue
+		case ' ':
+			crlf = f.crlfLines[i]
+			i++
+		case '+':
+			crlf = f.crlfLines[max(i-1, 0)]
+		}
+		line := op.line
+		if crlf && strings.HasSuffix(line, "\n") {
+			line = strings.TrimSuffix(line, "\n") + "\r\n"
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// splitFrontmatter separates a leading `---` delimited frontmatter block from
+// the rest of the content. ok is false when the content has no complete block.
+// The returned body is normalized as by normalizeText.
+func splitFrontmatter(content string) (frontmatter string, body string, ok bool) {
+	content, _ = normalizeText(content)
+	lines := strings.Split(content, "\n")
+	if len(lines) < 3 || lines[0] != "---" {
+		return "", content, false
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "---" {
+			return strings.Join(lines[1:i], "\n"), strings.Join(lines[i+1:], "\n"), true
+		}
+	}
+
+	return "", content, false
+}
+
+// frontmatterTagKeys are the frontmatter keys Obsidian reads tags from.
+// Updates always write tags back under the first one.
+var frontmatterTagKeys = []string{"tags", "tag"}
+
+// frontmatterTags returns the tags stored in parsed frontmatter, trimmed of
+// surrounding whitespace. Both list values and plain strings such as
+// `tags: golang, python` are accepted, and comma separated values exported by
+// other apps (`tags: ["one, two"]`) are split into individual tags.
+func frontmatterTags(data map[string]interface{}) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		tag = cleanTagValue(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	for _, key := range frontmatterTagKeys {
+		switch v := decodeFrontmatterValue(data[key]).(type) {
+		case string:
+			for _, tag := range splitTagString(v) {
+				add(tag)
+			}
+		case []interface{}:
+			for _, tag := range v {
+				if tagStr, ok := tag.(string); ok {
+					for _, part := range strings.Split(tagStr, ",") {
+						add(part)
+					}
+				}
+			}
+		case []string:
+			for _, tag := range v {
+				for _, part := range strings.Split(tag, ",") {
+					add(part)
+				}
+			}
+		}
+	}
+
+	return tags
+}
+
+// splitTagString splits a plain string tag value on commas and whitespace,
+// neither of which can appear inside a tag.
+func splitTagString(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// decodeFrontmatterValue returns the plain Go value of a frontmatter entry,
+// decoding entries which were kept as YAML nodes to preserve their formatting.
+func decodeFrontmatterValue(value interface{}) interface{} {
+	node, ok := value.(*yaml.Node)
+	if !ok {
+		return value
+	}
+
+	var decoded interface{}
+	if err := node.Decode(&decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// cleanTagValue strips the decorations Obsidian Properties may store around a
+// tag: surrounding whitespace, a leading '#', and [[link]] brackets.
+func cleanTagValue(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if strings.HasPrefix(tag, "[[") && strings.HasSuffix(tag, "]]") {
+		tag = strings.TrimSuffix(strings.TrimPrefix(tag, "[["), "]]")
+		// [[target|alias]] links to target
+		tag, _, _ = strings.Cut(tag, "|")
+		tag = strings.TrimSpace(tag)
+	}
+	return strings.TrimPrefix(tag, "#")
+}
+
+// Styles for Config.TagsStyle
+const (
+	// TagsStylePreserve writes tags in the style the note already uses
+	TagsStylePreserve = ""
+	// TagsStyleArray writes tags as an inline array: tags: [a, b]
+	TagsStyleArray = "array"
+	// TagsStyleList writes tags as a block list, one `- tag` per line
+	TagsStyleList = "list"
+)
+
+func validateTagsStyle(style string) error {
+	switch style {
+	case TagsStylePreserve, TagsStyleArray, TagsStyleList:
+		return nil
+	}
+	return fmt.Errorf("invalid tags style %q: must be %s or %s", style, TagsStyleArray, TagsStyleList)
+}
+
+// Placements for Config.TagsPlacement, where a tags property is added to
+// frontmatter which has none
+const (
+	// TagsPlacementEnd adds tags after the last property
+	TagsPlacementEnd = ""
+	// TagsPlacementTop adds tags before the first property
+	TagsPlacementTop = "top"
+	// TagsPlacementAfterTitle adds tags after the title property, or after
+	// the last property when there is no title
+	TagsPlacementAfterTitle = "after_title"
+	// TagsPlacementAlphabetical adds tags before the first property whose
+	// key sorts after it
+	TagsPlacementAlphabetical = "alphabetical"
+)
+
+func validateTagsPlacement(placement string) error {
+	switch placement {
+	case TagsPlacementEnd, TagsPlacementTop, TagsPlacementAfterTitle, TagsPlacementAlphabetical:
+		return nil
+	}
+	return fmt.Errorf("invalid tags placement %q: must be %s, %s or %s", placement, TagsPlacementTop, TagsPlacementAfterTitle, TagsPlacementAlphabetical)
+}
+
+// Policies for Config.EmptyFrontmatter, what happens to a frontmatter block
+// once an update removes its last tag
+const (
+	// EmptyFrontmatterRemove drops a block left without any property
+	EmptyFrontmatterRemove = ""
+	// EmptyFrontmatterKeep keeps the block, even when only `---` is left
+	EmptyFrontmatterKeep = "keep"
+	// EmptyFrontmatterPrune also drops a block whose remaining properties
+	// are all empty, such as `aliases:`, along with any comments in it
+	EmptyFrontmatterPrune = "prune"
+)
+
+func validateEmptyFrontmatter(policy string) error {
+	switch policy {
+	case EmptyFrontmatterRemove, EmptyFrontmatterKeep, EmptyFrontmatterPrune:
+		return nil
+	}
+	return fmt.Errorf("invalid empty frontmatter policy %q: must be %s or %s", policy, EmptyFrontmatterKeep, EmptyFrontmatterPrune)
+}
+
+// isEmptyValue reports whether a property value is null, "" or an empty
+// list or mapping
+func isEmptyValue(value *yaml.Node) bool {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return value.Tag == "!!null" || (value.Tag == "!!str" && value.Value == "")
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(value.Content) == 0
+	}
+	return false
+}
+
+// dropFrontmatter reports whether a block without tags is dropped under
+// Config.EmptyFrontmatter, given the values of its other properties and
+// whether it holds anything else, such as comments
+func dropFrontmatter(config *Config, values []*yaml.Node, blank bool) bool {
+	switch config.EmptyFrontmatter {
+	case EmptyFrontmatterKeep:
+		return false
+	case EmptyFrontmatterPrune:
+		for _, value := range values {
+			if !isEmptyValue(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return len(values) == 0 && blank
+}
+
+// tagsFormat is how a tags property is written
+type tagsFormat struct {
+	flow bool
+	// indent precedes the `- ` of each item of a block list
+	indent string
+	// quote is the quoting style of every tag, or 0 to quote only as needed
+	quote yaml.Style
+}
+
+// defaultTagsFormat is used for notes without tags
+var defaultTagsFormat = tagsFormat{indent: "    "}
+
+// detectTagsFormat returns the format of an existing tags value, read from
+// the frontmatter lines it was parsed from
+func detectTagsFormat(value *yaml.Node, lines []string) tagsFormat {
+	format := defaultTagsFormat
+	if value == nil || value.Kind != yaml.SequenceNode {
+		return format
+	}
+
+	format.flow = value.Style&yaml.FlowStyle != 0
+	if !format.flow && len(value.Content) > 0 && value.Content[0].Line <= len(lines) {
+		line := lines[value.Content[0].Line-1]
+		format.indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+	}
+
+	for i, item := range value.Content {
+		style := item.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+		if i > 0 && style != format.quote {
+			format.quote = 0
+			break
+		}
+		format.quote = style
+	}
+	return format
+}
+
+// renderTags returns the lines of a tags property holding tags
+func renderTags(tags []string, format tagsFormat) ([]string, error) {
+	items := make([]string, len(tags))
+	for i, tag := range tags {
+		data, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tag, Style: format.quote})
+		if err != nil {
+			return nil, fmt.Errorf("YAML marshal error: %w", err)
+		}
+		items[i] = strings.TrimSuffix(string(data), "\n")
+	}
+
+	if format.flow {
+		return []string{"tags: [" + strings.Join(items, ", ") + "]"}, nil
+	}
+	lines := []string{"tags:"}
+	for _, item := range items {
+		lines = append(lines, format.indent+"- "+item)
+	}
+	return lines, nil
+}
+
+// setFrontmatterTags returns the frontmatter block, including its `---`
+// delimiters, with its tags property set to tags, or removed when tags is
+// empty. Only the lines of the tags and tag properties are rewritten, so
+// every other property keeps its order, quoting and comments. A block left
+// without tags is dropped as Config.EmptyFrontmatter says. Tags are written in
+// Config.TagsStyle, or the style of the existing tags when it is
+// TagsStylePreserve. A new tags property goes where Config.TagsPlacement
+// says, and a new block also gets the empty Config.FrontmatterScaffold keys.
+func setFrontmatterTags(frontmatter string, tags []string, config *Config) (string, error) {
+	if frontmatter == "" && len(tags) > 0 {
+		var scaffold []string
+		for _, key := range config.FrontmatterScaffold {
+			if !slices.Contains(frontmatterTagKeys, key) {
+				scaffold = append(scaffold, key+":")
+			}
+		}
+		frontmatter = strings.Join(scaffold, "\n")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(frontmatter), &doc); err != nil {
+		return "", fmt.Errorf("YAML parse error: %w", err)
+	}
+
+	var lines []string
+	if frontmatter != "" {
+		lines = strings.Split(frontmatter, "\n")
+	}
+
+	var mapping *yaml.Node
+	if len(doc.Content) > 0 {
+		mapping = doc.Content[0]
+	}
+
+	var entry []string
+	if len(tags) > 0 {
+		var existing *yaml.Node
+		for _, key := range slices.Backward(frontmatterTagKeys) {
+			if value := mappingValue(mapping, key); value != nil {
+				existing = value
+			}
+		}
+
+		format := detectTagsFormat(existing, lines)
+		switch config.TagsStyle {
+		case TagsStyleArray:
+			format.flow = true
+		case TagsStyleList:
+			if format.flow {
+				format = tagsFormat{indent: defaultTagsFormat.indent, quote: format.quote}
+			}
+		}
+
+		var err error
+		if entry, err = renderTags(tags, format); err != nil {
+			return "", err
+		}
+	}
+
+	// spans holds the first and last+1 line of each property, found from
+	// where the next property starts
+	type span struct{ start, end int }
+	spans := make(map[string]span)
+	// values are those of the other properties
+	var values []*yaml.Node
+	// properties are the spans of the other properties, in order
+	type property struct {
+		key string
+		span
+	}
+	var properties []property
+	if mapping != nil && mapping.Kind == yaml.MappingNode {
+		if mapping.Style&yaml.FlowStyle != 0 {
+			return encodeFrontmatterTags(&doc, tags, config)
+		}
+
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key := mapping.Content[i]
+			if key.Column != 1 {
+				return encodeFrontmatterTags(&doc, tags, config)
+			}
+
+			end := len(lines)
+			if i+2 < len(mapping.Content) {
+				end = mapping.Content[i+2].Line - 1
+			}
+			// Comments and blank lines before the next property belong to it
+			for end > key.Line && isBlankOrComment(lines[end-1]) {
+				end--
+			}
+
+			if slices.Contains(frontmatterTagKeys, key.Value) {
+				spans[key.Value] = span{key.Line - 1, end}
+			} else {
+				values = append(values, mapping.Content[i+1])
+				properties = append(properties, property{key.Value, span{key.Line - 1, end}})
+			}
+		}
+	}
+
+	// Edits are made from the bottom up so earlier spans stay valid
+	type edit struct {
+		span
+		lines []string
+	}
+	var edits []edit
+	for _, key := range frontmatterTagKeys {
+		if s, ok := spans[key]; ok {
+			edits = append(edits, edit{span: s, lines: entry})
+			entry = nil
+		}
+	}
+	if entry != nil {
+		at := len(lines)
+		for at > 0 && strings.TrimSpace(lines[at-1]) == "" {
+			at--
+		}
+		switch config.TagsPlacement {
+		case TagsPlacementTop:
+			at = 0
+		case TagsPlacementAfterTitle:
+			for _, p := range properties {
+				if p.key == "title" {
+					at = p.end
+				}
+			}
+		case TagsPlacementAlphabetical:
+			// Tags go after the property before the first which sorts after
+			// them, so comments above that property stay with it
+			for i, p := range properties {
+				if strings.ToLower(p.key) > "tags" {
+					at = 0
+					if i > 0 {
+						at = properties[i-1].end
+					}
+					break
+				}
+			}
+		}
+		edits = append(edits, edit{span: span{at, at}, lines: entry})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		lines = append(lines[:e.start], append(append([]string(nil), e.lines...), lines[e.end:]...)...)
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(tags) == 0 && (mapping == nil || mapping.Kind == yaml.MappingNode) {
+		blank := strings.TrimSpace(text) == ""
+		if dropFrontmatter(config, values, blank) {
+			return "", nil
+		}
+		if blank {
+			return "---\n---\n", nil
+		}
+	}
+	return "---\n" + text + "\n---\n", nil
+}
+
+// encodeFrontmatterTags sets the tags of frontmatter which can't be edited
+// line by line, such as a {flow: mapping}, by re-encoding it
+func encodeFrontmatterTags(doc *yaml.Node, tags []string, config *Config) (string, error) {
+	mapping := doc.Content[0]
+	var content, values []*yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !slices.Contains(frontmatterTagKeys, mapping.Content[i].Value) {
+			content = append(content, mapping.Content[i], mapping.Content[i+1])
+			values = append(values, mapping.Content[i+1])
+		}
+	}
+	if len(tags) == 0 && dropFrontmatter(config, values, true) {
+		return "", nil
+	}
+	if len(tags) > 0 {
+		var value yaml.Node
+		if err := value.Encode(tags); err != nil {
+			return "", fmt.Errorf("YAML marshal error: %w", err)
+		}
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "tags"}, &value)
+	}
+	if len(content) == 0 {
+		return "---\n---\n", nil
+	}
+	mapping.Content = content
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("YAML marshal error: %w", err)
+	}
+	return "---\n" + string(data) + "---\n", nil
+}
+
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// tagValueSpan is where a tag is written in the frontmatter values the
+// scanner reads tags from, as byte offsets into the frontmatter
+type tagValueSpan struct {
+	start, end int
+	tag        string
+	// element is set when the span is a whole list element, quotes included,
+	// rather than a tag inside a longer value such as `golang, rust`
+	element bool
+}
+
+// frontmatterTagSpans returns where each tag of the tags and tag properties
+// is written in frontmatter, without its `---` delimiters, in order. Tags
+// are found as frontmatterTags reads them: list elements, comma separated
+// elements, plain string values, [[links]] and #tags. Values the spans can't
+// be found for, such as plain strings folded over several lines, are left out.
+func frontmatterTagSpans(frontmatter string) []tagValueSpan {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(frontmatter), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	lineStarts := []int{0}
+	for i, c := range frontmatter {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	var spans []tagValueSpan
+	for _, key := range frontmatterTagKeys {
+		value := mappingValue(doc.Content[0], key)
+		if value == nil {
+			continue
+		}
+		switch value.Kind {
+		case yaml.ScalarNode:
+			spans = append(spans, scalarTagSpans(frontmatter, lineStarts, value, splitTagString(value.Value))...)
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					continue
+				}
+				tag := strings.TrimSpace(item.Value)
+				start, end, ok := scalarSpan(frontmatter, lineStarts, item)
+				if ok && tag != "" && !strings.ContainsAny(tag, ",#[") {
+					spans = append(spans, tagValueSpan{start: start, end: end, tag: tag, element: true})
+					continue
+				}
+				spans = append(spans, scalarTagSpans(frontmatter, lineStarts, item, strings.Split(item.Value, ","))...)
+			}
+		}
+	}
+	return spans
+}
+
+// scalarTagSpans returns where each of the tags parts holds is written in the
+// scalar node
+func scalarTagSpans(frontmatter string, lineStarts []int, node *yaml.Node, parts []string) []tagValueSpan {
+	start, end, ok := scalarSpan(frontmatter, lineStarts, node)
+	if !ok {
+		return nil
+	}
+
+	var spans []tagValueSpan
+	pos := start
+	for _, part := range parts {
+		tag := cleanTagValue(part)
+		if tag == "" {
+			continue
+		}
+		// The tag is found as a whole word, so golang doesn't match inside
+		// golang/web, searching on from the previous tag
+		for i := pos; i < end; {
+			j := strings.Index(frontmatter[i:end], tag)
+			if j < 0 {
+				break
+			}
+			at := i + j
+			if (at == start || strings.ContainsRune(" \t,#[\"'", rune(frontmatter[at-1]))) &&
+				(at+len(tag) == end || strings.ContainsRune(" \t,]|\"'", rune(frontmatter[at+len(tag)]))) {
+				spans = append(spans, tagValueSpan{start: at, end: at + len(tag), tag: tag})
+				pos = at + len(tag)
+				break
+			}
+			i = at + 1
+		}
+	}
+	return spans
+}
+
+// scalarSpan returns the byte offsets of the text of a single line scalar
+// node in frontmatter, quotes included
+func scalarSpan(frontmatter string, lineStarts []int, node *yaml.Node) (int, int, bool) {
+	if node.Line < 1 || node.Line > len(lineStarts) {
+		return 0, 0, false
+	}
+	lineEnd := len(frontmatter)
+	if node.Line < len(lineStarts) {
+		lineEnd = lineStarts[node.Line] - 1
+	}
+	line := frontmatter[lineStarts[node.Line-1]:lineEnd]
+
+	// Columns count characters, not bytes
+	start, column := -1, node.Column
+	for i := range line {
+		if column--; column == 0 {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return 0, 0, false
+	}
+	text := line[start:]
+
+	end := -1
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+			} else if text[i] == '"' {
+				end = i + 1
+				break
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				end = i + 1
+				break
+			}
+		}
+	case 0:
+		if strings.HasPrefix(text, node.Value) {
+			end = len(node.Value)
+		}
+	}
+	if end < 0 {
+		return 0, 0, false
+	}
+	offset := lineStarts[node.Line-1] + start
+	return offset, offset + end, true
+}
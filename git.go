@@ -0,0 +1,251 @@
+package tagmanager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangedSinceFilter returns a FileFilter accepting only the files under
+// rootPath which differ from the git ref, including uncommitted changes and
+// untracked files which are not ignored. rootPath must be inside a git work tree.
+func ChangedSinceFilter(ctx context.Context, rootPath string, ref string) (FileFilter, error) {
+	changed, err := gitPaths(ctx, rootPath, "diff", "--name-only", "--relative", "-z", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", ref, err)
+	}
+
+	untracked, err := gitPaths(ctx, rootPath, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	files := make(map[string]bool, len(changed)+len(untracked))
+	for _, path := range append(changed, untracked...) {
+		files[path] = true
+	}
+
+	return func(relPath string) bool {
+		return files[relPath]
+	}, nil
+}
+
+// gitPaths runs git in dir and returns the NUL separated paths it prints
+func gitPaths(ctx context.Context, dir string, args ...string) ([]string, error) {
+	output, err := gitOutput(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range strings.Split(output, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// gitCommit is a commit walked by walkGitLog
+type gitCommit struct {
+	hash    string
+	time    time.Time
+	changes []gitChange
+}
+
+// gitChange is a file a commit changed. path is empty for a deleted file and
+// oldPath for an added one; a rename or copy has both, with the similarity of
+// the two files as its score.
+type gitChange struct {
+	status        byte
+	score         int
+	oldPath, path string
+}
+
+// walkGitLog calls fn with each commit changing files under dir, oldest
+// first, following the first parent of merges. Paths are relative to dir.
+func walkGitLog(ctx context.Context, dir string, fn func(gitCommit) error) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "-c", "core.quotePath=false", "log", "--reverse",
+		"--first-parent", "-m", "-M", "--name-status", "--relative", "--format=commit %H %ct", "--", ".")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run git: %w", err)
+	}
+
+	var commit *gitCommit
+	flush := func() error {
+		if commit == nil {
+			return nil
+		}
+		err := fn(*commit)
+		commit = nil
+		return err
+	}
+
+	var walkErr error
+	lines := bufio.NewScanner(stdout)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lines.Scan() && walkErr == nil {
+		line := lines.Text()
+		if header, ok := strings.CutPrefix(line, "commit "); ok {
+			if walkErr = flush(); walkErr != nil {
+				break
+			}
+			hash, seconds, _ := strings.Cut(header, " ")
+			unix, err := strconv.ParseInt(seconds, 10, 64)
+			if err != nil {
+				walkErr = fmt.Errorf("unexpected git log line %q", line)
+				break
+			}
+			commit = &gitCommit{hash: hash, time: time.Unix(unix, 0)}
+			continue
+		}
+		if line == "" || commit == nil {
+			continue
+		}
+
+		change, err := parseGitChange(line)
+		if err != nil {
+			walkErr = err
+			break
+		}
+		commit.changes = append(commit.changes, change)
+	}
+	if walkErr == nil {
+		walkErr = lines.Err()
+	}
+	if walkErr == nil {
+		walkErr = flush()
+	}
+
+	if walkErr != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return walkErr
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git log failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("git log failed: %w", err)
+	}
+	return nil
+}
+
+// parseGitChange parses a --name-status line, such as M<TAB>path or
+// R087<TAB>old<TAB>new
+func parseGitChange(line string) (gitChange, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 2 || fields[0] == "" {
+		return gitChange{}, fmt.Errorf("unexpected git log line %q", line)
+	}
+	for i := 1; i < len(fields); i++ {
+		if strings.HasPrefix(fields[i], `"`) {
+			unquoted, err := strconv.Unquote(fields[i])
+			if err != nil {
+				return gitChange{}, fmt.Errorf("unexpected git log path %s", fields[i])
+			}
+			fields[i] = unquoted
+		}
+	}
+
+	change := gitChange{status: fields[0][0]}
+	switch change.status {
+	case 'R', 'C':
+		if len(fields) < 3 {
+			return gitChange{}, fmt.Errorf("unexpected git log line %q", line)
+		}
+		change.score, _ = strconv.Atoi(fields[0][1:])
+		change.path = fields[2]
+		// A copy leaves the original in place
+		if change.status == 'R' {
+			change.oldPath = fields[1]
+		}
+	case 'D':
+		change.oldPath = fields[1]
+	default:
+		change.oldPath, change.path = fields[1], fields[1]
+	}
+	return change, nil
+}
+
+// gitBlobReader reads files at commits through one git cat-file process
+type gitBlobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func startGitBlobReader(ctx context.Context, dir string) (*gitBlobReader, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run git: %w", err)
+	}
+	return &gitBlobReader{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// read returns the content of object, such as <commit>:<path from the top of
+// the work tree>
+func (r *gitBlobReader) read(object string) ([]byte, error) {
+	if _, err := fmt.Fprintln(r.stdin, object); err != nil {
+		return nil, err
+	}
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 || fields[1] != "blob" {
+		return nil, fmt.Errorf("not a file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected git cat-file header %q", header)
+	}
+
+	// The content is followed by a newline
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(r.stdout, content); err != nil {
+		return nil, err
+	}
+	return content[:size], nil
+}
+
+func (r *gitBlobReader) close() {
+	_ = r.stdin.Close()
+	_ = r.cmd.Wait()
+}
+
+// gitOutput runs git in dir and returns what it prints
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
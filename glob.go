@@ -0,0 +1,170 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isGlob reports whether filePath contains glob metacharacters
+func isGlob(filePath string) bool {
+	return strings.ContainsAny(filePath, "*?[")
+}
+
+// ExpandFilePaths replaces each glob in filePaths, such as Projects/**/*.md,
+// and each directory under rootPath with the vault-relative paths of the
+// files they match. '**' matches any number of directories; the other
+// metacharacters are those of path.Match. A directory matches every file
+// beneath it which the scanner doesn't exclude. Other paths are returned
+// unchanged, and the result holds each path once, in the order first seen.
+func (m *DefaultTagManager) ExpandFilePaths(ctx context.Context, rootPath string, filePaths []string) ([]string, error) {
+	patterns := make(map[string]string)
+	for _, filePath := range filePaths {
+		pattern, err := m.expansionPattern(rootPath, filePath)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != "" {
+			patterns[filePath] = pattern
+		}
+	}
+	if len(patterns) == 0 {
+		return filePaths, nil
+	}
+
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	var files []string
+	for file, err := range m.scanner.WalkFiles(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		relPath, err := filepath.Rel(rootPath, file)
+		if err != nil {
+			continue
+		}
+		files = append(files, filepath.ToSlash(relPath))
+	}
+	sort.Strings(files)
+
+	expanded := []string{}
+	seen := make(map[string]bool)
+	add := func(filePath string) {
+		if !seen[filePath] {
+			seen[filePath] = true
+			expanded = append(expanded, filePath)
+		}
+	}
+	for _, filePath := range filePaths {
+		pattern, ok := patterns[filePath]
+		if !ok {
+			add(filePath)
+			continue
+		}
+		for _, file := range files {
+			if matchGlob(pattern, file) {
+				add(file)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// rootedFilePaths expands the directories and globs in filePaths against
+// rootPath, returning absolute paths. Without a rootPath the paths are
+// returned as they are, and globs are refused.
+func rootedFilePaths(ctx context.Context, manager TagManager, rootPath string, filePaths []string) ([]string, error) {
+	if rootPath == "" {
+		for _, filePath := range filePaths {
+			if isGlob(filePath) {
+				return nil, fmt.Errorf("glob %q requires root", filePath)
+			}
+		}
+		return filePaths, nil
+	}
+
+	expanded, err := manager.ExpandFilePaths(ctx, rootPath, filePaths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand file paths: %w", err)
+	}
+	rooted := make([]string, len(expanded))
+	for i, filePath := range expanded {
+		rooted[i] = filePath
+		if !filepath.IsAbs(filePath) {
+			rooted[i] = filepath.Join(rootPath, filePath)
+		}
+	}
+	return rooted, nil
+}
+
+// expansionPattern returns the glob ExpandFilePaths matches files against
+// for filePath, or "" when filePath is neither a glob nor a directory. An
+// absolute filePath inside rootPath is matched relative to it.
+func (m *DefaultTagManager) expansionPattern(rootPath, filePath string) (string, error) {
+	if filepath.IsAbs(filePath) {
+		if absoluteRoot, err := filepath.Abs(rootPath); err == nil {
+			if relPath, err := filepath.Rel(absoluteRoot, filePath); err == nil && relPath != ".." &&
+				!strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+				filePath = relPath
+			}
+		}
+	}
+
+	if isGlob(filePath) {
+		pattern := strings.TrimPrefix(filepath.ToSlash(filePath), "./")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q: %w", filePath, err)
+		}
+		return pattern, nil
+	}
+
+	// Paths escaping the root are left for UpdateTags to refuse
+	absolutePath, err := m.notePath(rootPath, filePath)
+	if err != nil {
+		return "", nil
+	}
+	if info, err := os.Stat(absolutePath); err != nil || !info.IsDir() {
+		return "", nil
+	}
+	dir := path.Clean(filepath.ToSlash(filePath))
+	if dir == "." {
+		return "**", nil
+	}
+	return dir + "/**", nil
+}
+
+// matchGlob reports whether the slash separated relPath matches pattern,
+// where a '**' segment matches zero or more directories
+func matchGlob(pattern, relPath string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchGlobSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlobSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
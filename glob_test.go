@@ -0,0 +1,166 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestExpandFilePaths(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"inbox.md":                   "#draft",
+		"Projects/alpha.md":          "#draft",
+		"Projects/web/beta.md":       "#draft",
+		"Projects/web/deep/gamma.md": "#draft",
+		"Projects/notes.txt":         "#draft",
+		"Archive/old.md":             "#draft",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name     string
+		paths    []string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "NoGlobs",
+			paths:    []string{"inbox.md", "missing.md"},
+			expected: []string{"inbox.md", "missing.md"},
+		},
+		{
+			name:     "DoubleStar",
+			paths:    []string{"Projects/**/*.md"},
+			expected: []string{"Projects/alpha.md", "Projects/web/beta.md", "Projects/web/deep/gamma.md"},
+		},
+		{
+			name:     "SingleStar",
+			paths:    []string{"Projects/*.md"},
+			expected: []string{"Projects/alpha.md"},
+		},
+		{
+			name:     "MixedWithoutDuplicates",
+			paths:    []string{"Projects/alpha.md", "Projects/*.md", "*.md"},
+			expected: []string{"Projects/alpha.md", "inbox.md"},
+		},
+		{
+			name:     "Directory",
+			paths:    []string{"Projects/web/", "inbox.md"},
+			expected: []string{"Projects/web/beta.md", "Projects/web/deep/gamma.md", "inbox.md"},
+		},
+		{
+			name:     "RootDirectory",
+			paths:    []string{"."},
+			expected: []string{"Archive/old.md", "Projects/alpha.md", "Projects/web/beta.md", "Projects/web/deep/gamma.md", "inbox.md"},
+		},
+		{
+			name:     "NoMatches",
+			paths:    []string{"Daily/**/*.md"},
+			expected: []string{},
+		},
+		{
+			name:  "InvalidGlob",
+			paths: []string{"Projects/[.md"},
+			err:   "invalid glob",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			expanded, err := manager.ExpandFilePaths(context.Background(), root, test.paths)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, expanded)
+		})
+	}
+
+	t.Run("AbsoluteInsideRoot", func(t *testing.T) {
+		expanded, err := manager.ExpandFilePaths(context.Background(), root, []string{
+			filepath.Join(root, "Projects", "web"),
+			filepath.Join(root, "*.md"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Projects/web/beta.md", "Projects/web/deep/gamma.md", "inbox.md"}, expanded)
+	})
+}
+
+func TestGlobFilePathsTools(t *testing.T) {
+	ctx := context.Background()
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	t.Run("UpdateTags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"inbox.md":             "body",
+			"Projects/alpha.md":    "body",
+			"Projects/web/beta.md": "body",
+		})
+		_, data, err := tagmanager.UpdateTagsTool(ctx, &mcp.CallToolRequest{}, tagmanager.TagUpdateParams{
+			AddTags:   []string{"project"},
+			FilePaths: []string{"Projects/**/*.md"},
+			Root:      root,
+		}, manager)
+		require.NoError(t, err)
+
+		result := data.(*tagmanager.TagUpdateResult)
+		assert.Equal(t, []string{"Projects/alpha.md", "Projects/web/beta.md"}, result.ModifiedFiles)
+		assert.Equal(t, map[string]int{"project": 2}, result.TagsAdded)
+	})
+
+	t.Run("GetFilesTags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"inbox.md":          "#inbox",
+			"Projects/alpha.md": "#alpha",
+		})
+		_, data, err := tagmanager.GetFilesTagsTool(ctx, &mcp.CallToolRequest{}, tagmanager.GetFilesTagsParams{
+			FilePaths: []string{"Projects/**/*.md", "inbox.md"},
+			Root:      root,
+		}, manager)
+		require.NoError(t, err)
+
+		files := data.([]tagmanager.FileTagInfo)
+		require.Len(t, files, 2)
+		assert.Equal(t, filepath.Join(root, "Projects/alpha.md"), files[0].Path)
+		assert.Equal(t, []string{"alpha"}, files[0].Tags)
+		assert.Equal(t, filepath.Join(root, "inbox.md"), files[1].Path)
+	})
+
+	t.Run("GetFilesTagsGlobRequiresRoot", func(t *testing.T) {
+		_, _, err := tagmanager.GetFilesTagsTool(ctx, &mcp.CallToolRequest{}, tagmanager.GetFilesTagsParams{
+			FilePaths: []string{"Projects/**/*.md"},
+		}, manager)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires root")
+	})
+
+	t.Run("UpdateCommand", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"inbox.md":                     "body",
+			"Projects/alpha.md":            "body",
+			"Projects/web/beta.md":         "body",
+			"Projects/board.excalidraw.md": "body",
+			"Daily/today.md":               "body",
+		})
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "project",
+			"--files", "Projects,Daily/*.md", "--root", root}, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{"Modified files: 3", "Projects/alpha.md", "Projects/web/beta.md", "Daily/today.md"})
+		assert.NotContains(t, stdout.String(), "excalidraw")
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "project",
+			"--files", "Missing/**/*.md", "--root", root}, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no files match")
+	})
+}
@@ -0,0 +1,215 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Kinds of TagGraph
+const (
+	// GraphCooccurrence links tags found in the same files, weighted by how
+	// many files have both
+	GraphCooccurrence = "cooccurrence"
+	// GraphHierarchy links each namespace to the tags nested directly
+	// beneath it, as tree shows them
+	GraphHierarchy = "hierarchy"
+)
+
+// Diagram syntaxes graph writes
+const (
+	DiagramDOT     = "dot"
+	DiagramMermaid = "mermaid"
+)
+
+// GraphEdge links two tags of a TagGraph
+type GraphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Weight int    `json:"weight"`
+}
+
+// TagGraph is the relationships between the tags of a vault, for drawing
+type TagGraph struct {
+	Kind string `json:"kind"`
+	// Nodes are the tags with the number of files they are in, most first.
+	// In a hierarchy the count includes the files of nested tags.
+	Nodes []TagCount `json:"nodes"`
+	// Edges are heaviest first. Co-occurrence edges are undirected, From
+	// sorting before To; hierarchy edges point from parent to child.
+	Edges []GraphEdge `json:"edges"`
+}
+
+func validateGraphKind(kind string) error {
+	if kind != GraphCooccurrence && kind != GraphHierarchy {
+		return fmt.Errorf("invalid graph kind %q: must be %s or %s", kind, GraphCooccurrence, GraphHierarchy)
+	}
+	return nil
+}
+
+func validateDiagram(diagram string) error {
+	if diagram != DiagramDOT && diagram != DiagramMermaid {
+		return fmt.Errorf("invalid diagram %q: must be %s or %s", diagram, DiagramDOT, DiagramMermaid)
+	}
+	return nil
+}
+
+// TagGraph returns the graph of kind, GraphCooccurrence or GraphHierarchy,
+// of the tags under rootPath
+func (m *DefaultTagManager) TagGraph(ctx context.Context, kind string, rootPath string) (*TagGraph, error) {
+	if err := validateGraphKind(kind); err != nil {
+		return nil, err
+	}
+	if kind == GraphHierarchy {
+		nodes, err := m.TagTree(ctx, rootPath)
+		if err != nil {
+			return nil, err
+		}
+		graph := &TagGraph{Kind: kind, Nodes: []TagCount{}, Edges: []GraphEdge{}}
+		addHierarchy(graph, nodes)
+		graph.sort()
+		return graph, nil
+	}
+
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+	counts := make(map[string]int)
+	weights := make(map[GraphEdge]int)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var tags []string
+		for _, tag := range fileInfo.Tags {
+			normalized := m.normalizeTag(tag)
+			if !seen[normalized] {
+				seen[normalized] = true
+				tags = append(tags, normalized)
+			}
+		}
+		sort.Strings(tags)
+		for i, tag := range tags {
+			counts[tag]++
+			for _, other := range tags[i+1:] {
+				weights[GraphEdge{From: tag, To: other}]++
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	graph := &TagGraph{Kind: kind, Nodes: make([]TagCount, 0, len(counts)), Edges: make([]GraphEdge, 0, len(weights))}
+	for tag, count := range counts {
+		graph.Nodes = append(graph.Nodes, TagCount{Tag: tag, Count: count})
+	}
+	for edge, weight := range weights {
+		edge.Weight = weight
+		graph.Edges = append(graph.Edges, edge)
+	}
+	graph.sort()
+	return graph, nil
+}
+
+// addHierarchy adds nodes, and the edges to their children, to graph
+func addHierarchy(graph *TagGraph, nodes []TagNode) {
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, TagCount{Tag: node.Tag, Count: node.Total})
+		for _, child := range node.Children {
+			graph.Edges = append(graph.Edges, GraphEdge{From: node.Tag, To: child.Tag, Weight: child.Total})
+		}
+		addHierarchy(graph, node.Children)
+	}
+}
+
+func (g *TagGraph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Count != g.Nodes[j].Count {
+			return g.Nodes[i].Count > g.Nodes[j].Count
+		}
+		return g.Nodes[i].Tag < g.Nodes[j].Tag
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+}
+
+// prune keeps the top most used tags, 0 for all, and the edges between them
+// weighing at least minWeight
+func (g *TagGraph) prune(top, minWeight int) {
+	if top > 0 && len(g.Nodes) > top {
+		g.Nodes = g.Nodes[:top]
+	}
+	kept := make(map[string]bool, len(g.Nodes))
+	for _, node := range g.Nodes {
+		kept[node.Tag] = true
+	}
+	edges := g.Edges[:0]
+	for _, edge := range g.Edges {
+		if edge.Weight >= minWeight && kept[edge.From] && kept[edge.To] {
+			edges = append(edges, edge)
+		}
+	}
+	g.Edges = edges
+}
+
+// writeDOT writes g as a Graphviz graph, undirected for co-occurrence
+func writeDOT(w io.Writer, g *TagGraph) error {
+	header, link := "graph tags {", "--"
+	if g.Kind == GraphHierarchy {
+		header, link = "digraph tags {", "->"
+	}
+	lines := []string{header, "  node [shape=box];"}
+	for _, node := range g.Nodes {
+		lines = append(lines, fmt.Sprintf("  %s [label=%s];", dotQuote(node.Tag), dotQuote(fmt.Sprintf("#%s (%d)", node.Tag, node.Count))))
+	}
+	for _, edge := range g.Edges {
+		lines = append(lines, fmt.Sprintf("  %s %s %s [weight=%d, label=\"%d\"];",
+			dotQuote(edge.From), link, dotQuote(edge.To), edge.Weight, edge.Weight))
+	}
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// dotQuote quotes s as a DOT string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// writeMermaid writes g as a Mermaid flowchart. Tags aren't valid Mermaid
+// ids, so nodes are numbered and labeled with their tags.
+func writeMermaid(w io.Writer, g *TagGraph) error {
+	lines := []string{"graph LR"}
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		ids[node.Tag] = fmt.Sprintf("t%d", i)
+		// Mermaid labels escape # and quotes as entity codes
+		label := fmt.Sprintf("#35;%s (%d)", strings.ReplaceAll(node.Tag, `"`, "#quot;"), node.Count)
+		lines = append(lines, fmt.Sprintf("  %s[\"%s\"]", ids[node.Tag], label))
+	}
+	link := "---"
+	if g.Kind == GraphHierarchy {
+		link = "-->"
+	}
+	for _, edge := range g.Edges {
+		lines = append(lines, fmt.Sprintf("  %s %s|%d| %s", ids[edge.From], link, edge.Weight, ids[edge.To]))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
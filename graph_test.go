@@ -0,0 +1,126 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTagGraph(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "#golang #project #project/alpha",
+		"b.md": "#golang #project/alpha",
+		"c.md": "#golang #rust",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	t.Run("Cooccurrence", func(t *testing.T) {
+		graph, err := manager.TagGraph(context.Background(), tagmanager.GraphCooccurrence, root)
+		require.NoError(t, err)
+		assert.Equal(t, &tagmanager.TagGraph{
+			Kind: tagmanager.GraphCooccurrence,
+			Nodes: []tagmanager.TagCount{
+				{Tag: "golang", Count: 3}, {Tag: "project/alpha", Count: 2}, {Tag: "project", Count: 1}, {Tag: "rust", Count: 1},
+			},
+			Edges: []tagmanager.GraphEdge{
+				{From: "golang", To: "project/alpha", Weight: 2},
+				{From: "golang", To: "project", Weight: 1},
+				{From: "golang", To: "rust", Weight: 1},
+				{From: "project", To: "project/alpha", Weight: 1},
+			},
+		}, graph)
+	})
+
+	t.Run("Hierarchy", func(t *testing.T) {
+		graph, err := manager.TagGraph(context.Background(), tagmanager.GraphHierarchy, root)
+		require.NoError(t, err)
+		assert.Equal(t, &tagmanager.TagGraph{
+			Kind: tagmanager.GraphHierarchy,
+			Nodes: []tagmanager.TagCount{
+				{Tag: "golang", Count: 3}, {Tag: "project", Count: 2}, {Tag: "project/alpha", Count: 2}, {Tag: "rust", Count: 1},
+			},
+			Edges: []tagmanager.GraphEdge{{From: "project", To: "project/alpha", Weight: 2}},
+		}, graph)
+	})
+
+	t.Run("InvalidKind", func(t *testing.T) {
+		_, err := manager.TagGraph(context.Background(), "citations", root)
+		assert.EqualError(t, err, `invalid graph kind "citations": must be cooccurrence or hierarchy`)
+	})
+}
+
+func TestGraphCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"a.md": "#golang #project/alpha",
+		"b.md": "#golang #project/alpha",
+		"c.md": "#golang #rust",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "DOT",
+			args: []string{"graph"},
+			expected: "graph tags {\n" +
+				"  node [shape=box];\n" +
+				"  \"golang\" [label=\"#golang (3)\"];\n" +
+				"  \"project/alpha\" [label=\"#project/alpha (2)\"];\n" +
+				"  \"rust\" [label=\"#rust (1)\"];\n" +
+				"  \"golang\" -- \"project/alpha\" [weight=2, label=\"2\"];\n" +
+				"  \"golang\" -- \"rust\" [weight=1, label=\"1\"];\n" +
+				"}\n",
+		},
+		{
+			name: "Mermaid",
+			args: []string{"graph", "--diagram", "mermaid", "--min-weight", "2"},
+			expected: "graph LR\n" +
+				"  t0[\"#35;golang (3)\"]\n" +
+				"  t1[\"#35;project/alpha (2)\"]\n" +
+				"  t2[\"#35;rust (1)\"]\n" +
+				"  t0 ---|2| t1\n",
+		},
+		{
+			name: "HierarchyDOT",
+			args: []string{"graph", "--kind", "hierarchy", "--top", "3"},
+			expected: "digraph tags {\n" +
+				"  node [shape=box];\n" +
+				"  \"golang\" [label=\"#golang (3)\"];\n" +
+				"  \"project\" [label=\"#project (2)\"];\n" +
+				"  \"project/alpha\" [label=\"#project/alpha (2)\"];\n" +
+				"  \"project\" -> \"project/alpha\" [weight=2, label=\"2\"];\n" +
+				"}\n",
+		},
+		{
+			name:     "CSV",
+			args:     []string{"graph", "--format", "csv"},
+			expected: "from,to,weight\ngolang,project/alpha,2\ngolang,rust,1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, append(test.args, "--root", root)...)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, output)
+		})
+	}
+
+	t.Run("InvalidDiagram", func(t *testing.T) {
+		_, err := run(t, "graph", "--diagram", "svg", "--root", root)
+		assert.EqualError(t, err, `invalid diagram "svg": must be dot or mermaid`)
+	})
+}
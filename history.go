@@ -0,0 +1,243 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Actions of a TagEvent
+const (
+	TagEventAdded   = "added"
+	TagEventRemoved = "removed"
+	// TagEventMoved is a file with the tag renamed or moved, keeping it
+	TagEventMoved = "moved"
+)
+
+// TagEvent is a commit adding a tag to a file, removing it, or moving a file
+// which has it
+type TagEvent struct {
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Path is the file's path after the commit, relative to the root with /
+	// separators, and OldPath its path before a TagEventMoved
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// TrendPoint is the number of files with a tag at the end of a month
+type TrendPoint struct {
+	// Month is formatted as 2006-01
+	Month string `json:"month"`
+	Files int    `json:"files"`
+}
+
+// TagHistory is a tag's history in the commits of a vault. A file keeps its
+// history when it is renamed or moved, so reorganizing notes neither resets
+// FirstSeen nor shows as the tag being removed and added again.
+type TagHistory struct {
+	Tag string `json:"tag"`
+	// FirstSeen is when a commit first added the tag to a file, and LastUsed
+	// when a commit last changed a file with it. Both are zero for a tag no
+	// commit has.
+	FirstSeen time.Time `json:"first_seen"`
+	LastUsed  time.Time `json:"last_used"`
+	// Files are the files with the tag in the last commit, sorted
+	Files  []string   `json:"files"`
+	Events []TagEvent `json:"events"`
+	// Trend has a point for every month from the first commit changing a
+	// file with the tag to the last commit
+	Trend []TrendPoint `json:"trend"`
+}
+
+// GetTagHistory returns the history of each of tags in the git history of
+// rootPath, following the first parent of merges. Renames are found as git
+// finds them, by content similarity, so a note renamed and edited in one
+// commit is still followed. Uncommitted changes aren't included.
+func (m *DefaultTagManager) GetTagHistory(ctx context.Context, rootPath string, tags []string) ([]TagHistory, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	prefix, err := gitOutput(ctx, rootPath, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git work tree: %w", rootPath, err)
+	}
+	prefix = strings.TrimSpace(prefix)
+
+	histories := make([]TagHistory, len(tags))
+	byTag := make(map[string]*TagHistory, len(tags))
+	for i, tag := range tags {
+		tag = m.normalizeTag(tag)
+		histories[i] = TagHistory{Tag: tag, Files: []string{}, Events: []TagEvent{}, Trend: []TrendPoint{}}
+		byTag[tag] = &histories[i]
+	}
+
+	blobs, err := startGitBlobReader(ctx, rootPath)
+	if err != nil {
+		return nil, err
+	}
+	defer blobs.close()
+
+	// files holds the tags of each note in the commit walked last, and
+	// counts how many of them have each tag
+	files := make(map[string]map[string]bool)
+	counts := make(map[string]int)
+	monthly := make(map[string]map[string]int)
+
+	readTags := func(commit, path string) (map[string]bool, error) {
+		content, err := blobs.read(commit + ":" + prefix + path)
+		if err != nil {
+			return nil, err
+		}
+		content, err = decryptNote(ctx, m.config, filepath.Join(rootPath, filepath.FromSlash(path)), content)
+		if err != nil {
+			return nil, err
+		}
+		fileTags := make(map[string]bool)
+		for _, tag := range m.scanner.ExtractTags(string(content)) {
+			fileTags[m.normalizeTag(tag)] = true
+		}
+		return fileTags, nil
+	}
+
+	err = walkGitLog(ctx, rootPath, func(commit gitCommit) error {
+		record := func(tag, action, path, oldPath string) {
+			if history := byTag[tag]; history != nil {
+				history.Events = append(history.Events, TagEvent{Commit: commit.hash, Time: commit.time,
+					Action: action, Path: path, OldPath: oldPath})
+			}
+		}
+
+		for _, change := range commit.changes {
+			oldPath, path := change.oldPath, change.path
+			if oldPath != "" && !m.isHistoryNote(oldPath) {
+				oldPath = ""
+			}
+			if path != "" && !m.isHistoryNote(path) {
+				path = ""
+			}
+
+			before := files[oldPath]
+			delete(files, oldPath)
+			after := map[string]bool{}
+			if path != "" {
+				if change.status == 'R' && change.score == 100 && oldPath != "" {
+					after = before
+				} else {
+					read, err := readTags(commit.hash, path)
+					if err != nil {
+						return fmt.Errorf("failed to read %s at %s: %w", path, commit.hash, err)
+					}
+					after = read
+				}
+				files[path] = after
+			}
+
+			for tag := range before {
+				if !after[tag] {
+					counts[tag]--
+					record(tag, TagEventRemoved, oldPath, "")
+				}
+			}
+			for tag := range after {
+				switch {
+				case !before[tag]:
+					counts[tag]++
+					record(tag, TagEventAdded, path, "")
+				case oldPath != path:
+					record(tag, TagEventMoved, path, oldPath)
+				}
+				if history := byTag[tag]; history != nil {
+					history.LastUsed = commit.time
+				}
+			}
+		}
+
+		month := commit.time.Format("2006-01")
+		for tag, history := range byTag {
+			if len(history.Events) == 0 {
+				continue
+			}
+			if monthly[tag] == nil {
+				monthly[tag] = make(map[string]int)
+			}
+			monthly[tag][month] = counts[tag]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path, fileTags := range files {
+		for tag := range fileTags {
+			if history := byTag[tag]; history != nil {
+				history.Files = append(history.Files, path)
+			}
+		}
+	}
+	for i := range histories {
+		history := &histories[i]
+		sort.Strings(history.Files)
+		for _, event := range history.Events {
+			if event.Action == TagEventAdded {
+				history.FirstSeen = event.Time
+				break
+			}
+		}
+		history.Trend = monthlyTrend(monthly[history.Tag])
+	}
+	return histories, nil
+}
+
+// isHistoryNote reports whether the file at relPath, relative to the root
+// with / separators, is a note a scan would read
+func (m *DefaultTagManager) isHistoryNote(relPath string) bool {
+	if !isNote(m.config, relPath, noteExtensions(m.config)) {
+		return false
+	}
+	for _, exclude := range m.config.ExcludeDirs {
+		if strings.Contains(relPath, exclude) {
+			return false
+		}
+	}
+	for _, pattern := range m.config.ExcludePatterns {
+		if matched, _ := filepath.Match(pattern, filepath.Base(relPath)); matched {
+			return false
+		}
+	}
+	return true
+}
+
+// monthlyTrend returns a point for every month from the first to the last in
+// counts, carrying the count of a month without commits over from the one
+// before
+func monthlyTrend(counts map[string]int) []TrendPoint {
+	trend := []TrendPoint{}
+	if len(counts) == 0 {
+		return trend
+	}
+
+	months := make([]string, 0, len(counts))
+	for month := range counts {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	first, _ := time.Parse("2006-01", months[0])
+	last, _ := time.Parse("2006-01", months[len(months)-1])
+	files := 0
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		if count, ok := counts[key]; ok {
+			files = count
+		}
+		trend = append(trend, TrendPoint{Month: key, Files: files})
+	}
+	return trend
+}
@@ -0,0 +1,106 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTagHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// The vault is a folder of the repository, as when notes live beside code
+	repo := t.TempDir()
+	root := filepath.Join(repo, "vault")
+	git := func(date string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date+"T12:00:00Z", "GIT_COMMITTER_DATE="+date+"T12:00:00Z")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	write := func(path, content string) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+	commit := func(date, message string) {
+		git(date, "add", "-A")
+		git(date, "commit", "-q", "-m", message)
+	}
+
+	body := strings.Repeat("Notes on goroutines, channels and interfaces.\n", 20)
+	git("2024-01-01", "init", "-q")
+	write("notes/go.md", "#golang\n"+body)
+	write("other.md", "#rust")
+	commit("2024-01-15", "add notes")
+
+	// A pure move, then a rename with an edit in the same commit
+	git("2024-02-15", "mv", "vault/notes", "vault/languages")
+	commit("2024-02-15", "move notes")
+	git("2024-04-15", "mv", "vault/languages/go.md", "vault/languages/golang.md")
+	write("languages/golang.md", "#golang\n"+body+"More on generics.\n")
+	write("new.md", "#golang")
+	commit("2024-04-15", "rename and add")
+
+	write("new.md", "untagged now")
+	commit("2024-05-15", "untag")
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	histories, err := manager.GetTagHistory(context.Background(), root, []string{"#golang", "never"})
+	require.NoError(t, err)
+	require.Len(t, histories, 2)
+
+	golang := histories[0]
+	assert.Equal(t, "golang", golang.Tag)
+	assert.Equal(t, "2024-01-15", golang.FirstSeen.UTC().Format(time.DateOnly))
+	assert.Equal(t, "2024-04-15", golang.LastUsed.UTC().Format(time.DateOnly))
+	assert.Equal(t, []string{"languages/golang.md"}, golang.Files)
+
+	var events []string
+	for _, event := range golang.Events {
+		events = append(events, event.Time.UTC().Format(time.DateOnly)+" "+event.Action+" "+event.OldPath+">"+event.Path)
+	}
+	assert.Equal(t, []string{
+		"2024-01-15 added >notes/go.md",
+		"2024-02-15 moved notes/go.md>languages/go.md",
+		"2024-04-15 moved languages/go.md>languages/golang.md",
+		"2024-04-15 added >new.md",
+		"2024-05-15 removed >new.md",
+	}, events)
+	assert.Equal(t, []tagmanager.TrendPoint{
+		{Month: "2024-01", Files: 1}, {Month: "2024-02", Files: 1}, {Month: "2024-03", Files: 1},
+		{Month: "2024-04", Files: 2}, {Month: "2024-05", Files: 1},
+	}, golang.Trend)
+
+	never := histories[1]
+	assert.True(t, never.FirstSeen.IsZero())
+	assert.Empty(t, never.Events)
+	assert.Empty(t, never.Trend)
+
+	var stdout bytes.Buffer
+	err = tagmanager.RunCmd([]string{"tag-manager", "history", "--root=" + root, "--tags=golang,never"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{
+		"#golang: first seen 2024-01-15, last used 2024-04-15, in 1 files",
+		"moved   notes/go.md -> languages/go.md",
+		"Files by month: 2024-01 1, 2024-02 1, 2024-03 1, 2024-04 2, 2024-05 1",
+		"#never: not in any commit",
+	})
+
+	_, err = manager.GetTagHistory(context.Background(), t.TempDir(), []string{"golang"})
+	assert.ErrorContains(t, err, "is not in a git work tree")
+}
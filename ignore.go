@@ -0,0 +1,120 @@
+package tagmanager
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ignoreDirective matches the HTML comments which exclude content from tag
+// extraction and modification:
+//
+//	<!-- tag-manager:ignore -->        the whole file
+//	<!-- tag-manager:ignore-start -->  everything up to the matching ignore-end
+//	<!-- tag-manager:ignore-end -->
+var ignoreDirective = regexp.MustCompile(`<!--\s*tag-manager:(ignore-start|ignore-end|ignore)\s*-->`)
+
+// ignoreSegment is a run of content either inside or outside an ignored region
+type ignoreSegment struct {
+	text    string
+	ignored bool
+}
+
+// ignoreDirectives returns the submatch indexes of the directives in
+// content, leaving out those in code blocks and inline code, which show a
+// directive rather than use it
+func ignoreDirectives(content string) [][]int {
+	matches := ignoreDirective.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	var directives [][]int
+	pos := 0
+	for _, segment := range splitCode(content) {
+		end := pos + len(segment.text)
+		for len(matches) > 0 && matches[0][0] < end {
+			if !segment.ignored {
+				directives = append(directives, matches[0])
+			}
+			matches = matches[1:]
+		}
+		pos = end
+	}
+	return directives
+}
+
+// hasIgnoreFileDirective reports whether content opts the whole file out
+func hasIgnoreFileDirective(content string) bool {
+	for _, match := range ignoreDirectives(content) {
+		if content[match[2]:match[3]] == "ignore" {
+			return true
+		}
+	}
+	return false
+}
+
+// splitIgnored splits content into segments inside and outside ignored
+// regions; the directive comments themselves are ignored, and those in code
+// are ordinary content. ignoring is the state
+// at the start of content and the state at its end is returned, so content can
+// be split a line at a time. An ignore-start without an ignore-end runs to the
+// end of the content.
+func splitIgnored(content string, ignoring bool) ([]ignoreSegment, bool) {
+	var segments []ignoreSegment
+	add := func(text string, ignored bool) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].ignored == ignored {
+			segments[n-1].text += text
+			return
+		}
+		segments = append(segments, ignoreSegment{text: text, ignored: ignored})
+	}
+
+	pos := 0
+	for _, match := range ignoreDirectives(content) {
+		add(content[pos:match[0]], ignoring)
+		add(content[match[0]:match[1]], true)
+		switch content[match[2]:match[3]] {
+		case "ignore-start":
+			ignoring = true
+		case "ignore-end":
+			ignoring = false
+		}
+		pos = match[1]
+	}
+	add(content[pos:], ignoring)
+
+	return segments, ignoring
+}
+
+// stripIgnored returns the parts of content outside ignored regions, each on
+// its own line so no hashtag can span a removed region.
+func stripIgnored(content string) string {
+	segments, _ := splitIgnored(content, false)
+
+	var parts []string
+	for _, segment := range segments {
+		if !segment.ignored {
+			parts = append(parts, segment.text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// mapOutsideIgnored applies fn to the parts of content outside ignored
+// regions, leaving the ignored regions untouched.
+func mapOutsideIgnored(content string, fn func(string) string) string {
+	segments, _ := splitIgnored(content, false)
+
+	var b strings.Builder
+	for _, segment := range segments {
+		if segment.ignored {
+			b.WriteString(segment.text)
+			continue
+		}
+		b.WriteString(fn(segment.text))
+	}
+	return b.String()
+}
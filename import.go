@@ -0,0 +1,213 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Formats of the manifests import reads
+const (
+	ManifestCSV  = "csv"
+	ManifestJSON = "json"
+)
+
+// Names of the CSV manifest columns, by what they hold. A tag column, as in
+// the CSV export writes, adds its tag.
+var (
+	manifestPathColumns   = []string{"path", "file"}
+	manifestAddColumns    = []string{"add", "tags-to-add", "tag"}
+	manifestRemoveColumns = []string{"remove", "tags-to-remove"}
+)
+
+// manifestFormat returns the format of the manifest at path: format when
+// set, else the one its extension names
+func manifestFormat(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if path == "-" || (format != ManifestCSV && format != ManifestJSON) {
+			return "", fmt.Errorf("cannot tell the format of manifest %s: pass --manifest-format", path)
+		}
+	}
+	if format != ManifestCSV && format != ManifestJSON {
+		return "", fmt.Errorf("invalid manifest format %q: must be %s or %s", format, ManifestCSV, ManifestJSON)
+	}
+	return format, nil
+}
+
+// readManifest reads the per-file operations of a CSV or JSON manifest from
+// path, or from stdin when path is "-". Rows for the same file are merged
+// into one operation, in the order the files first appear.
+func readManifest(path, format string, stdin io.Reader) ([]FileTagOp, error) {
+	format, err := manifestFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var ops []FileTagOp
+	if format == ManifestJSON {
+		if err := json.Unmarshal(data, &ops); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+		}
+		for i, op := range ops {
+			if op.Path == "" {
+				return nil, fmt.Errorf("invalid manifest %s: operation %d has no path", path, i+1)
+			}
+		}
+	} else if ops, err = parseCSVManifest(data); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	ops = mergeFileTagOps(ops)
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operations in manifest %s", path)
+	}
+	return ops, nil
+}
+
+// parseCSVManifest reads a CSV manifest, whose header names its path, add
+// and remove columns. Other columns are ignored.
+func parseCSVManifest(data []byte) ([]FileTagOp, error) {
+	// Spreadsheets often save CSV with a byte order mark
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	pathColumn, addColumns, removeColumns := -1, []int{}, []int{}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case slices.Contains(manifestPathColumns, name):
+			pathColumn = i
+		case slices.Contains(manifestAddColumns, name):
+			addColumns = append(addColumns, i)
+		case slices.Contains(manifestRemoveColumns, name):
+			removeColumns = append(removeColumns, i)
+		}
+	}
+	if pathColumn < 0 {
+		return nil, fmt.Errorf("header has no %s column", joinOr(manifestPathColumns))
+	}
+	if len(addColumns) == 0 && len(removeColumns) == 0 {
+		return nil, fmt.Errorf("header has no %s column", joinOr(slices.Concat(manifestAddColumns, manifestRemoveColumns)))
+	}
+
+	cell := func(record []string, column int) string {
+		if column < len(record) {
+			return record[column]
+		}
+		return ""
+	}
+	cells := func(record []string, columns []int) []string {
+		var tags []string
+		for _, column := range columns {
+			tags = append(tags, splitManifestTags(cell(record, column))...)
+		}
+		return tags
+	}
+
+	ops := []FileTagOp{}
+	for i, record := range records[1:] {
+		op := FileTagOp{
+			Path:   strings.TrimSpace(cell(record, pathColumn)),
+			Add:    cells(record, addColumns),
+			Remove: cells(record, removeColumns),
+		}
+		if op.Path == "" && len(op.Add) == 0 && len(op.Remove) == 0 {
+			continue
+		}
+		if op.Path == "" {
+			// Rows are numbered as a spreadsheet shows them, the header first
+			return nil, fmt.Errorf("row %d has no path", i+2)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// splitManifestTags splits a manifest cell into its tags, separated by
+// semicolons, commas or spaces, with any leading # dropped
+func splitManifestTags(value string) []string {
+	var tags []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' ' || r == '\t'
+	}) {
+		if tag := strings.TrimPrefix(field, "#"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// mergeFileTagOps merges the operations on the same file, keeping the order
+// the files first appear in. Files with nothing to add or remove are dropped.
+func mergeFileTagOps(ops []FileTagOp) []FileTagOp {
+	merged := []FileTagOp{}
+	index := make(map[string]int)
+	for _, op := range ops {
+		path := filepath.ToSlash(filepath.Clean(op.Path))
+		i, ok := index[path]
+		if !ok {
+			i = len(merged)
+			index[path] = i
+			merged = append(merged, FileTagOp{Path: path})
+		}
+		merged[i].Add = append(merged[i].Add, op.Add...)
+		merged[i].Remove = append(merged[i].Remove, op.Remove...)
+	}
+
+	kept := merged[:0]
+	for _, op := range merged {
+		if len(op.Add) > 0 || len(op.Remove) > 0 {
+			kept = append(kept, op)
+		}
+	}
+	return kept
+}
+
+// validateManifest checks the operations of a manifest before any is
+// applied, reporting every file outside root and every invalid tag to add
+func validateManifest(ctx context.Context, manager TagManager, root string, ops []FileTagOp) error {
+	var add []string
+	for _, op := range ops {
+		add = append(add, op.Add...)
+	}
+	results := manager.ValidateTags(ctx, add)
+
+	var errs []error
+	for _, op := range ops {
+		if filepath.IsAbs(op.Path) || op.Path == ".." || strings.HasPrefix(op.Path, "../") {
+			errs = append(errs, fmt.Errorf("%s: must be a path relative to %s", op.Path, root))
+			continue
+		}
+		for _, tag := range op.Add {
+			if result := results[tag]; result != nil && !result.IsValid {
+				errs = append(errs, fmt.Errorf("%s: invalid tag %q: %s", op.Path, tag, strings.Join(result.Issues, "; ")))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestImportCommand(t *testing.T) {
+	run := func(t *testing.T, stdin string, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(stdin)})
+		return stdout.String(), err
+	}
+	writeManifest := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	vault := map[string]string{
+		"alpha.md":         "---\ntags: [draft]\n---\n# Alpha",
+		"Projects/beta.md": "# Beta #draft",
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		content  string
+		stdin    string
+		args     []string
+		expected map[string][]string
+	}{
+		{
+			name:     "CSV",
+			manifest: "retag.csv",
+			content:  "file,tags-to-add,tags-to-remove\nalpha.md,golang;#review,draft\nProjects/beta.md,\"project, review\",\n",
+			expected: map[string][]string{
+				"alpha.md":         {"golang", "review"},
+				"Projects/beta.md": {"draft", "project", "review"},
+			},
+		},
+		{
+			name:     "CSVRowsMerged",
+			manifest: "retag.csv",
+			content:  "\ufeffPath,Add\nalpha.md,golang\n,\nalpha.md,review\n",
+			expected: map[string][]string{
+				"alpha.md":         {"draft", "golang", "review"},
+				"Projects/beta.md": {"draft"},
+			},
+		},
+		{
+			name:     "ExportCSV",
+			manifest: "tags.csv",
+			content:  "path,tag,source,occurrences,lines\nProjects/beta.md,golang,body,1,1\nProjects/beta.md,review,body,1,1\n",
+			expected: map[string][]string{
+				"alpha.md":         {"draft"},
+				"Projects/beta.md": {"draft", "golang", "review"},
+			},
+		},
+		{
+			name:  "JSONStdin",
+			stdin: `[{"path": "alpha.md", "remove": ["draft"]}, {"path": "Projects/beta.md", "add": ["golang"]}]`,
+			args:  []string{"--manifest-format", "json"},
+			expected: map[string][]string{
+				"alpha.md":         {},
+				"Projects/beta.md": {"draft", "golang"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, vault)
+			manifest := "-"
+			if test.manifest != "" {
+				manifest = writeManifest(t, test.manifest, test.content)
+			}
+			args := append([]string{"import", "--root", root, "--manifest", manifest}, test.args...)
+
+			output, err := run(t, test.stdin, append(args, "--dry-run")...)
+			require.NoError(t, err)
+			assertOutputContains(t, output, []string{"DRY RUN MODE", "Manifest " + manifest})
+			content, err := os.ReadFile(filepath.Join(root, "alpha.md"))
+			require.NoError(t, err)
+			assert.Equal(t, vault["alpha.md"], string(content))
+
+			_, err = run(t, test.stdin, args...)
+			require.NoError(t, err)
+			manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+			require.NoError(t, err)
+			for path, expected := range test.expected {
+				files, err := manager.GetFilesTags(t.Context(), []string{filepath.Join(root, path)})
+				require.NoError(t, err, path)
+				require.Len(t, files, 1, path)
+				assert.ElementsMatch(t, expected, files[0].Tags, path)
+			}
+		})
+	}
+}
+
+func TestImportCommandInvalidManifest(t *testing.T) {
+	root := writeVault(t, map[string]string{"alpha.md": "# Alpha"})
+
+	tests := []struct {
+		name     string
+		manifest string
+		content  string
+		expected string
+	}{
+		{name: "NoPathColumn", manifest: "m.csv", content: "tag\ngolang\n",
+			expected: "header has no path or file column"},
+		{name: "NoTagColumns", manifest: "m.csv", content: "path,note\nalpha.md,x\n",
+			expected: "header has no add, tags-to-add, tag, remove or tags-to-remove column"},
+		{name: "RowWithoutPath", manifest: "m.csv", content: "path,add\nalpha.md,golang\n,review\n",
+			expected: "row 3 has no path"},
+		{name: "Empty", manifest: "m.csv", content: "path,add\nalpha.md,\n",
+			expected: "no operations in manifest"},
+		{name: "UnknownFormat", manifest: "m.txt", content: "path,add\n",
+			expected: "cannot tell the format of manifest"},
+		{name: "BadJSON", manifest: "m.json", content: `{"path": "alpha.md"}`,
+			expected: "invalid manifest"},
+		{name: "InvalidTags", manifest: "m.csv", content: "path,add\nalpha.md,123;golang\n../outside.md,golang\n",
+			expected: "alpha.md: invalid tag \"123\""},
+		{name: "OutsideRoot", manifest: "m.csv", content: "path,add\n../outside.md,golang\n",
+			expected: "../outside.md: must be a path relative to"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := filepath.Join(t.TempDir(), test.manifest)
+			require.NoError(t, os.WriteFile(manifest, []byte(test.content), 0644))
+
+			err := tagmanager.RunCmd([]string{"tag-manager", "import", "--root", root, "--manifest", manifest},
+				&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+
+			content, err := os.ReadFile(filepath.Join(root, "alpha.md"))
+			require.NoError(t, err)
+			assert.Equal(t, "# Alpha", string(content))
+		})
+	}
+}
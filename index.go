@@ -0,0 +1,638 @@
+package tagmanager
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// IndexDir is the directory inside a vault which holds tag-manager state
+	IndexDir = ".tag-manager"
+	// IndexFileName is the append-only log of index records inside IndexDir
+	IndexFileName = "index.jsonl"
+
+	indexBatchSize = 64
+	// indexCompactionRatio triggers compaction once the log holds this many
+	// entries per live record.
+	indexCompactionRatio = 2
+)
+
+type IndexRecord struct {
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	ModTime   time.Time `json:"mod_time,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	IndexedAt time.Time `json:"indexed_at"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	// Rules is the fingerprint of the rules Tags were extracted with; a
+	// record extracted with other rules is stale
+	Rules string `json:"rules,omitempty"`
+}
+
+// Index record freshness, as reported by InspectIndex
+const (
+	IndexFresh   = "fresh"
+	IndexStale   = "stale"
+	IndexMissing = "missing"
+)
+
+// IndexRecordStatus is an index record compared against the file on disk
+type IndexRecordStatus struct {
+	IndexRecord
+	Status         string    `json:"status"`
+	CurrentHash    string    `json:"current_hash,omitempty"`
+	CurrentSize    int64     `json:"current_size,omitempty"`
+	CurrentModTime time.Time `json:"current_mod_time,omitempty"`
+}
+
+type IndexStats struct {
+	Files      int  `json:"files"`
+	Updated    int  `json:"updated"`
+	Removed    int  `json:"removed"`
+	LogEntries int  `json:"log_entries"`
+	Compacted  bool `json:"compacted"`
+}
+
+// Index is a persistent per-vault record of every scanned file and its tags.
+// Updates are appended to a log in batches so writers never rewrite the whole
+// index, and readers only contend with writers for the in-memory map. The log
+// is periodically compacted down to one entry per live file.
+type Index struct {
+	path string
+	// perm creates the log; OpenIndex leaves the default
+	perm filePerm
+
+	// mu guards records and entries
+	mu      sync.RWMutex
+	records map[string]IndexRecord
+	entries int
+
+	// writeMu serializes appends and compaction of the log file
+	writeMu sync.Mutex
+	pending []IndexRecord
+}
+
+// OpenIndex loads the index for the vault at rootPath, replaying the log so
+// the most recent record for each path wins. A missing log is an empty index.
+func OpenIndex(rootPath string) (*Index, error) {
+	ix := &Index{
+		path:    filepath.Join(rootPath, IndexDir, IndexFileName),
+		records: make(map[string]IndexRecord),
+	}
+
+	err := readIndexLog(ix.path, func(record IndexRecord) {
+		ix.entries++
+		ix.apply(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// openIndex opens the index of the vault at rootPath, creating files as the
+// config says
+func (m *DefaultTagManager) openIndex(rootPath string) (*Index, error) {
+	ix, err := OpenIndex(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	ix.perm = newFilePerm(m.config)
+	return ix, nil
+}
+
+// readIndexLog calls fn with each record in the log at path, oldest first. A
+// missing log holds no records.
+func readIndexLog(path string, fn func(IndexRecord)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record IndexRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn final write from an interrupted process is not fatal
+			continue
+		}
+		fn(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	return nil
+}
+
+// Path returns the location of the index log
+func (ix *Index) Path() string {
+	return ix.path
+}
+
+func (ix *Index) Get(path string) (IndexRecord, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	record, ok := ix.records[path]
+	return record, ok
+}
+
+// Records returns every live record sorted by path
+func (ix *Index) Records() []IndexRecord {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	records := make([]IndexRecord, 0, len(ix.records))
+	for _, record := range ix.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Path < records[j].Path
+	})
+	return records
+}
+
+// LogEntries returns the number of entries in the log, including superseded ones
+func (ix *Index) LogEntries() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.entries
+}
+
+// Put records a new state for a file. The record is visible to readers
+// immediately and is written to the log once a batch fills or Flush is called.
+func (ix *Index) Put(record IndexRecord) error {
+	ix.mu.Lock()
+	ix.apply(record)
+	ix.mu.Unlock()
+
+	ix.writeMu.Lock()
+	defer ix.writeMu.Unlock()
+
+	ix.pending = append(ix.pending, record)
+	if len(ix.pending) >= indexBatchSize {
+		return ix.flushLocked()
+	}
+	return nil
+}
+
+// Flush appends all pending records to the log
+func (ix *Index) Flush() error {
+	ix.writeMu.Lock()
+	defer ix.writeMu.Unlock()
+	return ix.flushLocked()
+}
+
+// NeedsCompaction reports whether superseded entries dominate the log
+func (ix *Index) NeedsCompaction() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.entries > indexBatchSize && ix.entries > len(ix.records)*indexCompactionRatio
+}
+
+// Compact rewrites the log with a single entry per live record. The new log
+// is written beside the old one and renamed into place so readers in other
+// processes never observe a partial file. The records are read back from the
+// log under the index lock, so those other processes appended are kept.
+func (ix *Index) Compact() error {
+	ix.writeMu.Lock()
+	defer ix.writeMu.Unlock()
+
+	if err := ix.flushLocked(); err != nil {
+		return err
+	}
+
+	if err := ix.perm.mkdirAll(filepath.Dir(ix.path)); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	unlock, err := lockIndex(ix.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	live := make(map[string]IndexRecord)
+	err = readIndexLog(ix.path, func(record IndexRecord) {
+		if record.Deleted {
+			delete(live, record.Path)
+			return
+		}
+		live[record.Path] = record
+	})
+	if err != nil {
+		return err
+	}
+	records := make([]IndexRecord, 0, len(live))
+	for _, record := range live {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Path < records[j].Path
+	})
+
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), IndexFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create compacted index: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	// The temporary file is private, so it takes the mode of the log it
+	// replaces, or the configured mode
+	mode := ix.perm.fileMode()
+	if info, err := os.Stat(ix.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to create compacted index: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("failed to write compacted index: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write compacted index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write compacted index: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), ix.path); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+
+	ix.mu.Lock()
+	ix.records = live
+	ix.entries = len(records)
+	ix.mu.Unlock()
+	return nil
+}
+
+func (ix *Index) apply(record IndexRecord) {
+	if record.Deleted {
+		delete(ix.records, record.Path)
+		return
+	}
+	ix.records[record.Path] = record
+}
+
+func (ix *Index) flushLocked() error {
+	if len(ix.pending) == 0 {
+		return nil
+	}
+
+	if err := ix.perm.mkdirAll(filepath.Dir(ix.path)); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	// Encode the whole batch up front so it reaches the log in a single write
+	var buf []byte
+	for _, record := range ix.pending {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode index record: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	unlock, err := lockIndex(ix.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := ix.perm.openAppend(ix.path)
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	if _, err := file.Write(buf); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to append to index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to append to index: %w", err)
+	}
+
+	ix.mu.Lock()
+	ix.entries += len(ix.pending)
+	ix.mu.Unlock()
+	ix.pending = nil
+	return nil
+}
+
+// UpdateIndex brings the vault index up to date, re-reading only files whose
+// size or modification time changed since they were last indexed.
+func (m *DefaultTagManager) UpdateIndex(ctx context.Context, rootPath string) (*IndexStats, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	ix, err := m.openIndex(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &IndexStats{}
+	seen := make(map[string]bool)
+
+	for path, err := range m.scanner.WalkFiles(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			continue
+		}
+		seen[relPath] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if err := m.scanFailed(&ScanError{Path: path, Phase: ScanPhaseStat, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rules := m.rulesFingerprint(path)
+		if record, ok := ix.Get(relPath); ok && record.fresh(info, rules) {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if err := m.scanFailed(&ScanError{Path: path, Phase: ScanPhaseRead, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// The hash is of the file as stored, so encrypted notes are hashed
+		// without being decrypted and InspectIndex can compare it cheaply
+		plaintext, err := decryptNote(ctx, m.config, path, content)
+		if err != nil {
+			if err := m.scanFailed(&ScanError{Path: path, Phase: ScanPhaseDecrypt, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if m.config.Strict {
+			if err := checkFrontmatter(string(plaintext)); err != nil {
+				if err := m.scanFailed(&ScanError{Path: path, Phase: ScanPhaseFrontmatter, Err: err}); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Tags are extracted as scans extract them, so scans can be served
+		// from the record
+		tags := m.scanner.ExtractTags(string(plaintext))
+		if scanner, ok := m.scanner.(*FilesystemScanner); ok {
+			_, plugins := scanner.vaultPlugins(path)
+			tags, _ = scanner.extractTags(string(plaintext), plugins)
+		}
+		sort.Strings(tags)
+
+		if err := ix.Put(IndexRecord{
+			Path:      relPath,
+			Hash:      hashContent(content),
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			Tags:      tags,
+			Rules:     rules,
+			IndexedAt: time.Now(),
+		}); err != nil {
+			return nil, err
+		}
+		stats.Updated++
+	}
+
+	// A cancelled walk hasn't seen every file, so absence proves nothing
+	if ctx.Err() == nil {
+		for _, record := range ix.Records() {
+			if seen[record.Path] {
+				continue
+			}
+			if err := ix.Put(IndexRecord{Path: record.Path, IndexedAt: time.Now(), Deleted: true}); err != nil {
+				return nil, err
+			}
+			stats.Removed++
+		}
+	}
+
+	if err := ix.Flush(); err != nil {
+		return nil, err
+	}
+
+	if ix.NeedsCompaction() {
+		if err := ix.Compact(); err != nil {
+			return nil, err
+		}
+		stats.Compacted = true
+	}
+
+	stats.Files = len(ix.Records())
+	stats.LogEntries = ix.LogEntries()
+	return stats, ctx.Err()
+}
+
+// CompactIndex rewrites the vault index log with one entry per live file
+func (m *DefaultTagManager) CompactIndex(ctx context.Context, rootPath string) (*IndexStats, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	ix, err := m.openIndex(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ix.Compact(); err != nil {
+		return nil, err
+	}
+
+	return &IndexStats{
+		Files:      len(ix.Records()),
+		LogEntries: ix.LogEntries(),
+		Compacted:  true,
+	}, nil
+}
+
+// InspectIndex returns the raw index records for the vault, optionally limited
+// to records carrying tag or for file, along with the current state of each
+// file on disk. A record is stale when its size or modification time no longer
+// matches, or the tag rules changed, which is what causes the next UpdateIndex
+// to re-read it.
+func (m *DefaultTagManager) InspectIndex(ctx context.Context, rootPath string, tag string, file string) ([]IndexRecordStatus, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	ix, err := m.openIndex(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tag = m.normalizeTag(tag)
+	if file != "" {
+		file = filepath.Clean(file)
+	}
+
+	var statuses []IndexRecordStatus
+	for _, record := range ix.Records() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if tag != "" && !containsTag(record.Tags, tag) {
+			continue
+		}
+		if file != "" && record.Path != file {
+			continue
+		}
+
+		status := IndexRecordStatus{IndexRecord: record, Status: IndexMissing}
+		path := filepath.Join(rootPath, record.Path)
+		if info, err := os.Stat(path); err == nil {
+			status.CurrentSize = info.Size()
+			status.CurrentModTime = info.ModTime()
+			status.Status = IndexStale
+			if record.fresh(info, m.rulesFingerprint(path)) {
+				status.Status = IndexFresh
+			}
+			if content, err := os.ReadFile(path); err == nil {
+				status.CurrentHash = hashContent(content)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// fresh reports whether the record still holds the tags of the file with info,
+// extracted with rules
+func (r IndexRecord) fresh(info os.FileInfo, rules string) bool {
+	return r.Size == info.Size() && r.ModTime.Equal(info.ModTime()) && r.Rules == rules
+}
+
+// rulesFingerprint identifies the rules the tags of the file at path are
+// extracted with: the config's tag rules and the plugins enabled for it
+func (m *DefaultTagManager) rulesFingerprint(path string) string {
+	var plugins *VaultPlugins
+	if scanner, ok := m.scanner.(*FilesystemScanner); ok {
+		_, plugins = scanner.vaultPlugins(path)
+	}
+	data, _ := json.Marshal(struct {
+		HashtagPattern     string
+		MinTagLength       int
+		MaxDigitRatio      float64
+		ExcludeKeywords    []string
+		ExcludeKeywordMode string
+		UnicodeTags        bool
+		Plugins            *VaultPlugins
+	}{
+		HashtagPattern:     m.config.HashtagPattern,
+		MinTagLength:       m.config.MinTagLength,
+		MaxDigitRatio:      m.config.MaxDigitRatio,
+		ExcludeKeywords:    m.config.ExcludeKeywords,
+		ExcludeKeywordMode: m.config.ExcludeKeywordMode,
+		UnicodeTags:        m.config.UnicodeTags,
+		Plugins:            plugins,
+	})
+	return hashContent(data)
+}
+
+// invalidateIndexed drops the record of the note at path from the index of
+// the vault holding it, if there is one, after tag-manager writes the note.
+// A note written with preserve_mtime, or to the same size within the mtime
+// resolution, would otherwise look unchanged to the index.
+func invalidateIndexed(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		logPath := filepath.Join(dir, IndexDir, IndexFileName)
+		if _, err := os.Stat(logPath); err == nil {
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			ix := &Index{path: logPath, records: make(map[string]IndexRecord)}
+			ix.pending = []IndexRecord{{Path: relPath, IndexedAt: time.Now(), Deleted: true}}
+			return ix.flushLocked()
+		}
+		if filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
+
+// indexedScanner scans files through Scanner, except those whose index record
+// is fresh, which it takes the tags of from the index instead of reading
+type indexedScanner struct {
+	Scanner
+	root    string
+	index   *Index
+	manager *DefaultTagManager
+}
+
+func (s *indexedScanner) ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error] {
+	return scanWalkedFiles(ctx, s, s.WalkFiles(ctx, rootPath, excludePaths))
+}
+
+func (s *indexedScanner) ScanFile(ctx context.Context, filePath string) (FileTagInfo, error) {
+	if relPath, err := filepath.Rel(s.root, filePath); err == nil {
+		record, ok := s.index.Get(relPath)
+		if ok {
+			if info, err := os.Stat(filePath); err == nil && record.fresh(info, s.manager.rulesFingerprint(filePath)) {
+				return FileTagInfo{Path: filePath, Tags: record.Tags}, nil
+			}
+		}
+	}
+	return s.Scanner.ScanFile(ctx, filePath)
+}
+
+// indexedScanner returns the manager's scanner, serving fresh files from the
+// index of the vault at rootPath when it has one. Strict scans read every
+// file, since the index doesn't record whether frontmatter parses.
+func (m *DefaultTagManager) indexedScanner(rootPath string) Scanner {
+	if m.config.Strict {
+		return m.scanner
+	}
+	ix, err := OpenIndex(rootPath)
+	if err != nil || len(ix.records) == 0 {
+		return m.scanner
+	}
+	return &indexedScanner{Scanner: m.scanner, root: rootPath, index: ix, manager: m}
+}
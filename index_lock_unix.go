@@ -0,0 +1,26 @@
+//go:build unix
+
+package tagmanager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockIndex takes the lock other processes appending to or compacting the
+// index log at path also take, returning its release
+func lockIndex(path string) (func(), error) {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock index: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock index: %w", err)
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,313 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tagmanager "github.com/thrawn01/tag-manager"
+)
+
+func TestIndexPersistence(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ix, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, ix.Put(tagmanager.IndexRecord{Path: "a.md", Tags: []string{"golang"}, IndexedAt: now}))
+	require.NoError(t, ix.Put(tagmanager.IndexRecord{Path: "b.md", Tags: []string{"python"}, IndexedAt: now}))
+	require.NoError(t, ix.Put(tagmanager.IndexRecord{Path: "a.md", Tags: []string{"rust"}, IndexedAt: now}))
+	require.NoError(t, ix.Put(tagmanager.IndexRecord{Path: "b.md", IndexedAt: now, Deleted: true}))
+
+	// Records are visible before they are flushed
+	record, ok := ix.Get("a.md")
+	require.True(t, ok)
+	assert.Equal(t, []string{"rust"}, record.Tags)
+
+	require.NoError(t, ix.Flush())
+
+	reopened, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, reopened.LogEntries())
+
+	records := reopened.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, "a.md", records[0].Path)
+	assert.Equal(t, []string{"rust"}, records[0].Tags)
+
+	require.NoError(t, reopened.Compact())
+	assert.Equal(t, 1, reopened.LogEntries())
+
+	compacted, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, compacted.LogEntries())
+	assert.Len(t, compacted.Records(), 1)
+}
+
+func TestIndexConcurrentAccess(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ix, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+
+	const writers, perWriter = 4, 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				path := fmt.Sprintf("w%d/%d.md", w, i%10)
+				assert.NoError(t, ix.Put(tagmanager.IndexRecord{Path: path, IndexedAt: time.Now()}))
+				_, _ = ix.Get(path)
+			}
+		}(w)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, ix.Compact())
+		}
+	}()
+	wg.Wait()
+
+	require.NoError(t, ix.Flush())
+
+	reopened, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, reopened.Records(), writers*10)
+}
+
+func TestUpdateIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	note1 := filepath.Join(tempDir, "note1.md")
+	note2 := filepath.Join(tempDir, "note2.md")
+	require.NoError(t, os.WriteFile(note1, []byte("#golang"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(note2, []byte("#python"), tagmanager.DefaultFilePermissions))
+
+	ctx := context.Background()
+	stats, err := manager.UpdateIndex(ctx, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, 2, stats.Updated)
+
+	// Unchanged files are not re-indexed
+	stats, err = manager.UpdateIndex(ctx, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Updated)
+	assert.Equal(t, 2, stats.LogEntries)
+
+	require.NoError(t, os.WriteFile(note1, []byte("#golang #rust"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.Chtimes(note1, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, os.Remove(note2))
+
+	stats, err = manager.UpdateIndex(ctx, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, 1, stats.Updated)
+	assert.Equal(t, 1, stats.Removed)
+
+	ix, err := tagmanager.OpenIndex(tempDir)
+	require.NoError(t, err)
+	record, ok := ix.Get("note1.md")
+	require.True(t, ok)
+	assert.Equal(t, []string{"golang", "rust"}, record.Tags)
+	assert.NotEmpty(t, record.Hash)
+}
+
+func TestIndexServesScans(t *testing.T) {
+	tempDir := t.TempDir()
+	note := filepath.Join(tempDir, "note.md")
+	require.NoError(t, os.WriteFile(note, []byte("#golang"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "other.md"), []byte("#python"), tagmanager.DefaultFilePermissions))
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = manager.UpdateIndex(ctx, tempDir)
+	require.NoError(t, err)
+
+	// Rewriting the note at the same size and modification time leaves its
+	// record fresh, so scans still see the indexed tag
+	info, err := os.Stat(note)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(note, []byte("#gopher"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.Chtimes(note, info.ModTime(), info.ModTime()))
+
+	files, err := manager.FindFilesByTags(ctx, []string{"golang", "gopher"}, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{note}, files["golang"])
+	assert.Empty(t, files["gopher"])
+
+	// A stale record is read again
+	require.NoError(t, os.Chtimes(note, info.ModTime(), info.ModTime().Add(time.Minute)))
+	tags, err := manager.ListAllTags(ctx, tempDir, 1)
+	require.NoError(t, err)
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	assert.ElementsMatch(t, []string{"gopher", "python"}, names)
+}
+
+func TestIndexRefreshes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Writes", func(t *testing.T) {
+		tempDir := t.TempDir()
+		note := filepath.Join(tempDir, "note.md")
+		require.NoError(t, os.WriteFile(note, []byte("#todo"), tagmanager.DefaultFilePermissions))
+
+		// With preserve_mtime a replace of the same length leaves the size
+		// and modification time as they were
+		config := tagmanager.DefaultConfig()
+		config.PreserveMtime = true
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+		_, err = manager.UpdateIndex(ctx, tempDir)
+		require.NoError(t, err)
+
+		_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "todo", NewTag: "task"}}, tempDir, false)
+		require.NoError(t, err)
+
+		files, err := manager.FindFilesByTags(ctx, []string{"todo", "task"}, tempDir)
+		require.NoError(t, err)
+		assert.Empty(t, files["todo"])
+		assert.Equal(t, []string{note}, files["task"])
+	})
+
+	t.Run("Rules", func(t *testing.T) {
+		tempDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("#abcd #golang"), tagmanager.DefaultFilePermissions))
+
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+		_, err = manager.UpdateIndex(ctx, tempDir)
+		require.NoError(t, err)
+
+		config := tagmanager.DefaultConfig()
+		config.MinTagLength = 5
+		manager, err = tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+		tags, err := manager.ListAllTags(ctx, tempDir, 1)
+		require.NoError(t, err)
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		assert.Equal(t, []string{"golang"}, names)
+
+		statuses, err := manager.InspectIndex(ctx, tempDir, "", "")
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, tagmanager.IndexStale, statuses[0].Status)
+	})
+
+	t.Run("CompactKeepsOtherAppends", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, err := tagmanager.OpenIndex(tempDir)
+		require.NoError(t, err)
+		require.NoError(t, first.Put(tagmanager.IndexRecord{Path: "a.md", IndexedAt: time.Now()}))
+		require.NoError(t, first.Flush())
+
+		// Another process appends after the first opened the index
+		second, err := tagmanager.OpenIndex(tempDir)
+		require.NoError(t, err)
+		require.NoError(t, second.Put(tagmanager.IndexRecord{Path: "b.md", IndexedAt: time.Now()}))
+		require.NoError(t, second.Flush())
+
+		require.NoError(t, first.Compact())
+		reopened, err := tagmanager.OpenIndex(tempDir)
+		require.NoError(t, err)
+		var paths []string
+		for _, record := range reopened.Records() {
+			paths = append(paths, record.Path)
+		}
+		assert.Equal(t, []string{"a.md", "b.md"}, paths)
+	})
+}
+
+func TestIndexCommand(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("#golang"), tagmanager.DefaultFilePermissions))
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "index", "build", "--root=" + tempDir, "--json"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+
+	var stats tagmanager.IndexStats
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Files)
+	assert.FileExists(t, filepath.Join(tempDir, tagmanager.IndexDir, tagmanager.IndexFileName))
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "index", "compact", "--root=" + tempDir},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"Indexed files: 1", "Log entries: 1", "Index compacted"})
+
+	err = tagmanager.RunCmd([]string{"tag-manager", "index", "bogus"}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	assert.Error(t, err)
+}
+
+func TestInspectIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	note1 := filepath.Join(tempDir, "note1.md")
+	note2 := filepath.Join(tempDir, "note2.md")
+	note3 := filepath.Join(tempDir, "note3.md")
+	require.NoError(t, os.WriteFile(note1, []byte("#golang"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(note2, []byte("#python"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.WriteFile(note3, []byte("#golang #rust"), tagmanager.DefaultFilePermissions))
+
+	ctx := context.Background()
+	_, err = manager.UpdateIndex(ctx, tempDir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(note1, []byte("#golang #web"), tagmanager.DefaultFilePermissions))
+	require.NoError(t, os.Chtimes(note1, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, os.Remove(note3))
+
+	statuses, err := manager.InspectIndex(ctx, tempDir, "#golang", "")
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+
+	assert.Equal(t, "note1.md", statuses[0].Path)
+	assert.Equal(t, tagmanager.IndexStale, statuses[0].Status)
+	assert.NotEqual(t, statuses[0].Hash, statuses[0].CurrentHash)
+	assert.Equal(t, int64(len("#golang #web")), statuses[0].CurrentSize)
+
+	assert.Equal(t, "note3.md", statuses[1].Path)
+	assert.Equal(t, tagmanager.IndexMissing, statuses[1].Status)
+
+	statuses, err = manager.InspectIndex(ctx, tempDir, "", "note2.md")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, tagmanager.IndexFresh, statuses[0].Status)
+	assert.Equal(t, statuses[0].Hash, statuses[0].CurrentHash)
+
+	var stdout bytes.Buffer
+	err = tagmanager.RunCmd([]string{"tag-manager", "index", "inspect", "--root=" + tempDir, "--tag=python"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"Found 1 index records", "note2.md (fresh)", "Hash:", "Tags:     #python"})
+}
@@ -0,0 +1,326 @@
+package tagmanager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// JournalFileName is the append-only log of write operations inside IndexDir
+const JournalFileName = "journal.jsonl"
+
+// Kinds of journaled operations
+const (
+	JournalReplace = "replace"
+	JournalUpdate  = "update"
+	JournalUndo    = "undo"
+)
+
+// JournalFile records one file written by an operation
+type JournalFile struct {
+	// Path is relative to the vault root
+	Path    string `json:"path"`
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash,omitempty"`
+	// Diff is a unified diff from the old content to the new
+	Diff string `json:"diff,omitempty"`
+	// Encrypted notes are recorded without hashes or a diff, which would
+	// reveal their plaintext, so they can't be undone from the journal
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// JournalOperation is one replace, update or undo and the files it wrote
+type JournalOperation struct {
+	ID   string    `json:"id"`
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// Undoes is the operation an undo rolled back
+	Undoes string        `json:"undoes,omitempty"`
+	Files  []JournalFile `json:"files"`
+}
+
+type UndoResult struct {
+	// Undone is the id of the operation rolled back
+	Undone        string   `json:"undone"`
+	RestoredFiles []string `json:"restored_files"`
+	FailedFiles   []string `json:"failed_files,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+	// Operation is the id the undo itself is journaled as
+	Operation string `json:"operation,omitempty"`
+	// Backup is the backup tree holding the files as they were before
+	Backup string `json:"backup,omitempty"`
+}
+
+// journal collects the files one operation writes, and appends the operation
+// to the vault's journal once it completes. A nil journal records nothing, so
+// callers needn't check whether journaling is enabled.
+type journal struct {
+	config   *Config
+	rootPath string
+	op       JournalOperation
+	// written is the number of files in the last appended copy of op
+	written int
+}
+
+// startJournal begins journaling one operation of kind on the vault at
+// rootPath, returning nil when the journal is disabled.
+func (m *DefaultTagManager) startJournal(rootPath, kind string) *journal {
+	if !m.config.Journal {
+		return nil
+	}
+	now := time.Now().UTC()
+	return &journal{
+		config:   m.config,
+		rootPath: rootPath,
+		op:       JournalOperation{ID: now.Format(backupTimeFormat), Kind: kind, Time: now},
+	}
+}
+
+// record notes that path was rewritten from oldContent to newContent, both
+// as plaintext
+func (j *journal) record(path string, oldContent, newContent []byte) {
+	if j == nil {
+		return
+	}
+
+	relPath, err := filepath.Rel(j.rootPath, path)
+	if err != nil {
+		relPath = path
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	if cryptHook(j.config, path) != nil {
+		j.op.Files = append(j.op.Files, JournalFile{Path: relPath, Encrypted: true})
+		return
+	}
+
+	j.op.Files = append(j.op.Files, JournalFile{
+		Path:    relPath,
+		OldHash: hashContent(oldContent),
+		NewHash: hashContent(newContent),
+		Diff:    unifiedDiff("a/"+relPath, "b/"+relPath, string(oldContent), string(newContent)),
+	})
+}
+
+// commit appends the operation to the journal, returning its id, or "" when
+// no files were written
+func (j *journal) commit() (string, error) {
+	if j == nil || len(j.op.Files) == 0 {
+		return "", nil
+	}
+	if err := j.checkpoint(); err != nil {
+		return "", err
+	}
+	return j.op.ID, nil
+}
+
+// checkpoint appends the operation so far to the journal, so the files
+// already written can be undone if the operation never completes. Each
+// checkpoint supersedes the last, as ReadJournal keeps the last copy of an
+// operation.
+func (j *journal) checkpoint() error {
+	if j == nil || len(j.op.Files) == j.written {
+		return nil
+	}
+
+	data, err := json.Marshal(j.op)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	path := filepath.Join(j.rootPath, IndexDir, JournalFileName)
+	perm := newFilePerm(j.config)
+	if err := perm.mkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	file, err := perm.openAppend(path)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	j.written = len(j.op.Files)
+	return nil
+}
+
+// ReadJournal returns the operations journaled in the vault at rootPath,
+// oldest first. A missing journal has no operations.
+func ReadJournal(rootPath string) ([]JournalOperation, error) {
+	file, err := os.Open(filepath.Join(rootPath, IndexDir, JournalFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var ops []JournalOperation
+	// seen indexes ops by id, as a checkpointed operation is appended again
+	// with more files each time
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var op JournalOperation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			// A torn final write from an interrupted process is not fatal
+			continue
+		}
+		if i, ok := seen[op.ID]; ok {
+			ops[i] = op
+			continue
+		}
+		seen[op.ID] = len(ops)
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].ID < ops[j].ID
+	})
+	return ops, nil
+}
+
+// Undo rolls back the journaled operation opID in the vault at rootPath, or
+// the most recent operation not yet undone when opID is empty. Each file is
+// restored only if it is unchanged since the operation wrote it; the others
+// are reported as failed. The undo is journaled in turn, but can't itself be
+// undone.
+func (m *DefaultTagManager) Undo(ctx context.Context, rootPath string, opID string, dryRun bool) (*UndoResult, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	ops, err := ReadJournal(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	undone := make(map[string]bool)
+	for _, op := range ops {
+		if op.Kind == JournalUndo {
+			undone[op.Undoes] = true
+		}
+	}
+
+	var target *JournalOperation
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := &ops[i]
+		if opID == "" && op.Kind != JournalUndo && !undone[op.ID] {
+			target = op
+			break
+		}
+		if opID != "" && op.ID == opID {
+			target = op
+			break
+		}
+	}
+
+	switch {
+	case target == nil && opID == "":
+		return nil, fmt.Errorf("no operations to undo")
+	case target == nil:
+		return nil, fmt.Errorf("operation %s not found in the journal", opID)
+	case target.Kind == JournalUndo:
+		return nil, fmt.Errorf("operation %s is an undo and cannot be undone", target.ID)
+	case undone[target.ID]:
+		return nil, fmt.Errorf("operation %s was already undone", target.ID)
+	}
+
+	result := &UndoResult{
+		Undone:        target.ID,
+		RestoredFiles: []string{},
+		FailedFiles:   []string{},
+		Errors:        []string{},
+	}
+
+	var backup *backup
+	var journal *journal
+	if !dryRun {
+		backup = m.startBackup(rootPath)
+		journal = m.startJournal(rootPath, JournalUndo)
+		if journal != nil {
+			journal.op.Undoes = target.ID
+		}
+	}
+
+	// Later changes to a file are rolled back first
+	for i := len(target.Files) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			break
+		}
+
+		file := target.Files[i]
+		if err := m.undoFile(rootPath, file, dryRun, backup, journal); err != nil {
+			result.FailedFiles = append(result.FailedFiles, file.Path)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", file.Path, err))
+			continue
+		}
+		result.RestoredFiles = append(result.RestoredFiles, file.Path)
+	}
+
+	sort.Strings(result.RestoredFiles)
+	sort.Strings(result.FailedFiles)
+	result.Backup = backup.location()
+
+	id, err := journal.commit()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Operation = id
+
+	return result, nil
+}
+
+func (m *DefaultTagManager) undoFile(rootPath string, file JournalFile, dryRun bool, backup *backup, journal *journal) error {
+	if file.Encrypted {
+		return fmt.Errorf("encrypted notes are not journaled; restore them from a backup")
+	}
+
+	path := filepath.Join(rootPath, filepath.FromSlash(file.Path))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if hashContent(content) != file.NewHash {
+		return fmt.Errorf("file has changed since the operation")
+	}
+
+	original, err := reverseDiff(string(content), file.Diff)
+	if err != nil {
+		return err
+	}
+	if hashContent([]byte(original)) != file.OldHash {
+		return fmt.Errorf("journaled diff does not restore the original content")
+	}
+
+	if dryRun {
+		return nil
+	}
+	if err := backup.save(path); err != nil {
+		return err
+	}
+	if err := checkUnchanged(path, content); err != nil {
+		return err
+	}
+	if err := overwriteFile(path, []byte(original), m.config.PreserveMtime); err != nil {
+		return err
+	}
+	journal.record(path, content, []byte(original))
+	return nil
+}
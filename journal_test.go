@@ -0,0 +1,195 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestJournalUndo(t *testing.T) {
+	ctx := context.Background()
+
+	newManager := func(t *testing.T) tagmanager.TagManager {
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+		return manager
+	}
+
+	long := strings.Repeat("filler line\n", 20)
+
+	for _, test := range []struct {
+		name    string
+		content string
+	}{
+		{name: "Simple", content: "---\ntags: [golang]\n---\n#draft note\n"},
+		{name: "NoTrailingNewline", content: "first line\n#draft"},
+		{name: "CRLF", content: "---\r\ntags: [draft]\r\n---\r\nbody #draft\r\n"},
+		{name: "SeparateHunks", content: "#draft start\n" + long + "middle #draft\n" + long + "end #draft"},
+		{name: "OnlyTag", content: "#draft"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, map[string]string{"notes/a.md": test.content})
+			manager := newManager(t)
+
+			result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+			require.NoError(t, err)
+			require.Len(t, result.ModifiedFiles, 1)
+			require.NotEmpty(t, result.Operation)
+
+			changed, err := os.ReadFile(filepath.Join(root, "notes", "a.md"))
+			require.NoError(t, err)
+			require.NotEqual(t, test.content, string(changed))
+
+			undo, err := manager.Undo(ctx, root, "", false)
+			require.NoError(t, err)
+			assert.Equal(t, result.Operation, undo.Undone)
+			assert.Equal(t, []string{"notes/a.md"}, undo.RestoredFiles)
+			assert.Empty(t, undo.Errors)
+
+			restored, err := os.ReadFile(filepath.Join(root, "notes", "a.md"))
+			require.NoError(t, err)
+			assert.Equal(t, test.content, string(restored))
+		})
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		original := "---\ntitle: Note\n---\n#inline body\n"
+		root := writeVault(t, map[string]string{"a.md": original})
+		manager := newManager(t)
+
+		result, err := manager.UpdateTags(ctx, []string{"golang"}, nil, root, []string{"a.md"}, false)
+		require.NoError(t, err)
+		require.NotEmpty(t, result.Operation)
+
+		ops, err := tagmanager.ReadJournal(root)
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, tagmanager.JournalUpdate, ops[0].Kind)
+		require.Len(t, ops[0].Files, 1)
+		assert.Equal(t, "a.md", ops[0].Files[0].Path)
+		assert.NotEqual(t, ops[0].Files[0].OldHash, ops[0].Files[0].NewHash)
+		assert.Contains(t, ops[0].Files[0].Diff, "- golang")
+
+		_, err = manager.Undo(ctx, root, result.Operation, false)
+		require.NoError(t, err)
+
+		restored, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, original, string(restored))
+	})
+
+	t.Run("LastSkipsUndone", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#one", "b.md": "#two"})
+		manager := newManager(t)
+
+		first, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "one", NewTag: "uno"}}, root, false)
+		require.NoError(t, err)
+		second, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "two", NewTag: "dos"}}, root, false)
+		require.NoError(t, err)
+
+		undo, err := manager.Undo(ctx, root, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, second.Operation, undo.Undone)
+		require.NotEmpty(t, undo.Operation)
+
+		undo, err = manager.Undo(ctx, root, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, first.Operation, undo.Undone)
+
+		_, err = manager.Undo(ctx, root, "", false)
+		assert.ErrorContains(t, err, "no operations to undo")
+
+		_, err = manager.Undo(ctx, root, first.Operation, false)
+		assert.ErrorContains(t, err, "already undone")
+
+		_, err = manager.Undo(ctx, root, undo.Operation, false)
+		assert.ErrorContains(t, err, "cannot be undone")
+
+		_, err = manager.Undo(ctx, root, "missing", false)
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("ChangedSince", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft", "b.md": "#draft"})
+		manager := newManager(t)
+
+		_, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.md"), []byte("#wip edited"), tagmanager.DefaultFilePermissions))
+
+		undo, err := manager.Undo(ctx, root, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md"}, undo.RestoredFiles)
+		assert.Equal(t, []string{"b.md"}, undo.FailedFiles)
+		require.Len(t, undo.Errors, 1)
+		assert.Contains(t, undo.Errors[0], "changed since")
+
+		content, err := os.ReadFile(filepath.Join(root, "b.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#wip edited", string(content))
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft"})
+		manager := newManager(t)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, true)
+		require.NoError(t, err)
+		assert.Empty(t, result.Operation)
+
+		_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+
+		undo, err := manager.Undo(ctx, root, "", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md"}, undo.RestoredFiles)
+		assert.Empty(t, undo.Operation)
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#wip", string(content))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#draft"})
+		config := tagmanager.DefaultConfig()
+		config.Journal = false
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Operation)
+		assert.NoFileExists(t, filepath.Join(root, tagmanager.IndexDir, tagmanager.JournalFileName))
+	})
+}
+
+func TestUndoCommand(t *testing.T) {
+	root := writeVault(t, map[string]string{"a.md": "#draft"})
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "replace", "--old=draft", "--new=wip", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"Journaled as operation"})
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "undo", "--list", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"replace", "1 files"})
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "undo", "--last", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{"Undoing operation", "Restored files: 1"})
+
+	content, err := os.ReadFile(filepath.Join(root, "a.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "#draft", string(content))
+}
@@ -0,0 +1,100 @@
+package tagmanager
+
+import "strings"
+
+// Obsidian Kanban boards end with a settings block holding the board's
+// configuration as JSON, which can mention tags (tag colors, tag sorting):
+//
+//	%% kanban:settings
+//	```
+//	{"kanban-plugin":"basic"}
+//	```
+//	%%
+//
+// The block is plugin data rather than note content, so it is never scanned
+// for tags and never modified; rewriting it corrupts the board.
+const kanbanSettingsStart = "%% kanban:settings"
+
+func isKanbanSettingsStart(line string) bool {
+	return strings.TrimSpace(line) == kanbanSettingsStart
+}
+
+func isKanbanSettingsEnd(line string) bool {
+	return strings.HasSuffix(strings.TrimSpace(line), "%%")
+}
+
+// kanbanSettingsRanges returns the byte ranges of the Kanban settings blocks
+// in content, each covering whole lines. A block without an end runs to the
+// end of the content.
+func kanbanSettingsRanges(content string) [][2]int {
+	var ranges [][2]int
+	start := -1
+
+	for pos := 0; pos < len(content); {
+		end := strings.IndexByte(content[pos:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += pos + 1
+		}
+		line := content[pos:end]
+
+		switch {
+		case start < 0 && isKanbanSettingsStart(line):
+			start = pos
+		case start >= 0 && isKanbanSettingsEnd(line):
+			ranges = append(ranges, [2]int{start, end})
+			start = -1
+		}
+		pos = end
+	}
+
+	if start >= 0 {
+		ranges = append(ranges, [2]int{start, len(content)})
+	}
+	return ranges
+}
+
+// stripKanbanSettings removes the Kanban settings blocks from content
+func stripKanbanSettings(content string) string {
+	ranges := kanbanSettingsRanges(content)
+	if len(ranges) == 0 {
+		return content
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		b.WriteString(content[pos:r[0]])
+		pos = r[1]
+	}
+	b.WriteString(content[pos:])
+	return b.String()
+}
+
+// mapOutsideKanbanSettings applies fn to the content outside Kanban settings
+// blocks, leaving the blocks unchanged.
+func mapOutsideKanbanSettings(content string, fn func(string) string) string {
+	ranges := kanbanSettingsRanges(content)
+	if len(ranges) == 0 {
+		return fn(content)
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		b.WriteString(fn(content[pos:r[0]]))
+		b.WriteString(content[r[0]:r[1]])
+		pos = r[1]
+	}
+	b.WriteString(fn(content[pos:]))
+	return b.String()
+}
+
+// mapOutsideProtected applies fn to the content tags may be changed in: outside
+// ignored regions and Kanban settings blocks.
+func mapOutsideProtected(content string, fn func(string) string) string {
+	return mapOutsideIgnored(content, func(text string) string {
+		return mapOutsideKanbanSettings(text, fn)
+	})
+}
@@ -0,0 +1,75 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+const kanbanSettings = "%% kanban:settings\n```\n{\"kanban-plugin\":\"basic\",\"tag-colors\":[{\"tagKey\":\"#draft\",\"color\":\"red\"},{\"tagKey\":\"#board-only\"}]}\n```\n%%\n"
+
+const kanbanBoard = "---\n\nkanban-plugin: basic\n\n---\n\n## Todo\n\n- [ ] Write docs #draft\n- [ ] Review #urgent\n\n## Done\n\n- [x] Ship it #release\n\n\n" + kanbanSettings
+
+func TestKanbanBoards(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Extract", func(t *testing.T) {
+		scanner, err := tagmanager.NewFilesystemScanner(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"draft", "urgent", "release"}, scanner.ExtractTags(kanbanBoard))
+
+		tags, _, err := scanner.ExtractTagsFromReader(ctx, strings.NewReader(kanbanBoard))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"draft", "urgent", "release"}, tags)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"board.md": kanbanBoard})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(root, "board.md"))
+		require.NoError(t, err)
+		assert.Equal(t, strings.Replace(kanbanBoard, "Write docs #draft", "Write docs #wip", 1), string(content))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"board.md": kanbanBoard})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		result, err := manager.UpdateTags(ctx, []string{"board"}, []string{"draft"}, root, []string{"board.md"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+
+		content, err := os.ReadFile(filepath.Join(root, "board.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "kanban-plugin: basic")
+		assert.Contains(t, string(content), "- board")
+		assert.NotContains(t, string(content), "Write docs #draft")
+		assert.True(t, strings.HasSuffix(string(content), kanbanSettings))
+	})
+
+	t.Run("BodyListItemsUntouched", func(t *testing.T) {
+		note := "---\ntags:\n  - draft\n---\nSteps:\n  - draft\n  - review\n"
+		root := writeVault(t, map[string]string{"note.md": note})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "draft", NewTag: "wip"}}, root, false)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(root, "note.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "---\ntags:\n  - \"wip\"\n---\nSteps:\n  - draft\n  - review\n", string(content))
+	})
+}
@@ -2,12 +2,12 @@ package tagmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,34 +16,128 @@ const DefaultFilePermissions = 0644
 
 type TagManager interface {
 	FindFilesByTags(ctx context.Context, tags []string, rootPath string) (map[string][]string, error)
+	FindFilesWithAllTags(ctx context.Context, tags []string, rootPath string) ([]string, error)
 	GetTagsInfo(ctx context.Context, tags []string, rootPath string) ([]TagInfo, error)
+	ProfileTags(ctx context.Context, tags []string, rootPath string) ([]TagProfile, error)
 	ListAllTags(ctx context.Context, rootPath string, minCount int) ([]TagInfo, error)
+	TagTree(ctx context.Context, rootPath string) ([]TagNode, error)
+	TagGraph(ctx context.Context, kind string, rootPath string) (*TagGraph, error)
+	ExportTags(ctx context.Context, rootPath string) (*TagExport, error)
+	Report(ctx context.Context, rootPath string, top int) (*VaultReport, error)
 	ReplaceTagsBatch(ctx context.Context, replacements []TagReplacement, rootPath string, dryRun bool) (*TagReplaceResult, error)
 	GetUntaggedFiles(ctx context.Context, rootPath string) ([]FileTagInfo, error)
+	GetFilesNotTaggedWith(ctx context.Context, tags []string, rootPath string) ([]FileTagInfo, error)
 	GetFilesTags(ctx context.Context, filePaths []string) ([]FileTagInfo, error)
+	ExpandFilePaths(ctx context.Context, rootPath string, filePaths []string) ([]string, error)
 	ValidateTags(ctx context.Context, tags []string) map[string]*ValidationResult
+	ValidateVault(ctx context.Context, rootPath string) (*VaultValidation, error)
 	UpdateTags(ctx context.Context, addTags []string, removeTags []string, rootPath string, filePaths []string, dryRun bool) (*TagUpdateResult, error)
+	UpdateTagsPerFile(ctx context.Context, rootPath string, ops []FileTagOp, dryRun bool) (*TagUpdateResult, error)
+	SuggestNamespaces(ctx context.Context, rootPath string, minCount int, threshold float64) ([]NamespaceSuggestion, error)
+	AuditFrontmatter(ctx context.Context, rootPath string) ([]FrontmatterProblem, error)
+	FixFrontmatter(ctx context.Context, rootPath string, dryRun bool) (*FrontmatterFixResult, error)
+	ProposeConfig(ctx context.Context, rootPath string) (*ConfigProposal, error)
+	UpdateIndex(ctx context.Context, rootPath string) (*IndexStats, error)
+	CompactIndex(ctx context.Context, rootPath string) (*IndexStats, error)
+	InspectIndex(ctx context.Context, rootPath string, tag string, file string) ([]IndexRecordStatus, error)
+	WhatChanged(ctx context.Context, rootPath string, since time.Time) ([]TagChange, error)
+	GetTagHistory(ctx context.Context, rootPath string, tags []string) ([]TagHistory, error)
+	TriageUntagged(ctx context.Context, rootPath string) ([]TriageItem, error)
+	PlanAutoTags(ctx context.Context, rootPath string) ([]FileTagOp, error)
+	PlanClean(ctx context.Context, rootPath string) (*CleanPlan, error)
+	WithFilter(filter FileFilter) TagManager
+	WithScanReport(report *ScanReport) TagManager
+	WithOrder(order ScanOrder) TagManager
+	WithProgress(progress ProgressFunc) TagManager
+	WithMeter(meter MeterFunc) TagManager
+	WithPreflight(mode string) TagManager
+	WithMigrate(patterns []string) TagManager
+	WithExtensions(extensions []string) TagManager
+	WithNotTags(tags []string) TagManager
+	WithOccurrences(decide OccurrenceFunc) TagManager
+	WithMaxModified(limit int) TagManager
+	WithResume(resume bool) TagManager
+	WithTransactional(enabled bool) TagManager
+	PruneBackups(ctx context.Context, rootPath string, keep int, olderThan time.Duration) ([]string, error)
+	Undo(ctx context.Context, rootPath string, opID string, dryRun bool) (*UndoResult, error)
+	BeginChangeSet(ctx context.Context, rootPath string) *ChangeSet
+	PreviewUpdateTags(ctx context.Context, addTags []string, removeTags []string, rootPath string, filePaths []string) (*UpdatePreview, error)
+	SelfTest(ctx context.Context, rootPath string, sampleSize int) (*SelfTestReport, error)
+	Doctor(ctx context.Context, rootPath string) (*DoctorReport, error)
 }
 
 type DefaultTagManager struct {
 	scanner   Scanner
 	validator Validator
 	config    *Config
+	rules     *RuleSet
+	// report, when set, collects the errors scans skip over
+	report *ScanReport
+	// ordered is set when the scanner yields files in a chosen order, which
+	// results keep instead of sorting by path
+	ordered bool
+	// transactional makes ReplaceTagsBatch modify every file or none
+	transactional bool
+	// progress, when set, is told about each file a batch operation finishes
+	progress ProgressFunc
+	// meter, when set, is told how far scans and batches have got
+	meter MeterFunc
+	// preflightMode is how files which can't be modified are handled before
+	// a batch operation starts, see the Preflight constants
+	preflightMode string
+	// migrate are the patterns of the top-of-file hashtags UpdateTags moves
+	// to frontmatter; empty moves them all
+	migrate []string
+	// maxModified caps the files a replace or update may modify; zero is
+	// no cap
+	maxModified int
+	// resume skips the files an interrupted replace or update finished
+	resume bool
+	// notTags, normalized, leave the files carrying them out of find results
+	notTags []string
+	// decide, when set, chooses which occurrences ReplaceTagsBatch renames
+	decide OccurrenceFunc
 }
 
 func NewDefaultTagManager(config *Config) (*DefaultTagManager, error) {
-	scanner, err := NewFilesystemScanner(config)
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+
+	rules, err := NewRuleSet(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scanner: %w", err)
 	}
 
 	return &DefaultTagManager{
-		scanner:   scanner,
-		validator: NewDefaultValidator(config),
-		config:    config,
+		scanner:       newFilesystemScanner(rules),
+		validator:     newDefaultValidator(rules),
+		config:        config,
+		rules:         rules,
+		transactional: config.Transactional,
+		preflightMode: config.Preflight,
+		migrate:       config.Migrate,
+		maxModified:   config.MaxModified,
 	}, nil
 }
 
+// WithExtensions returns a manager whose directory scans walk the files with
+// extensions, such as md and txt, instead of those of Config.Extensions. It
+// must be applied before the options which wrap scans, such as WithFilter
+// and WithOrder.
+func (m *DefaultTagManager) WithExtensions(extensions []string) TagManager {
+	widened := *m
+	if scanner, ok := m.scanner.(*FilesystemScanner); ok {
+		widened.scanner = scanner.withExtensions(extensions)
+	}
+	return &widened
+}
+
+// Rules returns the compiled tag rules shared by the manager's scanner and validator
+func (m *DefaultTagManager) Rules() *RuleSet {
+	return m.rules
+}
+
 func (m *DefaultTagManager) FindFilesByTags(ctx context.Context, tags []string, rootPath string) (map[string][]string, error) {
 	if err := m.validator.ValidatePath(rootPath); err != nil {
 		return nil, fmt.Errorf("invalid root path: %w", err)
@@ -55,8 +149,11 @@ func (m *DefaultTagManager) FindFilesByTags(ctx context.Context, tags []string,
 		result[tag] = []string{}
 	}
 
-	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+	for fileInfo, err := range m.indexedScanner(rootPath).ScanDirectory(ctx, rootPath, nil) {
 		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -64,6 +161,9 @@ func (m *DefaultTagManager) FindFilesByTags(ctx context.Context, tags []string,
 		for _, tag := range fileInfo.Tags {
 			fileTags[m.normalizeTag(tag)] = true
 		}
+		if m.excludedByNotTags(fileTags) {
+			continue
+		}
 
 		for _, searchTag := range normalizedTags {
 			if fileTags[searchTag] {
@@ -88,9 +188,10 @@ func (m *DefaultTagManager) GetTagsInfo(ctx context.Context, tags []string, root
 	var result []TagInfo
 	for tag, files := range filesByTag {
 		result = append(result, TagInfo{
-			Name:  tag,
-			Count: len(files),
-			Files: files,
+			Name:     tag,
+			Count:    len(files),
+			Files:    files,
+			Metadata: m.tagMetadata(tag),
 		})
 	}
 
@@ -108,8 +209,11 @@ func (m *DefaultTagManager) ListAllTags(ctx context.Context, rootPath string, mi
 
 	tagCounts := make(map[string]map[string]bool)
 
-	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+	for fileInfo, err := range m.indexedScanner(rootPath).ScanDirectory(ctx, rootPath, nil) {
 		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -122,10 +226,30 @@ func (m *DefaultTagManager) ListAllTags(ctx context.Context, rootPath string, mi
 		}
 	}
 
+	if m.config.Strict {
+		names := make([]string, 0, len(tagCounts))
+		for tag := range tagCounts {
+			names = append(names, tag)
+		}
+		if err := checkCaseCollisions(names); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pinned tags are always listed, even when unused or below minCount
+	pinned := make(map[string]bool)
+	for _, tag := range m.config.PinnedTags {
+		normalized := m.normalizeTag(tag)
+		pinned[normalized] = true
+		if tagCounts[normalized] == nil {
+			tagCounts[normalized] = make(map[string]bool)
+		}
+	}
+
 	var result []TagInfo
 	for tag, files := range tagCounts {
 		count := len(files)
-		if count >= minCount {
+		if count >= minCount || pinned[tag] {
 			fileList := make([]string, 0, len(files))
 			for file := range files {
 				fileList = append(fileList, file)
@@ -133,14 +257,19 @@ func (m *DefaultTagManager) ListAllTags(ctx context.Context, rootPath string, mi
 			sort.Strings(fileList)
 
 			result = append(result, TagInfo{
-				Name:  tag,
-				Count: count,
-				Files: fileList,
+				Name:     tag,
+				Count:    count,
+				Files:    fileList,
+				Pinned:   pinned[tag],
+				Metadata: m.tagMetadata(tag),
 			})
 		}
 	}
 
 	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pinned != result[j].Pinned {
+			return result[i].Pinned
+		}
 		if result[i].Count != result[j].Count {
 			return result[i].Count > result[j].Count
 		}
@@ -155,6 +284,20 @@ func (m *DefaultTagManager) ReplaceTagsBatch(ctx context.Context, replacements [
 		return nil, fmt.Errorf("invalid root path: %w", err)
 	}
 
+	if m.maxModified > 0 && !dryRun {
+		preview, err := m.uncapped().ReplaceTagsBatch(ctx, replacements, rootPath, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.checkMaxModified(len(preview.Diffs)); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.transactional && !dryRun {
+		return m.replaceTagsTransactional(ctx, replacements, rootPath)
+	}
+
 	result := &TagReplaceResult{
 		ModifiedFiles: []string{},
 		FailedFiles:   []string{},
@@ -176,22 +319,79 @@ func (m *DefaultTagManager) ReplaceTagsBatch(ctx context.Context, replacements [
 		}
 	}
 
+	files := make([]string, 0, len(filesToProcess))
 	for file := range filesToProcess {
-		if ctx.Err() != nil {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	files, problems, err := m.preflight(rootPath, files)
+	if err != nil {
+		return nil, err
+	}
+	result.Preflight = problems
+	for _, problem := range problems {
+		result.FailedFiles = append(result.FailedFiles, problem.Path)
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", problem.Path, problem.Error))
+	}
+
+	var backup *backup
+	var journal *journal
+	var checkpoint *checkpoint
+	if !dryRun {
+		if checkpoint, err = m.startCheckpoint(rootPath, JournalReplace, replacements); err != nil {
+			return nil, err
+		}
+		backup = m.startBackup(rootPath)
+		journal = m.startJournal(rootPath, JournalReplace)
+	}
+
+	chunks := m.newChunker(ctx, len(files), journal)
+	for i, file := range files {
+		if err := chunks.proceed(i); err != nil {
+			result.Remaining = files[i:]
+			result.Stopped = err.Error()
 			break
 		}
+		if checkpoint.skip(file) {
+			result.Resumed++
+			continue
+		}
 
-		if err := m.replaceTagsInFile(file, replacements, dryRun); err != nil {
+		before, after, err := m.replaceTagsInFile(ctx, file, replacements, dryRun, backup, journal)
+		m.fileDone(file, err == nil && before != after, err)
+		if err != nil {
 			result.FailedFiles = append(result.FailedFiles, file)
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", file, err))
 			continue
 		}
+		if err := checkpoint.record(file); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
 
-		result.ModifiedFiles = append(result.ModifiedFiles, file)
+		// A file whose every occurrence was declined, or whose tag is written
+		// in a way replace can't rename, wasn't modified
+		if before != after {
+			result.ModifiedFiles = append(result.ModifiedFiles, file)
+		}
+		if dryRun && before != after {
+			result.Diffs = append(result.Diffs, dryRunDiff(rootPath, file, before, after))
+		}
 	}
 
 	sort.Strings(result.ModifiedFiles)
 	sort.Strings(result.FailedFiles)
+	sortPlannedChanges(result.Diffs)
+	result.Backup = backup.location()
+
+	id, err := journal.commit()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Operation = id
+	if err := checkpoint.finish(result.Stopped == ""); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
 
 	return result, nil
 }
@@ -205,6 +405,9 @@ func (m *DefaultTagManager) GetUntaggedFiles(ctx context.Context, rootPath strin
 
 	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
 		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -213,9 +416,11 @@ func (m *DefaultTagManager) GetUntaggedFiles(ctx context.Context, rootPath strin
 		}
 	}
 
-	sort.Slice(untagged, func(i, j int) bool {
-		return untagged[i].Path < untagged[j].Path
-	})
+	if !m.ordered {
+		sort.Slice(untagged, func(i, j int) bool {
+			return untagged[i].Path < untagged[j].Path
+		})
+	}
 
 	return untagged, nil
 }
@@ -239,6 +444,9 @@ func (m *DefaultTagManager) GetFilesTags(ctx context.Context, filePaths []string
 
 		fileInfo, err := m.scanner.ScanFile(ctx, absPath)
 		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
 			result = append(result, FileTagInfo{
 				Path: absPath,
 				Tags: nil,
@@ -267,34 +475,241 @@ func (m *DefaultTagManager) ValidateTags(ctx context.Context, tags []string) map
 	return results
 }
 
-func (m *DefaultTagManager) replaceTagsInFile(filePath string, replacements []TagReplacement, dryRun bool) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
+// SuggestNamespaces finds flat tags used in at least minCount files which
+// co-occur with a single namespace (the first segment of a nested tag) in at
+// least threshold of those files, and suggests moving them into it.
+func (m *DefaultTagManager) SuggestNamespaces(ctx context.Context, rootPath string, minCount int, threshold float64) ([]NamespaceSuggestion, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
 	}
 
-	originalContent := string(content)
-	modifiedContent := originalContent
+	tagFiles := make(map[string]int)
+	coOccurrence := make(map[string]map[string]int)
 
-	for _, replacement := range replacements {
-		oldTag := m.normalizeTag(replacement.OldTag)
-		newTag := m.normalizeTag(replacement.NewTag)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-		hashtagPattern := regexp.MustCompile(`#` + regexp.QuoteMeta(oldTag) + `\b`)
-		modifiedContent = hashtagPattern.ReplaceAllString(modifiedContent, "#"+newTag)
+		var flatTags []string
+		namespaces := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			normalized := m.normalizeTag(tag)
+			if namespace, _, nested := strings.Cut(normalized, "/"); nested {
+				namespaces[namespace] = true
+				continue
+			}
+			flatTags = append(flatTags, normalized)
+		}
 
-		yamlArrayPattern := regexp.MustCompile(`(tags:\s*\[[^\]]*)"?` + regexp.QuoteMeta(oldTag) + `"?([^\]]*\])`)
-		modifiedContent = yamlArrayPattern.ReplaceAllString(modifiedContent, `${1}"`+newTag+`"${2}`)
+		for _, tag := range flatTags {
+			tagFiles[tag]++
+			if coOccurrence[tag] == nil {
+				coOccurrence[tag] = make(map[string]int)
+			}
+			for namespace := range namespaces {
+				coOccurrence[tag][namespace]++
+			}
+		}
+	}
+
+	var result []NamespaceSuggestion
+	for tag, count := range tagFiles {
+		if count < minCount {
+			continue
+		}
 
-		yamlListPattern := regexp.MustCompile(`(?m)(^\s+-\s+)"?` + regexp.QuoteMeta(oldTag) + `"?\s*$`)
-		modifiedContent = yamlListPattern.ReplaceAllString(modifiedContent, `${1}"`+newTag+`"`)
+		var best string
+		for namespace, n := range coOccurrence[tag] {
+			if namespace == tag {
+				continue
+			}
+			if best == "" || n > coOccurrence[tag][best] || (n == coOccurrence[tag][best] && namespace < best) {
+				best = namespace
+			}
+		}
+		if best == "" {
+			continue
+		}
+
+		ratio := float64(coOccurrence[tag][best]) / float64(count)
+		if ratio < threshold {
+			continue
+		}
+
+		result = append(result, NamespaceSuggestion{
+			Tag:          tag,
+			Namespace:    best,
+			SuggestedTag: best + "/" + tag,
+			Count:        count,
+			CoOccurrence: coOccurrence[tag][best],
+			Ratio:        ratio,
+		})
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+
+	return result, nil
+}
+
+// replaceTagsInFile renames tags in the note at filePath, returning its
+// plaintext before and after
+func (m *DefaultTagManager) replaceTagsInFile(ctx context.Context, filePath string, replacements []TagReplacement, dryRun bool, backup *backup, journal *journal) (string, string, error) {
+	// A note saved by an editor while it was being rewritten is read again,
+	// so the edit isn't lost
+	for attempt := 0; ; attempt++ {
+		before, after, err := m.replaceTagsInFileOnce(ctx, filePath, replacements, dryRun, backup, journal)
+		if errors.Is(err, ErrNoteChanged) && attempt < noteChangedRetries {
+			continue
+		}
+		return before, after, err
+	}
+}
+
+func (m *DefaultTagManager) replaceTagsInFileOnce(ctx context.Context, filePath string, replacements []TagReplacement, dryRun bool, backup *backup, journal *journal) (string, string, error) {
+	stored, content, err := readStoredNote(ctx, m.config, filePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	originalContent, format := normalizeText(string(content))
+	if hasIgnoreFileDirective(originalContent) {
+		return string(content), string(content), nil
+	}
+
+	var modifiedContent string
+	if m.decide != nil {
+		modifiedContent = m.decideOccurrences(filePath, originalContent, replacements)
+	} else {
+		modifiedContent = m.replaceTagsInContent(originalContent, replacements)
+	}
+	newContent := []byte(format.restore(modifiedContent))
+
 	if modifiedContent != originalContent && !dryRun {
-		return os.WriteFile(filePath, []byte(modifiedContent), DefaultFilePermissions)
+		if err := backup.save(filePath); err != nil {
+			return "", "", err
+		}
+		if err := writeNote(ctx, m.config, filePath, stored, newContent); err != nil {
+			return "", "", err
+		}
+		journal.record(filePath, content, newContent)
 	}
 
-	return nil
+	return string(content), string(newContent), nil
+}
+
+// replaceTagsInContent renames tags in normalized note content, leaving
+// protected regions unchanged
+func (m *DefaultTagManager) replaceTagsInContent(content string, replacements []TagReplacement) string {
+	return m.replaceOccurrences(content, replacements, nil)
+}
+
+// replaceOccurrences is replaceTagsInContent, calling render, when set, with
+// each occurrence's number, its text and the text which would rename it, for
+// the text to put in its place. Occurrences are numbered from 0 in the same
+// order on every call for the same content and replacements.
+func (m *DefaultTagManager) replaceOccurrences(content string, replacements []TagReplacement, render func(n int, oldText, newText string) string) string {
+	n := 0
+	replace := func(oldText, newText string) string {
+		if render == nil {
+			return newText
+		}
+		text := render(n, oldText, newText)
+		n++
+		return text
+	}
+
+	renames := make(map[string]string, len(replacements))
+	for _, replacement := range replacements {
+		oldTag := m.normalizeTag(replacement.OldTag)
+		if _, ok := renames[oldTag]; !ok {
+			renames[oldTag] = m.normalizeTag(replacement.NewTag)
+		}
+	}
+	replaceHashtags := func(text string) string {
+		for _, replacement := range replacements {
+			newTag := m.normalizeTag(replacement.NewTag)
+			text = m.replaceHashtagsFunc(text, m.normalizeTag(replacement.OldTag), func(hashtag string) string {
+				return replace(hashtag, "#"+newTag)
+			})
+		}
+		return text
+	}
+
+	inner, body, ok := splitFrontmatter(content)
+	if !ok {
+		return mapOutsideProtected(content, func(text string) string {
+			return mapOutsideCode(text, replaceHashtags)
+		})
+	}
+	frontmatter := content[:len(content)-len(body)]
+
+	// Frontmatter tags are renamed where the scanner reads them, found from
+	// the parsed YAML, so every form it accepts is renamed and list items
+	// which merely look like tags are left alone. The spans are found before
+	// any is replaced, so each tag is renamed once.
+	ignored := make([]bool, len(frontmatter))
+	segments, _ := splitIgnored(frontmatter, false)
+	pos := 0
+	for _, segment := range segments {
+		for i := pos; i < pos+len(segment.text); i++ {
+			ignored[i] = segment.ignored
+		}
+		pos += len(segment.text)
+	}
+	var b strings.Builder
+	pos = 0
+	offset := len("---\n")
+	for _, span := range frontmatterTagSpans(inner) {
+		newTag, ok := renames[span.tag]
+		start, end := offset+span.start, offset+span.end
+		if !ok || ignored[start] {
+			continue
+		}
+		b.WriteString(frontmatter[pos:start])
+		if span.element {
+			b.WriteString(replace(frontmatter[start:end], `"`+newTag+`"`))
+		} else {
+			b.WriteString(replace(frontmatter[start:end], newTag))
+		}
+		pos = end
+	}
+	b.WriteString(frontmatter[pos:])
+
+	return mapOutsideIgnored(b.String(), replaceHashtags) + mapOutsideProtected(body, func(text string) string {
+		return mapOutsideCode(text, replaceHashtags)
+	})
+}
+
+// replaceHashtags replaces the hashtags of tag in text with replacement. Only
+// the hashtags the scanner finds are replaced.
+func (m *DefaultTagManager) replaceHashtags(text, tag, replacement string) string {
+	return m.replaceHashtagsFunc(text, tag, func(string) string { return replacement })
+}
+
+// replaceHashtagsFunc replaces each hashtag of tag in text with fn's result
+// for the hashtag
+func (m *DefaultTagManager) replaceHashtagsFunc(text, tag string, fn func(hashtag string) string) string {
+	var b strings.Builder
+	pos := 0
+	for _, hashtag := range m.rules.hashtags(text) {
+		if hashtag.tag != tag {
+			continue
+		}
+		b.WriteString(text[pos:hashtag.start])
+		b.WriteString(fn(text[hashtag.start:hashtag.end]))
+		pos = hashtag.end
+	}
+	b.WriteString(text[pos:])
+	return b.String()
 }
 
 func (m *DefaultTagManager) normalizeTag(tag string) string {
@@ -337,153 +752,265 @@ func (m *DefaultTagManager) UpdateTags(ctx context.Context, addTags []string, re
 		Errors:        make([]string, 0),
 	}
 
-	for _, filePath := range filePaths {
-		cleanPath := filepath.Clean(filePath)
-		if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: path must be relative to root and cannot contain '..'", filePath))
-			continue
+	ops := make([]FileTagOp, len(filePaths))
+	for i, filePath := range filePaths {
+		ops[i] = FileTagOp{Path: filePath, Add: m.normalizeTags(resolvedAddTags), Remove: resolvedRemoveTags}
+	}
+	if err := m.applyFileTagOps(ctx, rootPath, ops, dryRun, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// applyFileTagOps adds and removes the resolved tags of each op in its file,
+// collecting the outcome in result
+func (m *DefaultTagManager) applyFileTagOps(ctx context.Context, rootPath string, ops []FileTagOp, dryRun bool, result *TagUpdateResult) error {
+	if m.maxModified > 0 && !dryRun {
+		preview := &TagUpdateResult{TagsAdded: make(map[string]int), TagsRemoved: make(map[string]int)}
+		if err := m.uncapped().applyFileTagOps(ctx, rootPath, ops, true, preview); err != nil {
+			return err
 		}
+		if err := m.checkMaxModified(len(preview.ModifiedFiles)); err != nil {
+			return err
+		}
+	}
+
+	valid, problems, err := m.preflight(rootPath, opPaths(ops))
+	if err != nil {
+		return err
+	}
+	result.Preflight = problems
+	for _, problem := range problems {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", problem.Path, problem.Error))
+	}
+	checked := make(map[string]bool, len(valid))
+	for _, path := range valid {
+		checked[path] = true
+	}
 
-		absolutePath := filepath.Join(rootPath, cleanPath)
-		if err := m.validator.ValidatePath(absolutePath); err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid path: %v", filePath, err))
+	var backup *backup
+	var journal *journal
+	var checkpoint *checkpoint
+	if !dryRun {
+		if checkpoint, err = m.startCheckpoint(rootPath, JournalUpdate, ops); err != nil {
+			return err
+		}
+		backup = m.startBackup(rootPath)
+		journal = m.startJournal(rootPath, JournalUpdate)
+	}
+
+	chunks := m.newChunker(ctx, len(ops), journal)
+	for i, op := range ops {
+		if err := chunks.proceed(i); err != nil {
+			for _, op := range ops[i:] {
+				if checked[op.Path] {
+					result.Remaining = append(result.Remaining, op.Path)
+				}
+			}
+			result.Stopped = err.Error()
+			break
+		}
+
+		filePath := op.Path
+		if !checked[filePath] {
+			continue
+		}
+		if checkpoint.skip(filePath) {
+			result.Resumed++
 			continue
 		}
 
-		content, err := os.ReadFile(absolutePath)
+		absolutePath, err := m.notePath(rootPath, filePath)
 		if err != nil {
+			m.fileDone(filePath, false, err)
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", filePath, err))
 			continue
 		}
 
-		originalContent := string(content)
-		modified := false
-
-		frontmatterData, bodyContent, err := m.parseFrontmatter(originalContent)
+		update, err := m.updateTagsInFile(ctx, absolutePath, op.Add, op.Remove, dryRun, backup, journal)
+		m.fileDone(filePath, update.modified, err)
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: malformed YAML frontmatter: %v", filePath, err))
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", filePath, err))
 			continue
 		}
+		if err := checkpoint.record(filePath); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
 
-		topHashtags := m.DetectTopOfFileHashtags(bodyContent)
-		migrationOccurred := len(topHashtags) > 0
-		if migrationOccurred {
+		if len(update.migrated) > 0 {
 			result.FilesMigrated = append(result.FilesMigrated, filePath)
-			for _, tag := range topHashtags {
+			for _, tag := range update.migrated {
 				result.TagsAdded[tag]++
 			}
-			bodyContent = m.removeTopHashtags(bodyContent, topHashtags)
-			modified = true
 		}
-
-		allAddTags := append(m.normalizeTags(resolvedAddTags), topHashtags...)
-		addedTags, removedTags := m.updateFrontmatterTags(frontmatterData, allAddTags, m.normalizeTags(resolvedRemoveTags))
-		if len(addedTags) > 0 || len(removedTags) > 0 {
-			modified = true
-			for _, tag := range addedTags {
-				if !migrationOccurred || !containsTag(topHashtags, tag) {
-					result.TagsAdded[tag]++
-				}
-			}
-			for _, tag := range removedTags {
-				result.TagsRemoved[tag]++
+		for _, tag := range update.added {
+			if !containsTag(update.migrated, tag) {
+				result.TagsAdded[tag]++
 			}
 		}
+		for _, tag := range update.removed {
+			result.TagsRemoved[tag]++
+		}
 
-		var newContent string
-		if modified {
-			frontmatterString, err := m.serializeFrontmatter(frontmatterData)
-			if err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("%s: error serializing frontmatter: %v", filePath, err))
-				continue
+		if update.modified {
+			result.ModifiedFiles = append(result.ModifiedFiles, filePath)
+			if dryRun {
+				result.Diffs = append(result.Diffs, dryRunDiff(rootPath, absolutePath, update.before, update.after))
 			}
+		}
+	}
 
-			modifiedBodyContent := m.removeHashtagsFromBody(bodyContent, resolvedRemoveTags)
-			newContent = frontmatterString + modifiedBodyContent
-		} else {
-			newContent = originalContent
+	sortPlannedChanges(result.Diffs)
+	result.Backup = backup.location()
+
+	id, err := journal.commit()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Operation = id
+	if err := checkpoint.finish(result.Stopped == ""); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	return nil
+}
+
+// updateTagsInFile adds and removes tags in the note at path, reading it
+// again if an editor saves it while it is being rewritten
+func (m *DefaultTagManager) updateTagsInFile(ctx context.Context, path string, addTags, removeTags []string, dryRun bool, backup *backup, journal *journal) (tagUpdate, error) {
+	for attempt := 0; ; attempt++ {
+		stored, content, err := readStoredNote(ctx, m.config, path)
+		if err != nil {
+			return tagUpdate{}, err
 		}
 
-		if modified && !dryRun {
-			if err := os.WriteFile(absolutePath, []byte(newContent), DefaultFilePermissions); err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", filePath, err))
-				continue
-			}
+		originalContent, format := normalizeText(string(content))
+		update, err := m.updateTagsInContent(originalContent, addTags, removeTags)
+		if err != nil || !update.modified {
+			return update, err
+		}
+		if dryRun {
+			update.before, update.after = string(content), format.restore(update.content)
+			return update, nil
 		}
 
-		if modified {
-			result.ModifiedFiles = append(result.ModifiedFiles, filePath)
+		if err := backup.save(path); err != nil {
+			return tagUpdate{}, err
+		}
+		written := []byte(format.restore(update.content))
+		err = writeNote(ctx, m.config, path, stored, written)
+		if errors.Is(err, ErrNoteChanged) && attempt < noteChangedRetries {
+			continue
+		}
+		if err != nil {
+			return tagUpdate{}, err
 		}
+		journal.record(path, content, written)
+		return update, nil
 	}
-
-	return result, nil
 }
 
-func (m *DefaultTagManager) parseFrontmatter(content string) (map[string]interface{}, string, error) {
-	lines := strings.Split(content, "\n")
-	if len(lines) < 3 || lines[0] != "---" {
-		return make(map[string]interface{}), content, nil
+// notePath resolves a note path relative to rootPath, refusing paths which
+// could escape it
+func (m *DefaultTagManager) notePath(rootPath, filePath string) (string, error) {
+	cleanPath := filepath.Clean(filePath)
+	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
+		return "", fmt.Errorf("path must be relative to root and cannot contain '..'")
 	}
 
-	endIdx := -1
-	for i := 1; i < len(lines); i++ {
-		if lines[i] == "---" {
-			endIdx = i
-			break
-		}
+	absolutePath := filepath.Join(rootPath, cleanPath)
+	if err := m.validator.ValidatePath(absolutePath); err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
 	}
+	return absolutePath, nil
+}
 
-	if endIdx == -1 {
-		return make(map[string]interface{}), content, nil
+// tagUpdate is the outcome of adding and removing tags in one note
+type tagUpdate struct {
+	content string
+	// migrated are the top-of-file hashtags moved into the frontmatter
+	migrated []string
+	added    []string
+	removed  []string
+	modified bool
+	// before and after are the note's plaintext, set on dry runs
+	before, after string
+}
+
+// updateTagsInContent adds and removes frontmatter tags in normalized note
+// content, migrating top-of-file hashtags into the frontmatter
+func (m *DefaultTagManager) updateTagsInContent(content string, addTags, removeTags []string) (tagUpdate, error) {
+	update := tagUpdate{content: content}
+	if hasIgnoreFileDirective(content) {
+		return update, fmt.Errorf("file is excluded by a tag-manager:ignore directive")
 	}
 
-	var frontmatterData map[string]interface{}
-	if frontmatterContent := strings.Join(lines[1:endIdx], "\n"); frontmatterContent != "" {
-		if err := yaml.Unmarshal([]byte(frontmatterContent), &frontmatterData); err != nil {
-			return nil, "", fmt.Errorf("YAML parse error: %w", err)
+	frontmatterData, bodyContent, err := m.parseFrontmatter(content)
+	if err != nil {
+		return update, fmt.Errorf("malformed YAML frontmatter: %w", err)
+	}
+
+	var topHashtags []string
+	for _, tag := range m.DetectTopOfFileHashtags(bodyContent) {
+		if m.shouldMigrate(tag) {
+			topHashtags = append(topHashtags, tag)
 		}
 	}
+	if len(topHashtags) > 0 {
+		update.migrated = topHashtags
+		bodyContent = m.removeTopHashtags(bodyContent, topHashtags)
+		update.modified = true
+	}
+
+	allAddTags := append(append([]string(nil), addTags...), topHashtags...)
+	var tags []string
+	tags, update.added, update.removed = m.updateFrontmatterTags(frontmatterData, allAddTags, m.normalizeTags(removeTags))
+	if len(update.added) > 0 || len(update.removed) > 0 {
+		update.modified = true
+	}
 
-	if frontmatterData == nil {
-		frontmatterData = make(map[string]interface{})
+	if update.modified {
+		frontmatter, _, _ := splitFrontmatter(content)
+		frontmatterString, err := setFrontmatterTags(frontmatter, tags, m.config)
+		if err != nil {
+			return update, fmt.Errorf("error serializing frontmatter: %w", err)
+		}
+		update.content = tidyEdit(content, frontmatterString+m.removeHashtagsFromBody(bodyContent, removeTags))
 	}
 
-	return frontmatterData, strings.Join(lines[endIdx+1:], "\n"), nil
+	return update, nil
 }
 
-func (m *DefaultTagManager) serializeFrontmatter(data map[string]interface{}) (string, error) {
-	if len(data) == 0 {
-		return "", nil
+func (m *DefaultTagManager) parseFrontmatter(content string) (map[string]interface{}, string, error) {
+	frontmatterContent, body, ok := splitFrontmatter(content)
+	if !ok {
+		return make(map[string]interface{}), content, nil
 	}
 
-	yamlBytes, err := yaml.Marshal(data)
-	if err != nil {
-		return "", fmt.Errorf("YAML marshal error: %w", err)
+	// Values are kept as nodes so dates, numbers and empty properties are
+	// read exactly as Obsidian stored them
+	var nodes map[string]yaml.Node
+	if frontmatterContent != "" {
+		if err := yaml.Unmarshal([]byte(frontmatterContent), &nodes); err != nil {
+			return nil, "", fmt.Errorf("YAML parse error: %w", err)
+		}
 	}
 
-	return "---\n" + string(yamlBytes) + "---\n", nil
+	frontmatterData := make(map[string]interface{}, len(nodes))
+	for key, node := range nodes {
+		node := node
+		frontmatterData[key] = &node
+	}
+
+	return frontmatterData, body, nil
 }
 
-func (m *DefaultTagManager) updateFrontmatterTags(data map[string]interface{}, addTags, removeTags []string) ([]string, []string) {
-	var currentTags []string
+// updateFrontmatterTags returns the tags of parsed frontmatter after adding
+// and removing tags, sorted, along with the tags actually added and removed
+func (m *DefaultTagManager) updateFrontmatterTags(data map[string]interface{}, addTags, removeTags []string) ([]string, []string, []string) {
+	currentTags := frontmatterTags(data)
 	var addedTags []string
 	var removedTagsList []string
 
-	if tagsInterface, exists := data["tags"]; exists {
-		switch v := tagsInterface.(type) {
-		case []interface{}:
-			for _, tag := range v {
-				if tagStr, ok := tag.(string); ok {
-					currentTags = append(currentTags, strings.TrimSpace(tagStr))
-				}
-			}
-		case []string:
-			for _, tag := range v {
-				currentTags = append(currentTags, strings.TrimSpace(tag))
-			}
-		}
-	}
-
 	tagSet := make(map[string]bool)
 	for _, tag := range currentTags {
 		tagSet[strings.ToLower(tag)] = true
@@ -512,14 +1039,8 @@ func (m *DefaultTagManager) updateFrontmatterTags(data map[string]interface{}, a
 		}
 	}
 
-	if len(filteredTags) > 0 || len(addedTags) > 0 {
-		sort.Strings(filteredTags)
-		data["tags"] = filteredTags
-	} else {
-		delete(data, "tags")
-	}
-
-	return addedTags, removedTagsList
+	sort.Strings(filteredTags)
+	return filteredTags, addedTags, removedTagsList
 }
 
 func (m *DefaultTagManager) resolveTagConflicts(addTags, removeTags []string) ([]string, []string, error) {
@@ -537,6 +1058,9 @@ func (m *DefaultTagManager) resolveTagConflicts(addTags, removeTags []string) ([
 				break
 			}
 		}
+		if hasConflict && m.config.Strict {
+			return nil, nil, &usageError{err: fmt.Errorf("tag %s is both added and removed", addTag)}
+		}
 		if !hasConflict {
 			filteredAddTags = append(filteredAddTags, addTag)
 		}
@@ -566,23 +1090,20 @@ func (m *DefaultTagManager) resolveTagConflicts(addTags, removeTags []string) ([
 }
 
 func (m *DefaultTagManager) removeHashtagsFromBody(content string, tags []string) string {
-	modifiedContent := content
-	for _, tag := range tags {
-		normalizedTag := m.normalizeTag(tag)
+	return mapOutsideProtected(content, func(text string) string {
+		return mapOutsideCode(text, func(text string) string {
+			for _, tag := range tags {
+				normalizedTag := m.normalizeTag(tag)
 
-		if len(normalizedTag) == 0 {
-			continue
-		}
+				if len(normalizedTag) == 0 {
+					continue
+				}
 
-		quotedTag := regexp.QuoteMeta(normalizedTag)
-		pattern := `#` + quotedTag + `\b`
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			continue
-		}
-		modifiedContent = re.ReplaceAllString(modifiedContent, "")
-	}
-	return modifiedContent
+				text = m.replaceHashtags(text, normalizedTag, "")
+			}
+			return text
+		})
+	})
 }
 
 func (m *DefaultTagManager) DetectTopOfFileHashtags(content string) []string {
@@ -659,13 +1180,18 @@ func (m *DefaultTagManager) removeTopHashtags(content string, hashtags []string)
 
 	for i := 0; i < boundary && i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
-		if line == "" {
+		if line == "" || !m.isHashtagOnlyLine(line) {
 			continue
 		}
 
-		if m.isHashtagOnlyLine(line) {
-			lines[i] = ""
+		// Hashtags which aren't migrated stay on their line
+		var kept []string
+		for _, word := range strings.Fields(line) {
+			if tag := m.normalizeTag(word); tag != "" && !containsTag(hashtags, tag) {
+				kept = append(kept, word)
+			}
 		}
+		lines[i] = strings.Join(kept, " ")
 	}
 
 	result := strings.Join(lines, "\n")
@@ -673,6 +1199,22 @@ func (m *DefaultTagManager) removeTopHashtags(content string, hashtags []string)
 	return result
 }
 
+// tagMetadata returns the configured display metadata for tag, matching the
+// configured tag names case-insensitively.
+func (m *DefaultTagManager) tagMetadata(tag string) map[string]string {
+	tag = m.normalizeTag(tag)
+	for name, metadata := range m.config.TagMetadata {
+		if strings.EqualFold(m.normalizeTag(name), tag) {
+			return metadata
+		}
+	}
+	return nil
+}
+
+func (m *DefaultTagManager) isProtected(tag string) bool {
+	return containsTag(m.config.ProtectedTags, m.normalizeTag(tag))
+}
+
 func containsTag(tags []string, target string) bool {
 	for _, tag := range tags {
 		if strings.EqualFold(tag, target) {
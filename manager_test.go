@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	tagmanager "github.com/thrawn01/tag-manager"
@@ -192,6 +193,157 @@ func TestTagManagerNonAtomicOperations(t *testing.T) {
 	}
 }
 
+func TestReplaceUnicodeTags(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	testFile := filepath.Join(tempDir, "note.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("#café notes, not #café-menu\n"), tagmanager.DefaultFilePermissions))
+
+	result, err := manager.ReplaceTagsBatch(context.Background(), []tagmanager.TagReplacement{
+		{OldTag: "café", NewTag: "coffee"},
+	}, tempDir, false)
+	require.NoError(t, err)
+	assert.Len(t, result.ModifiedFiles, 1)
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "#coffee notes, not #café-menu\n", string(content))
+}
+
+func TestReplaceNestedTags(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	testFile := filepath.Join(tempDir, "note.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("#project plus #project/alpha\n"), tagmanager.DefaultFilePermissions))
+
+	ctx := context.Background()
+	found, err := manager.FindFilesByTags(ctx, []string{"project/alpha"}, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testFile}, found["project/alpha"])
+
+	_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{
+		{OldTag: "project/alpha", NewTag: "project/beta"},
+	}, tempDir, false)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "#project plus #project/beta\n", string(content))
+}
+
+func TestReplaceFrontmatterArrayElements(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name     string
+		tags     string
+		expected string
+	}{
+		{name: "Plain", tags: "[golang]", expected: `["go"]`},
+		{name: "Quoted", tags: `["golang"]`, expected: `["go"]`},
+		{name: "AmongOthers", tags: `[a, "golang", b]`, expected: `[a, "go", b]`},
+		{name: "LongerTagFirst", tags: "[golang-extra, golang]", expected: `[golang-extra, "go"]`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte("---\ntags: "+test.tags+"\n---\n"), tagmanager.DefaultFilePermissions))
+
+			_, err := manager.ReplaceTagsBatch(context.Background(), []tagmanager.TagReplacement{
+				{OldTag: "golang", NewTag: "go"},
+			}, tempDir, false)
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, "---\ntags: "+test.expected+"\n---\n", string(content))
+		})
+	}
+}
+
+func TestReplaceFrontmatterForms(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name        string
+		frontmatter string
+		expected    string
+	}{
+		{name: "PlainString", frontmatter: "tags: golang", expected: "tags: gopher"},
+		{name: "CommaSeparated", frontmatter: "tags: golang, rust", expected: "tags: gopher, rust"},
+		{name: "QuotedCommaSeparated", frontmatter: `tags: "rust, golang"`, expected: `tags: "rust, gopher"`},
+		{name: "HashPrefixed", frontmatter: `tags: "#golang #rust"`, expected: `tags: "#gopher #rust"`},
+		{name: "SingularKey", frontmatter: "tag: [golang]", expected: `tag: ["gopher"]`},
+		{name: "SingularKeyPlainString", frontmatter: "tag: golang", expected: "tag: gopher"},
+		{name: "List", frontmatter: "tags:\n  - rust\n  - golang", expected: "tags:\n  - rust\n  - \"gopher\""},
+		{name: "UnindentedList", frontmatter: "tags:\n- golang\n- rust", expected: "tags:\n- \"gopher\"\n- rust"},
+		{name: "ListWithComment", frontmatter: "tags:\n  - golang # language\n  - rust", expected: "tags:\n  - \"gopher\" # language\n  - rust"},
+		{name: "SingleQuotedListItem", frontmatter: "tags:\n  - 'golang'", expected: "tags:\n  - \"gopher\""},
+		{name: "CommaSeparatedListItem", frontmatter: "tags:\n  - golang, rust", expected: "tags:\n  - gopher, rust"},
+		{name: "WikiLinkListItem", frontmatter: "tags:\n  - \"[[golang]]\"", expected: "tags:\n  - \"[[gopher]]\""},
+		{name: "ArrayAfterOtherKeys", frontmatter: "title: golang\naliases: [golang]\ntags: [golang]", expected: "title: golang\naliases: [golang]\ntags: [\"gopher\"]"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte("---\n"+test.frontmatter+"\n---\n# golang\n"), tagmanager.DefaultFilePermissions))
+
+			ctx := context.Background()
+			result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{
+				{OldTag: "golang", NewTag: "gopher"},
+			}, tempDir, false)
+			require.NoError(t, err)
+			assert.Equal(t, []string{testFile}, result.ModifiedFiles)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, "---\n"+test.expected+"\n---\n# golang\n", string(content))
+
+			found, err := manager.FindFilesByTags(ctx, []string{"golang", "gopher"}, tempDir)
+			require.NoError(t, err)
+			assert.Empty(t, found["golang"])
+			assert.Equal(t, []string{testFile}, found["gopher"])
+		})
+	}
+}
+
+func TestReplaceCountsOnlyChangedFiles(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"tagged.md": "---\ntags: golang\n---\n",
+		// Only list items under tags are tags, and hashtags in code aren't
+		"aliased.md": "---\naliases:\n  - golang\n---\n`#golang`\n",
+		// A value folded over several lines has no position to rename
+		"folded.md": "---\ntags: >-\n  rust,\n  golang\n---\n",
+		"other.md":  "#rust\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := manager.ReplaceTagsBatch(context.Background(), []tagmanager.TagReplacement{
+			{OldTag: "golang", NewTag: "gopher"},
+		}, tempDir, dryRun)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(tempDir, "tagged.md")}, result.ModifiedFiles)
+	}
+}
+
 func TestUpdateTags(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -225,9 +377,7 @@ tags: ["existing"]
 	require.NoError(t, err)
 	contentStr := string(modifiedContent)
 
-	assert.Contains(t, contentStr, "tags:")
-	assert.Contains(t, contentStr, "- new-tag")
-	assert.NotContains(t, contentStr, "[\"")
+	assert.Contains(t, contentStr, "tags: [\"new-tag\"]")
 	assert.NotContains(t, contentStr, "existing")
 }
 
@@ -325,9 +475,73 @@ tags: ["existing"]
 	require.NoError(t, err)
 
 	contentStr := string(modifiedContent)
-	assert.Contains(t, contentStr, "title: Important Title")
-	assert.Contains(t, contentStr, "author: Test Author")
-	assert.Contains(t, contentStr, "date: \"2024-01-01\"")
+	assert.Equal(t, `---
+title: "Important Title"
+author: "Test Author"
+date: "2024-01-01"
+tags: ["existing", "new-tag"]
+---
+# Test Content`, contentStr)
+}
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		content    string
+		addTags    []string
+		removeTags []string
+		expected   string
+	}{
+		{
+			name:     "CommentsOrderAndStyles",
+			content:  "---\n# Written by hand\nzeta: 'single'  # why\ntags: [golang] # tags\nalpha: >-\n  folded\n  text\n\n# Trailing\nlist:\n- a\n- b\n---\nbody\n",
+			addTags:  []string{"python"},
+			expected: "---\n# Written by hand\nzeta: 'single'  # why\ntags: [golang, python]\nalpha: >-\n  folded\n  text\n\n# Trailing\nlist:\n- a\n- b\n---\nbody\n",
+		},
+		{
+			name:     "AppendsTagsAfterLastProperty",
+			content:  "---\ntitle: \"Note\"\n# about the note\nnested:\n  key: value\n\n---\nbody\n",
+			addTags:  []string{"golang"},
+			expected: "---\ntitle: \"Note\"\n# about the note\nnested:\n  key: value\ntags:\n    - golang\n\n---\nbody\n",
+		},
+		{
+			name:       "RemovesLastTag",
+			content:    "---\ntitle: Note\ntags:\n  - golang\n# Keep me\ndate: 2024-01-01\n---\nbody\n",
+			removeTags: []string{"golang"},
+			expected:   "---\ntitle: Note\n# Keep me\ndate: 2024-01-01\n---\nbody\n",
+		},
+		{
+			name:       "DropsEmptyFrontmatter",
+			content:    "---\ntags: [golang]\n---\nbody\n",
+			removeTags: []string{"golang"},
+			expected:   "body\n",
+		},
+		{
+			name:     "FlowMapping",
+			content:  "---\n{title: Note, tags: [golang]}\n---\nbody\n",
+			addTags:  []string{"python"},
+			expected: "---\n{title: Note, tags: [golang, python]}\n---\nbody\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), test.addTags, test.removeTags, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
 }
 
 func TestTagConflictResolution(t *testing.T) {
@@ -445,9 +659,7 @@ tags: ["existing-tag", "another-tag"]
 	require.NoError(t, err)
 	contentStr := string(modifiedContent)
 
-	assert.Contains(t, contentStr, "- existing-tag")
-	assert.Contains(t, contentStr, "- another-tag")
-	assert.Contains(t, contentStr, "- new-tag")
+	assert.Contains(t, contentStr, "tags: [\"another-tag\", \"existing-tag\", \"new-tag\"]")
 }
 
 func TestRemoveTagsFromBody(t *testing.T) {
@@ -745,11 +957,563 @@ Body with #body-tag`
 	require.NoError(t, err)
 	contentStr := string(modifiedContent)
 
-	assert.Contains(t, contentStr, "- existing")
-	assert.Contains(t, contentStr, "- migrated1")
-	assert.Contains(t, contentStr, "- migrated2")
-	assert.Contains(t, contentStr, "title: Document")
+	assert.Contains(t, contentStr, "tags: [\"existing\", \"migrated1\", \"migrated2\", \"trigger-migration\"]")
+	assert.Contains(t, contentStr, "title: \"Document\"")
 	assert.NotContains(t, contentStr, "#migrated1")
 	assert.NotContains(t, contentStr, "#migrated2")
 	assert.Contains(t, contentStr, "#body-tag")
 }
+
+func TestSuggestNamespaces(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	testFiles := map[string]string{
+		"k8s1.md":  "#kubernetes #work/infra",
+		"k8s2.md":  "#kubernetes #work/oncall",
+		"k8s3.md":  "#kubernetes #work/infra #home/lab",
+		"go1.md":   "#golang #work/infra",
+		"go2.md":   "#golang #home/lab",
+		"lone1.md": "#reading",
+	}
+
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	suggestions, err := manager.SuggestNamespaces(context.Background(), tempDir, 2, 0.9)
+	require.NoError(t, err)
+
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "kubernetes", suggestions[0].Tag)
+	assert.Equal(t, "work", suggestions[0].Namespace)
+	assert.Equal(t, "work/kubernetes", suggestions[0].SuggestedTag)
+	assert.Equal(t, 3, suggestions[0].Count)
+	assert.Equal(t, 3, suggestions[0].CoOccurrence)
+}
+
+func TestTagMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	config.TagMetadata = map[string]map[string]string{
+		"#Golang": {"color": "#00ADD8", "icon": "gopher", "group": "languages"},
+	}
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "note.md"), []byte("#golang #python"), tagmanager.DefaultFilePermissions))
+
+	ctx := context.Background()
+	tags, err := manager.ListAllTags(ctx, tempDir, 1)
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+	for _, tag := range tags {
+		if tag.Name == "golang" {
+			assert.Equal(t, "gopher", tag.Metadata["icon"])
+			continue
+		}
+		assert.Nil(t, tag.Metadata)
+	}
+
+	_, result, err := tagmanager.GetTagsInfoTool(ctx, &mcp.CallToolRequest{}, tagmanager.GetTagsInfoParams{
+		Tags: []string{"golang"},
+		Root: tempDir,
+	}, manager)
+	require.NoError(t, err)
+	infos := result.([]tagmanager.TagInfo)
+	require.Len(t, infos, 1)
+	assert.Equal(t, map[string]string{"color": "#00ADD8", "icon": "gopher", "group": "languages"}, infos[0].Metadata)
+}
+
+func TestUpdateTagsNormalizesScalarTags(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "SingularKey",
+			content:  "---\ntitle: Note\ntag: golang\n---\n# Note\n",
+			expected: "---\ntitle: Note\ntags:\n    - golang\n    - python\n---\n# Note\n",
+		},
+		{
+			name:     "QuotedCommaSeparated",
+			content:  "---\ntags: \"golang, rust, \"\n---\n# Note\n",
+			expected: "---\ntags:\n    - golang\n    - python\n    - rust\n---\n# Note\n",
+		},
+		{
+			name:     "ScalarString",
+			content:  "---\ntags: golang, rust\n---\n# Note\n",
+			expected: "---\ntags:\n    - golang\n    - python\n    - rust\n---\n# Note\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), []string{"python"}, nil, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+}
+
+func TestReplaceScalarTags(t *testing.T) {
+	files := map[string]string{
+		"singular.md":        "---\ntitle: Note\ntag: golang\n---\n# Note\n",
+		"scalar.md":          "---\ntags: golang, rust\n---\n# Note\n",
+		"singular-list.md":   "---\ntag:\n- rust\n- golang\n---\n# Note\n",
+		"unindented-list.md": "---\ntags:\n- golang # language\n---\n# Note\n",
+	}
+	expected := map[string]string{
+		"singular.md":        "---\ntitle: Note\ntag: gopher\n---\n# Note\n",
+		"scalar.md":          "---\ntags: gopher, rust\n---\n# Note\n",
+		"singular-list.md":   "---\ntag:\n- rust\n- \"gopher\"\n---\n# Note\n",
+		"unindented-list.md": "---\ntags:\n- \"gopher\" # language\n---\n# Note\n",
+	}
+	root := writeVault(t, files)
+
+	run := func(args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...), &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	output := run("--dry-run", "replace", "--old=golang", "--new=gopher", "--root="+root)
+	assert.Contains(t, output, "Modified files: 4")
+	for name, content := range files {
+		actual, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(actual), name)
+	}
+
+	output = run("replace", "--old=golang", "--new=gopher", "--root="+root)
+	assert.Contains(t, output, "Modified files: 4")
+	for name, content := range expected {
+		actual, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(actual), name)
+	}
+
+	output = run("list", "--root="+root)
+	assert.Contains(t, output, "gopher")
+	assert.NotContains(t, output, "golang")
+}
+
+func TestUpdateTagsPreservesProperties(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	const testContent = `---
+created: 2024-01-15
+done: true
+version: 1.10
+zip: 01234
+due:
+related: "[[Other Note]]"
+title: "Quoted Title"
+author: 'Single Quoted'
+tags:
+  - "#golang"
+  - "[[rust]]"
+---
+# Note
+`
+
+	testFile := filepath.Join(tempDir, "note.md")
+	require.NoError(t, os.WriteFile(testFile, []byte(testContent), tagmanager.DefaultFilePermissions))
+
+	result, err := manager.UpdateTags(context.Background(), []string{"python"}, []string{"rust"}, tempDir, []string{"note.md"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+
+	contentStr := string(content)
+	assert.Contains(t, contentStr, "created: 2024-01-15\n")
+	assert.Contains(t, contentStr, "done: true\n")
+	assert.Contains(t, contentStr, "version: 1.10\n")
+	assert.Contains(t, contentStr, "zip: 01234\n")
+	assert.Contains(t, contentStr, "due:\n")
+	assert.Contains(t, contentStr, "related: \"[[Other Note]]\"\n")
+	// Quoting written by hand is kept even where YAML doesn't need it
+	assert.Contains(t, contentStr, "title: \"Quoted Title\"\n")
+	assert.Contains(t, contentStr, "author: 'Single Quoted'\n")
+	assert.Contains(t, contentStr, "tags:\n  - \"golang\"\n  - \"python\"\n")
+	assert.NotContains(t, contentStr, "rust")
+}
+
+func TestLineEndingAndBOMPreservation(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "CRLF",
+			content:  "---\r\ntitle: Note\r\ntags:\r\n  - golang\r\n---\r\n# Note\r\n#rust text\r\n",
+			expected: "---\r\ntitle: Note\r\ntags:\r\n  - golang\r\n  - python\r\n---\r\n# Note\r\n text\r\n",
+		},
+		{
+			name:     "BOM",
+			content:  "\ufeff---\ntags: [golang]\n---\n# Note\n",
+			expected: "\ufeff---\ntags: [golang, python]\n---\n# Note\n",
+		},
+		{
+			name:     "BOMAndCRLFWithoutFrontmatter",
+			content:  "\ufeff# Note\r\n",
+			expected: "\ufeff---\r\ntags:\r\n    - python\r\n---\r\n# Note\r\n",
+		},
+		{
+			// Each line keeps its own ending, and added lines take the
+			// ending of the line before them
+			name:     "MixedEndings",
+			content:  "---\r\ntags:\r\n  - golang\r\n---\r\n# Note\nBody\n#rust text\nEnd\n",
+			expected: "---\r\ntags:\r\n  - golang\r\n  - python\r\n---\r\n# Note\nBody\n text\nEnd\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), []string{"python"}, []string{"rust"}, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		testFile := filepath.Join(tempDir, "replace.md")
+		require.NoError(t, os.WriteFile(testFile, []byte("\ufeff---\r\ntags:\r\n  - golang\r\n---\r\n#golang\r\n"), tagmanager.DefaultFilePermissions))
+
+		_, err := manager.ReplaceTagsBatch(context.Background(), []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, tempDir, false)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		assert.Equal(t, "\ufeff---\r\ntags:\r\n  - \"go\"\r\n---\r\n#go\r\n", string(content))
+	})
+}
+
+func TestIgnoreDirectives(t *testing.T) {
+	tempDir := t.TempDir()
+	config := tagmanager.DefaultConfig()
+	manager, err := tagmanager.NewDefaultTagManager(config)
+	require.NoError(t, err)
+
+	ignoredRegion := "<!-- tag-manager:ignore-start -->\nExample: #golang\n<!-- tag-manager:ignore-end -->\n"
+	files := map[string]string{
+		"region.md":  "#golang text\n" + ignoredRegion,
+		"ignored.md": "<!-- tag-manager:ignore -->\n#golang text\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), tagmanager.DefaultFilePermissions))
+	}
+
+	ctx := context.Background()
+	found, err := manager.FindFilesByTags(ctx, []string{"golang"}, tempDir)
+	require.NoError(t, err)
+	assert.Len(t, found["golang"], 1)
+
+	_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, tempDir, false)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "region.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "#go text\n"+ignoredRegion, string(content))
+
+	content, err = os.ReadFile(filepath.Join(tempDir, "ignored.md"))
+	require.NoError(t, err)
+	assert.Equal(t, files["ignored.md"], string(content))
+
+	result, err := manager.UpdateTags(ctx, nil, []string{"golang"}, tempDir, []string{"region.md", "ignored.md"}, false)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], "tag-manager:ignore")
+
+	content, err = os.ReadFile(filepath.Join(tempDir, "region.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ignoredRegion)
+}
+
+func TestUpdateTagsStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		style    string
+		content  string
+		expected string
+	}{
+		{
+			name:     "FlowArray",
+			content:  "---\ntags: [golang]\n---\n",
+			expected: "---\ntags: [golang, python]\n---\n",
+		},
+		{
+			name:     "SingleQuotedArray",
+			content:  "---\ntags: ['golang']\n---\n",
+			expected: "---\ntags: ['golang', 'python']\n---\n",
+		},
+		{
+			name:     "MixedQuotingQuotesAsNeeded",
+			content:  "---\ntags: [\"golang\", rust, '2024']\n---\n",
+			expected: "---\ntags: [\"2024\", golang, python]\n---\n",
+		},
+		{
+			name:     "BlockListIndentation",
+			content:  "---\ntags:\n  - golang\n---\n",
+			expected: "---\ntags:\n  - golang\n  - python\n---\n",
+		},
+		{
+			name:     "UnindentedBlockList",
+			content:  "---\ntags:\n- golang\n---\n",
+			expected: "---\ntags:\n- golang\n- python\n---\n",
+		},
+		{
+			name:     "NewTags",
+			content:  "---\ntitle: Note\n---\n",
+			expected: "---\ntitle: Note\ntags:\n    - python\n---\n",
+		},
+		{
+			name:     "ForceArray",
+			style:    tagmanager.TagsStyleArray,
+			content:  "---\ntags:\n  - \"golang\"\n---\n",
+			expected: "---\ntags: [\"golang\", \"python\"]\n---\n",
+		},
+		{
+			name:     "ForceList",
+			style:    tagmanager.TagsStyleList,
+			content:  "---\ntags: [golang]\n---\n",
+			expected: "---\ntags:\n    - golang\n    - python\n---\n",
+		},
+		{
+			name:     "ForceListKeepsIndentation",
+			style:    tagmanager.TagsStyleList,
+			content:  "---\ntags:\n  - golang\n---\n",
+			expected: "---\ntags:\n  - golang\n  - python\n---\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.TagsStyle = test.style
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), []string{"python"}, []string{"rust"}, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+
+	t.Run("InvalidStyle", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.TagsStyle = "inline"
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid tags style")
+	})
+}
+
+func TestUpdateTagsPlacement(t *testing.T) {
+	properties := "---\ntitle: Note\n# when it was made\ncreated: 2024-01-01\nstatus: draft\n---\nBody\n"
+	tests := []struct {
+		name      string
+		placement string
+		scaffold  []string
+		content   string
+		expected  string
+	}{
+		{
+			name:     "End",
+			content:  properties,
+			expected: "---\ntitle: Note\n# when it was made\ncreated: 2024-01-01\nstatus: draft\ntags:\n    - python\n---\nBody\n",
+		},
+		{
+			name:      "Top",
+			placement: tagmanager.TagsPlacementTop,
+			content:   properties,
+			expected:  "---\ntags:\n    - python\ntitle: Note\n# when it was made\ncreated: 2024-01-01\nstatus: draft\n---\nBody\n",
+		},
+		{
+			name:      "AfterTitle",
+			placement: tagmanager.TagsPlacementAfterTitle,
+			content:   properties,
+			expected:  "---\ntitle: Note\ntags:\n    - python\n# when it was made\ncreated: 2024-01-01\nstatus: draft\n---\nBody\n",
+		},
+		{
+			name:      "AfterTitleWithoutTitle",
+			placement: tagmanager.TagsPlacementAfterTitle,
+			content:   "---\nstatus: draft\n---\n",
+			expected:  "---\nstatus: draft\ntags:\n    - python\n---\n",
+		},
+		{
+			name:      "Alphabetical",
+			placement: tagmanager.TagsPlacementAlphabetical,
+			content:   "---\ncreated: 2024-01-01\n# shown in the title bar\ntitle: Note\n---\n",
+			expected:  "---\ncreated: 2024-01-01\ntags:\n    - python\n# shown in the title bar\ntitle: Note\n---\n",
+		},
+		{
+			name:      "ExistingTagsStayPut",
+			placement: tagmanager.TagsPlacementTop,
+			content:   "---\ntitle: Note\ntags: [golang]\n---\n",
+			expected:  "---\ntitle: Note\ntags: [golang, python]\n---\n",
+		},
+		{
+			name:     "Scaffold",
+			scaffold: []string{"aliases", "cssclasses"},
+			content:  "Body\n",
+			expected: "---\naliases:\ncssclasses:\ntags:\n    - python\n---\nBody\n",
+		},
+		{
+			name:      "ScaffoldAlphabetical",
+			placement: tagmanager.TagsPlacementAlphabetical,
+			scaffold:  []string{"aliases", "title"},
+			content:   "Body\n",
+			expected:  "---\naliases:\ntags:\n    - python\ntitle:\n---\nBody\n",
+		},
+		{
+			name:     "ScaffoldOnlyForNewFrontmatter",
+			scaffold: []string{"aliases"},
+			content:  "---\ntitle: Note\n---\n",
+			expected: "---\ntitle: Note\ntags:\n    - python\n---\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.TagsPlacement = test.placement
+			config.FrontmatterScaffold = test.scaffold
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), []string{"python"}, nil, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+
+	t.Run("InvalidPlacement", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.TagsPlacement = "bottom"
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid tags placement")
+	})
+}
+
+func TestUpdateTagsEmptyFrontmatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		content  string
+		expected string
+	}{
+		{
+			name:     "RemoveEmptyBlock",
+			content:  "---\ntags: [golang]\n---\nBody\n",
+			expected: "Body\n",
+		},
+		{
+			name:     "RemoveKeepsEmptyProperties",
+			content:  "---\naliases:\ntags: [golang]\n---\nBody\n",
+			expected: "---\naliases:\n---\nBody\n",
+		},
+		{
+			name:     "Keep",
+			policy:   tagmanager.EmptyFrontmatterKeep,
+			content:  "---\ntags: [golang]\n---\nBody\n",
+			expected: "---\n---\nBody\n",
+		},
+		{
+			name:     "Prune",
+			policy:   tagmanager.EmptyFrontmatterPrune,
+			content:  "---\naliases:\n# from the template\ncssclasses: []\ntitle: \"\"\ntags: [golang]\n---\nBody\n",
+			expected: "Body\n",
+		},
+		{
+			name:     "PruneKeepsProperties",
+			policy:   tagmanager.EmptyFrontmatterPrune,
+			content:  "---\naliases:\nstatus: draft\ntags: [golang]\n---\nBody\n",
+			expected: "---\naliases:\nstatus: draft\n---\nBody\n",
+		},
+		{
+			name:     "PruneFlowMapping",
+			policy:   tagmanager.EmptyFrontmatterPrune,
+			content:  "---\n{aliases: [], tags: [golang]}\n---\nBody\n",
+			expected: "Body\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.EmptyFrontmatter = test.policy
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), nil, []string{"golang"}, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.EmptyFrontmatter = "delete"
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid empty frontmatter policy")
+	})
+}
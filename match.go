@@ -0,0 +1,66 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+)
+
+// How find matches files against the tags searched for
+const (
+	// MatchAny finds the files with each tag, listed per tag
+	MatchAny = "any"
+	// MatchAll finds the files with every tag, as one list
+	MatchAll = "all"
+)
+
+func validateMatchMode(mode string) error {
+	switch mode {
+	case "", MatchAny, MatchAll:
+		return nil
+	}
+	return fmt.Errorf("invalid match mode %q: must be %s or %s", mode, MatchAny, MatchAll)
+}
+
+// FindFilesWithAllTags returns the files which have every one of tags, in the
+// order they were scanned
+func (m *DefaultTagManager) FindFilesWithAllTags(ctx context.Context, tags []string, rootPath string) ([]string, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	normalizedTags := m.normalizeTags(tags)
+	files := []string{}
+	if len(normalizedTags) == 0 {
+		return files, nil
+	}
+
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fileTags := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			fileTags[m.normalizeTag(tag)] = true
+		}
+		if m.excludedByNotTags(fileTags) {
+			continue
+		}
+
+		matched := true
+		for _, searchTag := range normalizedTags {
+			if !fileTags[searchTag] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			files = append(files, fileInfo.Path)
+		}
+	}
+
+	return files, nil
+}
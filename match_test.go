@@ -0,0 +1,83 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestFindFilesWithAllTags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":       "---\ntags: [golang]\n---\nNotes on #tutorial",
+		"b.md":       "#golang",
+		"notes/c.md": "#golang #tutorial #draft",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		{name: "Single", tags: []string{"golang"}, expected: []string{"a.md", "b.md", "notes/c.md"}},
+		{name: "Intersection", tags: []string{"#golang", "tutorial"}, expected: []string{"a.md", "notes/c.md"}},
+		{name: "Three", tags: []string{"golang", "tutorial", "draft"}, expected: []string{"notes/c.md"}},
+		{name: "NoMatch", tags: []string{"golang", "python"}, expected: []string{}},
+		{name: "NoTags", tags: nil, expected: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := manager.FindFilesWithAllTags(context.Background(), test.tags, root)
+			require.NoError(t, err)
+			relative := []string{}
+			for _, file := range files {
+				rel, err := filepath.Rel(root, file)
+				require.NoError(t, err)
+				relative = append(relative, filepath.ToSlash(rel))
+			}
+			assert.Equal(t, test.expected, relative)
+		})
+	}
+
+	t.Run("Tool", func(t *testing.T) {
+		_, data, err := tagmanager.FindFilesByTagsTool(context.Background(), &mcp.CallToolRequest{},
+			tagmanager.FindFilesByTagsParams{Tags: []string{"golang", "tutorial"}, Root: root, Match: "all", Compact: true}, manager)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md", "notes/c.md"}, data)
+
+		_, _, err = tagmanager.FindFilesByTagsTool(context.Background(), &mcp.CallToolRequest{},
+			tagmanager.FindFilesByTagsParams{Tags: []string{"golang"}, Root: root, Match: "most"}, manager)
+		assert.EqualError(t, err, `invalid match mode "most": must be any or all`)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		run := func(t *testing.T, args ...string) (string, error) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager", "find", "--root", root}, args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			return stdout.String(), err
+		}
+
+		output, err := run(t, "--tags=golang,tutorial", "--match=all", "--json")
+		require.NoError(t, err)
+		var files []string
+		require.NoError(t, json.Unmarshal([]byte(output), &files))
+		assert.Equal(t, []string{filepath.Join(root, "a.md"), filepath.Join(root, "notes/c.md")}, files)
+
+		output, err = run(t, "--tags=golang,tutorial", "--match=all")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Found 2 files with all of #golang, #tutorial:", filepath.Join(root, "a.md")})
+
+		_, err = run(t, "--tags=golang", "--match=most")
+		assert.EqualError(t, err, `invalid match mode "most": must be any or all`)
+	})
+}
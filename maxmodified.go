@@ -0,0 +1,45 @@
+package tagmanager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMaxModified is returned, before any file is modified, by a replace or
+// update which would modify more files than Config.MaxModified allows
+var ErrMaxModified = errors.New("too many files would be modified")
+
+func validateMaxModified(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid max_modified %d: must not be negative", limit)
+	}
+	return nil
+}
+
+// WithMaxModified returns a manager whose ReplaceTagsBatch and UpdateTags
+// fail with ErrMaxModified, modifying nothing, when they would modify more
+// than limit files. A limit of zero or less removes the cap.
+func (m *DefaultTagManager) WithMaxModified(limit int) TagManager {
+	capped := *m
+	capped.maxModified = max(limit, 0)
+	return &capped
+}
+
+// checkMaxModified fails when count files exceed the cap
+func (m *DefaultTagManager) checkMaxModified(count int) error {
+	if m.maxModified > 0 && count > m.maxModified {
+		return fmt.Errorf("%w: %d files would be modified, more than the limit of %d, so none were", ErrMaxModified, count, m.maxModified)
+	}
+	return nil
+}
+
+// uncapped returns a manager for the dry run counting the files an operation
+// would modify, which neither checks the cap nor reports progress
+func (m *DefaultTagManager) uncapped() *DefaultTagManager {
+	counting := *m
+	counting.maxModified = 0
+	counting.progress = nil
+	// Every occurrence is counted, as which will be accepted isn't known yet
+	counting.decide = nil
+	return &counting
+}
@@ -0,0 +1,80 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestMaxModified(t *testing.T) {
+	ctx := context.Background()
+	note := "---\ntags: [golang]\n---\nBody\n"
+	files := map[string]string{"a.md": note, "b.md": note, "c.md": "Just text\n"}
+
+	assertUnchanged := func(t *testing.T, root string) {
+		for name, expected := range files {
+			content, err := os.ReadFile(filepath.Join(root, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(content), name)
+		}
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		root := writeVault(t, files)
+		config := tagmanager.DefaultConfig()
+		config.MaxModified = 1
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		_, err = manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		require.ErrorIs(t, err, tagmanager.ErrMaxModified)
+		assert.Contains(t, err.Error(), "2 files would be modified")
+		assertUnchanged(t, root)
+
+		// Dry runs modify nothing, so they aren't capped
+		result, err := manager.ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, true)
+		require.NoError(t, err)
+		assert.Len(t, result.Diffs, 2)
+
+		result, err = manager.WithMaxModified(2).ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		require.NoError(t, err)
+		assert.Len(t, result.ModifiedFiles, 2)
+	})
+
+	t.Run("UpdateCountsOnlyChangedFiles", func(t *testing.T) {
+		root := writeVault(t, files)
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.WithMaxModified(2).UpdateTags(ctx, []string{"reviewed"}, nil, root, []string{"a.md", "b.md", "c.md"}, false)
+		require.ErrorIs(t, err, tagmanager.ErrMaxModified)
+		assertUnchanged(t, root)
+
+		// Only a.md and b.md have golang to remove
+		result, err := manager.WithMaxModified(2).UpdateTags(ctx, nil, []string{"golang"}, root, []string{"a.md", "b.md", "c.md"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md", "b.md"}, result.ModifiedFiles)
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		root := writeVault(t, files)
+
+		err := tagmanager.RunCmd([]string{"tag-manager", "replace", "--old", "golang", "--new", "go", "--root", root,
+			"--max-modified", "1"}, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		require.ErrorIs(t, err, tagmanager.ErrMaxModified)
+		assertUnchanged(t, root)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.MaxModified = -1
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, "invalid max_modified")
+	})
+}
@@ -2,11 +2,15 @@ package tagmanager
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
+	"sort"
 	"syscall"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -14,32 +18,61 @@ import (
 // Parameter structures for MCP tools
 type FindFilesByTagsParams struct {
 	Tags       []string `json:"tags"`
-	Root       string   `json:"root"`
+	Root       string   `json:"root,omitempty"`
+	RootName   string   `json:"root_name,omitempty"`
 	MaxResults *int     `json:"max_results,omitempty"`
+	Order      string   `json:"order,omitempty"`
+	// Match is any, for the files with each tag, or all, for a single list
+	// of the files with every tag
+	Match string `json:"match,omitempty"`
+	// NotTags leaves out the files carrying any of these tags
+	NotTags []string `json:"not_tags,omitempty"`
+	// Compact returns paths relative to the root and omits tags without files
+	Compact bool `json:"compact,omitempty"`
 }
 
 type GetTagsInfoParams struct {
 	Tags           []string `json:"tags"`
-	Root           string   `json:"root"`
+	Root           string   `json:"root,omitempty"`
+	RootName       string   `json:"root_name,omitempty"`
 	MaxFilesPerTag *int     `json:"max_files_per_tag,omitempty"`
+	// Compact returns CompactTag results with paths relative to the root
+	Compact bool `json:"compact,omitempty"`
 }
 
 type ListAllTagsParams struct {
-	Root       string `json:"root"`
+	Root       string `json:"root,omitempty"`
+	RootName   string `json:"root_name,omitempty"`
 	MinCount   int    `json:"min_count"`
 	Pattern    string `json:"pattern,omitempty"`
 	MaxResults *int   `json:"max_results,omitempty"`
+	// Sort is name, count or files, with pinned tags kept first; empty sorts
+	// by count
+	Sort  string `json:"sort,omitempty"`
+	Order string `json:"order,omitempty"`
+	// Compact returns CompactTag results without file lists
+	Compact bool `json:"compact,omitempty"`
 }
 
 type ReplaceTagsBatchParams struct {
 	Replacements []TagReplacement `json:"replacements"`
-	Root         string           `json:"root"`
-	DryRun       bool             `json:"dry_run"`
+	Root         string           `json:"root,omitempty"`
+	RootName     string           `json:"root_name,omitempty"`
+	DryRun       bool             `json:"dry_run,omitempty"`
+	// Transactional modifies every file or none
+	Transactional *bool `json:"transactional,omitempty"`
 }
 
 type GetUntaggedFilesParams struct {
-	Root       string `json:"root"`
+	Root       string `json:"root,omitempty"`
+	RootName   string `json:"root_name,omitempty"`
 	MaxResults *int   `json:"max_results,omitempty"`
+	Order      string `json:"order,omitempty"`
+	// TaggedWith returns the files carrying none of these tags instead of
+	// the files without tags
+	TaggedWith []string `json:"tagged_with,omitempty"`
+	// Missing is another name for TaggedWith; the tags of both are used
+	Missing []string `json:"missing,omitempty"`
 }
 
 type ValidateTagsParams struct {
@@ -48,11 +81,66 @@ type ValidateTagsParams struct {
 
 type GetFilesTagsParams struct {
 	FilePaths []string `json:"file_paths"`
-	MaxFiles  *int     `json:"max_files,omitempty"`
+	// Root, when set, is the vault relative paths and globs in FilePaths are
+	// resolved against
+	Root     string `json:"root,omitempty"`
+	RootName string `json:"root_name,omitempty"`
+	MaxFiles *int   `json:"max_files,omitempty"`
+}
+
+type UpdateTagsPerFileParams struct {
+	Operations []FileTagOp `json:"operations"`
+	Root       string      `json:"root,omitempty"`
+	RootName   string      `json:"root_name,omitempty"`
+	DryRun     bool        `json:"dry_run,omitempty"`
+	Migrate    []string    `json:"migrate,omitempty"`
+}
+
+type PreviewUpdateTagsParams struct {
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	FilePaths  []string `json:"file_paths"`
+	Root       string   `json:"root,omitempty"`
+	RootName   string   `json:"root_name,omitempty"`
+	Migrate    []string `json:"migrate,omitempty"`
+}
+
+type ListRootsParams struct{}
+
+type GetChangesParams struct {
+	// Since is RFC 3339, YYYY-MM-DD or a duration before now such as 24h
+	Since    string `json:"since"`
+	Root     string `json:"root,omitempty"`
+	RootName string `json:"root_name,omitempty"`
 }
 
 // Tool handler functions
 func FindFilesByTagsTool(ctx context.Context, req *mcp.CallToolRequest, args FindFilesByTagsParams, manager TagManager) (*mcp.CallToolResult, any, error) {
+	manager, err := withOrder(manager, args.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateMatchMode(args.Match); err != nil {
+		return nil, nil, err
+	}
+	if len(args.NotTags) > 0 {
+		manager = manager.WithNotTags(args.NotTags)
+	}
+	if args.Match == MatchAll {
+		files, err := manager.FindFilesWithAllTags(ctx, args.Tags, args.Root)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find files by tags: %w", err)
+		}
+		if args.MaxResults != nil && len(files) > *args.MaxResults {
+			files = files[:*args.MaxResults]
+		}
+		if args.Compact {
+			return nil, relativePaths(args.Root, files), nil
+		}
+		return nil, files, nil
+	}
+
 	result, err := manager.FindFilesByTags(ctx, args.Tags, args.Root)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to find files by tags: %w", err)
@@ -62,6 +150,9 @@ func FindFilesByTagsTool(ctx context.Context, req *mcp.CallToolRequest, args Fin
 		result = limitFilesByTagsResults(result, *args.MaxResults)
 	}
 
+	if args.Compact {
+		return nil, compactFilesByTags(result, args.Root), nil
+	}
 	return nil, result, nil
 }
 
@@ -75,6 +166,9 @@ func GetTagsInfoTool(ctx context.Context, req *mcp.CallToolRequest, args GetTags
 		result = limitTagInfoFiles(result, *args.MaxFilesPerTag)
 	}
 
+	if args.Compact {
+		return nil, compactTags(result, args.Root, true), nil
+	}
 	return nil, result, nil
 }
 
@@ -92,14 +186,28 @@ func ListAllTagsTool(ctx context.Context, req *mcp.CallToolRequest, args ListAll
 		result = filterTagsByPattern(result, pattern)
 	}
 
+	if args.Sort != "" {
+		if err := SortTags(result, args.Sort, args.Order); err != nil {
+			return nil, nil, err
+		}
+	} else if args.Order != "" {
+		return nil, nil, fmt.Errorf("order requires sort")
+	}
+
 	if args.MaxResults != nil && len(result) > *args.MaxResults {
 		result = result[:*args.MaxResults]
 	}
 
+	if args.Compact {
+		return nil, compactTags(result, args.Root, false), nil
+	}
 	return nil, result, nil
 }
 
 func ReplaceTagsBatchTool(ctx context.Context, req *mcp.CallToolRequest, args ReplaceTagsBatchParams, manager TagManager) (*mcp.CallToolResult, any, error) {
+	if args.Transactional != nil {
+		manager = manager.WithTransactional(*args.Transactional)
+	}
 	result, err := manager.ReplaceTagsBatch(ctx, args.Replacements, args.Root, args.DryRun)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to replace tags: %w", err)
@@ -109,7 +217,17 @@ func ReplaceTagsBatchTool(ctx context.Context, req *mcp.CallToolRequest, args Re
 }
 
 func GetUntaggedFilesTool(ctx context.Context, req *mcp.CallToolRequest, args GetUntaggedFilesParams, manager TagManager) (*mcp.CallToolResult, any, error) {
-	result, err := manager.GetUntaggedFiles(ctx, args.Root)
+	manager, err := withOrder(manager, args.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result []FileTagInfo
+	if taggedWith := slices.Concat(args.TaggedWith, args.Missing); len(taggedWith) > 0 {
+		result, err = manager.GetFilesNotTaggedWith(ctx, taggedWith, args.Root)
+	} else {
+		result, err = manager.GetUntaggedFiles(ctx, args.Root)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get untagged files: %w", err)
 	}
@@ -127,7 +245,10 @@ func ValidateTagsTool(ctx context.Context, req *mcp.CallToolRequest, args Valida
 }
 
 func GetFilesTagsTool(ctx context.Context, req *mcp.CallToolRequest, args GetFilesTagsParams, manager TagManager) (*mcp.CallToolResult, any, error) {
-	filePaths := args.FilePaths
+	filePaths, err := rootedFilePaths(ctx, manager, args.Root, args.FilePaths)
+	if err != nil {
+		return nil, nil, err
+	}
 	if args.MaxFiles != nil && len(filePaths) > *args.MaxFiles {
 		filePaths = filePaths[:*args.MaxFiles]
 	}
@@ -141,7 +262,31 @@ func GetFilesTagsTool(ctx context.Context, req *mcp.CallToolRequest, args GetFil
 }
 
 func UpdateTagsTool(ctx context.Context, req *mcp.CallToolRequest, args TagUpdateParams, manager TagManager) (*mcp.CallToolResult, any, error) {
-	result, err := manager.UpdateTags(ctx, args.AddTags, args.RemoveTags, args.Root, args.FilePaths, false)
+	manager, err := withMigrate(manager, args.Migrate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePaths, err := manager.ExpandFilePaths(ctx, args.Root, args.FilePaths)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to expand file paths: %w", err)
+	}
+
+	result, err := manager.UpdateTags(ctx, args.AddTags, args.RemoveTags, args.Root, filePaths, args.DryRun)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	return nil, result, nil
+}
+
+func UpdateTagsPerFileTool(ctx context.Context, req *mcp.CallToolRequest, args UpdateTagsPerFileParams, manager TagManager) (*mcp.CallToolResult, any, error) {
+	manager, err := withMigrate(manager, args.Migrate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := manager.UpdateTagsPerFile(ctx, args.Root, args.Operations, args.DryRun)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to update tags: %w", err)
 	}
@@ -149,6 +294,51 @@ func UpdateTagsTool(ctx context.Context, req *mcp.CallToolRequest, args TagUpdat
 	return nil, result, nil
 }
 
+func PreviewUpdateTagsTool(ctx context.Context, req *mcp.CallToolRequest, args PreviewUpdateTagsParams, manager TagManager) (*mcp.CallToolResult, any, error) {
+	manager, err := withMigrate(manager, args.Migrate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePaths, err := manager.ExpandFilePaths(ctx, args.Root, args.FilePaths)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to expand file paths: %w", err)
+	}
+
+	result, err := manager.PreviewUpdateTags(ctx, args.AddTags, args.RemoveTags, args.Root, filePaths)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to preview tag update: %w", err)
+	}
+
+	return nil, result, nil
+}
+
+// ListRootsTool lists the named roots in config, sorted by name
+func ListRootsTool(ctx context.Context, req *mcp.CallToolRequest, args ListRootsParams, config *Config) (*mcp.CallToolResult, any, error) {
+	roots := []RootInfo{}
+	for name, path := range config.Roots {
+		roots = append(roots, RootInfo{Name: name, Path: path})
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+	return nil, roots, nil
+}
+
+func GetChangesTool(ctx context.Context, req *mcp.CallToolRequest, args GetChangesParams, manager TagManager) (*mcp.CallToolResult, any, error) {
+	since, err := parseSince(args.Since, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := manager.WhatChanged(ctx, args.Root, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+
+	return nil, result, nil
+}
+
 // Helper functions for result limiting
 func limitFilesByTagsResults(result map[string][]string, maxResults int) map[string][]string {
 	limited := make(map[string][]string)
@@ -184,23 +374,82 @@ func filterTagsByPattern(tagInfos []TagInfo, pattern *regexp.Regexp) []TagInfo {
 	return filtered
 }
 
+// defaultDryRun returns dryRun, or the config's default_dry_run when the
+// request leaves the dry_run argument out
+func defaultDryRun(req *mcp.CallToolRequest, dryRun bool, config *Config) bool {
+	if req == nil || req.Params == nil {
+		return dryRun
+	}
+	var arguments map[string]json.RawMessage
+	if raw, ok := req.Params.Arguments.(json.RawMessage); ok && len(raw) > 0 {
+		if err := json.Unmarshal(raw, &arguments); err != nil {
+			return dryRun
+		}
+	}
+	if _, ok := arguments["dry_run"]; ok {
+		return dryRun
+	}
+	return config.DefaultDryRun
+}
+
 // RunMCPServer starts the MCP server implementation using the official Go SDK
 // If transport is nil, it will use stdio transport
 func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
+	server, _, _, err := newMCPServer(configPath, "")
+	if err != nil {
+		return err
+	}
+
+	// Set up context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle shutdown signals
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	// Use provided transport or default to stdio
+	if transport != nil {
+		// Use the provided InMemoryTransport for testing
+		return server.Run(ctx, transport)
+	} else {
+		// Use stdio transport for production
+		return server.Run(ctx, &mcp.StdioTransport{})
+	}
+}
+
+// newMCPServer returns the MCP server with every tool registered, along with
+// the config and manager its tools use. A root limits the tools to it and the
+// configured roots, as newServedRoots describes.
+func newMCPServer(configPath, root string) (*mcp.Server, *Config, TagManager, error) {
 	config, err := LoadConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	manager, err := NewDefaultTagManager(config)
 	if err != nil {
-		return fmt.Errorf("failed to create tag manager: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create tag manager: %w", err)
+	}
+
+	// Stdout carries the MCP protocol, so warnings go to stderr
+	for _, conflict := range manager.Rules().Conflicts() {
+		if config.Strict {
+			return nil, nil, nil, fmt.Errorf("tag rules conflict: %s", conflict)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", conflict)
 	}
 
+	served := newServedRoots(config, root)
+
 	// Create MCP server
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "tag-manager",
-		Version: "1.0.0",
+		Version: ReadBuildInfo().Version,
 	}, nil)
 
 	// Register all MCP tools
@@ -208,6 +457,11 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "find_files_by_tags",
 		Description: "Find files containing specific tags",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindFilesByTagsParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
 		return FindFilesByTagsTool(ctx, req, args, manager)
 	})
 
@@ -215,6 +469,11 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "get_tags_info",
 		Description: "Get detailed information about specific tags including file lists",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetTagsInfoParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
 		return GetTagsInfoTool(ctx, req, args, manager)
 	})
 
@@ -222,6 +481,11 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "list_all_tags",
 		Description: "List all tags with usage statistics and optional filtering",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args ListAllTagsParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
 		return ListAllTagsTool(ctx, req, args, manager)
 	})
 
@@ -229,6 +493,12 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "replace_tags_batch",
 		Description: "Replace/rename tags across multiple files with batch operation",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args ReplaceTagsBatchParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		args.DryRun = defaultDryRun(req, args.DryRun, config)
 		return ReplaceTagsBatchTool(ctx, req, args, manager)
 	})
 
@@ -236,6 +506,11 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "get_untagged_files",
 		Description: "Find files that don't have any tags",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetUntaggedFilesParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
 		return GetUntaggedFilesTool(ctx, req, args, manager)
 	})
 
@@ -250,6 +525,14 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "get_files_tags",
 		Description: "Get all tags associated with specific files",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetFilesTagsParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		if err := served.checkFiles(root, args.FilePaths); err != nil {
+			return nil, nil, err
+		}
 		return GetFilesTagsTool(ctx, req, args, manager)
 	})
 
@@ -257,27 +540,70 @@ func RunMCPServer(configPath string, transport *mcp.InMemoryTransport) error {
 		Name:        "update_tags",
 		Description: "Add and remove tags from specific files with automatic hashtag migration",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args TagUpdateParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		if err := served.checkFiles(root, args.FilePaths); err != nil {
+			return nil, nil, err
+		}
+		args.DryRun = defaultDryRun(req, args.DryRun, config)
 		return UpdateTagsTool(ctx, req, args, manager)
 	})
 
-	// Set up context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "update_tags_per_file",
+		Description: "Add and remove a different set of tags in each file in one batch, with automatic hashtag migration",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args UpdateTagsPerFileParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		if err := served.checkFiles(root, opPaths(args.Operations)); err != nil {
+			return nil, nil, err
+		}
+		args.DryRun = defaultDryRun(req, args.DryRun, config)
+		return UpdateTagsPerFileTool(ctx, req, args, manager)
+	})
 
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		cancel()
-	}()
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "preview_update_tags",
+		Description: "Preview update_tags without modifying files: the diff of each file, hashtag migrations and conflicting tags",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args PreviewUpdateTagsParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		if err := served.checkFiles(root, args.FilePaths); err != nil {
+			return nil, nil, err
+		}
+		return PreviewUpdateTagsTool(ctx, req, args, manager)
+	})
 
-	// Use provided transport or default to stdio
-	if transport != nil {
-		// Use the provided InMemoryTransport for testing
-		return server.Run(ctx, transport)
-	} else {
-		// Use stdio transport for production
-		return server.Run(ctx, &mcp.StdioTransport{})
-	}
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_changes",
+		Description: "List files whose tags changed since a time, with the tags added and removed",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetChangesParams) (*mcp.CallToolResult, any, error) {
+		root, err := served.resolve(config, args.Root, args.RootName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args.Root = root
+		return GetChangesTool(ctx, req, args, manager)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_roots",
+		Description: "List the named vaults other tools accept as root_name",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ListRootsParams) (*mcp.CallToolResult, any, error) {
+		return ListRootsTool(ctx, req, args, config)
+	})
+
+	return server, config, manager, nil
 }
@@ -0,0 +1,54 @@
+package tagmanager
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// validateMigratePatterns checks the patterns of Config.Migrate
+func validateMigratePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("invalid migrate pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// WithMigrate returns a manager whose UpdateTags only migrates the
+// top-of-file hashtags matching one of patterns to frontmatter, leaving the
+// others inline. Patterns match tags case-insensitively like globs over the
+// tag's '/' separated segments, so project/* matches project/alpha but not
+// project/alpha/beta, and project/** matches both. Nil patterns migrate every
+// hashtag, as Config.Migrate does when empty.
+func (m *DefaultTagManager) WithMigrate(patterns []string) TagManager {
+	selective := *m
+	selective.migrate = patterns
+	return &selective
+}
+
+// withMigrate limits the hashtags manager migrates to patterns, leaving
+// manager unchanged when there are none
+func withMigrate(manager TagManager, patterns []string) (TagManager, error) {
+	if len(patterns) == 0 {
+		return manager, nil
+	}
+	if err := validateMigratePatterns(patterns); err != nil {
+		return nil, err
+	}
+	return manager.WithMigrate(patterns), nil
+}
+
+// shouldMigrate reports whether a top-of-file hashtag moves to frontmatter
+func (m *DefaultTagManager) shouldMigrate(tag string) bool {
+	if len(m.migrate) == 0 {
+		return true
+	}
+	for _, pattern := range m.migrate {
+		if matchGlob(strings.ToLower(pattern), strings.ToLower(tag)) {
+			return true
+		}
+	}
+	return false
+}
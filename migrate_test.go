@@ -0,0 +1,59 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	note := "#project/alpha #keep\n#status-done\n\nBody\n"
+
+	t.Run("OnlyMatchingHashtags", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.WithMigrate([]string{"project/*", "status-*"}).UpdateTags(ctx, []string{"reviewed"}, nil, root,
+			[]string{"a.md"}, false)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "project/alpha")
+		assert.Contains(t, string(content), "status-done")
+		assert.Contains(t, string(content), "reviewed")
+		assert.Contains(t, string(content), "---\n#keep\n")
+		assert.NotContains(t, string(content), "#project/alpha")
+		assert.NotContains(t, string(content), "#status-done")
+	})
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.Migrate = []string{"project/["}
+		_, err := tagmanager.NewDefaultTagManager(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid migrate pattern")
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note})
+
+		err := tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "reviewed", "--files", "a.md",
+			"--root", root, "--migrate", "project/*"}, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "#project/alpha")
+		assert.Contains(t, string(content), "#keep")
+		assert.Contains(t, string(content), "#status-done")
+	})
+}
@@ -0,0 +1,72 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// WithNotTags returns a manager whose FindFilesByTags, FindFilesWithAllTags
+// and GetTagsInfo leave out the files carrying any of tags, such as drafts or
+// archived notes. Nil tags leave nothing out.
+func (m *DefaultTagManager) WithNotTags(tags []string) TagManager {
+	filtered := *m
+	filtered.notTags = m.normalizeTags(tags)
+	return &filtered
+}
+
+// excludedByNotTags reports whether a file with fileTags, normalized, carries
+// one of the tags WithNotTags leaves out
+func (m *DefaultTagManager) excludedByNotTags(fileTags map[string]bool) bool {
+	for _, tag := range m.notTags {
+		if fileTags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFilesNotTaggedWith returns the files which carry none of tags, with the
+// tags they do carry. It is GetUntaggedFiles for a chosen set of tags, such as
+// finding the notes no project/* tag was given.
+func (m *DefaultTagManager) GetFilesNotTaggedWith(ctx context.Context, tags []string, rootPath string) ([]FileTagInfo, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	normalizedTags := m.normalizeTags(tags)
+	var result []FileTagInfo
+
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fileTags := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			fileTags[m.normalizeTag(tag)] = true
+		}
+
+		tagged := false
+		for _, tag := range normalizedTags {
+			if fileTags[tag] {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			result = append(result, fileInfo)
+		}
+	}
+
+	if !m.ordered {
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Path < result[j].Path
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestNotTags(t *testing.T) {
+	ctx := context.Background()
+	root := writeVault(t, map[string]string{
+		"a.md":       "#golang #draft",
+		"b.md":       "#golang",
+		"c.md":       "---\ntags: [golang, archive]\n---\nBody\n",
+		"notes/d.md": "#python #golang",
+		"e.md":       "no tags",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	relative := func(t *testing.T, files []string) []string {
+		result := []string{}
+		for _, file := range files {
+			rel, err := filepath.Rel(root, file)
+			require.NoError(t, err)
+			result = append(result, filepath.ToSlash(rel))
+		}
+		return result
+	}
+
+	t.Run("FindFilesByTags", func(t *testing.T) {
+		results, err := manager.WithNotTags([]string{"draft", "#archive"}).FindFilesByTags(ctx, []string{"golang"}, root)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b.md", "notes/d.md"}, relative(t, results["golang"]))
+	})
+
+	t.Run("FindFilesWithAllTags", func(t *testing.T) {
+		files, err := manager.WithNotTags([]string{"python"}).FindFilesWithAllTags(ctx, []string{"golang"}, root)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md", "b.md", "c.md"}, relative(t, files))
+	})
+
+	t.Run("GetFilesNotTaggedWith", func(t *testing.T) {
+		files, err := manager.GetFilesNotTaggedWith(ctx, []string{"draft", "python"}, root)
+		require.NoError(t, err)
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		assert.Equal(t, []string{"b.md", "c.md", "e.md"}, relative(t, paths))
+	})
+
+	t.Run("Tools", func(t *testing.T) {
+		_, data, err := tagmanager.FindFilesByTagsTool(ctx, &mcp.CallToolRequest{},
+			tagmanager.FindFilesByTagsParams{Tags: []string{"golang"}, Root: root, NotTags: []string{"draft", "archive", "python"}, Compact: true}, manager)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{"golang": {"b.md"}}, data)
+
+		_, data, err = tagmanager.GetUntaggedFilesTool(ctx, &mcp.CallToolRequest{},
+			tagmanager.GetUntaggedFilesParams{Root: root, TaggedWith: []string{"golang"}}, manager)
+		require.NoError(t, err)
+		files := data.([]tagmanager.FileTagInfo)
+		require.Len(t, files, 1)
+		assert.Equal(t, filepath.Join(root, "e.md"), files[0].Path)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		run := func(t *testing.T, args ...string) (string, error) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			return stdout.String(), err
+		}
+
+		output, err := run(t, "find", "--root", root, "--tags=golang", "--not-tags=draft,archive", "--json")
+		require.NoError(t, err)
+		var results map[string][]string
+		require.NoError(t, json.Unmarshal([]byte(output), &results))
+		assert.Equal(t, []string{"b.md", "notes/d.md"}, relative(t, results["golang"]))
+
+		output, err = run(t, "untagged", "--root", root, "--tagged-with=golang")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Found 1 files without golang:", filepath.Join(root, "e.md")})
+
+		// --missing is an alias
+		output, err = run(t, "untagged", "--root", root, "--missing=golang")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Found 1 files without golang:", filepath.Join(root, "e.md")})
+	})
+}
@@ -0,0 +1,129 @@
+package tagmanager
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Occurrence is one place in a note where a replace would rename a tag
+type Occurrence struct {
+	Path   string
+	OldTag string
+	NewTag string
+	// Line is the 1-based line of the occurrence, and Text that line as it
+	// is before the rename
+	Line int
+	Text string
+	// Start and End are the byte offsets of the occurrence in Text
+	Start int
+	End   int
+}
+
+// OccurrenceFunc reports whether to rename an occurrence
+type OccurrenceFunc func(occurrence Occurrence) bool
+
+// Markers wrapping each occurrence while they are located. Notes are text, so
+// these control characters don't appear in them.
+const (
+	occurrenceStart = "\x00"
+	occurrenceText  = "\x01"
+	occurrenceEnd   = "\x02"
+)
+
+// WithOccurrences returns a manager whose ReplaceTagsBatch asks decide about
+// each occurrence of a tag it would rename, in the order they appear in each
+// file, and renames only those decide accepts. Transactional replaces and
+// change sets rename every occurrence without asking.
+func (m *DefaultTagManager) WithOccurrences(decide OccurrenceFunc) TagManager {
+	interactive := *m
+	interactive.decide = decide
+	return &interactive
+}
+
+// decideOccurrences renames the occurrences in the normalized content of the
+// note at path which m.decide accepts
+func (m *DefaultTagManager) decideOccurrences(path, content string, replacements []TagReplacement) string {
+	// Each occurrence is first marked, keeping its text, to find where it is
+	oldTags := make(map[int]string)
+	newTags := make(map[int]string)
+	marked := m.replaceOccurrences(content, replacements, func(n int, oldText, newText string) string {
+		oldTags[n] = strings.Trim(strings.TrimPrefix(strings.TrimSpace(oldText), "#"), `"`)
+		newTags[n] = strings.Trim(strings.TrimPrefix(newText, "#"), `"`)
+		return occurrenceStart + strconv.Itoa(n) + occurrenceText + oldText + occurrenceEnd
+	})
+	if len(oldTags) == 0 {
+		return content
+	}
+
+	occurrences := locateOccurrences(marked)
+	numbers := make([]int, 0, len(occurrences))
+	for n := range occurrences {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		a, b := occurrences[numbers[i]], occurrences[numbers[j]]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Start < b.Start
+	})
+
+	accepted := make(map[int]bool)
+	for _, n := range numbers {
+		occurrence := occurrences[n]
+		occurrence.Path = path
+		occurrence.OldTag = oldTags[n]
+		occurrence.NewTag = newTags[n]
+		accepted[n] = m.decide(occurrence)
+	}
+
+	return m.replaceOccurrences(content, replacements, func(n int, oldText, newText string) string {
+		if accepted[n] {
+			return newText
+		}
+		return oldText
+	})
+}
+
+// locateOccurrences returns where each marked occurrence is in the content
+// left once the markers are removed, by occurrence number
+func locateOccurrences(marked string) map[int]Occurrence {
+	type span struct{ start, end int }
+	spans := make(map[int]span)
+
+	var content strings.Builder
+	for len(marked) > 0 {
+		i := strings.Index(marked, occurrenceStart)
+		if i < 0 {
+			content.WriteString(marked)
+			break
+		}
+		content.WriteString(marked[:i])
+		marked = marked[i+len(occurrenceStart):]
+
+		number, rest, _ := strings.Cut(marked, occurrenceText)
+		text, rest, _ := strings.Cut(rest, occurrenceEnd)
+		n, _ := strconv.Atoi(number)
+		spans[n] = span{start: content.Len(), end: content.Len() + len(text)}
+		content.WriteString(text)
+		marked = rest
+	}
+
+	text := content.String()
+	occurrences := make(map[int]Occurrence, len(spans))
+	for n, s := range spans {
+		lineStart := strings.LastIndex(text[:s.start], "\n") + 1
+		lineEnd := len(text)
+		if i := strings.Index(text[s.start:], "\n"); i >= 0 {
+			lineEnd = s.start + i
+		}
+		occurrences[n] = Occurrence{
+			Line:  strings.Count(text[:s.start], "\n") + 1,
+			Text:  text[lineStart:lineEnd],
+			Start: s.start - lineStart,
+			End:   min(s.end, lineEnd) - lineStart,
+		}
+	}
+	return occurrences
+}
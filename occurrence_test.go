@@ -0,0 +1,130 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+const occurrenceNote = "---\ntags: [java, notes]\n---\nLearning #java today.\nThe grade was #java again, and #java.\n"
+
+func TestWithOccurrences(t *testing.T) {
+	root := writeVault(t, map[string]string{"a.md": occurrenceNote})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	var asked []tagmanager.Occurrence
+	decide := func(occurrence tagmanager.Occurrence) bool {
+		asked = append(asked, occurrence)
+		// Rename the frontmatter tag and the last occurrence on line 5 only
+		return occurrence.Line == 2 || (occurrence.Line == 5 && occurrence.Start > 20)
+	}
+	result, err := manager.WithOccurrences(decide).ReplaceTagsBatch(context.Background(),
+		[]tagmanager.TagReplacement{{OldTag: "java", NewTag: "jvm"}}, root, false)
+	require.NoError(t, err)
+	assert.Len(t, result.ModifiedFiles, 1)
+
+	require.Len(t, asked, 4)
+	for i, line := range []int{2, 4, 5, 5} {
+		assert.Equal(t, line, asked[i].Line)
+		assert.Equal(t, "java", asked[i].OldTag)
+		assert.Equal(t, "jvm", asked[i].NewTag)
+	}
+	assert.Equal(t, "java", asked[0].Text[asked[0].Start:asked[0].End])
+	assert.Equal(t, "#java", asked[2].Text[asked[2].Start:asked[2].End])
+	assert.Less(t, asked[2].Start, asked[3].Start)
+
+	content, err := os.ReadFile(filepath.Join(root, "a.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "---\ntags: [\"jvm\", notes]\n---\nLearning #java today.\nThe grade was #java again, and #jvm.\n", string(content))
+}
+
+func TestReplaceInteractive(t *testing.T) {
+	run := func(t *testing.T, stdin string, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(stdin)})
+		return stdout.String(), err
+	}
+	read := func(t *testing.T, root, name string) string {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	tests := []struct {
+		name  string
+		stdin string
+		a     string
+		b     string
+	}{
+		{
+			name:  "EachOccurrence",
+			stdin: "y\nn\n\ny\ny\n",
+			a:     "---\ntags: [\"jvm\", notes]\n---\nLearning #java today.\nThe grade was #java again, and #jvm.\n",
+			b:     "Also #jvm here\n",
+		},
+		{
+			name:  "RestOfFile",
+			stdin: "n\na\nn\n",
+			a:     "---\ntags: [java, notes]\n---\nLearning #jvm today.\nThe grade was #jvm again, and #jvm.\n",
+			b:     "Also #java here\n",
+		},
+		{
+			name:  "SkipRestOfFile",
+			stdin: "y\ns\ny\n",
+			a:     "---\ntags: [\"jvm\", notes]\n---\nLearning #java today.\nThe grade was #java again, and #java.\n",
+			b:     "Also #jvm here\n",
+		},
+		{
+			name:  "Quit",
+			stdin: "y\nq\n",
+			a:     "---\ntags: [\"jvm\", notes]\n---\nLearning #java today.\nThe grade was #java again, and #java.\n",
+			b:     "Also #java here\n",
+		},
+		{
+			name:  "EndOfInput",
+			stdin: "",
+			a:     occurrenceNote,
+			b:     "Also #java here\n",
+		},
+		{
+			name:  "UnknownAnswer",
+			stdin: "maybe\ny\ns\nn\n",
+			a:     "---\ntags: [\"jvm\", notes]\n---\nLearning #java today.\nThe grade was #java again, and #java.\n",
+			b:     "Also #java here\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, map[string]string{"a.md": occurrenceNote, "b.md": "Also #java here\n"})
+			output, err := run(t, test.stdin, "replace", "--old", "java", "--new", "jvm", "--root", root, "--interactive")
+			require.NoError(t, err)
+			assertOutputContains(t, output, []string{"a.md", "  2: tags: [java, notes]", "Rename #java to #jvm? [y/n/a/s/q]"})
+			assert.Equal(t, test.a, read(t, root, "a.md"))
+			assert.Equal(t, test.b, read(t, root, "b.md"))
+		})
+	}
+
+	t.Run("Incompatible", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": occurrenceNote})
+		for flag, expected := range map[string]string{
+			"--transactional": "--interactive cannot be combined with --transactional",
+			"--stream":        "--interactive cannot be combined with --stream",
+			"--json":          "--interactive cannot be combined with --format=json",
+		} {
+			_, err := run(t, "y\n", "replace", "--old", "java", "--new", "jvm", "--root", root, "--interactive", flag)
+			require.Error(t, err, flag)
+			assert.Contains(t, err.Error(), expected)
+		}
+		assert.Equal(t, occurrenceNote, read(t, root, "a.md"))
+	})
+}
@@ -0,0 +1,269 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// VaultConfigFile is the per-vault configuration file written by `init`
+	VaultConfigFile = ".tag-manager.yaml"
+
+	maxProtectedCandidates = 5
+)
+
+// commonExcludeDirs are folders found in many Obsidian vaults which rarely
+// contain notes whose tags should be managed.
+var commonExcludeDirs = []string{
+	".git", ".obsidian", ".trash", "100 Archive", "Archive", "Attachments", "attachments", "Templates", "templates",
+}
+
+// ProposeConfig scans a vault and proposes a configuration based on the
+// folders present and the tagging conventions already in use.
+func (m *DefaultTagManager) ProposeConfig(ctx context.Context, rootPath string) (*ConfigProposal, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	proposal := &ConfigProposal{
+		Root:            rootPath,
+		ExcludeDirs:     []string{},
+		ExcludePatterns: append([]string{}, m.config.ExcludePatterns...),
+		ProtectedTags:   []string{},
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			present[entry.Name()] = true
+		}
+	}
+	for _, dir := range commonExcludeDirs {
+		if present[dir] {
+			proposal.ExcludeDirs = append(proposal.ExcludeDirs, dir)
+		}
+	}
+
+	// Scan with the proposed excludes so template placeholders and archives
+	// don't skew the detected conventions.
+	scanConfig := *m.config
+	scanConfig.ExcludeDirs = append(append([]string{}, m.config.ExcludeDirs...), proposal.ExcludeDirs...)
+	scanner, err := NewFilesystemScanner(&scanConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scanner: %w", err)
+	}
+
+	tagCounts := make(map[string]int)
+	styleCounts := make(map[string]int)
+
+	for fileInfo, err := range scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		proposal.TotalFiles++
+
+		if len(fileInfo.Tags) == 0 {
+			continue
+		}
+
+		content, err := readNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			continue
+		}
+
+		frontmatterTagCount := 0
+		if frontmatter, _, ok := splitFrontmatter(string(content)); ok {
+			var doc yaml.Node
+			var data map[string]interface{}
+			if yaml.Unmarshal([]byte(frontmatter), &doc) == nil && len(doc.Content) > 0 && doc.Content[0].Decode(&data) == nil {
+				frontmatterTagCount = len(frontmatterTags(data))
+				styleCounts[frontmatterTagsStyle(doc.Content[0])]++
+			}
+		}
+		if frontmatterTagCount > 0 {
+			proposal.FrontmatterTagFiles++
+		}
+		if len(fileInfo.Tags) > frontmatterTagCount {
+			proposal.InlineTagFiles++
+		}
+
+		for _, tag := range fileInfo.Tags {
+			tagCounts[m.normalizeTag(tag)]++
+		}
+	}
+
+	switch {
+	case proposal.FrontmatterTagFiles == 0 && proposal.InlineTagFiles == 0:
+		proposal.TagStyle = "none"
+	case proposal.InlineTagFiles == 0:
+		proposal.TagStyle = "frontmatter"
+	case proposal.FrontmatterTagFiles == 0:
+		proposal.TagStyle = "inline"
+	default:
+		proposal.TagStyle = "mixed"
+	}
+
+	switch {
+	case styleCounts[TagsStyleArray] > styleCounts[TagsStyleList]:
+		proposal.TagsStyle = TagsStyleArray
+	case styleCounts[TagsStyleList] > styleCounts[TagsStyleArray]:
+		proposal.TagsStyle = TagsStyleList
+	}
+
+	allTags := make([]string, 0, len(tagCounts))
+	for tag := range tagCounts {
+		allTags = append(allTags, tag)
+	}
+	proposal.CaseConvention = proposeTagCase(allTags)
+
+	// The most widely used tags are the ones a careless rename hurts most
+	tags := make([]string, 0, len(tagCounts))
+	for tag, count := range tagCounts {
+		if count > 1 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tagCounts[tags[i]] != tagCounts[tags[j]] {
+			return tagCounts[tags[i]] > tagCounts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > maxProtectedCandidates {
+		tags = tags[:maxProtectedCandidates]
+	}
+	proposal.ProtectedTags = append(proposal.ProtectedTags, tags...)
+
+	return proposal, nil
+}
+
+// Config returns the configuration described by the proposal, layered on top
+// of base.
+func (p *ConfigProposal) Config(base *Config) *Config {
+	config := *base
+	config.ExcludeDirs = mergeUnique(base.ExcludeDirs, p.ExcludeDirs)
+	config.ExcludePatterns = mergeUnique(base.ExcludePatterns, p.ExcludePatterns)
+	config.ProtectedTags = mergeUnique(base.ProtectedTags, p.ProtectedTags)
+	if p.TagsStyle != TagsStylePreserve {
+		config.TagsStyle = p.TagsStyle
+	}
+	if p.CaseConvention != TagCaseAny {
+		config.TagCase = p.CaseConvention
+	}
+	return &config
+}
+
+// Render returns the proposal as a YAML configuration file.
+func (p *ConfigProposal) Render(base *Config) (string, error) {
+	config := p.Config(base)
+
+	data, err := yaml.Marshal(struct {
+		ExcludeDirs     []string `yaml:"exclude_dirs"`
+		ExcludePatterns []string `yaml:"exclude_patterns"`
+		TagsStyle       string   `yaml:"tags_style,omitempty"`
+		TagCase         string   `yaml:"tag_case,omitempty"`
+		ProtectedTags   []string `yaml:"protected_tags"`
+	}{
+		ExcludeDirs:     config.ExcludeDirs,
+		ExcludePatterns: config.ExcludePatterns,
+		TagsStyle:       config.TagsStyle,
+		TagCase:         config.TagCase,
+		ProtectedTags:   config.ProtectedTags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("YAML marshal error: %w", err)
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "# tag-manager configuration for %s\n", p.Root)
+	b.Write(data)
+	return b.String(), nil
+}
+
+// frontmatterTagsStyle returns the TagsStyle the tags of a frontmatter
+// mapping are written in, or TagsStylePreserve when they aren't a list
+func frontmatterTagsStyle(mapping *yaml.Node) string {
+	for _, key := range frontmatterTagKeys {
+		if value := mappingValue(mapping, key); value != nil && value.Kind == yaml.SequenceNode {
+			if value.Style&yaml.FlowStyle != 0 {
+				return TagsStyleArray
+			}
+			return TagsStyleList
+		}
+	}
+	return TagsStylePreserve
+}
+
+// proposeTagCase returns the casing convention of tags: the one whose word
+// separator the most tags use, or lowercase when none uses one. When fewer
+// than half the tags follow it there is no convention, TagCaseAny.
+func proposeTagCase(tags []string) string {
+	used := make(map[string]int)
+	for _, tag := range tags {
+		switch {
+		case strings.Contains(tag, "-"):
+			used[TagCaseKebab]++
+		case strings.Contains(tag, "_"):
+			used[TagCaseSnake]++
+		case strings.IndexFunc(tag, unicode.IsUpper) > 0:
+			used[TagCaseCamel]++
+		}
+	}
+
+	convention, best := TagCaseLower, 0
+	for _, candidate := range []string{TagCaseKebab, TagCaseSnake, TagCaseCamel} {
+		if used[candidate] > best {
+			convention, best = candidate, used[candidate]
+		}
+	}
+
+	following := 0
+	for _, tag := range tags {
+		if followsTagCase(tag, convention) {
+			following++
+		}
+	}
+	if following == 0 || 2*following < len(tags) {
+		return TagCaseAny
+	}
+	return convention
+}
+
+func mergeUnique(base, extra []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, value := range append(append([]string{}, base...), extra...) {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// writeVaultConfig writes content to the vault config file in rootPath.
+func writeVaultConfig(config *Config, rootPath string, content string, force bool) (string, error) {
+	path := filepath.Join(rootPath, VaultConfigFile)
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+	if err := newFilePerm(config).writeFile(path, []byte(content)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,153 @@
+package tagmanager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fields directory scans can be ordered by
+const (
+	OrderByPath    = "path"
+	OrderByModTime = "mtime"
+	OrderBySize    = "size"
+)
+
+// ScanOrder orders the files visited by directory scans
+type ScanOrder struct {
+	By         string
+	Descending bool
+}
+
+// ParseScanOrder parses an order written as field[:asc|desc], such as
+// "mtime:desc". The field is path, mtime or size; the direction defaults to asc.
+func ParseScanOrder(s string) (ScanOrder, error) {
+	by, direction, _ := strings.Cut(s, ":")
+
+	var order ScanOrder
+	switch by {
+	case OrderByPath, OrderByModTime, OrderBySize:
+		order.By = by
+	default:
+		return ScanOrder{}, fmt.Errorf("invalid order %q: field must be %s, %s or %s", s, OrderByPath, OrderByModTime, OrderBySize)
+	}
+
+	switch direction {
+	case "", "asc":
+	case "desc":
+		order.Descending = true
+	default:
+		return ScanOrder{}, fmt.Errorf("invalid order %q: direction must be asc or desc", s)
+	}
+	return order, nil
+}
+
+func (o ScanOrder) String() string {
+	if o.Descending {
+		return o.By + ":desc"
+	}
+	return o.By + ":asc"
+}
+
+// orderedScanner visits the files of a directory scan in order. The whole
+// directory is walked and every file stat'd before the first is yielded.
+type orderedScanner struct {
+	Scanner
+	order ScanOrder
+}
+
+// orderedFile is a walked file with the attributes it is ordered by
+type orderedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (s *orderedScanner) WalkFiles(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		var files []orderedFile
+		for path, err := range s.Scanner.WalkFiles(ctx, rootPath, excludePaths) {
+			if err != nil {
+				if !yield(path, err) {
+					return
+				}
+				continue
+			}
+
+			file := orderedFile{path: path}
+			if s.order.By != OrderByPath {
+				info, err := os.Stat(path)
+				if err != nil {
+					if !yield(path, &ScanError{Path: path, Phase: ScanPhaseStat, Err: err}) {
+						return
+					}
+					continue
+				}
+				file.size = info.Size()
+				file.modTime = info.ModTime()
+			}
+			files = append(files, file)
+		}
+
+		sort.Slice(files, func(i, j int) bool {
+			a, b := files[i], files[j]
+
+			var c int
+			switch s.order.By {
+			case OrderByModTime:
+				c = a.modTime.Compare(b.modTime)
+			case OrderBySize:
+				c = cmp.Compare(a.size, b.size)
+			default:
+				c = strings.Compare(a.path, b.path)
+			}
+			if s.order.Descending {
+				c = -c
+			}
+
+			// Ties are always broken by ascending path so the order is stable
+			if c == 0 {
+				return a.path < b.path
+			}
+			return c < 0
+		})
+
+		for _, file := range files {
+			if !yield(file.path, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (s *orderedScanner) ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error] {
+	return scanWalkedFiles(ctx, s, s.WalkFiles(ctx, rootPath, excludePaths))
+}
+
+// WithOrder returns a manager whose directory scans visit files in order, so
+// the files in its results are listed in that order.
+func (m *DefaultTagManager) WithOrder(order ScanOrder) TagManager {
+	ordered := *m
+	ordered.scanner = &orderedScanner{Scanner: m.scanner, order: order}
+	ordered.ordered = true
+	return &ordered
+}
+
+// withOrder orders manager's scans by an order written for ParseScanOrder.
+// An empty order leaves manager unchanged.
+func withOrder(manager TagManager, order string) (TagManager, error) {
+	if order == "" {
+		return manager, nil
+	}
+
+	scanOrder, err := ParseScanOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	return manager.WithOrder(scanOrder), nil
+}
@@ -0,0 +1,111 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestParseScanOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected tagmanager.ScanOrder
+		err      string
+	}{
+		{input: "path", expected: tagmanager.ScanOrder{By: tagmanager.OrderByPath}},
+		{input: "mtime:desc", expected: tagmanager.ScanOrder{By: tagmanager.OrderByModTime, Descending: true}},
+		{input: "size:asc", expected: tagmanager.ScanOrder{By: tagmanager.OrderBySize}},
+		{input: "name", err: "field must be"},
+		{input: "size:down", err: "direction must be"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			order, err := tagmanager.ParseScanOrder(test.input)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, order)
+		})
+	}
+}
+
+func TestScanOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	now := time.Now()
+
+	// Names, sizes and modification times are each ordered differently
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{name: "a.md", content: "medium note", age: time.Hour},
+		{name: "b.md", content: "the longest note of all", age: 3 * time.Hour},
+		{name: "c.md", content: "short", age: 2 * time.Hour},
+	}
+	for _, file := range files {
+		path := filepath.Join(tempDir, file.name)
+		require.NoError(t, os.WriteFile(path, []byte(file.content), tagmanager.DefaultFilePermissions))
+		modTime := now.Add(-file.age)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	tests := []struct {
+		order    tagmanager.ScanOrder
+		expected []string
+	}{
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderByPath}, expected: []string{"a.md", "b.md", "c.md"}},
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderByPath, Descending: true}, expected: []string{"c.md", "b.md", "a.md"}},
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderByModTime}, expected: []string{"b.md", "c.md", "a.md"}},
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderByModTime, Descending: true}, expected: []string{"a.md", "c.md", "b.md"}},
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderBySize}, expected: []string{"c.md", "a.md", "b.md"}},
+		{order: tagmanager.ScanOrder{By: tagmanager.OrderBySize, Descending: true}, expected: []string{"b.md", "a.md", "c.md"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.order.String(), func(t *testing.T) {
+			untagged, err := manager.WithOrder(test.order).GetUntaggedFiles(context.Background(), tempDir)
+			require.NoError(t, err)
+
+			var names []string
+			for _, file := range untagged {
+				names = append(names, filepath.Base(file.Path))
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+
+	t.Run("CLI", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "untagged", "--root=" + tempDir, "--order=mtime:desc", "--json"}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		require.NoError(t, err)
+
+		var untagged []tagmanager.FileTagInfo
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &untagged))
+		require.Len(t, untagged, 3)
+		assert.Equal(t, filepath.Join(tempDir, "a.md"), untagged[0].Path)
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "untagged", "--root=" + tempDir, "--order=newest"}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		assert.ErrorContains(t, err, "invalid order")
+	})
+}
@@ -0,0 +1,398 @@
+package tagmanager
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for --format
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatTemplate = "template"
+)
+
+// listSeparator joins list values, such as a file's tags, within one cell
+const listSeparator = ";"
+
+// formatter writes command results in one output format. Formats writing a
+// table are only offered by the commands whose results have rows.
+type formatter struct {
+	name   string
+	result func(w io.Writer, o *output, result any) error
+	table  func(w io.Writer, t table) error
+}
+
+// formatters are the output formats every command shares, in the order help
+// and errors list them. Text has neither writer as each command prints it.
+var formatters = []formatter{
+	{name: FormatText},
+	{name: FormatJSON, result: writeJSON},
+	{name: FormatYAML, result: func(w io.Writer, _ *output, result any) error { return writeYAML(w, result) }},
+	{name: FormatCSV, table: writeCSV},
+	{name: FormatMarkdown, table: writeMarkdown},
+	{name: FormatTemplate, result: writeTemplate},
+}
+
+// outputFlags are the output flags of a command
+type outputFlags struct {
+	format   string
+	json     bool
+	template string
+	tabular  bool
+}
+
+// addOutputFlags adds --format, with --output as its alias and --json as its
+// shorthand, and --template to fs. Tabular commands, whose results have rows,
+// offer the table formats too.
+func addOutputFlags(fs *flag.FlagSet, tabular bool) *outputFlags {
+	flags := &outputFlags{tabular: tabular}
+	usage := "Output format: " + joinOr(flags.names())
+	fs.StringVar(&flags.format, "format", "", usage)
+	fs.StringVar(&flags.format, "output", "", usage)
+	fs.BoolVar(&flags.json, "json", false, "Output as JSON")
+	fs.StringVar(&flags.template, "template", "", "Go template to execute on the JSON form of the result; implies --format=template")
+	return flags
+}
+
+// names are the formats the command offers
+func (f *outputFlags) names() []string {
+	var names []string
+	for _, formatter := range formatters {
+		if f.tabular || formatter.table == nil {
+			names = append(names, formatter.name)
+		}
+	}
+	return names
+}
+
+// resolve returns the output the flags select
+func (f *outputFlags) resolve(indent bool) (*output, error) {
+	format := f.format
+	if f.json {
+		if format != "" && format != FormatJSON {
+			return nil, fmt.Errorf("--json cannot be combined with --format=%s", format)
+		}
+		format = FormatJSON
+	}
+	if f.template != "" {
+		if format != "" && format != FormatTemplate {
+			return nil, fmt.Errorf("--template cannot be combined with --format=%s", format)
+		}
+		format = FormatTemplate
+	}
+	if format == "" {
+		format = FormatText
+	}
+
+	names := f.names()
+	if !slices.Contains(names, format) {
+		return nil, fmt.Errorf("invalid format %q: must be %s", format, joinOr(names))
+	}
+	o := &output{format: format, indent: indent}
+	if format == FormatTemplate {
+		if f.template == "" {
+			return nil, fmt.Errorf("--format=template needs --template")
+		}
+		tmpl, err := template.New("output").Funcs(template.FuncMap{"join": joinValues}).Parse(f.template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		o.template = tmpl
+	}
+	return o, nil
+}
+
+// joinValues joins the values of a JSON list for templates
+func joinValues(values []any, sep string) string {
+	words := make([]string, len(values))
+	for i, value := range values {
+		words[i] = fmt.Sprint(value)
+	}
+	return strings.Join(words, sep)
+}
+
+// joinOr joins words as "a, b or c"
+func joinOr(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+// output writes a command's result in the selected format
+type output struct {
+	format string
+	// indent indents JSON, which is easier to read but harder to pipe
+	indent   bool
+	template *template.Template
+}
+
+// text reports whether the command prints its result itself
+func (o *output) text() bool {
+	return o.format == FormatText
+}
+
+// write writes result, using rows for the table formats. It returns false
+// for text, which each command prints itself.
+func (o *output) write(w io.Writer, result any, rows func() table) (bool, error) {
+	for _, formatter := range formatters {
+		if formatter.name != o.format {
+			continue
+		}
+		switch {
+		case formatter.table != nil:
+			return true, formatter.table(w, rows())
+		case formatter.result != nil:
+			return true, formatter.result(w, o, result)
+		}
+	}
+	return false, nil
+}
+
+// table is a result as rows under stable column headers, for the tabular
+// formats
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func writeJSON(w io.Writer, o *output, result any) error {
+	encoder := json.NewEncoder(w)
+	if o.indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(result)
+}
+
+// writeTemplate executes the template on the JSON form of result, so it
+// names fields as the JSON does
+func writeTemplate(w io.Writer, o *output, result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return o.template.Execute(w, value)
+}
+
+// writeYAML encodes result through its JSON form, so the YAML keeps the
+// json field names, order and omissions of the result structs
+func writeYAML(w io.Writer, result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// resetStyle drops the flow and quoting styles parsing JSON left on node
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+func writeCSV(w io.Writer, t table) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(t.header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(t.rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// writeMarkdown writes t as a GitHub-flavored markdown table, escaping
+// anything in a cell which would break the row
+func writeMarkdown(w io.Writer, t table) error {
+	escaper := strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ")
+	writeRow := func(cells []string) error {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escaper.Replace(cell)
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		return err
+	}
+
+	if err := writeRow(t.header); err != nil {
+		return err
+	}
+	divider := make([]string, len(t.header))
+	for i := range divider {
+		divider[i] = "---"
+	}
+	if err := writeRow(divider); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if err := writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsTable has a row per tag with its count, as list prints
+func tagsTable(tags []TagInfo) table {
+	t := table{header: []string{"tag", "count", "pinned"}}
+	for _, tag := range tags {
+		t.rows = append(t.rows, []string{tag.Name, strconv.Itoa(tag.Count), strconv.FormatBool(tag.Pinned)})
+	}
+	return t
+}
+
+// tagTreeTable has a row per node of the tree, parents before their
+// children, as tree prints
+func tagTreeTable(nodes []TagNode) table {
+	t := table{header: []string{"tag", "count", "total"}}
+	var add func(nodes []TagNode)
+	add = func(nodes []TagNode) {
+		for _, node := range nodes {
+			t.rows = append(t.rows, []string{node.Tag, strconv.Itoa(node.Count), strconv.Itoa(node.Total)})
+			add(node.Children)
+		}
+	}
+	add(nodes)
+	return t
+}
+
+// graphEdgesTable has a row per edge of a graph, as graph links them
+func graphEdgesTable(g *TagGraph) table {
+	t := table{header: []string{"from", "to", "weight"}}
+	for _, edge := range g.Edges {
+		t.rows = append(t.rows, []string{edge.From, edge.To, strconv.Itoa(edge.Weight)})
+	}
+	return t
+}
+
+// cloudTable has a row per word of a cloud, as cloud prints
+func cloudTable(words []CloudWord) table {
+	t := table{header: []string{"tag", "count", "weight"}}
+	for _, word := range words {
+		t.rows = append(t.rows, []string{word.Tag, strconv.Itoa(word.Count), strconv.Itoa(word.Weight)})
+	}
+	return t
+}
+
+// exportTable has a row per tag of each file, as export dumps them
+func exportTable(export *TagExport) table {
+	t := table{header: []string{"path", "tag", "source", "occurrences", "lines"}}
+	for _, entry := range export.Entries {
+		lines := make([]string, len(entry.Lines))
+		for i, line := range entry.Lines {
+			lines[i] = strconv.Itoa(line)
+		}
+		t.rows = append(t.rows, []string{entry.Path, entry.Tag, entry.Source,
+			strconv.Itoa(entry.Occurrences), strings.Join(lines, listSeparator)})
+	}
+	return t
+}
+
+// tagInfoTable has a row per tag with its files and metadata, as info prints
+func tagInfoTable(infos []TagInfo) table {
+	t := table{header: []string{"tag", "count", "files", "metadata"}}
+	for _, info := range infos {
+		t.rows = append(t.rows, []string{info.Name, strconv.Itoa(info.Count),
+			strings.Join(info.Files, listSeparator), strings.Join(metadataPairs(info.Metadata), listSeparator)})
+	}
+	return t
+}
+
+// metadataPairs returns tag metadata as key=value pairs, sorted by key
+func metadataPairs(metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + metadata[key]
+	}
+	return pairs
+}
+
+// tagFilesTable has a row per file of each tag, as find prints
+func tagFilesTable(results map[string][]string) table {
+	t := table{header: []string{"tag", "file"}}
+	tags := make([]string, 0, len(results))
+	for tag := range results {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		for _, file := range results[tag] {
+			t.rows = append(t.rows, []string{tag, file})
+		}
+	}
+	return t
+}
+
+// fileTagsTable has a row per file with its tags, sorted, as file-tags prints
+func fileTagsTable(files []FileTagInfo) table {
+	t := table{header: []string{"path", "tags"}}
+	for _, file := range files {
+		tags := append([]string(nil), file.Tags...)
+		sort.Strings(tags)
+		t.rows = append(t.rows, []string{file.Path, strings.Join(tags, listSeparator)})
+	}
+	return t
+}
+
+// tagProfileTable has a row per tag profile, with tagInfoTable's columns so
+// info's table output is unchanged by profiling
+func tagProfileTable(profiles []TagProfile) table {
+	infos := make([]TagInfo, len(profiles))
+	for i, profile := range profiles {
+		infos[i] = profile.TagInfo
+	}
+	return tagInfoTable(infos)
+}
+
+// fileListTable has a row per file, as find --match=all prints
+func fileListTable(files []string) table {
+	t := table{header: []string{"path"}}
+	for _, file := range files {
+		t.rows = append(t.rows, []string{file})
+	}
+	return t
+}
+
+// pathsTable has a row per file, as untagged prints
+func pathsTable(files []FileTagInfo) table {
+	t := table{header: []string{"path"}}
+	for _, file := range files {
+		t.rows = append(t.rows, []string{file.Path})
+	}
+	return t
+}
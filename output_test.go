@@ -0,0 +1,269 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCSVOutput(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":     "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md":     "Notes on #golang, with a comma",
+		"plain.md": "No tags here",
+	})
+
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "List",
+			args:     []string{"list", "--root", root, "--format", "csv"},
+			expected: "tag,count,pinned\ngolang,2,false\npython,1,false\n",
+		},
+		{
+			name:     "Find",
+			args:     []string{"find", "--tags", "golang", "--root", root, "--format", "csv"},
+			expected: "tag,file\ngolang," + filepath.Join(root, "a.md") + "\ngolang," + filepath.Join(root, "b.md") + "\n",
+		},
+		{
+			name:     "Info",
+			args:     []string{"info", "--tags", "python", "--root", root, "--format", "csv"},
+			expected: "tag,count,files,metadata\npython,1," + filepath.Join(root, "a.md") + ",\n",
+		},
+		{
+			name:     "Untagged",
+			args:     []string{"untagged", "--root", root, "--format", "csv"},
+			expected: "path\n" + filepath.Join(root, "plain.md") + "\n",
+		},
+		{
+			name:     "FileTags",
+			args:     []string{"file-tags", "--files", filepath.Join(root, "a.md"), "--format", "csv"},
+			expected: "path,tags\n" + filepath.Join(root, "a.md") + ",golang;python\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, run(t, test.args...))
+		})
+	}
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		err := tagmanager.RunCmd([]string{"tag-manager", "list", "--root", root, "--format", "xml"},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.ErrorContains(t, err, "invalid format")
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "list", "--root", root, "--json", "--format", "csv"},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.ErrorContains(t, err, "--json cannot be combined")
+	})
+}
+
+func TestYAMLOutput(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md": "Notes on #golang",
+	})
+
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	t.Run("List", func(t *testing.T) {
+		output := run(t, "list", "--root", root, "--format", "yaml")
+		var tags []map[string]any
+		require.NoError(t, yaml.Unmarshal([]byte(output), &tags))
+		require.Len(t, tags, 2)
+		assert.Equal(t, "golang", tags[0]["name"])
+		assert.Equal(t, 2, tags[0]["count"])
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		output := run(t, "validate", "--tags", "golang,123", "--format", "yaml")
+		var results map[string]map[string]any
+		require.NoError(t, yaml.Unmarshal([]byte(output), &results))
+		assert.Equal(t, true, results["golang"]["is_valid"])
+		assert.Equal(t, false, results["123"]["is_valid"])
+	})
+
+	t.Run("UpdateUsesJSONFieldNames", func(t *testing.T) {
+		output := run(t, "update", "--add", "reviewed", "--files", "b.md", "--root", root, "--dry-run", "--format", "yaml")
+		_, output, _ = strings.Cut(output, "DRY RUN MODE - No files will be modified\n")
+		var result map[string]any
+		require.NoError(t, yaml.Unmarshal([]byte(output), &result))
+		assert.Equal(t, []any{"b.md"}, result["modified_files"])
+		assert.Equal(t, map[string]any{"reviewed": 1}, result["tags_added"])
+	})
+
+	t.Run("CSVOnlyForTables", func(t *testing.T) {
+		err := tagmanager.RunCmd([]string{"tag-manager", "validate", "--tags", "golang", "--format", "csv"},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.ErrorContains(t, err, `invalid format "csv": must be text, json, yaml or template`)
+	})
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":     "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md":     "Notes on #golang",
+		"x|y.md":   "No tags here",
+		"plain.md": "No tags here",
+	})
+
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	t.Run("List", func(t *testing.T) {
+		assert.Equal(t, "| tag | count | pinned |\n| --- | --- | --- |\n| golang | 2 | false |\n| python | 1 | false |\n",
+			run(t, "list", "--root", root, "--format", "markdown"))
+	})
+
+	t.Run("EscapesPipes", func(t *testing.T) {
+		output := run(t, "untagged", "--root", root, "--format", "markdown")
+		assert.Contains(t, output, "| "+filepath.Join(root, `x\|y.md`)+" |\n")
+	})
+
+	t.Run("OnlyForTables", func(t *testing.T) {
+		err := tagmanager.RunCmd([]string{"tag-manager", "validate", "--tags", "golang", "--format", "markdown"},
+			&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		assert.ErrorContains(t, err, "invalid format")
+	})
+}
+
+func TestJSONIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "CompactWhenPiped",
+			args:     []string{"tag-manager", "validate", "--tags", "golang", "--json"},
+			expected: "{\"golang\":{\"is_valid\":true}}\n",
+		},
+		{
+			name:     "Indented",
+			args:     []string{"tag-manager", "--json-indent", "validate", "--tags", "golang", "--json"},
+			expected: "{\n  \"golang\": {\n    \"is_valid\": true\n  }\n}\n",
+		},
+		{
+			name:     "IndentedFormat",
+			args:     []string{"tag-manager", "--json-indent", "validate", "--tags", "golang", "--format", "json"},
+			expected: "{\n  \"golang\": {\n    \"is_valid\": true\n  }\n}\n",
+		},
+		{
+			name:     "TurnedOff",
+			args:     []string{"tag-manager", "--json-indent=false", "validate", "--tags", "golang", "--json"},
+			expected: "{\"golang\":{\"is_valid\":true}}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(test.args, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, stdout.String())
+		})
+	}
+}
+
+func TestTemplateOutput(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md": "Notes on #golang",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Template",
+			args:     []string{"list", "--root", root, "--template", `{{range .}}{{.name}}={{.count}} {{end}}`},
+			expected: "golang=2 python=1 ",
+		},
+		{
+			name:     "Join",
+			args:     []string{"file-tags", "--files", filepath.Join(root, "b.md"), "--format", "template", "--template", `{{range .}}{{.path}}: {{join .tags ","}}{{end}}`},
+			expected: filepath.Join(root, "b.md") + ": golang",
+		},
+		{
+			name:     "OutputAlias",
+			args:     []string{"validate", "--tags", "golang", "--output", "yaml"},
+			expected: "golang:\n  is_valid: true\n",
+		},
+		{
+			name:     "StreamPerFile",
+			args:     []string{"update", "--add", "reviewed", "--files", "b.md", "--root", root, "--stream", "--template", "{{.path}};"},
+			expected: "b.md;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager"}, test.args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(stdout.String(), test.expected), stdout.String())
+		})
+	}
+
+	errorTests := []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{
+			name:  "NoTemplate",
+			args:  []string{"list", "--root", root, "--format", "template"},
+			error: "--format=template needs --template",
+		},
+		{
+			name:  "TemplateWithOtherFormat",
+			args:  []string{"list", "--root", root, "--format", "csv", "--template", "{{.}}"},
+			error: "--template cannot be combined with --format=csv",
+		},
+		{
+			name:  "BadTemplate",
+			args:  []string{"list", "--root", root, "--template", "{{.name"},
+			error: "invalid template",
+		},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := tagmanager.RunCmd(append([]string{"tag-manager"}, test.args...),
+				&tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+			assert.ErrorContains(t, err, test.error)
+		})
+	}
+}
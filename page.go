@@ -0,0 +1,48 @@
+package tagmanager
+
+import (
+	"flag"
+	"fmt"
+)
+
+// pageFlags are the --limit and --offset flags of commands whose results can
+// be paged through
+type pageFlags struct {
+	limit  int
+	offset int
+}
+
+// addPageFlags adds --limit and --offset to fs
+func addPageFlags(fs *flag.FlagSet, noun string) *pageFlags {
+	flags := &pageFlags{}
+	fs.IntVar(&flags.limit, "limit", 0, "Show at most this many "+noun+", 0 for no limit")
+	fs.IntVar(&flags.offset, "offset", 0, "Skip this many "+noun+" before the first shown")
+	return flags
+}
+
+func (f *pageFlags) validate() error {
+	if f.limit < 0 {
+		return fmt.Errorf("invalid limit %d: must be 0 or more", f.limit)
+	}
+	if f.offset < 0 {
+		return fmt.Errorf("invalid offset %d: must be 0 or more", f.offset)
+	}
+	return nil
+}
+
+// paged reports whether the flags select only some of the results
+func (f *pageFlags) paged() bool {
+	return f.limit > 0 || f.offset > 0
+}
+
+// page returns the items on the page the flags select
+func page[T any](f *pageFlags, items []T) []T {
+	if f.offset >= len(items) {
+		return items[:0]
+	}
+	items = items[f.offset:]
+	if f.limit > 0 && len(items) > f.limit {
+		items = items[:f.limit]
+	}
+	return items
+}
@@ -0,0 +1,77 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestPagination(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "#alpha #beta",
+		"b.md": "#alpha #gamma",
+		"c.md": "#alpha #delta",
+		"d.md": "no tags",
+		"e.md": "none here",
+		"f.md": "nor here",
+	})
+
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+
+	t.Run("List", func(t *testing.T) {
+		output, err := run(t, "list", "--root", root, "--sort=name", "--limit=2", "--offset=1", "--json")
+		require.NoError(t, err)
+		var tags []tagmanager.TagInfo
+		require.NoError(t, json.Unmarshal([]byte(output), &tags))
+		require.Len(t, tags, 2)
+		assert.Equal(t, "beta", tags[0].Name)
+		assert.Equal(t, "delta", tags[1].Name)
+	})
+
+	t.Run("ListText", func(t *testing.T) {
+		output, err := run(t, "list", "--root", root, "--sort=name", "--limit=1")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Found 4 tags, showing 1 from offset 0:", "#alpha"})
+		assert.NotContains(t, output, "#beta")
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		output, err := run(t, "find", "--root", root, "--tags=alpha", "--offset=1", "--limit=1", "--json")
+		require.NoError(t, err)
+		var results map[string][]string
+		require.NoError(t, json.Unmarshal([]byte(output), &results))
+		require.Len(t, results["alpha"], 1)
+		assert.Contains(t, results["alpha"][0], "b.md")
+	})
+
+	t.Run("Untagged", func(t *testing.T) {
+		output, err := run(t, "untagged", "--root", root, "--offset=2", "--json")
+		require.NoError(t, err)
+		var files []tagmanager.FileTagInfo
+		require.NoError(t, json.Unmarshal([]byte(output), &files))
+		require.Len(t, files, 1)
+		assert.Contains(t, files[0].Path, "f.md")
+	})
+
+	t.Run("PastTheEnd", func(t *testing.T) {
+		_, err := run(t, "--fail-if-empty", "untagged", "--root", root, "--offset=10")
+		require.Error(t, err)
+		assert.Equal(t, tagmanager.ExitEmpty, tagmanager.ExitCode(err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := run(t, "list", "--root", root, "--limit=-1")
+		assert.EqualError(t, err, "invalid limit -1: must be 0 or more")
+		_, err = run(t, "find", "--root", root, "--tags=alpha", "--offset=-2")
+		assert.EqualError(t, err, "invalid offset -2: must be 0 or more")
+	})
+}
@@ -0,0 +1,78 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestOutputPatch(t *testing.T) {
+	files := map[string]string{
+		"a.md":       "#golang\nBody\n",
+		"notes/b.md": "---\ntags: [golang]\n---\nBody\n",
+		"c.md":       "#python\n",
+	}
+
+	for _, test := range []struct {
+		name     string
+		args     []string
+		expected map[string]string
+	}{
+		{
+			name: "Replace",
+			args: []string{"replace", "--old", "golang", "--new", "programming"},
+			expected: map[string]string{
+				"a.md":       "#programming\nBody\n",
+				"notes/b.md": "---\ntags: [\"programming\"]\n---\nBody\n",
+			},
+		},
+		{
+			name: "Update",
+			args: []string{"update", "--add", "reviewed", "--files", "c.md"},
+			expected: map[string]string{
+				"c.md": "---\ntags:\n    - python\n    - reviewed\n---\n",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, files)
+			patch := filepath.Join(t.TempDir(), "changes.patch")
+
+			var stdout bytes.Buffer
+			args := append(append([]string{"tag-manager"}, test.args...), "--root", root, "--output-patch", patch)
+			err := tagmanager.RunCmd(args, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			require.NoError(t, err)
+			assert.Contains(t, stdout.String(), "the vault was not modified")
+
+			// The vault is untouched
+			for name, content := range files {
+				written, err := os.ReadFile(filepath.Join(root, name))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(written))
+			}
+
+			written, err := os.ReadFile(patch)
+			require.NoError(t, err)
+			for name := range test.expected {
+				assert.Contains(t, string(written), "--- a/"+name+"\n+++ b/"+name+"\n")
+			}
+
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git not installed")
+			}
+			out, err := exec.Command("git", "-C", root, "apply", patch).CombinedOutput()
+			require.NoError(t, err, string(out))
+			for name, content := range test.expected {
+				applied, err := os.ReadFile(filepath.Join(root, name))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(applied))
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package tagmanager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ObsidianDir is the directory holding an Obsidian vault's settings
+const ObsidianDir = ".obsidian"
+
+// Obsidian community plugins the scanner integrates with, by plugin id.
+// Kanban board settings need no plugin integration; they are always skipped.
+const (
+	PluginDataview  = "dataview"
+	PluginTemplater = "templater-obsidian"
+)
+
+// VaultPlugins is the set of plugin integrations enabled for a vault
+type VaultPlugins struct {
+	Dataview  bool
+	Templater bool
+	// TemplatesFolder is the Templater template folder relative to the vault
+	// root; templates are not notes, so their tags aren't scanned.
+	TemplatesFolder string
+}
+
+func (p *VaultPlugins) enable(id string) {
+	switch id {
+	case PluginDataview:
+		p.Dataview = true
+	case PluginTemplater:
+		p.Templater = true
+	}
+}
+
+func (p *VaultPlugins) any() bool {
+	return p.Dataview || p.Templater
+}
+
+// DetectPlugins reads the community plugins enabled in the vault at
+// vaultRoot from its .obsidian settings. The settings are only read, never
+// written. A vault without settings has no plugins enabled.
+func DetectPlugins(vaultRoot string) (VaultPlugins, error) {
+	var plugins VaultPlugins
+
+	data, err := os.ReadFile(filepath.Join(vaultRoot, ObsidianDir, "community-plugins.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plugins, nil
+		}
+		return plugins, err
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return plugins, err
+	}
+	for _, id := range ids {
+		plugins.enable(id)
+	}
+
+	if plugins.Templater {
+		var settings struct {
+			TemplatesFolder string `json:"templates_folder"`
+		}
+		data, err := os.ReadFile(filepath.Join(vaultRoot, ObsidianDir, "plugins", PluginTemplater, "data.json"))
+		if err == nil && json.Unmarshal(data, &settings) == nil {
+			plugins.TemplatesFolder = strings.Trim(settings.TemplatesFolder, "/")
+		}
+	}
+
+	return plugins, nil
+}
+
+// pluginDetector finds the vault a file belongs to and the plugins enabled
+// in it, caching both so each vault's settings are read once.
+type pluginDetector struct {
+	config *Config
+
+	mu sync.Mutex
+	// vaults maps a directory to the root of the vault containing it, or ""
+	vaults map[string]string
+	// plugins maps a vault root to its plugins
+	plugins map[string]*VaultPlugins
+}
+
+func newPluginDetector(config *Config) *pluginDetector {
+	return &pluginDetector{
+		config:  config,
+		vaults:  make(map[string]string),
+		plugins: make(map[string]*VaultPlugins),
+	}
+}
+
+// forPath returns the vault root containing path and the plugins enabled in
+// it, or nil plugins when no integration applies.
+func (d *pluginDetector) forPath(path string) (string, *VaultPlugins) {
+	if !d.config.DetectPlugins && len(d.config.Plugins) == 0 {
+		return "", nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	vaultRoot := ""
+	if d.config.DetectPlugins {
+		vaultRoot = d.vaultRoot(filepath.Dir(path))
+	}
+
+	if plugins, ok := d.plugins[vaultRoot]; ok {
+		return vaultRoot, plugins
+	}
+
+	var plugins VaultPlugins
+	if vaultRoot != "" {
+		// Unreadable settings leave only the configured plugins enabled
+		plugins, _ = DetectPlugins(vaultRoot)
+	}
+	for _, id := range d.config.Plugins {
+		plugins.enable(id)
+	}
+
+	var result *VaultPlugins
+	if plugins.any() {
+		result = &plugins
+	}
+	d.plugins[vaultRoot] = result
+	return vaultRoot, result
+}
+
+// vaultRoot returns the nearest ancestor of dir holding a .obsidian
+// directory, or "" when dir is not inside a vault. Callers hold d.mu.
+func (d *pluginDetector) vaultRoot(dir string) string {
+	if root, ok := d.vaults[dir]; ok {
+		return root
+	}
+
+	root := ""
+	if info, err := os.Stat(filepath.Join(dir, ObsidianDir)); err == nil && info.IsDir() {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = d.vaultRoot(parent)
+	}
+
+	d.vaults[dir] = root
+	return root
+}
+
+// isTemplate reports whether path is inside the vault's Templater folder
+func (p *VaultPlugins) isTemplate(vaultRoot, path string) bool {
+	if p == nil || p.TemplatesFolder == "" || vaultRoot == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Join(vaultRoot, p.TemplatesFolder), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+var (
+	// templaterCommand matches a Templater command such as <% tp.file.title %>
+	templaterCommand = regexp.MustCompile(`<%[\s\S]*?%>`)
+	// dataviewInlineQuery matches inline Dataview queries such as `= this.tags`
+	dataviewInlineQuery = regexp.MustCompile("`\\$?=[^`]*`")
+	// dataviewFence opens a Dataview query block
+	dataviewFence = regexp.MustCompile("^\\s*(```+|~~~+)\\s*dataview(js)?\\s*$")
+)
+
+// strip removes the content the enabled plugins own from a note body: Dataview
+// queries and Templater commands. Hashtags in these are queries or template
+// code, not tags of the note.
+func (p *VaultPlugins) strip(body string) string {
+	if p.Templater {
+		body = templaterCommand.ReplaceAllString(body, "")
+	}
+
+	lines := strings.Split(body, "\n")
+	kept := lines[:0]
+	fence := ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		case p.Dataview && dataviewFence.MatchString(line):
+			fence = dataviewFence.FindStringSubmatch(line)[1]
+			continue
+		}
+
+		if p.Dataview {
+			line = dataviewInlineQuery.ReplaceAllString(line, "")
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,93 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func writeVault(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), tagmanager.DefaultFilePermissions))
+	}
+	return root
+}
+
+func TestPluginIntegrations(t *testing.T) {
+	notes := map[string]string{
+		"Templates/daily.md": "#template-only\n<% tp.date.now() %>",
+		"board.md":           "---\nkanban-plugin: basic\n---\n## Todo\n- [ ] write #docs\n\n%% kanban:settings\n```\n{\"kanban-plugin\":\"basic\",\"tag-colors\":[{\"tagKey\":\"#board-setting\"}]}\n```\n%%\n",
+		"query.md":           "# Projects #projects\n\n```dataview\nLIST FROM #active-project\n```\nInline `= [[#inline-query]]` and `$= dv.pages('#js-query')` queries\n",
+		"meeting.md":         "#meeting <% tp.file.cursor() %> <%* tR += '#generated' %>",
+	}
+	withPlugins := func(files map[string]string) map[string]string {
+		vault := map[string]string{
+			".obsidian/community-plugins.json":               `["dataview", "templater-obsidian", "obsidian-kanban"]`,
+			".obsidian/plugins/templater-obsidian/data.json": `{"templates_folder": "Templates/"}`,
+		}
+		for name, content := range files {
+			vault[name] = content
+		}
+		return vault
+	}
+
+	ctx := context.Background()
+	listTags := func(t *testing.T, config *tagmanager.Config, root string) []string {
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		tags, err := manager.ListAllTags(ctx, root, 1)
+		require.NoError(t, err)
+
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		return names
+	}
+
+	t.Run("Detected", func(t *testing.T) {
+		root := writeVault(t, withPlugins(notes))
+		assert.ElementsMatch(t, []string{"docs", "projects", "meeting"}, listTags(t, tagmanager.DefaultConfig(), root))
+	})
+
+	t.Run("DetectedFromSubdirectory", func(t *testing.T) {
+		root := writeVault(t, withPlugins(notes))
+		assert.ElementsMatch(t, []string{"template-only"}, listTags(t, tagmanager.DefaultConfig(), filepath.Join(root, "Templates")))
+	})
+
+	t.Run("DetectionDisabled", func(t *testing.T) {
+		root := writeVault(t, withPlugins(notes))
+		config := tagmanager.DefaultConfig()
+		config.DetectPlugins = false
+		// Dataview queries are code, which is never scanned for tags
+		assert.ElementsMatch(t, []string{"template-only", "docs", "projects", "meeting", "generated"}, listTags(t, config, root))
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		root := writeVault(t, notes)
+		config := tagmanager.DefaultConfig()
+		config.Plugins = []string{tagmanager.PluginDataview}
+		assert.ElementsMatch(t, []string{"template-only", "docs", "projects", "meeting", "generated"}, listTags(t, config, root))
+	})
+
+	t.Run("DetectPlugins", func(t *testing.T) {
+		root := writeVault(t, withPlugins(nil))
+		plugins, err := tagmanager.DetectPlugins(root)
+		require.NoError(t, err)
+		assert.Equal(t, tagmanager.VaultPlugins{Dataview: true, Templater: true, TemplatesFolder: "Templates"}, plugins)
+
+		plugins, err = tagmanager.DetectPlugins(t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, tagmanager.VaultPlugins{}, plugins)
+	})
+}
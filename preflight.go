@@ -0,0 +1,109 @@
+package tagmanager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Preflight modes for Config.Preflight, which check every file an operation
+// targets can be modified before any is
+const (
+	// PreflightAbort fails the operation when any file can't be modified
+	PreflightAbort = "abort"
+	// PreflightSkip modifies the files which can be and reports the others
+	PreflightSkip = "skip"
+)
+
+// Kinds of PreflightProblem
+const (
+	PreflightMissing    = "missing"
+	PreflightPermission = "permission"
+	PreflightLocked     = "locked"
+)
+
+// PreflightProblem is a file an operation targets which can't be modified
+type PreflightProblem struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+	Error   string `json:"error"`
+}
+
+// PreflightError is returned when PreflightAbort finds files which can't be
+// modified, before any file is
+type PreflightError struct {
+	Problems []PreflightProblem
+}
+
+func (e *PreflightError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "preflight found %d files which can't be modified, so none were:", len(e.Problems))
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s: %s (%s)", problem.Path, problem.Problem, problem.Error)
+	}
+	return b.String()
+}
+
+func validatePreflightMode(mode string) error {
+	switch mode {
+	case "", PreflightAbort, PreflightSkip:
+		return nil
+	}
+	return fmt.Errorf("invalid preflight mode %q: must be %s or %s", mode, PreflightAbort, PreflightSkip)
+}
+
+// WithPreflight returns a manager which checks every file ReplaceTagsBatch or
+// UpdateTags targets can be modified before modifying any, handling those
+// which can't as mode says. An empty mode disables the check. A transactional
+// replace already modifies every file or none, so it isn't checked.
+func (m *DefaultTagManager) WithPreflight(mode string) TagManager {
+	checked := *m
+	checked.preflightMode = mode
+	return &checked
+}
+
+// preflight returns the paths which can be modified, and the problems with
+// the others. Relative paths are resolved against rootPath. With
+// PreflightAbort any problem is returned as a *PreflightError instead.
+func (m *DefaultTagManager) preflight(rootPath string, paths []string) ([]string, []PreflightProblem, error) {
+	if m.preflightMode == "" {
+		return paths, nil, nil
+	}
+
+	var valid []string
+	var problems []PreflightProblem
+	for _, path := range paths {
+		target := path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(rootPath, target)
+		}
+		if err := checkWritable(m.config, target); err != nil {
+			problems = append(problems, preflightProblem(path, err))
+			continue
+		}
+		valid = append(valid, path)
+	}
+
+	if len(problems) > 0 && m.preflightMode == PreflightAbort {
+		return nil, nil, &PreflightError{Problems: problems}
+	}
+	return valid, problems, nil
+}
+
+// preflightProblem classifies the error checkWritable returned for a file
+func preflightProblem(path string, err error) PreflightProblem {
+	problem := PreflightProblem{Path: path, Error: err.Error()}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		problem.Problem = PreflightMissing
+	// EPERM rather than EACCES means the file is immutable or append-only
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EBUSY), errors.Is(err, syscall.ETXTBSY):
+		problem.Problem = PreflightLocked
+	default:
+		problem.Problem = PreflightPermission
+	}
+	return problem
+}
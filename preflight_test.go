@@ -0,0 +1,87 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+	note := "---\ntags: [golang]\n---\nBody\n"
+
+	t.Run("AbortLeavesEveryFile", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note, "b.md": note})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.WithPreflight(tagmanager.PreflightAbort).UpdateTags(ctx, []string{"reviewed"}, nil, root,
+			[]string{"a.md", "missing.md", "b.md", "gone.md"}, false)
+		var preflightErr *tagmanager.PreflightError
+		require.ErrorAs(t, err, &preflightErr)
+		require.Len(t, preflightErr.Problems, 2)
+		assert.Equal(t, "missing.md", preflightErr.Problems[0].Path)
+		assert.Equal(t, tagmanager.PreflightMissing, preflightErr.Problems[0].Problem)
+		assert.Equal(t, "gone.md", preflightErr.Problems[1].Path)
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, note, string(content))
+	})
+
+	t.Run("SkipModifiesTheRest", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		result, err := manager.WithPreflight(tagmanager.PreflightSkip).UpdateTags(ctx, []string{"reviewed"}, nil, root,
+			[]string{"a.md", "missing.md"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.md"}, result.ModifiedFiles)
+		require.Len(t, result.Preflight, 1)
+		assert.Equal(t, "missing.md", result.Preflight[0].Path)
+		assert.Len(t, result.Errors, 1)
+	})
+
+	t.Run("ReadOnlyFile", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write read-only files")
+		}
+		root := writeVault(t, map[string]string{"a.md": "#golang", "b.md": "#golang"})
+		require.NoError(t, os.Chmod(filepath.Join(root, "b.md"), 0444))
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = manager.WithPreflight(tagmanager.PreflightAbort).ReplaceTagsBatch(ctx,
+			[]tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		var preflightErr *tagmanager.PreflightError
+		require.ErrorAs(t, err, &preflightErr)
+		require.Len(t, preflightErr.Problems, 1)
+		assert.Equal(t, tagmanager.PreflightPermission, preflightErr.Problems[0].Problem)
+
+		content, err := os.ReadFile(filepath.Join(root, "a.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "#golang", string(content))
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": note})
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "reviewed", "--files", "a.md,missing.md",
+			"--root", root, "--preflight", "skip"}, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.Error(t, err)
+		assertOutputContains(t, stdout.String(), []string{"Preflight skipped 1 files", "missing.md: missing", "Modified files: 1"})
+
+		err = tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "reviewed", "--files", "a.md",
+			"--root", root, "--preflight", "maybe"}, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid preflight mode")
+	})
+}
@@ -0,0 +1,171 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxCoTags is how many co-occurring tags a TagProfile lists
+const maxCoTags = 5
+
+// TagCount is a tag and the number of files it was counted in
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagProfile is what info shows to help decide whether to keep or merge a tag
+type TagProfile struct {
+	TagInfo
+	// CoTags are the tags most often found in the same files, most frequent first
+	CoTags []TagCount `json:"co_tags,omitempty"`
+	// FirstModified and LastModified are the oldest and newest modification
+	// times of the tag's files
+	FirstModified *time.Time `json:"first_modified,omitempty"`
+	LastModified  *time.Time `json:"last_modified,omitempty"`
+	// FrontmatterFiles and BodyFiles count the files with the tag in their
+	// frontmatter and as a hashtag in their body; a file can have both
+	FrontmatterFiles int `json:"frontmatter_files"`
+	BodyFiles        int `json:"body_files"`
+}
+
+// ProfileTags returns GetTagsInfo's results along with each tag's co-occurring
+// tags, the modification times of its files, and where in them it is written
+func (m *DefaultTagManager) ProfileTags(ctx context.Context, tags []string, rootPath string) ([]TagProfile, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	normalizedTags := m.normalizeTags(tags)
+	profiles := make(map[string]*TagProfile)
+	coCounts := make(map[string]map[string]int)
+	for _, tag := range normalizedTags {
+		profiles[tag] = &TagProfile{TagInfo: TagInfo{Name: tag, Files: []string{}, Metadata: m.tagMetadata(tag)}}
+		coCounts[tag] = make(map[string]int)
+	}
+
+	for fileInfo, err := range m.indexedScanner(rootPath).ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fileTags := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			fileTags[m.normalizeTag(tag)] = true
+		}
+		if m.excludedByNotTags(fileTags) {
+			continue
+		}
+
+		var matched []*TagProfile
+		for tag, profile := range profiles {
+			if fileTags[tag] {
+				matched = append(matched, profile)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		inFrontmatter, inBody := m.tagPlacement(ctx, fileInfo.Path)
+		var modTime time.Time
+		if stat, err := os.Stat(fileInfo.Path); err == nil {
+			modTime = stat.ModTime()
+		}
+
+		for _, profile := range matched {
+			profile.Files = append(profile.Files, fileInfo.Path)
+			for tag := range fileTags {
+				if tag != profile.Name {
+					coCounts[profile.Name][tag]++
+				}
+			}
+			if inFrontmatter[profile.Name] {
+				profile.FrontmatterFiles++
+			}
+			// A tag found in neither, such as one in malformed frontmatter
+			// the scanner read as hashtags, is counted as written in the body
+			if inBody[profile.Name] || !inFrontmatter[profile.Name] {
+				profile.BodyFiles++
+			}
+			if !modTime.IsZero() {
+				if profile.FirstModified == nil || modTime.Before(*profile.FirstModified) {
+					first := modTime
+					profile.FirstModified = &first
+				}
+				if profile.LastModified == nil || modTime.After(*profile.LastModified) {
+					last := modTime
+					profile.LastModified = &last
+				}
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := make([]TagProfile, 0, len(profiles))
+	for tag, profile := range profiles {
+		profile.Count = len(profile.Files)
+		profile.CoTags = topTagCounts(coCounts[tag], maxCoTags)
+		result = append(result, *profile)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// tagPlacement returns the normalized tags in a note's frontmatter and those
+// written as hashtags in its body
+func (m *DefaultTagManager) tagPlacement(ctx context.Context, path string) (frontmatter, body map[string]bool) {
+	frontmatter = make(map[string]bool)
+	body = make(map[string]bool)
+
+	content, err := readNote(ctx, m.config, path)
+	if err != nil {
+		return frontmatter, body
+	}
+	text, _ := normalizeText(string(content))
+
+	bodyText := text
+	if data, rest, err := m.parseFrontmatter(text); err == nil {
+		bodyText = rest
+		for _, tag := range frontmatterTags(data) {
+			frontmatter[m.normalizeTag(tag)] = true
+		}
+	}
+	for _, tag := range m.scanner.ExtractTags(bodyText) {
+		body[m.normalizeTag(tag)] = true
+	}
+	return frontmatter, body
+}
+
+// topTagCounts returns up to limit of counts, largest first, ties by tag
+func topTagCounts(counts map[string]int, limit int) []TagCount {
+	result := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
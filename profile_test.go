@@ -0,0 +1,79 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestProfileTags(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "---\ntags: [golang, backend]\n---\nNotes on #tutorial",
+		"b.md": "---\ntags: [golang]\n---\nMore #golang and #backend",
+		"c.md": "#golang #tutorial",
+		"d.md": "#python",
+	})
+	old := time.Date(2023, 4, 2, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2025, 1, 18, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "a.md"), old, old))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "c.md"), recent, recent))
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	profiles, err := manager.ProfileTags(context.Background(), []string{"golang", "missing"}, root)
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+
+	golang := profiles[0]
+	assert.Equal(t, "golang", golang.Name)
+	assert.Equal(t, 3, golang.Count)
+	assert.Equal(t, 2, golang.FrontmatterFiles)
+	assert.Equal(t, 2, golang.BodyFiles)
+	assert.Equal(t, []tagmanager.TagCount{{Tag: "backend", Count: 2}, {Tag: "tutorial", Count: 2}}, golang.CoTags)
+	require.NotNil(t, golang.FirstModified)
+	assert.True(t, golang.FirstModified.Equal(old))
+	assert.True(t, golang.LastModified.After(recent) || golang.LastModified.Equal(recent))
+
+	missing := profiles[1]
+	assert.Equal(t, "missing", missing.Name)
+	assert.Equal(t, 0, missing.Count)
+	assert.Empty(t, missing.CoTags)
+	assert.Nil(t, missing.FirstModified)
+
+	t.Run("Command", func(t *testing.T) {
+		run := func(t *testing.T, args ...string) (string, error) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager", "info", "--root", root}, args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			return stdout.String(), err
+		}
+
+		output, err := run(t, "--tags=tutorial")
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{
+			"Count: 2",
+			"Written in: frontmatter of 0 files, body of 2 files",
+			"Often with: #golang (2), #backend (1)",
+			"Modified: 2023-04-02 to ",
+		})
+
+		output, err = run(t, "--tags=backend", "--json")
+		require.NoError(t, err)
+		var decoded []tagmanager.TagProfile
+		require.NoError(t, json.Unmarshal([]byte(output), &decoded))
+		require.Len(t, decoded, 1)
+		assert.Equal(t, 2, decoded[0].Count)
+		assert.Equal(t, 1, decoded[0].FrontmatterFiles)
+		assert.Equal(t, 1, decoded[0].BodyFiles)
+		assert.Equal(t, []tagmanager.TagCount{{Tag: "golang", Count: 2}, {Tag: "tutorial", Count: 1}}, decoded[0].CoTags)
+	})
+}
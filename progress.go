@@ -0,0 +1,83 @@
+package tagmanager
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+)
+
+// FileResult is the outcome for one file of ReplaceTagsBatch or UpdateTags
+type FileResult struct {
+	Path     string `json:"path"`
+	Modified bool   `json:"modified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ProgressFunc is called with each file's result as soon as the file is done,
+// before the operation returns its full result
+type ProgressFunc func(FileResult)
+
+// WithProgress returns a manager which reports each file ReplaceTagsBatch and
+// UpdateTags process to progress. A transactional replace writes every file
+// at once, so it reports them all after the last one is written.
+func (m *DefaultTagManager) WithProgress(progress ProgressFunc) TagManager {
+	reporting := *m
+	reporting.progress = progress
+	return &reporting
+}
+
+// fileDone reports a processed file to the manager's ProgressFunc, if any
+func (m *DefaultTagManager) fileDone(path string, modified bool, err error) {
+	if m.progress == nil {
+		return
+	}
+	result := FileResult{Path: path, Modified: modified}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	m.progress(result)
+}
+
+// Progress is how far a scan or batch has got. Total is zero while scanning,
+// when the number of files isn't known yet; a batch then counts Done up to
+// its Total.
+type Progress struct {
+	Done  int
+	Total int
+}
+
+// MeterFunc is told the progress of long operations, for progress bars
+type MeterFunc func(Progress)
+
+// WithMeter returns a manager which reports to meter each file its scans read
+// and each file ReplaceTagsBatch and UpdateTags reach.
+func (m *DefaultTagManager) WithMeter(meter MeterFunc) TagManager {
+	metered := *m
+	metered.meter = meter
+	metered.scanner = &meteredScanner{Scanner: m.scanner, meter: meter}
+	return &metered
+}
+
+// meteredScanner counts the files it scans for a MeterFunc
+type meteredScanner struct {
+	Scanner
+	meter   MeterFunc
+	scanned atomic.Int64
+}
+
+func (s *meteredScanner) ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error] {
+	return scanWalkedFiles(ctx, s, s.WalkFiles(ctx, rootPath, excludePaths))
+}
+
+func (s *meteredScanner) ScanFile(ctx context.Context, filePath string) (FileTagInfo, error) {
+	info, err := s.Scanner.ScanFile(ctx, filePath)
+	s.meter(Progress{Done: int(s.scanned.Add(1))})
+	return info, err
+}
+
+// metered reports a batch's progress to the manager's MeterFunc, if any
+func (m *DefaultTagManager) metered(done, total int) {
+	if m.meter != nil {
+		m.meter(Progress{Done: done, Total: total})
+	}
+}
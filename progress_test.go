@@ -0,0 +1,154 @@
+package tagmanager_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestProgress(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Replace", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md": "#golang",
+			"b.md": "#golang and more",
+			"c.md": "#python",
+		})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		var results []tagmanager.FileResult
+		result, err := manager.WithProgress(func(r tagmanager.FileResult) {
+			results = append(results, r)
+		}).ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, false)
+		require.NoError(t, err)
+
+		sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+		assert.Equal(t, []tagmanager.FileResult{
+			{Path: filepath.Join(root, "a.md"), Modified: true},
+			{Path: filepath.Join(root, "b.md"), Modified: true},
+		}, results)
+		assert.Len(t, result.ModifiedFiles, 2)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md": "---\ntags: [golang]\n---\nBody",
+			"b.md": "---\ntags: [docker]\n---\nBody",
+		})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		var results []tagmanager.FileResult
+		_, err = manager.WithProgress(func(r tagmanager.FileResult) {
+			results = append(results, r)
+		}).UpdateTags(ctx, []string{"docker"}, nil, root, []string{"a.md", "b.md", "missing.md"}, false)
+		require.NoError(t, err)
+
+		require.Len(t, results, 3)
+		assert.Equal(t, tagmanager.FileResult{Path: "a.md", Modified: true}, results[0])
+		assert.Equal(t, tagmanager.FileResult{Path: "b.md"}, results[1])
+		assert.Equal(t, "missing.md", results[2].Path)
+		assert.NotEmpty(t, results[2].Error)
+	})
+
+	t.Run("StreamJSON", func(t *testing.T) {
+		root := writeVault(t, map[string]string{
+			"a.md": "#golang",
+			"b.md": "#golang",
+		})
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "replace", "--old", "golang", "--new", "go",
+			"--root", root, "--stream", "--json"}, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+
+		var lines []string
+		scanner := bufio.NewScanner(&stdout)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		require.Len(t, lines, 3)
+		for _, line := range lines[:2] {
+			var result tagmanager.FileResult
+			require.NoError(t, json.Unmarshal([]byte(line), &result))
+			assert.True(t, result.Modified)
+		}
+
+		// The last line is the full result
+		var result tagmanager.TagReplaceResult
+		require.NoError(t, json.Unmarshal([]byte(lines[2]), &result))
+		assert.Len(t, result.ModifiedFiles, 2)
+	})
+
+	t.Run("StreamText", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "---\ntags: [golang]\n---\nBody"})
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "update", "--add", "docker", "--files", "a.md",
+			"--root", root, "--stream"}, &tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(stdout.String(), "  ✓ a.md\n"), stdout.String())
+	})
+}
+
+func TestMeter(t *testing.T) {
+	ctx := context.Background()
+	root := writeVault(t, map[string]string{
+		"a.md": "#golang",
+		"b.md": "#golang and more",
+		"c.md": "#python",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	t.Run("Scan", func(t *testing.T) {
+		var progress []tagmanager.Progress
+		_, err := manager.WithMeter(func(p tagmanager.Progress) {
+			progress = append(progress, p)
+		}).ListAllTags(ctx, root, 1)
+		require.NoError(t, err)
+
+		assert.Equal(t, []tagmanager.Progress{{Done: 1}, {Done: 2}, {Done: 3}}, progress)
+	})
+
+	t.Run("ScopedScan", func(t *testing.T) {
+		var progress []tagmanager.Progress
+		_, err := manager.WithMeter(func(p tagmanager.Progress) {
+			progress = append(progress, p)
+		}).WithFilter(func(relPath string) bool { return relPath != "c.md" }).ListAllTags(ctx, root, 1)
+		require.NoError(t, err)
+
+		assert.Equal(t, []tagmanager.Progress{{Done: 1}, {Done: 2}}, progress)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		var batch []tagmanager.Progress
+		_, err := manager.WithMeter(func(p tagmanager.Progress) {
+			if p.Total > 0 {
+				batch = append(batch, p)
+			}
+		}).ReplaceTagsBatch(ctx, []tagmanager.TagReplacement{{OldTag: "golang", NewTag: "go"}}, root, true)
+		require.NoError(t, err)
+
+		assert.Equal(t, []tagmanager.Progress{{Done: 0, Total: 2}, {Done: 1, Total: 2}}, batch)
+	})
+
+	t.Run("NoBarWhenNotATerminal", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "list", "--root", root},
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &stderr})
+		require.NoError(t, err)
+		assert.Empty(t, stderr.String())
+	})
+}
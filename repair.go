@@ -0,0 +1,231 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JournalFix is the journal kind of FixFrontmatter
+const JournalFix = "fix"
+
+// FrontmatterFix is a file whose frontmatter FixFrontmatter repaired
+type FrontmatterFix struct {
+	// Path is relative to the vault root
+	Path string `json:"path"`
+	// Fixes describes each repair made
+	Fixes []string `json:"fixes"`
+	// Diff is a unified diff of the repairs
+	Diff string `json:"diff"`
+}
+
+type FrontmatterFixResult struct {
+	Fixed []FrontmatterFix `json:"fixed"`
+	// Unfixed are the malformed files which couldn't be repaired safely
+	Unfixed []FrontmatterProblem `json:"unfixed"`
+	Errors  []string             `json:"errors,omitempty"`
+	// Backup is the backup tree holding the files as they were before
+	Backup string `json:"backup,omitempty"`
+	// Operation is the journal id of the changes, which undo takes
+	Operation string `json:"operation,omitempty"`
+}
+
+var (
+	// frontmatterPropertyLine matches a top-level `key:` line
+	frontmatterPropertyLine = regexp.MustCompile(`^[^\s#:-][^:]*:(\s|$)`)
+	// unclosedFlowList matches a `key: [items` line missing its closing bracket
+	unclosedFlowList = regexp.MustCompile(`^(\s*[^\s#:][^:]*:\s*)\[([^\[\]{}]*)$`)
+)
+
+// FixFrontmatter repairs the common frontmatter mistakes that have only one
+// possible fix: a missing closing `---`, indentation with tabs, and a [list]
+// of tags missing its closing bracket. A file is only changed when every
+// problem in it is repaired and its frontmatter then parses.
+func (m *DefaultTagManager) FixFrontmatter(ctx context.Context, rootPath string, dryRun bool) (*FrontmatterFixResult, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	result := &FrontmatterFixResult{
+		Fixed:   []FrontmatterFix{},
+		Unfixed: []FrontmatterProblem{},
+		Errors:  []string{},
+	}
+
+	var backup *backup
+	var journal *journal
+	if !dryRun {
+		backup = m.startBackup(rootPath)
+		journal = m.startJournal(rootPath, JournalFix)
+	}
+
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootPath, fileInfo.Path)
+		if err != nil {
+			relPath = fileInfo.Path
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		stored, content, err := readStoredNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", relPath, err))
+			continue
+		}
+
+		text, format := normalizeText(string(content))
+		if hasIgnoreFileDirective(text) || !strings.HasPrefix(text, "---\n") {
+			continue
+		}
+
+		fixed, fixes := repairFrontmatter(text, m.rules)
+		if _, _, err := m.parseFrontmatter(fixed); err != nil {
+			frontmatter, _, _ := splitFrontmatter(fixed)
+			result.Unfixed = append(result.Unfixed, diagnoseFrontmatter(relPath, frontmatter))
+			continue
+		}
+		if len(fixes) == 0 {
+			continue
+		}
+
+		written := []byte(format.restore(fixed))
+		if !dryRun {
+			if err := backup.save(fileInfo.Path); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", relPath, err))
+				continue
+			}
+			if err := writeNote(ctx, m.config, fileInfo.Path, stored, written); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", relPath, err))
+				continue
+			}
+			journal.record(fileInfo.Path, content, written)
+		}
+
+		result.Fixed = append(result.Fixed, FrontmatterFix{
+			Path:  relPath,
+			Fixes: fixes,
+			Diff:  unifiedDiff("a/"+relPath, "b/"+relPath, string(content), string(written)),
+		})
+	}
+
+	sort.Slice(result.Fixed, func(i, j int) bool {
+		return result.Fixed[i].Path < result.Fixed[j].Path
+	})
+	sort.Slice(result.Unfixed, func(i, j int) bool {
+		return result.Unfixed[i].Path < result.Unfixed[j].Path
+	})
+	result.Backup = backup.location()
+
+	id, err := journal.commit()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Operation = id
+
+	return result, nil
+}
+
+// repairFrontmatter applies the safe frontmatter repairs to normalized text
+// beginning with `---`, returning the result and a description of each
+// repair. The caller checks the result parses.
+func repairFrontmatter(text string, rules *RuleSet) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var fixes []string
+
+	end := frontmatterEnd(lines)
+	if end < 0 {
+		// The block is closed after the properties directly below the
+		// opening ---, if they are followed by a blank line or nothing
+		end = 1
+		for end < len(lines) && lines[end] != "" && isFrontmatterLine(lines[end]) {
+			end++
+		}
+		if end == 1 || (end < len(lines) && lines[end] != "") {
+			return text, nil
+		}
+		lines = append(lines[:end], append([]string{"---"}, lines[end:]...)...)
+		fixes = append(fixes, fmt.Sprintf("added the missing closing --- on line %d", end+1))
+	}
+
+	// Tabs are only replaced when they make up the whole indentation, so the
+	// nesting they express is unambiguous
+	var tabbed []int
+	for i := 1; i < end; i++ {
+		indent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+		if strings.Contains(indent, "\t") {
+			if strings.Trim(indent, "\t") != "" {
+				tabbed = nil
+				break
+			}
+			tabbed = append(tabbed, i)
+		}
+	}
+	for _, i := range tabbed {
+		trimmed := strings.TrimLeft(lines[i], "\t")
+		lines[i] = strings.Repeat("  ", len(lines[i])-len(trimmed)) + trimmed
+	}
+	if len(tabbed) > 0 {
+		fixes = append(fixes, fmt.Sprintf("replaced tab indentation with spaces on %d lines", len(tabbed)))
+	}
+
+	for i := 1; i < end; i++ {
+		match := unclosedFlowList.FindStringSubmatch(lines[i])
+		if match == nil || !isTagList(match[2], rules) {
+			continue
+		}
+		lines[i] = strings.TrimRight(lines[i], " ") + "]"
+		fixes = append(fixes, fmt.Sprintf("closed the [list] on line %d", i+1))
+	}
+
+	if len(fixes) == 0 {
+		return text, nil
+	}
+	return strings.Join(lines, "\n"), fixes
+}
+
+// frontmatterEnd returns the index of the closing --- line, or -1
+func frontmatterEnd(lines []string) int {
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "---" {
+			return i
+		}
+	}
+	return -1
+}
+
+// isFrontmatterLine reports whether line looks like part of a YAML property
+// list: a `key:` line, an indented line, or a list item
+func isFrontmatterLine(line string) bool {
+	return frontmatterPropertyLine.MatchString(line) ||
+		strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") ||
+		strings.HasPrefix(line, "- ")
+}
+
+// isTagList reports whether the comma separated items are all tags, possibly
+// quoted or prefixed with '#'
+func isTagList(items string, rules *RuleSet) bool {
+	found := false
+	for _, item := range strings.Split(items, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if len(item) >= 2 && (item[0] == '"' || item[0] == '\'') && item[len(item)-1] == item[0] {
+			item = item[1 : len(item)-1]
+		}
+		if !rules.isValidTag(strings.TrimPrefix(item, "#")) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
@@ -0,0 +1,147 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestFixFrontmatter(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		fixes    []string
+		unfixed  bool
+	}{
+		{
+			name:     "MissingClose",
+			content:  "---\ntitle: Note\ntags: [golang]\n\n# Heading\n",
+			expected: "---\ntitle: Note\ntags: [golang]\n---\n\n# Heading\n",
+			fixes:    []string{"added the missing closing --- on line 4"},
+		},
+		{
+			name:     "MissingCloseEndOfFile",
+			content:  "---\ntags:\n  - golang",
+			expected: "---\ntags:\n  - golang\n---",
+			fixes:    []string{"added the missing closing --- on line 4"},
+		},
+		{
+			name:     "Tabs",
+			content:  "---\ntags:\n\t- golang\n\t- python\n---\nbody\n",
+			expected: "---\ntags:\n  - golang\n  - python\n---\nbody\n",
+			fixes:    []string{"replaced tab indentation with spaces on 2 lines"},
+		},
+		{
+			// Closing the list still leaves #rust, which YAML reads as a comment
+			name:    "UnclosedListWithHashtag",
+			content: "---\ntitle: Note\ntags: [golang, #rust\n---\nbody\n",
+			unfixed: true,
+		},
+		{
+			name:     "UnclosedTagList",
+			content:  "---\ntitle: Note\ntags: [golang, \"python\", web-dev \n---\nbody\n",
+			expected: "---\ntitle: Note\ntags: [golang, \"python\", web-dev]\n---\nbody\n",
+			fixes:    []string{"closed the [list] on line 3"},
+		},
+		{
+			name:     "CombinedWithCRLF",
+			content:  "---\r\ntags: [golang\r\nnested:\r\n\tkey: value\r\n\r\nbody\r\n",
+			expected: "---\r\ntags: [golang]\r\nnested:\r\n  key: value\r\n---\r\n\r\nbody\r\n",
+			fixes: []string{
+				"added the missing closing --- on line 5",
+				"replaced tab indentation with spaces on 1 lines",
+				"closed the [list] on line 2",
+			},
+		},
+		{
+			name:    "UnclosedListOfText",
+			content: "---\ntitle: [Draft: needs work\n---\n",
+			unfixed: true,
+		},
+		{
+			name:    "MixedIndentation",
+			content: "---\ntags:\n \t- golang\n---\n",
+			unfixed: true,
+		},
+		{
+			name:    "HorizontalRule",
+			content: "---\nSome text after a rule\n",
+		},
+		{
+			name:    "Valid",
+			content: "---\ntags: [golang]\n---\nbody\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, map[string]string{"note.md": test.content})
+			manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+			require.NoError(t, err)
+
+			preview, err := manager.FixFrontmatter(ctx, root, true)
+			require.NoError(t, err)
+			content, err := os.ReadFile(filepath.Join(root, "note.md"))
+			require.NoError(t, err)
+			assert.Equal(t, test.content, string(content))
+
+			result, err := manager.FixFrontmatter(ctx, root, false)
+			require.NoError(t, err)
+			assert.Equal(t, preview.Fixed, result.Fixed)
+			assert.Empty(t, result.Errors)
+
+			content, err = os.ReadFile(filepath.Join(root, "note.md"))
+			require.NoError(t, err)
+
+			switch {
+			case test.unfixed:
+				assert.Empty(t, result.Fixed)
+				require.Len(t, result.Unfixed, 1)
+				assert.Equal(t, "note.md", result.Unfixed[0].Path)
+				assert.Equal(t, test.content, string(content))
+			case test.fixes == nil:
+				assert.Empty(t, result.Fixed)
+				assert.Empty(t, result.Unfixed)
+				assert.Equal(t, test.content, string(content))
+			default:
+				require.Len(t, result.Fixed, 1)
+				assert.Equal(t, test.fixes, result.Fixed[0].Fixes)
+				assert.Contains(t, result.Fixed[0].Diff, "+++ b/note.md")
+				assert.Equal(t, test.expected, string(content))
+				require.NotEmpty(t, result.Operation)
+
+				_, err := manager.Undo(ctx, root, result.Operation, false)
+				require.NoError(t, err)
+				content, err = os.ReadFile(filepath.Join(root, "note.md"))
+				require.NoError(t, err)
+				assert.Equal(t, test.content, string(content))
+			}
+		})
+	}
+}
+
+func TestFixFrontmatterCommand(t *testing.T) {
+	root := writeVault(t, map[string]string{"a.md": "---\ntags: [golang\n---\n", "b.md": "---\ntitle: a: b\n---\n"})
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "fix", "frontmatter", "--dry-run", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{
+		"DRY RUN MODE",
+		"Repaired files: 1",
+		"a.md: closed the [list] on line 2",
+		"-tags: [golang",
+		"+tags: [golang]",
+		"Files to repair by hand: 1",
+		"b.md:2: mapping values are not allowed",
+	})
+}
@@ -0,0 +1,266 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DuplicateGroup is a set of tags which are likely spellings of one tag
+type DuplicateGroup struct {
+	// Tags are sorted by use, most first
+	Tags []TagCount `json:"tags"`
+	// Suggested is the spelling to merge the others into, the most used
+	Suggested string `json:"suggested"`
+}
+
+// VaultReport is an overview of the tags of a vault, for sharing with people
+// who don't run tag-manager themselves
+type VaultReport struct {
+	Root      string    `json:"root"`
+	Generated time.Time `json:"generated"`
+	Files     int       `json:"files"`
+	// TaggedFiles are the files with at least one tag, and Uses the number
+	// of tags of every file added up
+	TaggedFiles int `json:"tagged_files"`
+	Tags        int `json:"tags"`
+	Uses        int `json:"uses"`
+	// Top are the most used tags, most first
+	Top []TagCount `json:"top"`
+	// Untagged are the paths of the files without tags, relative to the root
+	Untagged   []string         `json:"untagged"`
+	Invalid    []TagValidation  `json:"invalid"`
+	Duplicates []DuplicateGroup `json:"duplicates"`
+}
+
+// Report returns an overview of the tags under rootPath with its top most
+// used tags, the untagged files, the tags failing validation, and the tags
+// which are likely duplicates of each other
+func (m *DefaultTagManager) Report(ctx context.Context, rootPath string, top int) (*VaultReport, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	report := &VaultReport{Root: rootPath, Generated: time.Now(), Untagged: []string{}}
+	counts := make(map[string]int)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			seen[m.normalizeTag(tag)] = true
+		}
+		for tag := range seen {
+			counts[tag]++
+		}
+		report.Uses += len(seen)
+		if len(seen) > 0 {
+			report.TaggedFiles++
+			continue
+		}
+		if relPath, err := filepath.Rel(rootPath, fileInfo.Path); err == nil {
+			report.Untagged = append(report.Untagged, filepath.ToSlash(relPath))
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	sort.Strings(report.Untagged)
+
+	validation, err := m.ValidateVault(ctx, rootPath)
+	if err != nil {
+		return nil, err
+	}
+	report.Files = validation.Files
+	report.Invalid = validation.Invalid
+	for i := range report.Invalid {
+		files := make([]string, len(report.Invalid[i].Files))
+		for j, path := range report.Invalid[i].Files {
+			if relPath, err := filepath.Rel(rootPath, path); err == nil {
+				path = filepath.ToSlash(relPath)
+			}
+			files[j] = path
+		}
+		report.Invalid[i].Files = files
+	}
+
+	report.Tags = len(counts)
+	if top <= 0 {
+		top = len(counts)
+	}
+	report.Top = topTagCounts(counts, top)
+	report.Duplicates = duplicateTags(counts)
+	return report, nil
+}
+
+// duplicateTags groups the tags in counts which differ only in case, in
+// - and _ separators, or in a plural s on a segment, such as Project and
+// projects, or to-do and todo
+func duplicateTags(counts map[string]int) []DuplicateGroup {
+	spellings := make(map[string][]string)
+	for tag := range counts {
+		key := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(tag))
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			if len(segment) > 3 && strings.HasSuffix(segment, "s") && !strings.HasSuffix(segment, "ss") {
+				segments[i] = strings.TrimSuffix(segment, "s")
+			}
+		}
+		key = strings.Join(segments, "/")
+		spellings[key] = append(spellings[key], tag)
+	}
+
+	groups := []DuplicateGroup{}
+	for _, names := range spellings {
+		if len(names) < 2 {
+			continue
+		}
+		group := DuplicateGroup{Tags: make([]TagCount, len(names))}
+		for i, name := range names {
+			group.Tags[i] = TagCount{Tag: name, Count: counts[name]}
+		}
+		sort.Slice(group.Tags, func(i, j int) bool {
+			if group.Tags[i].Count != group.Tags[j].Count {
+				return group.Tags[i].Count > group.Tags[j].Count
+			}
+			return group.Tags[i].Tag < group.Tags[j].Tag
+		})
+		group.Suggested = group.Tags[0].Tag
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Suggested < groups[j].Suggested
+	})
+	return groups
+}
+
+// duplicateReplacements returns the replace --replacements value merging g
+// into its suggested spelling
+func duplicateReplacements(g DuplicateGroup) string {
+	var pairs []string
+	for _, tag := range g.Tags[1:] {
+		pairs = append(pairs, tag.Tag+":"+g.Suggested)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// writeReportHTML writes report as a single HTML page with its styles and
+// scripts inline, so it can be shared as one file
+func writeReportHTML(w io.Writer, report *VaultReport) error {
+	return reportTemplate.Execute(w, report)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(part, whole int) string {
+		if whole == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.0f%%", 100*float64(part)/float64(whole))
+	},
+	"average": func(uses, files int) string {
+		if files == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.1f", float64(uses)/float64(files))
+	},
+	"bar": func(count int, top []TagCount) string {
+		if len(top) == 0 || top[0].Count == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", 100*float64(count)/float64(top[0].Count))
+	},
+	"join":         strings.Join,
+	"replacements": duplicateReplacements,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Tag report: {{.Root}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0 auto; max-width: 960px; padding: 24px; color: #1f2328; }
+h1 { font-size: 1.6em; margin-bottom: 4px; }
+h2 { font-size: 1.2em; margin-top: 32px; border-bottom: 1px solid #d0d7de; padding-bottom: 4px; }
+.meta { color: #656d76; margin-top: 0; }
+.stats { display: flex; flex-wrap: wrap; gap: 12px; }
+.stat { border: 1px solid #d0d7de; border-radius: 6px; padding: 12px 16px; min-width: 120px; }
+.stat b { display: block; font-size: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+td.count { text-align: right; width: 4em; }
+.bar { background: #ddf4ff; height: 1em; border-radius: 2px; }
+.tag { color: #0969da; }
+.empty { color: #656d76; font-style: italic; }
+code { background: #f6f8fa; padding: 2px 4px; border-radius: 4px; font-size: 0.9em; }
+input[type=search] { padding: 4px 8px; margin-bottom: 8px; width: 50%; }
+</style>
+</head>
+<body>
+<h1>Tag report</h1>
+<p class="meta">{{.Root}} &middot; generated {{.Generated.Format "2006-01-02 15:04"}}</p>
+
+<div class="stats">
+<div class="stat"><b>{{.Files}}</b>files</div>
+<div class="stat"><b>{{.TaggedFiles}}</b>tagged ({{percent .TaggedFiles .Files}})</div>
+<div class="stat"><b>{{.Tags}}</b>tags</div>
+<div class="stat"><b>{{average .Uses .TaggedFiles}}</b>tags per tagged file</div>
+<div class="stat"><b>{{len .Invalid}}</b>invalid tags</div>
+<div class="stat"><b>{{len .Duplicates}}</b>duplicate candidates</div>
+</div>
+
+<h2>Top tags</h2>
+{{if .Top}}<input type="search" placeholder="Filter tags" data-filter="top">
+<table id="top">
+<tr><th>Tag</th><th>Files</th><th></th></tr>
+{{range .Top}}<tr><td class="tag">#{{.Tag}}</td><td class="count">{{.Count}}</td><td><div class="bar" style="width: {{bar .Count $.Top}}"></div></td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No tags found.</p>{{end}}
+
+<h2>Untagged files ({{len .Untagged}})</h2>
+{{if .Untagged}}<input type="search" placeholder="Filter files" data-filter="untagged">
+<table id="untagged">
+{{range .Untagged}}<tr><td>{{.}}</td></tr>
+{{end}}</table>
+{{else}}<p class="empty">Every file has tags.</p>{{end}}
+
+<h2>Invalid tags ({{len .Invalid}})</h2>
+{{if .Invalid}}<table>
+<tr><th>Tag</th><th>Issues</th><th>Suggestions</th><th>Files</th></tr>
+{{range .Invalid}}<tr><td class="tag">#{{.Tag}}</td><td>{{join .Issues "; "}}</td><td>{{join .Suggestions "; "}}</td><td>{{join .Files ", "}}</td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No invalid tags.</p>{{end}}
+
+<h2>Duplicate candidates ({{len .Duplicates}})</h2>
+{{if .Duplicates}}<p>Tags differing only in case, separators or a plural, with the command merging each group into its most used spelling.</p>
+<table>
+<tr><th>Tags</th><th>Merge with</th></tr>
+{{range .Duplicates}}<tr><td>{{range $i, $tag := .Tags}}{{if $i}}, {{end}}<span class="tag">#{{$tag.Tag}}</span> ({{$tag.Count}}){{end}}</td><td><code>tag-manager replace --replacements="{{replacements .}}" --root="{{$.Root}}" --dry-run</code></td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No duplicate candidates.</p>{{end}}
+
+<script>
+document.querySelectorAll("input[data-filter]").forEach(function (input) {
+  var rows = document.getElementById(input.dataset.filter).querySelectorAll("tr");
+  input.addEventListener("input", function () {
+    var query = input.value.toLowerCase();
+    rows.forEach(function (row) {
+      if (row.querySelector("td")) {
+        row.style.display = row.textContent.toLowerCase().indexOf(query) >= 0 ? "" : "none";
+      }
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
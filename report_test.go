@@ -0,0 +1,108 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestReport(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"alpha.md":        "---\ntags: [project, golang, ab]\n---\n# Alpha",
+		"beta.md":         "# Beta #Project #go-lang",
+		"gamma.md":        "#projects #golang",
+		"Inbox/idea.md":   "Nothing here",
+		"Inbox/README.md": "Nor here",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	report, err := manager.Report(context.Background(), root, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, report.Files)
+	assert.Equal(t, 3, report.TaggedFiles)
+	assert.Equal(t, 5, report.Tags)
+	assert.Equal(t, 6, report.Uses)
+	assert.Equal(t, []tagmanager.TagCount{{Tag: "golang", Count: 2}, {Tag: "Project", Count: 1}}, report.Top)
+	assert.Equal(t, []string{"Inbox/README.md", "Inbox/idea.md"}, report.Untagged)
+
+	require.Len(t, report.Invalid, 1)
+	assert.Equal(t, "ab", report.Invalid[0].Tag)
+	assert.Equal(t, []string{"alpha.md"}, report.Invalid[0].Files)
+
+	assert.Equal(t, []tagmanager.DuplicateGroup{
+		{Tags: []tagmanager.TagCount{{Tag: "Project", Count: 1}, {Tag: "project", Count: 1}, {Tag: "projects", Count: 1}}, Suggested: "Project"},
+		{Tags: []tagmanager.TagCount{{Tag: "golang", Count: 2}, {Tag: "go-lang", Count: 1}}, Suggested: "golang"},
+	}, report.Duplicates)
+}
+
+func TestReportCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"alpha.md":  "#golang #go-lang",
+		"<beta>.md": "# Beta",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name: "HTML",
+			expected: []string{"<!DOCTYPE html>", "<style>", "<script>", "Top tags", `<td class="tag">#golang</td>`,
+				"Untagged files (1)", "<td>&lt;beta&gt;.md</td>", "No invalid tags.",
+				`tag-manager replace --replacements="golang:go-lang"`},
+		},
+		{
+			name:     "JSON",
+			args:     []string{"--json"},
+			expected: []string{`"tagged_files":1`, `"untagged":["\u003cbeta\u003e.md"]`, `"suggested":"go-lang"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, append([]string{"report", "--root", root}, test.args...)...)
+			require.NoError(t, err)
+			assertOutputContains(t, output, test.expected)
+		})
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.html")
+		output, err := run(t, "report", "--root", root, "--html", path)
+		require.NoError(t, err)
+		assertOutputContains(t, output, []string{"Report written to " + path, "2 files, 2 tags, 1 untagged files"})
+
+		page, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(page), "<!DOCTYPE html>")
+	})
+
+	t.Run("FileAndJSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.html")
+		output, err := run(t, "report", "--root", root, "--html", path, "--json")
+		require.NoError(t, err)
+		var report tagmanager.VaultReport
+		require.NoError(t, json.Unmarshal([]byte(output), &report))
+		assert.Equal(t, 2, report.Files)
+		assert.FileExists(t, path)
+	})
+
+	_, err := run(t, "report", "--root", root, "--top", "-1")
+	assert.EqualError(t, err, "invalid top -1: must be 0 or more")
+}
@@ -0,0 +1,103 @@
+package tagmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RootInfo is a vault named in the config's roots
+type RootInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// resolveRoot returns the path of the root named rootName, or root when no
+// name is given
+func resolveRoot(config *Config, root, rootName string) (string, error) {
+	if rootName == "" {
+		return root, nil
+	}
+	if root != "" {
+		return "", fmt.Errorf("pass either root or root_name, not both")
+	}
+	path, ok := config.Roots[rootName]
+	if !ok {
+		return "", fmt.Errorf("unknown root_name %q; list_roots lists the configured roots", rootName)
+	}
+	return path, nil
+}
+
+// validateRoots reports named roots which can't be used
+func validateRoots(roots map[string]string) error {
+	for name, path := range roots {
+		if name == "" {
+			return fmt.Errorf("roots: name cannot be empty")
+		}
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("roots.%s: path %q must be absolute", name, path)
+		}
+	}
+	return nil
+}
+
+// servedRoots are the only vaults MCP tools reach when the server is served
+// over HTTP: the served root first, then the configured roots. Nil, as over
+// stdio, allows any root.
+type servedRoots []string
+
+// newServedRoots returns the served roots for root, or nil when root is empty
+func newServedRoots(config *Config, root string) servedRoots {
+	if root == "" {
+		return nil
+	}
+	served := servedRoots{filepath.Clean(root)}
+	for _, path := range config.Roots {
+		served = append(served, filepath.Clean(path))
+	}
+	return served
+}
+
+// resolve returns the root a tool was given, as resolveRoot does, refusing
+// roots outside the served ones. A tool given no root gets the served root.
+func (s servedRoots) resolve(config *Config, root, rootName string) (string, error) {
+	root, err := resolveRoot(config, root, rootName)
+	if err != nil || s == nil {
+		return root, err
+	}
+	if root == "" {
+		return s[0], nil
+	}
+	if !s.contains(root) {
+		return "", fmt.Errorf("root %s is not served; use the served root or a configured root", root)
+	}
+	return root, nil
+}
+
+// checkFiles refuses file paths, relative to root or absolute, which are
+// outside the served roots
+func (s servedRoots) checkFiles(root string, filePaths []string) error {
+	if s == nil {
+		return nil
+	}
+	for _, filePath := range filePaths {
+		path := filePath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		if !s.contains(path) {
+			return fmt.Errorf("file path is outside the served roots: %s", filePath)
+		}
+	}
+	return nil
+}
+
+func (s servedRoots) contains(path string) bool {
+	for _, root := range s {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
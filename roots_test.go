@@ -0,0 +1,86 @@
+package tagmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestMCPRoots(t *testing.T) {
+	ctx := context.Background()
+	work := writeVault(t, map[string]string{"plan.md": "#project"})
+	personal := writeVault(t, map[string]string{"diary.md": "#journal"})
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := fmt.Sprintf("roots:\n  work: %q\n  personal: %q\n", work, personal)
+	require.NoError(t, os.WriteFile(configPath, []byte(config), tagmanager.DefaultFilePermissions))
+
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	go func() {
+		_ = tagmanager.RunCmd([]string{"tag-manager", "--config=" + configPath, "-mcp"},
+			&tagmanager.RunCmdOptions{MCPTransport: serverTransport})
+	}()
+
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v1.0.0"}, nil).
+		Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = session.Close()
+	}()
+
+	callTool := func(t *testing.T, name string, args map[string]any) *mcp.CallToolResult {
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("ListRoots", func(t *testing.T) {
+		result := callTool(t, "list_roots", map[string]any{})
+		require.False(t, result.IsError)
+
+		var roots []tagmanager.RootInfo
+		data, err := json.Marshal(result.StructuredContent)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &roots))
+		assert.Equal(t, []tagmanager.RootInfo{
+			{Name: "personal", Path: personal},
+			{Name: "work", Path: work},
+		}, roots)
+	})
+
+	for _, test := range []struct {
+		name     string
+		args     map[string]any
+		expected string
+		err      string
+	}{
+		{name: "ByName", args: map[string]any{"root_name": "work"}, expected: "project"},
+		{name: "OtherName", args: map[string]any{"root_name": "personal"}, expected: "journal"},
+		{name: "ByPath", args: map[string]any{"root": personal}, expected: "journal"},
+		{name: "UnknownName", args: map[string]any{"root_name": "music"}, err: `unknown root_name "music"`},
+		{name: "NameAndPath", args: map[string]any{"root_name": "work", "root": work}, err: "either root or root_name"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := callTool(t, "list_all_tags", test.args)
+			if test.err != "" {
+				require.True(t, result.IsError)
+				require.NotEmpty(t, result.Content)
+				assert.Contains(t, result.Content[0].(*mcp.TextContent).Text, test.err)
+				return
+			}
+			require.False(t, result.IsError)
+
+			data, err := json.Marshal(result.StructuredContent)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), test.expected)
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package tagmanager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RuleSet is the compiled form of the tag rules in a Config. A manager's
+// scanner and validator share one RuleSet so they always agree on what is a
+// tag, and conflicts within the config are detected once when it is built.
+type RuleSet struct {
+	config          *Config
+	hashtagPattern  *regexp.Regexp
+	invalidChars    *regexp.Regexp
+	excludeKeywords []excludeKeyword
+	conflicts       []RuleConflict
+}
+
+// Modes for matching Config.ExcludeKeywords against tags
+const (
+	// KeywordMatchSubstring excludes tags containing the keyword anywhere
+	KeywordMatchSubstring = "substring"
+	// KeywordMatchWord excludes tags containing the keyword as a whole word,
+	// delimited by the ends of the tag or by any character other than a letter
+	KeywordMatchWord = "word"
+	// KeywordMatchAnchored excludes tags, or segments of nested tags, which
+	// start with the keyword
+	KeywordMatchAnchored = "anchored"
+)
+
+// excludeKeyword is a compiled entry of Config.ExcludeKeywords
+type excludeKeyword struct {
+	keyword string
+	pattern *regexp.Regexp
+}
+
+// RuleConflict describes a hashtag on which HashtagPattern and the tag
+// character rules disagree, so the scanner and validator would decide it
+// differently or the scanner would silently drop it.
+type RuleConflict struct {
+	Example string `json:"example"`
+	Message string `json:"message"`
+}
+
+func (c RuleConflict) String() string {
+	return fmt.Sprintf("%s (e.g. %s)", c.Message, c.Example)
+}
+
+// ruleProbes are the characters placed inside sample hashtags to compare
+// HashtagPattern with the tag character rules.
+var ruleProbes = []rune{'-', '_', '/', '0', '.', ',', ':', ';', '!', '?', '+', '=', '@', '&', '*', '\'', '"', '(', '[', '{', '|', '~', 'é', '日'}
+
+func NewRuleSet(config *Config) (*RuleSet, error) {
+	hashtagPattern, err := regexp.Compile(config.HashtagPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hashtag pattern: %w", err)
+	}
+
+	excludeKeywords, err := compileExcludeKeywords(config)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &RuleSet{
+		config:          config,
+		hashtagPattern:  hashtagPattern,
+		invalidChars:    regexp.MustCompile(`[^` + tagChars(config) + `]`),
+		excludeKeywords: excludeKeywords,
+	}
+	rs.conflicts = rs.findConflicts()
+	return rs, nil
+}
+
+// compileExcludeKeywords compiles each keyword for the configured match mode.
+// A keyword written as /pattern/ is a regular expression matched anywhere in
+// the tag, whatever the mode. All matching ignores case.
+func compileExcludeKeywords(config *Config) ([]excludeKeyword, error) {
+	mode := config.ExcludeKeywordMode
+	if err := validateKeywordMode(mode); err != nil {
+		return nil, err
+	}
+
+	var keywords []excludeKeyword
+	for _, keyword := range config.ExcludeKeywords {
+		if keyword == "" {
+			continue
+		}
+
+		var expr string
+		switch {
+		case len(keyword) > 2 && strings.HasPrefix(keyword, "/") && strings.HasSuffix(keyword, "/"):
+			expr = keyword[1 : len(keyword)-1]
+		case mode == KeywordMatchWord:
+			expr = regexp.QuoteMeta(keyword)
+			if first, _ := utf8.DecodeRuneInString(keyword); unicode.IsLetter(first) {
+				expr = `(?:^|\PL)` + expr
+			}
+			if last, _ := utf8.DecodeLastRuneInString(keyword); unicode.IsLetter(last) {
+				expr += `(?:\PL|$)`
+			}
+		case mode == KeywordMatchAnchored:
+			expr = `(?:^|/)` + regexp.QuoteMeta(keyword)
+		default:
+			expr = regexp.QuoteMeta(keyword)
+		}
+
+		pattern, err := regexp.Compile(`(?i)` + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude keyword %q: %w", keyword, err)
+		}
+		keywords = append(keywords, excludeKeyword{keyword: keyword, pattern: pattern})
+	}
+	return keywords, nil
+}
+
+func validateKeywordMode(mode string) error {
+	switch mode {
+	case "", KeywordMatchSubstring, KeywordMatchWord, KeywordMatchAnchored:
+		return nil
+	}
+	return fmt.Errorf("invalid exclude_keyword_mode %q: must be %s, %s or %s",
+		mode, KeywordMatchSubstring, KeywordMatchWord, KeywordMatchAnchored)
+}
+
+// excludedKeyword returns the first ExcludeKeywords entry matching tag
+func (rs *RuleSet) excludedKeyword(tag string) (string, bool) {
+	for _, keyword := range rs.excludeKeywords {
+		if keyword.pattern.MatchString(tag) {
+			return keyword.keyword, true
+		}
+	}
+	return "", false
+}
+
+// Conflicts returns the disagreements between HashtagPattern and the tag
+// character rules found when the RuleSet was built.
+func (rs *RuleSet) Conflicts() []RuleConflict {
+	return rs.conflicts
+}
+
+func (rs *RuleSet) findConflicts() []RuleConflict {
+	var conflicts []RuleConflict
+
+	if plain := "#abcd"; rs.hashtagPattern.FindString(plain) != plain {
+		conflicts = append(conflicts, RuleConflict{
+			Example: plain,
+			Message: "hashtag_pattern does not match a plain hashtag",
+		})
+	}
+
+	for _, r := range ruleProbes {
+		if !rs.config.UnicodeTags && r > unicode.MaxASCII && unicode.IsLetter(r) {
+			// With unicode_tags off, hashtags with non-ASCII letters are dropped on purpose
+			continue
+		}
+
+		example := "#ab" + string(r) + "cd"
+		matched := rs.hashtagPattern.FindString(example) == example
+		allowed := rs.isTagRune(r)
+
+		switch {
+		case matched && !allowed:
+			conflicts = append(conflicts, RuleConflict{
+				Example: example,
+				Message: fmt.Sprintf("hashtag_pattern matches %q, which tag rules reject, so these hashtags are ignored", r),
+			})
+		case allowed && !matched:
+			conflicts = append(conflicts, RuleConflict{
+				Example: example,
+				Message: fmt.Sprintf("tag rules allow %q but hashtag_pattern stops at it, so validate accepts tags the scanner never finds", r),
+			})
+		}
+	}
+
+	if leading := "#1abc"; rs.hashtagPattern.FindString(leading) == leading {
+		conflicts = append(conflicts, RuleConflict{
+			Example: leading,
+			Message: "hashtag_pattern matches hashtags starting with a digit, which validate rejects",
+		})
+	}
+
+	return conflicts
+}
+
+// isLetter reports whether r may start a tag
+func (rs *RuleSet) isLetter(r rune) bool {
+	if rs.config.UnicodeTags {
+		return unicode.IsLetter(r)
+	}
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// hasOnlyTagRunes reports whether every character of tag can be part of a tag
+func (rs *RuleSet) hasOnlyTagRunes(tag string) bool {
+	for _, ch := range tag {
+		if !rs.isTagRune(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rs *RuleSet) isValidTag(tag string) bool {
+	tagLength := utf8.RuneCountInString(tag)
+	if tagLength < rs.config.MinTagLength {
+		return false
+	}
+
+	if !rs.hasOnlyTagRunes(tag) {
+		return false
+	}
+
+	// Nested tags (project/alpha) must not have empty segments
+	if strings.HasPrefix(tag, "/") || strings.HasSuffix(tag, "/") || strings.Contains(tag, "//") {
+		return false
+	}
+
+	if _, excluded := rs.excludedKeyword(tag); excluded {
+		return false
+	}
+
+	if rs.isHexColor(tag) {
+		return false
+	}
+
+	if rs.looksLikeID(tag) {
+		return false
+	}
+
+	if rs.isURLFragment(tag) {
+		return false
+	}
+
+	digitCount := 0
+	for _, ch := range tag {
+		if ch >= '0' && ch <= '9' {
+			digitCount++
+		}
+	}
+	digitRatio := float64(digitCount) / float64(tagLength)
+	return digitRatio <= rs.config.MaxDigitRatio
+}
+
+// isTagRune reports whether r may appear inside a tag. When UnicodeTags is
+// disabled only ASCII letters and digits are accepted. The slash separates the
+// segments of a nested tag such as project/alpha.
+func (rs *RuleSet) isTagRune(r rune) bool {
+	if r == '-' || r == '_' || r == '/' {
+		return true
+	}
+	if rs.config.UnicodeTags {
+		return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+	}
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func (rs *RuleSet) isHexColor(tag string) bool {
+	if len(tag) != 3 && len(tag) != 6 {
+		return false
+	}
+	for _, ch := range tag {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') && (ch < 'A' || ch > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func (rs *RuleSet) looksLikeID(tag string) bool {
+	if len(tag) < 8 {
+		return false
+	}
+
+	hasUpperCase := false
+	hasLowerCase := false
+	hasDigit := false
+	consecutiveDigits := 0
+	maxConsecutive := 0
+
+	for _, ch := range tag {
+		if ch >= 'A' && ch <= 'Z' {
+			hasUpperCase = true
+			consecutiveDigits = 0
+		} else if ch >= 'a' && ch <= 'z' {
+			hasLowerCase = true
+			consecutiveDigits = 0
+		} else if ch >= '0' && ch <= '9' {
+			hasDigit = true
+			consecutiveDigits++
+			if consecutiveDigits > maxConsecutive {
+				maxConsecutive = consecutiveDigits
+			}
+		} else {
+			consecutiveDigits = 0
+		}
+	}
+
+	if maxConsecutive > 4 {
+		return true
+	}
+
+	if hasUpperCase && hasLowerCase && hasDigit && len(tag) > 12 {
+		return true
+	}
+
+	return false
+}
+
+func (rs *RuleSet) isURLFragment(tag string) bool {
+	urlPatterns := []string{
+		"http", "https", "ftp", "www", ".com", ".org", ".net",
+		"localhost", "127.0.0.1", "::1",
+	}
+
+	tagLower := strings.ToLower(tag)
+	for _, pattern := range urlPatterns {
+		if strings.Contains(tagLower, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashtag is a valid hashtag found in text, which is text[start:end] and
+// names tag
+type hashtag struct {
+	start, end int
+	tag        string
+}
+
+// hashtags returns the valid hashtags in text, in order
+func (rs *RuleSet) hashtags(text string) []hashtag {
+	var found []hashtag
+	for _, match := range rs.hashtagPattern.FindAllStringIndex(text, -1) {
+		name := strings.TrimPrefix(text[match[0]:match[1]], "#")
+		// A trailing slash ends a sentence or path rather than a nested tag
+		tag := strings.TrimRight(name, "/")
+		if rs.isValidTag(tag) && rs.hashtagBoundaryAt(text, match[0], match[1]) {
+			found = append(found, hashtag{start: match[0], end: match[1] - (len(name) - len(tag)), tag: tag})
+		}
+	}
+	return found
+}
+
+func (rs *RuleSet) checkHashtagBoundary(content string, hashtag string) bool {
+	// Find all occurrences of this hashtag
+	start := 0
+	for {
+		index := strings.Index(content[start:], hashtag)
+		if index == -1 {
+			return false
+		}
+
+		absoluteIndex := start + index
+		if rs.hashtagBoundaryAt(content, absoluteIndex, absoluteIndex+len(hashtag)) {
+			return true
+		}
+
+		// Move to next possible occurrence
+		start = absoluteIndex + 1
+	}
+}
+
+// hashtagBoundaryAt reports whether content[start:end] stands alone as a
+// hashtag rather than being part of a word or an email address
+func (rs *RuleSet) hashtagBoundaryAt(content string, start, end int) bool {
+	// Check character before hashtag
+	if start > 0 {
+		prevChar, _ := utf8.DecodeLastRuneInString(content[:start])
+		// Don't allow @ before # (email case) or word characters
+		if prevChar == '@' || rs.isTagRune(prevChar) {
+			return false
+		}
+	}
+
+	// Check character after hashtag
+	if end < len(content) {
+		nextChar, _ := utf8.DecodeRuneInString(content[end:])
+		if rs.isTagRune(nextChar) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagChars returns the body of a regex character class matching the same
+// characters as isTagRune, for use in patterns that must respect tag boundaries.
+func tagChars(config *Config) string {
+	if config.UnicodeTags {
+		return `\p{L}\p{M}\p{N}_\-/`
+	}
+	return `a-zA-Z0-9_\-/`
+}
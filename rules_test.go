@@ -0,0 +1,143 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tagmanager "github.com/thrawn01/tag-manager"
+)
+
+func TestRuleSetConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(config *tagmanager.Config)
+		examples []string
+	}{
+		{
+			name:   "Default",
+			modify: func(config *tagmanager.Config) {},
+		},
+		{
+			name:   "ASCIIOnlyWithDefaultPattern",
+			modify: func(config *tagmanager.Config) { config.UnicodeTags = false },
+		},
+		{
+			name: "PatternMatchesRejectedCharacters",
+			modify: func(config *tagmanager.Config) {
+				config.HashtagPattern = `#[a-zA-Z][a-zA-Z0-9_\-/.]*`
+				config.UnicodeTags = false
+			},
+			examples: []string{"#ab.cd"},
+		},
+		{
+			name: "PatternMissesAllowedCharacters",
+			modify: func(config *tagmanager.Config) {
+				config.HashtagPattern = `#[a-zA-Z][a-zA-Z0-9_\-]*`
+			},
+			examples: []string{"#ab/cd", "#abécd", "#ab日cd"},
+		},
+		{
+			name: "PatternAllowsLeadingDigit",
+			modify: func(config *tagmanager.Config) {
+				config.HashtagPattern = `#[\p{L}\p{N}][\p{L}\p{M}\p{N}_\-/]*`
+			},
+			examples: []string{"#1abc"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			test.modify(config)
+
+			rules, err := tagmanager.NewRuleSet(config)
+			require.NoError(t, err)
+
+			var examples []string
+			for _, conflict := range rules.Conflicts() {
+				examples = append(examples, conflict.Example)
+			}
+			assert.ElementsMatch(t, test.examples, examples)
+		})
+	}
+
+	_, err := tagmanager.NewRuleSet(&tagmanager.Config{HashtagPattern: "[invalid"})
+	assert.Error(t, err)
+}
+
+func TestRuleConflictWarning(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("hashtag_pattern: '#[a-zA-Z][a-zA-Z0-9_\\-/]*'\n"), tagmanager.DefaultFilePermissions))
+
+	var stdout, stderr bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "--config=" + configPath, "list", "--root=" + tempDir},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &stderr})
+	require.NoError(t, err)
+	assert.Contains(t, stderr.String(), "warning: tag rules allow 'é' but hashtag_pattern stops at it")
+}
+
+func TestExcludeKeywordModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		keywords []string
+		tag      string
+		excluded bool
+	}{
+		{name: "SubstringInsideWord", mode: tagmanager.KeywordMatchSubstring, keywords: []string{"draft"}, tag: "redrafted", excluded: true},
+		{name: "SubstringIgnoresCase", mode: tagmanager.KeywordMatchSubstring, keywords: []string{"Draft"}, tag: "my-draft", excluded: true},
+		{name: "EmptyModeIsSubstring", mode: "", keywords: []string{"draft"}, tag: "redrafted", excluded: true},
+		{name: "WordInsideWord", mode: tagmanager.KeywordMatchWord, keywords: []string{"draft"}, tag: "redrafted"},
+		{name: "WordBetweenHyphens", mode: tagmanager.KeywordMatchWord, keywords: []string{"draft"}, tag: "my-draft-notes", excluded: true},
+		{name: "WordBeforeDigits", mode: tagmanager.KeywordMatchWord, keywords: []string{"ftn"}, tag: "ftn12", excluded: true},
+		{name: "WordNestedSegment", mode: tagmanager.KeywordMatchWord, keywords: []string{"draft"}, tag: "project/draft", excluded: true},
+		{name: "WordTrailingHyphen", mode: tagmanager.KeywordMatchWord, keywords: []string{"diff-"}, tag: "diff-check", excluded: true},
+		{name: "AnchoredStart", mode: tagmanager.KeywordMatchAnchored, keywords: []string{"bibr"}, tag: "bibrefs", excluded: true},
+		{name: "AnchoredSegment", mode: tagmanager.KeywordMatchAnchored, keywords: []string{"bibr"}, tag: "refs/bibrefs", excluded: true},
+		{name: "AnchoredMiddle", mode: tagmanager.KeywordMatchAnchored, keywords: []string{"bibr"}, tag: "my-bibrefs"},
+		{name: "Regex", mode: tagmanager.KeywordMatchWord, keywords: []string{"/^wip(-|$)/"}, tag: "wip-notes", excluded: true},
+		{name: "RegexNoMatch", mode: tagmanager.KeywordMatchWord, keywords: []string{"/^wip(-|$)/"}, tag: "wipeout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.ExcludeKeywordMode = test.mode
+			config.ExcludeKeywords = test.keywords
+
+			scanner, err := tagmanager.NewFilesystemScanner(config)
+			require.NoError(t, err)
+			validator := tagmanager.NewDefaultValidator(config)
+
+			result := validator.ValidateTag(test.tag)
+			tags := scanner.ExtractTags("#" + test.tag)
+			if test.excluded {
+				assert.False(t, result.IsValid)
+				assert.Contains(t, result.Issues, "Tag contains excluded keyword: "+test.keywords[0])
+				assert.Empty(t, tags)
+			} else {
+				assert.True(t, result.IsValid, result.Issues)
+				assert.Equal(t, []string{test.tag}, tags)
+			}
+		})
+	}
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		for _, modify := range []func(config *tagmanager.Config){
+			func(config *tagmanager.Config) { config.ExcludeKeywordMode = "exact" },
+			func(config *tagmanager.Config) { config.ExcludeKeywords = []string{"/[unclosed/"} },
+		} {
+			config := tagmanager.DefaultConfig()
+			modify(config)
+
+			_, err := tagmanager.NewRuleSet(config)
+			assert.Error(t, err)
+			assert.Error(t, tagmanager.NewDefaultValidator(config).ValidateConfig(config))
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Scan phases reported by ScanError
+const (
+	ScanPhaseWalk    = "walk"
+	ScanPhaseRead    = "read"
+	ScanPhaseStat    = "stat"
+	ScanPhaseDecrypt = "decrypt"
+	// ScanPhaseFrontmatter is frontmatter tags can't be read from
+	// unambiguously, reported only in strict mode
+	ScanPhaseFrontmatter = "frontmatter"
+	// ScanPhaseTags is frontmatter tags a file is scanned without because
+	// of characters tags can't have, reported as a warning
+	ScanPhaseTags = "tags"
+)
+
+// ScanError is a failure to scan one file or directory
+type ScanError struct {
+	Path  string
+	Phase string
+	Err   error
+}
+
+func (e *ScanError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %v", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Phase, e.Path, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ScanError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path  string `json:"path"`
+		Phase string `json:"phase"`
+		Error string `json:"error"`
+	}{e.Path, e.Phase, e.Err.Error()})
+}
+
+// ScanReport collects the errors scans skip over, and the warnings about
+// files scanned in part. Attach one to a manager with WithScanReport; it is
+// safe for concurrent use.
+type ScanReport struct {
+	mu       sync.Mutex
+	errors   []*ScanError
+	warnings []*ScanError
+}
+
+// Errors returns the scan errors recorded so far
+func (r *ScanReport) Errors() []*ScanError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*ScanError(nil), r.errors...)
+}
+
+// Warnings returns the warnings recorded so far, such as frontmatter tags
+// left out of a file's tags
+func (r *ScanReport) Warnings() []*ScanError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*ScanError(nil), r.warnings...)
+}
+
+func (r *ScanReport) warn(warning *ScanError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, warning)
+}
+
+func (r *ScanReport) record(err error) {
+	var scanErr *ScanError
+	if !errors.As(err, &scanErr) {
+		scanErr = &ScanError{Phase: ScanPhaseWalk, Err: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, scanErr)
+}
+
+// WithScanReport returns a manager which records the files its scans skip
+// because of errors in report, and the frontmatter tags they leave out.
+func (m *DefaultTagManager) WithScanReport(report *ScanReport) TagManager {
+	reporting := *m
+	reporting.report = report
+	if scanner, ok := m.scanner.(*FilesystemScanner); ok {
+		reporting.scanner = scanner.withReport(report)
+	}
+	return &reporting
+}
+
+// scanFailed records a scan error, returning it when Config.FailOnScanError
+// or Config.Strict asks for scans to stop rather than skip the file. Cancellation isn't a
+// failure of any file, so it is neither recorded nor returned.
+func (m *DefaultTagManager) scanFailed(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	if m.report != nil {
+		m.report.record(err)
+	}
+	if m.config.FailOnScanError || m.config.Strict {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+	return nil
+}
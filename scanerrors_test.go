@@ -0,0 +1,112 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestScanReport(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "good.md"), []byte("#golang"), tagmanager.DefaultFilePermissions))
+	// A dangling symlink fails to read even when the tests run as root
+	broken := filepath.Join(tempDir, "broken.md")
+	require.NoError(t, os.Symlink(filepath.Join(tempDir, "missing.md"), broken))
+
+	ctx := context.Background()
+
+	t.Run("Recorded", func(t *testing.T) {
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		report := &tagmanager.ScanReport{}
+		tags, err := manager.WithScanReport(report).ListAllTags(ctx, tempDir, 1)
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+		assert.Equal(t, "golang", tags[0].Name)
+
+		scanErrors := report.Errors()
+		require.Len(t, scanErrors, 1)
+		assert.Equal(t, broken, scanErrors[0].Path)
+		assert.Equal(t, tagmanager.ScanPhaseRead, scanErrors[0].Phase)
+		assert.ErrorIs(t, scanErrors[0], os.ErrNotExist)
+	})
+
+	t.Run("FailOnScanError", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.FailOnScanError = true
+		manager, err := tagmanager.NewDefaultTagManager(config)
+		require.NoError(t, err)
+
+		_, err = manager.ListAllTags(ctx, tempDir, 1)
+		require.Error(t, err)
+
+		var scanErr *tagmanager.ScanError
+		require.ErrorAs(t, err, &scanErr)
+		assert.Equal(t, broken, scanErr.Path)
+	})
+
+	t.Run("Verbose", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "--verbose", "list", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, stdout.String(), "golang")
+		assert.Contains(t, stderr.String(), "1 files skipped because of scan errors")
+		assert.Contains(t, stderr.String(), "[read] "+broken)
+
+		stderr.Reset()
+		err = tagmanager.RunCmd([]string{"tag-manager", "--fail-on-scan-error", "list", "--root=" + tempDir}, &tagmanager.RunCmdOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		assert.ErrorContains(t, err, "broken.md")
+	})
+}
+
+func TestScanReportWarnings(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		// ab is too short, but only bad characters make a warning
+		"bad.md":  "---\ntags: [golang, \"c++\", \"what?\", ab]\n---\n",
+		"good.md": "---\ntags: [rust]\n---\n",
+	})
+	path := filepath.Join(root, "bad.md")
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+	report := &tagmanager.ScanReport{}
+	_, err = manager.WithScanReport(report).ListAllTags(context.Background(), root, 1)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors())
+
+	warnings := report.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, path, warnings[0].Path)
+	assert.Equal(t, tagmanager.ScanPhaseTags, warnings[0].Phase)
+	assert.EqualError(t, warnings[0].Err, "frontmatter tags with invalid characters ignored: c++, what?")
+
+	var stdout, stderr bytes.Buffer
+	err = tagmanager.RunCmd([]string{"tag-manager", "--verbose", "list", "--root=" + root}, &tagmanager.RunCmdOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, stderr.String(), "1 files scanned with warnings")
+	assert.Contains(t, stderr.String(), "[tags] "+path+": frontmatter tags with invalid characters ignored: c++, what?")
+
+	stderr.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "list", "--root=" + root}, &tagmanager.RunCmdOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, stderr.String(), "warnings")
+}
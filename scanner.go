@@ -9,63 +9,106 @@ import (
 	"iter"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Scanner interface {
 	ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error]
 	ScanFile(ctx context.Context, filePath string) (FileTagInfo, error)
+	WalkFiles(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[string, error]
 	ExtractTags(content string) []string
-	ExtractTagsFromReader(ctx context.Context, reader io.Reader) []string
+	ExtractTagsFromReader(ctx context.Context, reader io.Reader) (tags []string, truncated bool, err error)
 }
 
 type FilesystemScanner struct {
-	config             *Config
-	hashtagPattern     *regexp.Regexp
-	yamlTagPattern     *regexp.Regexp
-	yamlTagListPattern *regexp.Regexp
+	config *Config
+	rules  *RuleSet
+	// fsys is the file system scanned; nil scans the real filesystem with OS paths
+	fsys fs.FS
+	// plugins finds the Obsidian plugins enabled for files on the real filesystem
+	plugins *pluginDetector
+	// extensions, when set, replace Config.Extensions
+	extensions []string
+	// report, when set, is warned of the frontmatter tags files are scanned
+	// without
+	report *ScanReport
 }
 
 func NewFilesystemScanner(config *Config) (*FilesystemScanner, error) {
-	hashtagPattern, err := regexp.Compile(config.HashtagPattern)
+	rules, err := NewRuleSet(config)
 	if err != nil {
-		return nil, fmt.Errorf("invalid hashtag pattern: %w", err)
+		return nil, err
 	}
+	return newFilesystemScanner(rules), nil
+}
 
-	yamlTagPattern, err := regexp.Compile(config.YAMLTagPattern)
-	if err != nil {
-		return nil, fmt.Errorf("invalid YAML tag pattern: %w", err)
+// newFilesystemScanner returns a scanner using an already compiled RuleSet
+func newFilesystemScanner(rules *RuleSet) *FilesystemScanner {
+	return &FilesystemScanner{
+		config:  rules.config,
+		rules:   rules,
+		plugins: newPluginDetector(rules.config),
 	}
+}
 
-	yamlTagListPattern, err := regexp.Compile(config.YAMLListPattern)
+// NewFSScanner returns a scanner which reads from fsys instead of the real
+// filesystem, such as an embed.FS or fstest.MapFS. Paths passed to and
+// returned by the scanner are slash-separated fs.FS paths, with "." as the root.
+func NewFSScanner(fsys fs.FS, config *Config) (*FilesystemScanner, error) {
+	scanner, err := NewFilesystemScanner(config)
 	if err != nil {
-		return nil, fmt.Errorf("invalid YAML list pattern: %w", err)
+		return nil, err
 	}
-
-	return &FilesystemScanner{
-		config:             config,
-		hashtagPattern:     hashtagPattern,
-		yamlTagPattern:     yamlTagPattern,
-		yamlTagListPattern: yamlTagListPattern,
-	}, nil
+	scanner.fsys = fsys
+	return scanner, nil
 }
 
 func (s *FilesystemScanner) ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error] {
+	return scanWalkedFiles(ctx, s, s.WalkFiles(ctx, rootPath, excludePaths))
+}
+
+// scanWalkedFiles scans each file yielded by paths, passing walk errors through
+func scanWalkedFiles(ctx context.Context, scanner Scanner, paths iter.Seq2[string, error]) iter.Seq2[FileTagInfo, error] {
 	return func(yield func(FileTagInfo, error) bool) {
-		allExcludes := append(s.config.ExcludeDirs, excludePaths...)
+		for path, err := range paths {
+			if err != nil {
+				if !yield(FileTagInfo{Path: path}, err) {
+					return
+				}
+				continue
+			}
+
+			if !yield(scanner.ScanFile(ctx, path)) {
+				return
+			}
+		}
+	}
+}
 
-		if err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+// WalkFiles yields the path of every markdown file under rootPath which is not
+// excluded by the config or excludePaths, without reading the files.
+func (s *FilesystemScanner) WalkFiles(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		allExcludes := append(append([]string{}, s.config.ExcludeDirs...), excludePaths...)
+		stopped := false
+
+		if err := s.walkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
 
 			if err != nil {
-				yield(FileTagInfo{}, err)
+				if !yield(path, &ScanError{Path: path, Phase: ScanPhaseWalk, Err: err}) {
+					stopped = true
+					return filepath.SkipAll
+				}
 				return nil
 			}
 
-			relPath, _ := filepath.Rel(rootPath, path)
+			relPath := s.relPath(rootPath, path)
 
 			for _, exclude := range allExcludes {
 				if strings.Contains(relPath, exclude) {
@@ -77,10 +120,17 @@ func (s *FilesystemScanner) ScanDirectory(ctx context.Context, rootPath string,
 			}
 
 			if d.IsDir() {
+				if d.Name() == BackupDir {
+					return filepath.SkipDir
+				}
+				// Templates are skipped unless they are what was asked for
+				if vaultRoot, plugins := s.vaultPlugins(path); path != rootPath && plugins.isTemplate(vaultRoot, path) {
+					return filepath.SkipDir
+				}
 				return nil
 			}
 
-			if !strings.HasSuffix(path, ".md") {
+			if !isNote(s.config, path, s.noteExtensions()) {
 				return nil
 			}
 
@@ -90,229 +140,332 @@ func (s *FilesystemScanner) ScanDirectory(ctx context.Context, rootPath string,
 				}
 			}
 
-			fileInfo, err := s.ScanFile(ctx, path)
-			if !yield(fileInfo, err) {
-				return fmt.Errorf("scan terminated by consumer")
+			if !yield(path, nil) {
+				stopped = true
+				return filepath.SkipAll
 			}
 			return nil
-		}); err != nil {
-			yield(FileTagInfo{}, err)
+		}); err != nil && !stopped {
+			yield("", &ScanError{Path: rootPath, Phase: ScanPhaseWalk, Err: err})
 		}
 	}
 }
 
-func (s *FilesystemScanner) ScanFile(ctx context.Context, filePath string) (FileTagInfo, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return FileTagInfo{Path: filePath}, err
+// noteExtensions are the extensions of the files the scanner walks
+func (s *FilesystemScanner) noteExtensions() []string {
+	if s.extensions != nil {
+		return s.extensions
 	}
+	return noteExtensions(s.config)
+}
 
-	tags := s.ExtractTags(string(content))
-	return FileTagInfo{
-		Path: filePath,
-		Tags: tags,
-	}, nil
+// withExtensions returns a copy of the scanner which walks the files with
+// extensions instead
+func (s *FilesystemScanner) withExtensions(extensions []string) *FilesystemScanner {
+	widened := *s
+	widened.extensions = extensions
+	return &widened
 }
 
-func (s *FilesystemScanner) ExtractTags(content string) []string {
-	tagMap := make(map[string]bool)
+// withReport returns a copy of the scanner which warns report of the
+// frontmatter tags it leaves out
+func (s *FilesystemScanner) withReport(report *ScanReport) *FilesystemScanner {
+	reporting := *s
+	reporting.report = report
+	return &reporting
+}
 
-	hashtagMatches := s.hashtagPattern.FindAllString(content, -1)
-	for _, match := range hashtagMatches {
-		tag := strings.TrimPrefix(match, "#")
-		if s.isValidTag(tag) && s.checkHashtagBoundary(content, match) {
-			tagMap[tag] = true
-		}
+// noteExtensions returns Config.Extensions, or md when it is empty
+func noteExtensions(config *Config) []string {
+	if len(config.Extensions) == 0 {
+		return []string{"md"}
 	}
+	return config.Extensions
+}
 
-	if yamlMatch := s.yamlTagPattern.FindStringSubmatch(content); len(yamlMatch) > 1 {
-		tags := strings.Split(yamlMatch[1], ",")
-		for _, tag := range tags {
-			tag = strings.TrimSpace(tag)
-			tag = strings.Trim(tag, `"'`)
-			if s.isValidTag(tag) {
-				tagMap[tag] = true
-			}
-		}
-	}
+// isNote reports whether path is a note: a file ending in one of extensions,
+// or one a CryptHook decrypts, such as journal.md.age
+func isNote(config *Config, path string, extensions []string) bool {
+	return hasExtension(path, extensions) || cryptHook(config, path) != nil
+}
 
-	if yamlListMatch := s.yamlTagListPattern.FindStringSubmatch(content); len(yamlListMatch) > 1 {
-		lines := strings.Split(yamlListMatch[1], "\n")
-		for _, line := range lines {
-			tag := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-"))
-			tag = strings.Trim(tag, `"'`)
-			if s.isValidTag(tag) {
-				tagMap[tag] = true
-			}
+// hasExtension reports whether path ends in one of extensions, ignoring case
+func hasExtension(path string, extensions []string) bool {
+	for _, extension := range extensions {
+		suffix := "." + strings.TrimPrefix(extension, ".")
+		if len(path) > len(suffix) && strings.EqualFold(path[len(path)-len(suffix):], suffix) {
+			return true
 		}
 	}
+	return false
+}
 
-	var tags []string
-	for tag := range tagMap {
-		tags = append(tags, tag)
+// validateExtensions checks each extension is a file extension
+func validateExtensions(extensions []string) error {
+	for _, extension := range extensions {
+		trimmed := strings.TrimPrefix(extension, ".")
+		if trimmed == "" || strings.ContainsAny(trimmed, `/\*?[`) {
+			return fmt.Errorf("invalid extension %q: must be a file extension such as md or txt", extension)
+		}
 	}
-	return tags
+	return nil
 }
 
-func (s *FilesystemScanner) ExtractTagsFromReader(ctx context.Context, reader io.Reader) []string {
-	scanner := bufio.NewScanner(reader)
-	var content strings.Builder
-
-	for scanner.Scan() {
-		if ctx.Err() != nil {
-			return nil
+// parseExtensions parses a comma-separated list of extensions, such as
+// md,mdx,txt
+func parseExtensions(value string) ([]string, error) {
+	var extensions []string
+	for _, extension := range strings.Split(value, ",") {
+		if extension = strings.TrimSpace(extension); extension != "" {
+			extensions = append(extensions, extension)
 		}
-		content.WriteString(scanner.Text())
-		content.WriteString("\n")
 	}
-
-	return s.ExtractTags(content.String())
+	if len(extensions) == 0 {
+		return nil, fmt.Errorf("--extensions cannot be empty")
+	}
+	if err := validateExtensions(extensions); err != nil {
+		return nil, err
+	}
+	return extensions, nil
 }
 
-func (s *FilesystemScanner) isValidTag(tag string) bool {
-	if len(tag) < s.config.MinTagLength {
-		return false
+func (s *FilesystemScanner) ScanFile(ctx context.Context, filePath string) (FileTagInfo, error) {
+	content, err := s.readFile(filePath)
+	if err != nil {
+		return FileTagInfo{Path: filePath}, &ScanError{Path: filePath, Phase: ScanPhaseRead, Err: err}
 	}
 
-	for _, keyword := range s.config.ExcludeKeywords {
-		if strings.Contains(strings.ToLower(tag), keyword) {
-			return false
-		}
+	content, err = decryptNote(ctx, s.config, filePath, content)
+	if err != nil {
+		return FileTagInfo{Path: filePath}, &ScanError{Path: filePath, Phase: ScanPhaseDecrypt, Err: err}
 	}
 
-	if s.isHexColor(tag) {
-		return false
+	if s.config.Strict {
+		if err := checkFrontmatter(string(content)); err != nil {
+			return FileTagInfo{Path: filePath}, &ScanError{Path: filePath, Phase: ScanPhaseFrontmatter, Err: err}
+		}
 	}
 
-	if s.looksLikeID(tag) {
-		return false
+	_, plugins := s.vaultPlugins(filePath)
+	tags, dropped := s.extractTags(string(content), plugins)
+	if len(dropped) > 0 && s.report != nil {
+		s.report.warn(&ScanError{Path: filePath, Phase: ScanPhaseTags,
+			Err: fmt.Errorf("frontmatter tags with invalid characters ignored: %s", strings.Join(dropped, ", "))})
 	}
+	return FileTagInfo{
+		Path: filePath,
+		Tags: tags,
+	}, nil
+}
 
-	if s.isURLFragment(tag) {
-		return false
+func (s *FilesystemScanner) walkDir(root string, fn fs.WalkDirFunc) error {
+	if s.fsys == nil {
+		return filepath.WalkDir(root, fn)
 	}
+	return fs.WalkDir(s.fsys, root, fn)
+}
 
-	digitCount := 0
-	for _, ch := range tag {
-		if ch >= '0' && ch <= '9' {
-			digitCount++
-		}
+func (s *FilesystemScanner) readFile(name string) ([]byte, error) {
+	if s.fsys == nil {
+		return os.ReadFile(name)
 	}
-	digitRatio := float64(digitCount) / float64(len(tag))
-	return digitRatio <= s.config.MaxDigitRatio
+	return fs.ReadFile(s.fsys, name)
 }
 
-func (s *FilesystemScanner) isHexColor(tag string) bool {
-	if len(tag) != 3 && len(tag) != 6 {
-		return false
+// relPath returns path relative to root, which it is known to be inside
+func (s *FilesystemScanner) relPath(root, path string) string {
+	if s.fsys == nil {
+		rel, _ := filepath.Rel(root, path)
+		return rel
 	}
-	for _, ch := range tag {
-		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') && (ch < 'A' || ch > 'F') {
-			return false
-		}
+	if root == "." {
+		return path
 	}
-	return true
+	return strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
 }
 
-func (s *FilesystemScanner) looksLikeID(tag string) bool {
-	if len(tag) < 8 {
-		return false
+// vaultPlugins returns the root of the vault containing path and the plugin
+// integrations enabled for it, which are nil when none apply.
+func (s *FilesystemScanner) vaultPlugins(path string) (string, *VaultPlugins) {
+	if s.fsys != nil {
+		return "", nil
 	}
+	return s.plugins.forPath(path)
+}
 
-	hasUpperCase := false
-	hasLowerCase := false
-	hasDigit := false
-	consecutiveDigits := 0
-	maxConsecutive := 0
-
-	for _, ch := range tag {
-		if ch >= 'A' && ch <= 'Z' {
-			hasUpperCase = true
-			consecutiveDigits = 0
-		} else if ch >= 'a' && ch <= 'z' {
-			hasLowerCase = true
-			consecutiveDigits = 0
-		} else if ch >= '0' && ch <= '9' {
-			hasDigit = true
-			consecutiveDigits++
-			if consecutiveDigits > maxConsecutive {
-				maxConsecutive = consecutiveDigits
-			}
-		} else {
-			consecutiveDigits = 0
-		}
+func (s *FilesystemScanner) ExtractTags(content string) []string {
+	tags, _ := s.extractTags(content, nil)
+	return tags
+}
+
+// extractTags extracts the tags in content, leaving out the content owned by
+// plugins when they are given. The frontmatter tags left out because of
+// characters tags can't have are returned as dropped.
+func (s *FilesystemScanner) extractTags(content string, plugins *VaultPlugins) (tags, dropped []string) {
+	tagMap := make(map[string]bool)
+
+	content, _ = normalizeText(content)
+	if hasIgnoreFileDirective(content) {
+		return nil, nil
 	}
 
-	if maxConsecutive > 4 {
-		return true
+	body := content
+	if frontmatter, rest, ok := splitFrontmatter(content); ok {
+		// Malformed frontmatter is scanned as ordinary content
+		if s.addFrontmatterTags(frontmatter, tagMap) {
+			body = rest
+		}
 	}
 
-	if hasUpperCase && hasLowerCase && hasDigit && len(tag) > 12 {
-		return true
+	body = stripKanbanSettings(body)
+	if plugins != nil {
+		body = plugins.strip(body)
 	}
 
-	return false
+	s.addHashtags(stripCode(stripIgnored(body)), tagMap)
+	return tagList(tagMap), droppedTags(tagMap)
 }
 
-func (s *FilesystemScanner) isURLFragment(tag string) bool {
-	urlPatterns := []string{
-		"http", "https", "ftp", "www", ".com", ".org", ".net",
-		"localhost", "127.0.0.1", "::1",
-	}
+// ExtractTagsFromReader extracts tags from reader a line at a time, so only
+// the frontmatter block and the current line are held in memory. At most
+// Config.MaxReaderBytes are read; when the reader holds more, or ctx is
+// cancelled, the tags found so far are returned with truncated set, along
+// with ErrTruncated in strict mode. A line cut
+// off by the budget is not scanned, so it can't yield partial tags.
+func (s *FilesystemScanner) ExtractTagsFromReader(ctx context.Context, reader io.Reader) (tags []string, truncated bool, err error) {
+	tagMap := make(map[string]bool)
 
-	tagLower := strings.ToLower(tag)
-	for _, pattern := range urlPatterns {
-		if strings.Contains(tagLower, pattern) {
-			return true
-		}
+	source := reader
+	var limited *io.LimitedReader
+	if s.config.MaxReaderBytes > 0 {
+		limited = &io.LimitedReader{R: reader, N: s.config.MaxReaderBytes}
+		source = limited
 	}
+	buffered := bufio.NewReader(source)
 
-	return false
-}
+	// Frontmatter lines are held until the closing delimiter, then parsed
+	var frontmatter []string
+	inFrontmatter := false
+	firstLine := true
+	ignoring := false
+	inKanbanSettings := false
+	var fences codeFences
 
-func (s *FilesystemScanner) checkHashtagBoundary(content string, hashtag string) bool {
-	// Find all occurrences of this hashtag
-	start := 0
 	for {
-		index := strings.Index(content[start:], hashtag)
-		if index == -1 {
-			break
+		if err := ctx.Err(); err != nil {
+			return tagList(tagMap), true, err
+		}
+
+		line, readErr := buffered.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return tagList(tagMap), true, readErr
 		}
 
-		absoluteIndex := start + index
-
-		// Check character before hashtag
-		validBefore := true
-		if absoluteIndex > 0 {
-			prevChar := content[absoluteIndex-1]
-			// Don't allow @ before # (email case) or alphanumeric characters
-			if prevChar == '@' ||
-				(prevChar >= 'a' && prevChar <= 'z') || (prevChar >= 'A' && prevChar <= 'Z') ||
-				(prevChar >= '0' && prevChar <= '9') || prevChar == '-' || prevChar == '_' {
-				validBefore = false
+		if readErr == io.EOF && limited != nil && limited.N == 0 {
+			// The budget is spent; any further byte means the input was cut short
+			var probe [1]byte
+			if n, _ := io.ReadFull(reader, probe[:]); n > 0 {
+				truncated = true
+				line = ""
 			}
 		}
 
-		// Check character after hashtag
-		validAfter := true
-		endIndex := absoluteIndex + len(hashtag)
-		if endIndex < len(content) {
-			nextChar := content[endIndex]
-			if (nextChar >= 'a' && nextChar <= 'z') || (nextChar >= 'A' && nextChar <= 'Z') ||
-				(nextChar >= '0' && nextChar <= '9') || nextChar == '-' || nextChar == '_' {
-				validAfter = false
+		text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		// A directive in a code block is shown, not used
+		if fences.fence == "" && !codeFenceOpen.MatchString(text) && hasIgnoreFileDirective(text) {
+			return nil, false, nil
+		}
+		if firstLine {
+			text = strings.TrimPrefix(text, utf8BOM)
+			firstLine = false
+			if text == "---" && readErr == nil {
+				inFrontmatter = true
+				continue
 			}
 		}
 
-		// If this occurrence is valid, return true
-		if validBefore && validAfter {
-			return true
+		if inFrontmatter {
+			if text == "---" {
+				inFrontmatter = false
+				if !s.addFrontmatterTags(strings.Join(frontmatter, "\n"), tagMap) {
+					s.addHashtags(stripIgnored("---\n"+strings.Join(frontmatter, "\n")), tagMap)
+				}
+				frontmatter = nil
+			} else {
+				frontmatter = append(frontmatter, text)
+			}
+		} else if inKanbanSettings || isKanbanSettingsStart(text) {
+			inKanbanSettings = !isKanbanSettingsEnd(text)
+		} else if !fences.inBlock(text) {
+			var segments []ignoreSegment
+			segments, ignoring = splitIgnored(text, ignoring)
+			for _, segment := range segments {
+				if !segment.ignored {
+					s.addHashtags(stripInlineCode(segment.text), tagMap)
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			break
 		}
+	}
 
-		// Move to next possible occurrence
-		start = absoluteIndex + 1
+	// An unterminated frontmatter block is ordinary content
+	if inFrontmatter {
+		s.addHashtags(stripIgnored("---\n"+strings.Join(frontmatter, "\n")), tagMap)
 	}
 
-	// No valid occurrence found
-	return false
+	if truncated && s.config.Strict {
+		return tagList(tagMap), true, ErrTruncated
+	}
+	return tagList(tagMap), truncated, nil
+}
+
+// addFrontmatterTags adds the valid tags in the YAML frontmatter block to
+// tagMap, reporting false when the block can't be parsed. Tags with
+// characters tags can't have are added as false, for droppedTags.
+func (s *FilesystemScanner) addFrontmatterTags(frontmatter string, tagMap map[string]bool) bool {
+	var data map[string]interface{}
+	if err := yaml.Unmarshal([]byte(frontmatter), &data); err != nil {
+		return false
+	}
+
+	for _, tag := range frontmatterTags(data) {
+		if s.rules.isValidTag(tag) {
+			tagMap[tag] = true
+		} else if !s.rules.hasOnlyTagRunes(tag) {
+			tagMap[tag] = false
+		}
+	}
+	return true
+}
+
+// addHashtags adds the valid inline hashtags in text to tagMap
+func (s *FilesystemScanner) addHashtags(text string, tagMap map[string]bool) {
+	for _, hashtag := range s.rules.hashtags(text) {
+		tagMap[hashtag.tag] = true
+	}
+}
+
+func tagList(tagMap map[string]bool) []string {
+	var tags []string
+	for tag, valid := range tagMap {
+		if valid {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// droppedTags returns the tags addFrontmatterTags left out of tagMap, sorted
+func droppedTags(tagMap map[string]bool) []string {
+	var dropped []string
+	for tag, valid := range tagMap {
+		if !valid {
+			dropped = append(dropped, tag)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
 }
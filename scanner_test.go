@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -77,6 +78,34 @@ This has #hashtag and #more-tags in the content.`,
 			content:  "Email user@domain.com#golang should not extract golang, but #golang should.",
 			expected: []string{"golang"},
 		},
+		{
+			name:     "UnicodeHashtags",
+			content:  "Notes on #développement and #日本語 plus #café.",
+			expected: []string{"développement", "日本語", "café"},
+		},
+		{
+			name:     "NestedTags",
+			content:  "Working on #project/alpha and #area/work/meetings today.",
+			expected: []string{"project/alpha", "area/work/meetings"},
+		},
+		{
+			name:     "NestedTagTrailingSlash",
+			content:  "See #project/ for details.",
+			expected: []string{"project"},
+		},
+		{
+			name: "NestedTagsInYAML",
+			content: `---
+tags: ["project/alpha", "status/active"]
+---
+Content here`,
+			expected: []string{"project/alpha", "status/active"},
+		},
+		{
+			name:     "UnicodeHashtagBoundary",
+			content:  "Inside a word like naïve#golang is not a tag, but #golang is.",
+			expected: []string{"golang"},
+		},
 	}
 
 	for _, test := range tests {
@@ -148,6 +177,102 @@ func TestFilesystemScannerScanDirectory(t *testing.T) {
 	}
 }
 
+func TestFSScanner(t *testing.T) {
+	fsys := fstest.MapFS{
+		"vault/file1.md":             {Data: []byte("# File 1\n#golang #programming")},
+		"vault/subdir/file2.md":      {Data: []byte("---\ntags: [python]\n---\n# File 2")},
+		"vault/file.excalidraw.md":   {Data: []byte("#diagram")},
+		"vault/100 Archive/old.md":   {Data: []byte("#archived")},
+		"vault/notes.txt":            {Data: []byte("#ignored")},
+		"elsewhere/outside-vault.md": {Data: []byte("#outside")},
+	}
+
+	scanner, err := tagmanager.NewFSScanner(fsys, tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	results := make(map[string][]string)
+	for fileInfo, err := range scanner.ScanDirectory(ctx, "vault", nil) {
+		require.NoError(t, err)
+		results[fileInfo.Path] = fileInfo.Tags
+	}
+
+	require.Len(t, results, 2)
+	assert.ElementsMatch(t, []string{"golang", "programming"}, results["vault/file1.md"])
+	assert.Equal(t, []string{"python"}, results["vault/subdir/file2.md"])
+
+	var paths []string
+	for path, err := range scanner.WalkFiles(ctx, ".", []string{"subdir"}) {
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+	assert.Equal(t, []string{"elsewhere/outside-vault.md", "vault/file1.md"}, paths)
+
+	fileInfo, err := scanner.ScanFile(ctx, "elsewhere/outside-vault.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outside"}, fileInfo.Tags)
+
+	_, err = scanner.ScanFile(ctx, "missing.md")
+	assert.Error(t, err)
+}
+
+func TestExtractTagsFromReader(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	scanner, err := tagmanager.NewFilesystemScanner(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	contents := []string{
+		"# Title\n#golang and #python\nmore #web-dev text",
+		"---\ntags: [yaml-tag, another]\n---\n#hashtag in body",
+		"\ufeff---\r\ntags:\r\n  - golang\r\n---\r\n#python\r\n",
+		"---\ntags: [incomplete\n---\n#hashtag works though",
+		"---\n#not-closed frontmatter",
+		"no trailing newline #golang",
+		strings.Repeat("a", 100*1024) + " #long-line",
+		"#golang\n<!-- tag-manager:ignore-start -->\n#python\n<!-- tag-manager:ignore-end -->\n#rust",
+		"#golang <!-- tag-manager:ignore-start -->#python\n#java<!-- tag-manager:ignore-end --> #rust",
+		"---\ntags: [golang]\n---\n#python\n<!-- tag-manager:ignore -->",
+		"#golang\n```html\n<!-- tag-manager:ignore -->\n<!-- tag-manager:ignore-start -->\n```\n#rust",
+		"#golang `<!-- tag-manager:ignore-start -->` #rust",
+	}
+
+	for _, content := range contents {
+		tags, truncated, err := scanner.ExtractTagsFromReader(ctx, strings.NewReader(content))
+		require.NoError(t, err)
+		assert.False(t, truncated)
+		assert.ElementsMatch(t, scanner.ExtractTags(content), tags)
+	}
+
+	t.Run("Budget", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.MaxReaderBytes = int64(len("#golang\n#python\n#ru"))
+		scanner, err := tagmanager.NewFilesystemScanner(config)
+		require.NoError(t, err)
+
+		tags, truncated, err := scanner.ExtractTagsFromReader(ctx, strings.NewReader("#golang\n#python\n#rust\n"))
+		require.NoError(t, err)
+		assert.True(t, truncated)
+		assert.ElementsMatch(t, []string{"golang", "python"}, tags)
+
+		tags, truncated, err = scanner.ExtractTagsFromReader(ctx, strings.NewReader("#golang\n#python\n#ru"))
+		require.NoError(t, err)
+		assert.False(t, truncated)
+		assert.ElementsMatch(t, []string{"golang", "python"}, tags)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		tags, truncated, err := scanner.ExtractTagsFromReader(ctx, strings.NewReader("#golang"))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, truncated)
+		assert.Empty(t, tags)
+	})
+}
+
 func TestFilesystemScannerEdgeCases(t *testing.T) {
 	config := tagmanager.DefaultConfig()
 	scanner, err := tagmanager.NewFilesystemScanner(config)
@@ -178,11 +303,101 @@ func TestFilesystemScannerEdgeCases(t *testing.T) {
 			content:  strings.Repeat("a", 10000) + " #long-content",
 			expected: []string{"long-content"},
 		},
+		{
+			name:     "YAMLMultiLineArray",
+			content:  "---\ntags: [\n  golang,\n  'single-quoted',\n  \"double-quoted\"\n]\n---\nContent",
+			expected: []string{"golang", "single-quoted", "double-quoted"},
+		},
+		{
+			name:     "YAMLIndentedKeyIsNotTags",
+			content:  "---\nmeta:\n  tags: [nested]\n---\nContent",
+			expected: []string{},
+		},
+		{
+			name:     "YAMLCommentIsNotHashtag",
+			content:  "---\n#comment here\ntags:\n  - golang # trailing comment\n---\nContent",
+			expected: []string{"golang"},
+		},
+		{
+			name:     "YAMLSingularTagKey",
+			content:  "---\ntag: golang\n---\nContent",
+			expected: []string{"golang"},
+		},
+		{
+			name:     "YAMLScalarStringTags",
+			content:  "---\ntags: golang, python web-dev\n---\nContent",
+			expected: []string{"golang", "python", "web-dev"},
+		},
+		{
+			name:     "YAMLQuotedCommaSeparatedString",
+			content:  "---\ntags: \"one-tag, two-tag,three-tag \"\n---\nContent",
+			expected: []string{"one-tag", "two-tag", "three-tag"},
+		},
+		{
+			name:     "YAMLCommaSeparatedListItem",
+			content:  "---\ntags:\n  - \"one-tag, two-tag\"\n  - three-tag\n---\nContent",
+			expected: []string{"one-tag", "two-tag", "three-tag"},
+		},
+		{
+			name:     "PropertiesQuotedHashTags",
+			content:  "---\ntags:\n  - \"#golang\"\n  - '#python'\n---\nContent",
+			expected: []string{"golang", "python"},
+		},
+		{
+			name:     "PropertiesLinkTags",
+			content:  "---\ntags:\n  - \"[[golang]]\"\n  - \"[[web-dev|Web]]\"\n---\nContent",
+			expected: []string{"golang", "web-dev"},
+		},
+		{
+			name:     "PropertiesEmptyTags",
+			content:  "---\ntags:\n---\n#hashtag",
+			expected: []string{"hashtag"},
+		},
+		{
+			name:     "CRLFFrontmatter",
+			content:  "---\r\ntags:\r\n  - golang\r\n---\r\n#python\r\n",
+			expected: []string{"golang", "python"},
+		},
+		{
+			name:     "BOMFrontmatter",
+			content:  "\ufeff---\ntags: [golang]\n---\nContent",
+			expected: []string{"golang"},
+		},
 		{
 			name:     "MalformedYAML",
 			content:  "---\ntags: [incomplete\n---\n#hashtag works though",
 			expected: []string{"hashtag"},
 		},
+		{
+			name:     "IgnoredRegion",
+			content:  "#golang\n<!-- tag-manager:ignore-start -->\n#python\n<!-- tag-manager:ignore-end -->\n#rust",
+			expected: []string{"golang", "rust"},
+		},
+		{
+			name:     "IgnoredInlineRegion",
+			content:  "#golang <!--tag-manager:ignore-start-->#python<!--tag-manager:ignore-end--> #rust",
+			expected: []string{"golang", "rust"},
+		},
+		{
+			name:     "UnterminatedIgnoredRegion",
+			content:  "#golang\n<!-- tag-manager:ignore-start -->\n#python",
+			expected: []string{"golang"},
+		},
+		{
+			name:     "IgnoredFile",
+			content:  "---\ntags: [golang]\n---\n<!-- tag-manager:ignore -->\n#python",
+			expected: nil,
+		},
+		{
+			name:     "DirectivesInCodeBlock",
+			content:  "#golang\n```html\n<!-- tag-manager:ignore -->\n<!-- tag-manager:ignore-start -->\n```\n#rust",
+			expected: []string{"golang", "rust"},
+		},
+		{
+			name:     "DirectiveInInlineCode",
+			content:  "#golang `<!-- tag-manager:ignore-start -->` #rust",
+			expected: []string{"golang", "rust"},
+		},
 	}
 
 	for _, test := range tests {
@@ -202,3 +417,66 @@ func TestFilesystemScannerEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestFilesystemScannerASCIIOnlyTags(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	config.UnicodeTags = false
+	scanner, err := tagmanager.NewFilesystemScanner(config)
+	require.NoError(t, err)
+
+	tags := scanner.ExtractTags("Notes on #développement and #日本語 with #golang.")
+	assert.Equal(t, []string{"golang"}, tags)
+}
+
+func TestScannerExtensions(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":   "#markdown",
+		"b.mdx":  "#mdx",
+		"c.TXT":  "#text",
+		"d.json": "#json",
+	})
+
+	tests := []struct {
+		name       string
+		extensions []string
+		expected   []string
+	}{
+		{name: "Default", expected: []string{"markdown"}},
+		{name: "Configured", extensions: []string{"md", ".mdx"}, expected: []string{"markdown", "mdx"}},
+		{name: "CaseInsensitive", extensions: []string{"txt"}, expected: []string{"text"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			if test.extensions != nil {
+				config.Extensions = test.extensions
+			}
+			manager, err := tagmanager.NewDefaultTagManager(config)
+			require.NoError(t, err)
+
+			tags, err := manager.ListAllTags(context.Background(), root, 1)
+			require.NoError(t, err)
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+			assert.ElementsMatch(t, test.expected, names)
+		})
+	}
+
+	t.Run("WithExtensions", func(t *testing.T) {
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+		tags, err := manager.WithExtensions([]string{"md", "json"}).ListAllTags(context.Background(), root, 1)
+		require.NoError(t, err)
+		assert.Len(t, tags, 2)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.Extensions = []string{"*.md"}
+		_, err := tagmanager.NewDefaultTagManager(config)
+		assert.ErrorContains(t, err, `invalid extension "*.md"`)
+	})
+}
@@ -0,0 +1,82 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileFilter reports whether a file should be scanned. relPath is relative to
+// the root being scanned and always uses forward slashes.
+type FileFilter func(relPath string) bool
+
+// PathPrefixFilter returns a filter accepting the files in the folder prefix,
+// relative to the root, such as "Inbox" or "Projects/2025"
+func PathPrefixFilter(prefix string) (FileFilter, error) {
+	cleaned := path.Clean(filepath.ToSlash(prefix))
+	if prefix == "" || path.IsAbs(cleaned) || filepath.IsAbs(prefix) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return nil, fmt.Errorf("invalid path prefix %q: must be a folder relative to the root", prefix)
+	}
+	if cleaned == "." {
+		return func(string) bool { return true }, nil
+	}
+	return func(relPath string) bool {
+		return relPath == cleaned || strings.HasPrefix(relPath, cleaned+"/")
+	}, nil
+}
+
+// fileListFilter returns a filter accepting only filePaths, which are either
+// absolute or relative to rootPath
+func fileListFilter(rootPath string, filePaths []string) FileFilter {
+	accepted := make(map[string]bool, len(filePaths))
+	for _, filePath := range filePaths {
+		if filepath.IsAbs(filePath) {
+			if relPath, err := filepath.Rel(rootPath, filePath); err == nil {
+				filePath = relPath
+			}
+		}
+		accepted[path.Clean(filepath.ToSlash(filePath))] = true
+	}
+	return func(relPath string) bool {
+		return accepted[relPath]
+	}
+}
+
+// filteredScanner limits directory scans to the files accepted by filter
+type filteredScanner struct {
+	Scanner
+	filter FileFilter
+}
+
+func (s *filteredScanner) WalkFiles(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for path, err := range s.Scanner.WalkFiles(ctx, rootPath, excludePaths) {
+			if err == nil {
+				relPath, relErr := filepath.Rel(rootPath, path)
+				if relErr == nil && !s.filter(filepath.ToSlash(relPath)) {
+					continue
+				}
+			}
+			if !yield(path, err) {
+				return
+			}
+		}
+	}
+}
+
+func (s *filteredScanner) ScanDirectory(ctx context.Context, rootPath string, excludePaths []string) iter.Seq2[FileTagInfo, error] {
+	return scanWalkedFiles(ctx, s, s.WalkFiles(ctx, rootPath, excludePaths))
+}
+
+// WithFilter returns a manager whose directory scans only visit files accepted
+// by filter. Files are still addressed by their full path, and operations on
+// explicit file lists are unaffected. Because files outside the filter are not
+// seen, the returned manager must not be used to update the index.
+func (m *DefaultTagManager) WithFilter(filter FileFilter) TagManager {
+	scoped := *m
+	scoped.scanner = &filteredScanner{Scanner: m.scanner, filter: filter}
+	return &scoped
+}
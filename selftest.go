@@ -0,0 +1,400 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultSelfTestSample is the number of files SelfTest copies by default
+	DefaultSelfTestSample = 100
+
+	// selfTestSuffix names the temporary tags SelfTest renames to and adds
+	selfTestSuffix = "-selftest"
+	selfTestTag    = "tag-manager" + selfTestSuffix
+)
+
+// SelfTestCheck is one verification made by SelfTest
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Failures describe each problem found, prefixed with the file's path
+	Failures []string `json:"failures,omitempty"`
+}
+
+// SelfTestReport is the outcome of SelfTest. Confidence is the fraction of
+// the sampled files tested, those not skipped, which passed every check.
+type SelfTestReport struct {
+	Files int `json:"files"`
+	// Skipped are the sampled files update refuses to modify, such as files
+	// with malformed frontmatter, with the reason
+	Skipped    []string        `json:"skipped,omitempty"`
+	Tag        string          `json:"tag,omitempty"`
+	Checks     []SelfTestCheck `json:"checks"`
+	Confidence float64         `json:"confidence"`
+}
+
+// Failed reports whether any check failed
+func (r *SelfTestReport) Failed() bool {
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// SelfTest copies up to sampleSize files of the vault at rootPath to a
+// temporary directory and runs replace and update there, verifying that tags
+// end up as expected, that repeating an update changes nothing, and that the
+// content other than tags is unchanged. The vault itself is only read. A
+// sampleSize of 0 copies every file.
+func (m *DefaultTagManager) SelfTest(ctx context.Context, rootPath string, sampleSize int) (*SelfTestReport, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	var files []FileTagInfo
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		files = append(files, fileInfo)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	files = sampleFiles(files, sampleSize)
+
+	tempRoot, err := os.MkdirTemp("", "tag-manager-selftest-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create self-test directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tempRoot) }()
+
+	// The copy keeps the vault's plugin list so plugin content is handled alike
+	copyPaths := []string{filepath.Join(ObsidianDir, "community-plugins.json")}
+	var relPaths []string
+	for _, file := range files {
+		relPath, err := filepath.Rel(rootPath, file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", file.Path, err)
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	for _, relPath := range append(copyPaths, relPaths...) {
+		if err := copyFile(filepath.Join(rootPath, relPath), filepath.Join(tempRoot, relPath)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to copy %s: %w", relPath, err)
+		}
+	}
+
+	// Changes to the copy are neither backed up nor journaled
+	config := *m.config
+	config.Backup = ""
+	config.Journal = false
+	manager, err := NewDefaultTagManager(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SelfTestReport{Files: len(relPaths), Checks: []SelfTestCheck{}}
+
+	// Files update refuses to touch would fail every check for a known reason
+	preview, err := manager.UpdateTags(ctx, []string{selfTestTag}, nil, tempRoot, relPaths, true)
+	if err != nil {
+		return nil, err
+	}
+	refused := make(map[string]bool)
+	for _, message := range preview.Errors {
+		relPath, _, _ := strings.Cut(message, ": ")
+		refused[relPath] = true
+		report.Skipped = append(report.Skipped, message)
+	}
+
+	var tested []string
+	var testedFiles []FileTagInfo
+	for i, relPath := range relPaths {
+		if !refused[relPath] {
+			tested = append(tested, relPath)
+			testedFiles = append(testedFiles, files[i])
+		}
+	}
+
+	st := &selfTest{ctx: ctx, manager: manager, root: tempRoot, files: tested, failed: make(map[string]bool)}
+	if err := st.snapshot(); err != nil {
+		return nil, err
+	}
+
+	report.Tag = m.mostUsedTag(testedFiles)
+	if err := st.run(report); err != nil {
+		return nil, err
+	}
+
+	if len(tested) > 0 {
+		report.Confidence = float64(len(tested)-len(st.failed)) / float64(len(tested))
+	} else {
+		report.Confidence = 1
+	}
+	return report, nil
+}
+
+// sampleFiles picks up to n files spread evenly across files
+func sampleFiles(files []FileTagInfo, n int) []FileTagInfo {
+	if n <= 0 || len(files) <= n {
+		return files
+	}
+	sample := make([]FileTagInfo, 0, n)
+	for i := 0; i < n; i++ {
+		sample = append(sample, files[i*len(files)/n])
+	}
+	return sample
+}
+
+// mostUsedTag returns the tag in the most files
+func (m *DefaultTagManager) mostUsedTag(files []FileTagInfo) string {
+	counts := make(map[string]int)
+	for _, file := range files {
+		for _, tag := range file.Tags {
+			counts[tag]++
+		}
+	}
+
+	best := ""
+	for tag, count := range counts {
+		if count > counts[best] || (count == counts[best] && tag < best) {
+			best = tag
+		}
+	}
+	return best
+}
+
+func copyFile(src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, DefaultFilePermissions)
+}
+
+// selfTest is the state of one SelfTest run on the copied files
+type selfTest struct {
+	ctx     context.Context
+	manager *DefaultTagManager
+	root    string
+	files   []string
+
+	// tags and content are each file's tags and the hash of its non-tag
+	// content as copied
+	tags    map[string][]string
+	content map[string]string
+	// failed records the files which failed any check
+	failed map[string]bool
+}
+
+func (st *selfTest) snapshot() error {
+	st.tags = make(map[string][]string)
+	st.content = make(map[string]string)
+	for _, relPath := range st.files {
+		tags, content, err := st.read(relPath)
+		if err != nil {
+			return fmt.Errorf("failed to read copy of %s: %w", relPath, err)
+		}
+		st.tags[relPath] = tags
+		st.content[relPath] = content
+	}
+	return nil
+}
+
+// read returns a copied file's tags, lower cased and sorted, and the hash of
+// its content other than tags
+func (st *selfTest) read(relPath string) ([]string, string, error) {
+	path := filepath.Join(st.root, relPath)
+	fileInfo, err := st.manager.scanner.ScanFile(st.ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := readNote(st.ctx, st.manager.config, path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tags := make([]string, 0, len(fileInfo.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range fileInfo.Tags {
+		if tag = strings.ToLower(tag); !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, hashContent([]byte(st.manager.nonTagContent(string(content)))), nil
+}
+
+// nonTagContent reduces a note to what tag operations must not change: the
+// frontmatter without its tags, and the body without hashtags, compared
+// by value and ignoring how whitespace is laid out.
+func (m *DefaultTagManager) nonTagContent(content string) string {
+	text, _ := normalizeText(content)
+	data, body, err := m.parseFrontmatter(text)
+	if err != nil {
+		return text
+	}
+	for _, key := range frontmatterTagKeys {
+		delete(data, key)
+	}
+
+	// Values are compared decoded, not as the nodes which carry positions
+	values := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if node, ok := value.(*yaml.Node); ok {
+			if err := node.Decode(&value); err != nil {
+				return text
+			}
+		}
+		values[key] = value
+	}
+
+	frontmatter, err := json.Marshal(values)
+	if err != nil {
+		return text
+	}
+	body = m.rules.hashtagPattern.ReplaceAllString(body, "")
+	return string(frontmatter) + "\n" + strings.Join(strings.Fields(body), " ")
+}
+
+func (st *selfTest) run(report *SelfTestReport) error {
+	if report.Tag != "" {
+		renamed := report.Tag + selfTestSuffix
+		rename := []TagReplacement{{OldTag: report.Tag, NewTag: renamed}}
+		if _, err := st.manager.ReplaceTagsBatch(st.ctx, rename, st.root, false); err != nil {
+			return err
+		}
+		if err := st.check(report, "replace renames the tag", func(relPath string, tags []string, content string) string {
+			if containsTag(st.tags[relPath], report.Tag) && !containsTag(tags, renamed) {
+				return fmt.Sprintf("%s not renamed to %s", report.Tag, renamed)
+			}
+			if containsTag(tags, report.Tag) {
+				return fmt.Sprintf("%s still present", report.Tag)
+			}
+			return ""
+		}); err != nil {
+			return err
+		}
+		if err := st.checkContent(report, "replace leaves other content unchanged"); err != nil {
+			return err
+		}
+
+		restore := []TagReplacement{{OldTag: renamed, NewTag: report.Tag}}
+		if _, err := st.manager.ReplaceTagsBatch(st.ctx, restore, st.root, false); err != nil {
+			return err
+		}
+		if err := st.check(report, "replacing back restores the tags", st.sameTags); err != nil {
+			return err
+		}
+	}
+
+	if _, err := st.manager.UpdateTags(st.ctx, []string{selfTestTag}, nil, st.root, st.files, false); err != nil {
+		return err
+	}
+	if err := st.check(report, "update adds the tag", func(relPath string, tags []string, content string) string {
+		if !containsTag(tags, selfTestTag) {
+			return fmt.Sprintf("%s not added", selfTestTag)
+		}
+		return ""
+	}); err != nil {
+		return err
+	}
+	if err := st.checkContent(report, "update leaves other content unchanged"); err != nil {
+		return err
+	}
+
+	before := make(map[string][]byte)
+	for _, relPath := range st.files {
+		content, err := os.ReadFile(filepath.Join(st.root, relPath))
+		if err != nil {
+			return err
+		}
+		before[relPath] = content
+	}
+	if _, err := st.manager.UpdateTags(st.ctx, []string{selfTestTag}, nil, st.root, st.files, false); err != nil {
+		return err
+	}
+	if err := st.check(report, "repeating the update changes nothing", func(relPath string, tags []string, content string) string {
+		written, err := os.ReadFile(filepath.Join(st.root, relPath))
+		if err != nil {
+			return err.Error()
+		}
+		if string(written) != string(before[relPath]) {
+			return "file changed by the repeated update"
+		}
+		return ""
+	}); err != nil {
+		return err
+	}
+
+	if _, err := st.manager.UpdateTags(st.ctx, nil, []string{selfTestTag}, st.root, st.files, false); err != nil {
+		return err
+	}
+	if err := st.check(report, "update removes the tag, restoring the tags", st.sameTags); err != nil {
+		return err
+	}
+	return st.checkContent(report, "content other than tags is unchanged at the end")
+}
+
+func (st *selfTest) sameTags(relPath string, tags []string, content string) string {
+	if strings.Join(tags, ",") != strings.Join(st.tags[relPath], ",") {
+		return fmt.Sprintf("tags are [%s], expected [%s]", strings.Join(tags, ", "), strings.Join(st.tags[relPath], ", "))
+	}
+	return ""
+}
+
+// check runs verify against every copied file with its current tags and
+// non-tag content hash, adding the outcome to report. verify returns a
+// description of the problem, or "".
+func (st *selfTest) check(report *SelfTestReport, name string, verify func(relPath string, tags []string, content string) string) error {
+	result := SelfTestCheck{Name: name, Passed: true}
+	for _, relPath := range st.files {
+		if st.ctx.Err() != nil {
+			return st.ctx.Err()
+		}
+
+		tags, content, err := st.read(relPath)
+		problem := ""
+		if err != nil {
+			problem = err.Error()
+		} else {
+			problem = verify(relPath, tags, content)
+		}
+
+		if problem != "" {
+			result.Passed = false
+			result.Failures = append(result.Failures, fmt.Sprintf("%s: %s", relPath, problem))
+			st.failed[relPath] = true
+		}
+	}
+	report.Checks = append(report.Checks, result)
+	return nil
+}
+
+func (st *selfTest) checkContent(report *SelfTestReport, name string) error {
+	return st.check(report, name, func(relPath string, tags []string, content string) string {
+		if content != st.content[relPath] {
+			return "content other than tags changed"
+		}
+		return ""
+	})
+}
@@ -0,0 +1,81 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	files := map[string]string{
+		"a.md":         "---\ntitle: \"Important\"\ndate: 2024-01-01\ntags:\n  - golang\n  - notes\n---\n# Heading\n\nBody #golang text\n",
+		"sub/b.md":     "#golang #idea\nSome text\n",
+		"c.md":         "---\ntags: [golang\n---\nbroken\n",
+		"d.md":         "plain\r\nno tags\r\n",
+		"e.md":         "---\ntags: [golang, go/tools]\n---\nSee #go/tools\n",
+		"board.md":     "- [ ] card #golang\n\n%% kanban:settings\n```\n{\"tag-colors\":[{\"tagKey\":\"#golang\"}]}\n```\n%%\n",
+		"ignored.md":   "<!-- tag-manager:ignore -->\n#golang\n",
+		"unicode.md":   "\ufeff# Café\n\n#golang and #développement\n",
+		"list-body.md": "---\ntags:\n  - golang\n---\n- golang\n",
+	}
+
+	newManager := func(t *testing.T) tagmanager.TagManager {
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+		return manager
+	}
+
+	t.Run("Passes", func(t *testing.T) {
+		root := writeVault(t, files)
+		report, err := newManager(t).SelfTest(ctx, root, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, len(files), report.Files)
+		assert.Equal(t, "golang", report.Tag)
+		require.Len(t, report.Skipped, 2)
+		assert.Contains(t, report.Skipped[0], "c.md: malformed YAML frontmatter")
+		assert.Contains(t, report.Skipped[1], "ignored.md")
+
+		require.NotEmpty(t, report.Checks)
+		for _, check := range report.Checks {
+			assert.True(t, check.Passed, "%s: %v", check.Name, check.Failures)
+		}
+		assert.False(t, report.Failed())
+		assert.Equal(t, 1.0, report.Confidence)
+
+		// The vault itself is left alone
+		for name, content := range files {
+			actual, err := os.ReadFile(filepath.Join(root, name))
+			require.NoError(t, err)
+			assert.Equal(t, content, string(actual), name)
+		}
+		assert.NoDirExists(t, filepath.Join(root, tagmanager.IndexDir))
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		root := writeVault(t, files)
+		report, err := newManager(t).SelfTest(ctx, root, 3)
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Files)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang\n", "b.md": "---\ntags: [golang]\n---\n"})
+
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd([]string{"tag-manager", "selftest", "--root=" + root}, &tagmanager.RunCmdOptions{Stdout: &stdout})
+		require.NoError(t, err)
+		assertOutputContains(t, stdout.String(), []string{
+			"Tested a copy of 2 files, renaming #golang",
+			"[PASS] repeating the update changes nothing",
+			"Confidence: 100%",
+		})
+	})
+}
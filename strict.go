@@ -0,0 +1,72 @@
+package tagmanager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrTruncated is returned by ExtractTagsFromReader in strict mode when the
+// input is longer than Config.MaxReaderBytes
+var ErrTruncated = errors.New("input exceeds max_reader_bytes")
+
+// checkFrontmatter returns why tags can't be read from content's frontmatter
+// unambiguously: the block isn't valid YAML, so it is scanned as ordinary
+// content, or a tag key holds something other than a string or a list of
+// strings, which is ignored. Strict mode fails on these instead.
+func checkFrontmatter(content string) error {
+	content, _ = normalizeText(content)
+	if hasIgnoreFileDirective(content) {
+		return nil
+	}
+	frontmatter, _, ok := splitFrontmatter(content)
+	if !ok {
+		return nil
+	}
+
+	var data map[string]interface{}
+	if err := yaml.Unmarshal([]byte(frontmatter), &data); err != nil {
+		return fmt.Errorf("frontmatter is not valid YAML: %s", yamlErrorMessage(err))
+	}
+
+	for _, key := range frontmatterTagKeys {
+		switch v := data[key].(type) {
+		case nil, string:
+		case []interface{}:
+			for _, tag := range v {
+				if _, ok := tag.(string); !ok {
+					return fmt.Errorf("frontmatter %s holds %v, which is not a tag", key, tag)
+				}
+			}
+		default:
+			return fmt.Errorf("frontmatter %s holds %v, which is neither a tag nor a list of tags", key, v)
+		}
+	}
+	return nil
+}
+
+// checkCaseCollisions returns an error naming the tags which differ only in
+// case, which Obsidian treats as one tag
+func checkCaseCollisions(tags []string) error {
+	spellings := make(map[string][]string)
+	for _, tag := range tags {
+		folded := strings.ToLower(tag)
+		spellings[folded] = append(spellings[folded], tag)
+	}
+
+	var collisions []string
+	for _, names := range spellings {
+		if len(names) > 1 {
+			sort.Strings(names)
+			collisions = append(collisions, strings.Join(names, ", "))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	sort.Strings(collisions)
+	return fmt.Errorf("tags differ only in case: %s", strings.Join(collisions, "; "))
+}
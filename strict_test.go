@@ -0,0 +1,74 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestStrictMode(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+		args  []string
+		err   string
+	}{
+		{
+			name:  "MalformedFrontmatter",
+			files: map[string]string{"a.md": "---\ntags: [golang\n---\n#body"},
+			args:  []string{"list"},
+			err:   "frontmatter",
+		},
+		{
+			name:  "FrontmatterTagsShape",
+			files: map[string]string{"a.md": "---\ntags:\n  golang: true\n---\nBody"},
+			args:  []string{"list"},
+			err:   "neither a tag nor a list of tags",
+		},
+		{
+			name:  "CaseCollision",
+			files: map[string]string{"a.md": "#Golang", "b.md": "#golang"},
+			args:  []string{"list"},
+			err:   "tags differ only in case: Golang, golang",
+		},
+		{
+			name:  "AddedAndRemoved",
+			files: map[string]string{"a.md": "---\ntags: [golang]\n---\nBody"},
+			args:  []string{"update", "--add", "golang,python", "--remove", "golang", "--files", "a.md"},
+			err:   "tag golang is both added and removed",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, test.files)
+			run := func(global ...string) error {
+				args := append([]string{"tag-manager"}, global...)
+				args = append(append(args, test.args...), "--root", root)
+				return tagmanager.RunCmd(args, &tagmanager.RunCmdOptions{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+			}
+
+			require.NoError(t, run())
+
+			err := run("--strict")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	t.Run("TruncatedReader", func(t *testing.T) {
+		config := tagmanager.DefaultConfig()
+		config.MaxReaderBytes = 8
+		config.Strict = true
+		scanner, err := tagmanager.NewFilesystemScanner(config)
+		require.NoError(t, err)
+
+		tags, truncated, err := scanner.ExtractTagsFromReader(context.Background(), strings.NewReader("#golang\nand more text"))
+		assert.ErrorIs(t, err, tagmanager.ErrTruncated)
+		assert.True(t, truncated)
+		assert.Equal(t, []string{"golang"}, tags)
+	})
+}
@@ -0,0 +1,113 @@
+package tagmanager
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Conventions for Config.TagCase
+const (
+	// TagCaseAny accepts tags in any case
+	TagCaseAny = ""
+	// TagCaseLower accepts tags without capital letters
+	TagCaseLower = "lowercase"
+	// TagCaseKebab accepts lowercase words separated by hyphens: web-dev
+	TagCaseKebab = "kebab-case"
+	// TagCaseSnake accepts lowercase words separated by underscores: web_dev
+	TagCaseSnake = "snake_case"
+	// TagCaseCamel accepts words run together, each after the first
+	// capitalized: webDev
+	TagCaseCamel = "camelCase"
+)
+
+func validateTagCase(convention string) error {
+	switch convention {
+	case TagCaseAny, TagCaseLower, TagCaseKebab, TagCaseSnake, TagCaseCamel:
+		return nil
+	}
+	return fmt.Errorf("invalid tag case %q: must be %s, %s, %s or %s",
+		convention, TagCaseLower, TagCaseKebab, TagCaseSnake, TagCaseCamel)
+}
+
+// followsTagCase reports whether every segment of a nested tag follows
+// convention
+func followsTagCase(tag, convention string) bool {
+	hasUpper := strings.IndexFunc(tag, unicode.IsUpper) >= 0
+	switch convention {
+	case TagCaseLower:
+		return !hasUpper
+	case TagCaseKebab:
+		return !hasUpper && !strings.Contains(tag, "_")
+	case TagCaseSnake:
+		return !hasUpper && !strings.Contains(tag, "-")
+	case TagCaseCamel:
+		if strings.ContainsAny(tag, "-_") {
+			return false
+		}
+		for _, segment := range strings.Split(tag, "/") {
+			if first, _ := utf8.DecodeRuneInString(segment); unicode.IsUpper(first) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// convertTagCase returns tag with each segment of a nested tag rewritten in
+// convention. Words are split at hyphens, underscores and where a capital
+// letter follows a lowercase one.
+func convertTagCase(tag, convention string) string {
+	if convention == TagCaseLower {
+		return strings.ToLower(tag)
+	}
+
+	segments := strings.Split(tag, "/")
+	for i, segment := range segments {
+		words := tagWords(segment)
+		for j, word := range words {
+			word = strings.ToLower(word)
+			if convention == TagCaseCamel && j > 0 {
+				first, size := utf8.DecodeRuneInString(word)
+				word = string(unicode.ToUpper(first)) + word[size:]
+			}
+			words[j] = word
+		}
+		switch convention {
+		case TagCaseKebab:
+			segments[i] = strings.Join(words, "-")
+		case TagCaseSnake:
+			segments[i] = strings.Join(words, "_")
+		case TagCaseCamel:
+			segments[i] = strings.Join(words, "")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// tagWords splits a tag segment into its words
+func tagWords(segment string) []string {
+	var words []string
+	var word []rune
+	previous := rune(0)
+	for _, r := range segment {
+		switch {
+		case r == '-' || r == '_':
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+		case unicode.IsUpper(r) && unicode.IsLower(previous):
+			words = append(words, string(word))
+			word = []rune{r}
+		default:
+			word = append(word, r)
+		}
+		previous = r
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
@@ -0,0 +1,46 @@
+package tagmanager_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTagCase(t *testing.T) {
+	for _, test := range []struct {
+		convention string
+		tag        string
+		suggested  string
+	}{
+		{convention: tagmanager.TagCaseAny, tag: "Web_Dev"},
+		{convention: tagmanager.TagCaseLower, tag: "web-dev"},
+		{convention: tagmanager.TagCaseLower, tag: "Web-Dev", suggested: "web-dev"},
+		{convention: tagmanager.TagCaseKebab, tag: "project/web-dev"},
+		{convention: tagmanager.TagCaseKebab, tag: "project/web_dev", suggested: "project/web-dev"},
+		{convention: tagmanager.TagCaseKebab, tag: "Project/webDev", suggested: "project/web-dev"},
+		{convention: tagmanager.TagCaseSnake, tag: "web_dev"},
+		{convention: tagmanager.TagCaseSnake, tag: "web-dev", suggested: "web_dev"},
+		{convention: tagmanager.TagCaseCamel, tag: "project/webDev"},
+		{convention: tagmanager.TagCaseCamel, tag: "Project/web-dev", suggested: "project/webDev"},
+		{convention: tagmanager.TagCaseCamel, tag: "WebDev", suggested: "webDev"},
+	} {
+		t.Run(test.convention+"/"+test.tag, func(t *testing.T) {
+			config := tagmanager.DefaultConfig()
+			config.TagCase = test.convention
+			result := tagmanager.NewDefaultValidator(config).ValidateTag(test.tag)
+
+			if test.suggested == "" {
+				assert.True(t, result.IsValid, result.Issues)
+				return
+			}
+			assert.False(t, result.IsValid)
+			assert.Contains(t, result.Issues, "Tag doesn't follow the "+test.convention+" convention")
+			assert.Contains(t, result.Suggestions, "Suggested: "+test.suggested)
+		})
+	}
+
+	config := tagmanager.DefaultConfig()
+	config.TagCase = "PascalCase"
+	assert.ErrorContains(t, tagmanager.ValidateConfig(config), `invalid tag case "PascalCase"`)
+}
@@ -0,0 +1,61 @@
+package tagmanager
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Fields tag listings can be sorted by. Count is the number of files a tag is
+// in, so TagSortCount and TagSortFiles order tags the same way.
+const (
+	TagSortName  = "name"
+	TagSortCount = "count"
+	TagSortFiles = "files"
+)
+
+// SortTags sorts tags by the field named by, ascending or descending as order
+// says. An empty order sorts names ascending and counts descending. Ties are
+// broken by name. Pinned tags stay first, as ListAllTags puts them, sorted
+// among themselves the same way.
+func SortTags(tags []TagInfo, by, order string) error {
+	var compare func(a, b TagInfo) int
+	descending := false
+	switch by {
+	case TagSortName:
+		compare = func(a, b TagInfo) int { return cmp.Compare(a.Name, b.Name) }
+	case TagSortCount, TagSortFiles:
+		compare = func(a, b TagInfo) int { return cmp.Compare(a.Count, b.Count) }
+		descending = true
+	default:
+		return fmt.Errorf("invalid sort %q: must be %s, %s or %s", by, TagSortName, TagSortCount, TagSortFiles)
+	}
+
+	switch order {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return fmt.Errorf("invalid order %q: must be asc or desc", order)
+	}
+
+	slices.SortStableFunc(tags, func(a, b TagInfo) int {
+		if a.Pinned != b.Pinned {
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		}
+		c := compare(a, b)
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			return cmp.Compare(a.Name, b.Name)
+		}
+		return c
+	})
+	return nil
+}
@@ -0,0 +1,72 @@
+package tagmanager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestSortTags(t *testing.T) {
+	tags := func() []tagmanager.TagInfo {
+		return []tagmanager.TagInfo{
+			{Name: "pinned", Count: 0, Pinned: true},
+			{Name: "aardvark", Count: 2, Pinned: true},
+			{Name: "gamma", Count: 3},
+			{Name: "beta", Count: 1},
+			{Name: "alpha", Count: 1},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		by       string
+		order    string
+		expected []string
+	}{
+		// Pinned tags stay first, sorted among themselves
+		{name: "Name", by: tagmanager.TagSortName, expected: []string{"aardvark", "pinned", "alpha", "beta", "gamma"}},
+		{name: "NameDesc", by: tagmanager.TagSortName, order: "desc", expected: []string{"pinned", "aardvark", "gamma", "beta", "alpha"}},
+		{name: "Count", by: tagmanager.TagSortCount, expected: []string{"aardvark", "pinned", "gamma", "alpha", "beta"}},
+		{name: "CountAsc", by: tagmanager.TagSortCount, order: "asc", expected: []string{"pinned", "aardvark", "alpha", "beta", "gamma"}},
+		{name: "Files", by: tagmanager.TagSortFiles, expected: []string{"aardvark", "pinned", "gamma", "alpha", "beta"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted := tags()
+			require.NoError(t, tagmanager.SortTags(sorted, test.by, test.order))
+			var names []string
+			for _, tag := range sorted {
+				names = append(names, tag.Name)
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.EqualError(t, tagmanager.SortTags(tags(), "size", ""), `invalid sort "size": must be name, count or files`)
+		assert.EqualError(t, tagmanager.SortTags(tags(), "name", "up"), `invalid order "up": must be asc or desc`)
+	})
+
+	t.Run("ListAllTagsTool", func(t *testing.T) {
+		root := writeVault(t, map[string]string{"a.md": "#golang #python", "b.md": "#golang"})
+		manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+		require.NoError(t, err)
+
+		_, data, err := tagmanager.ListAllTagsTool(context.Background(), &mcp.CallToolRequest{},
+			tagmanager.ListAllTagsParams{Root: root, Sort: "name", Order: "desc"}, manager)
+		require.NoError(t, err)
+		result := data.([]tagmanager.TagInfo)
+		require.Len(t, result, 2)
+		assert.Equal(t, "python", result[0].Name)
+		assert.Equal(t, "golang", result[1].Name)
+
+		_, _, err = tagmanager.ListAllTagsTool(context.Background(), &mcp.CallToolRequest{},
+			tagmanager.ListAllTagsParams{Root: root, Order: "asc"}, manager)
+		assert.EqualError(t, err, "order requires sort")
+	})
+}
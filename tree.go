@@ -0,0 +1,94 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagNode is a segment of the tag hierarchy, such as alpha in project/alpha
+type TagNode struct {
+	Name string `json:"name"`
+	// Tag is the full tag the node stands for, such as project/alpha
+	Tag string `json:"tag"`
+	// Count is how many files have exactly Tag, and Total how many have Tag
+	// or a tag nested beneath it; a file with several counts once
+	Count    int       `json:"count"`
+	Total    int       `json:"total"`
+	Children []TagNode `json:"children,omitempty"`
+}
+
+// TagTree returns the tags under rootPath as a tree of their '/' separated
+// segments, each level sorted by name. Nodes for namespaces which aren't
+// themselves used as tags have a Count of 0.
+func (m *DefaultTagManager) TagTree(ctx context.Context, rootPath string) ([]TagNode, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	counts := make(map[string]int)
+	totals := make(map[string]int)
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		tags := make(map[string]bool)
+		ancestors := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			normalized := strings.Trim(m.normalizeTag(tag), "/")
+			if normalized == "" {
+				continue
+			}
+			tags[normalized] = true
+			for i, c := range normalized {
+				if c == '/' {
+					ancestors[normalized[:i]] = true
+				}
+			}
+			ancestors[normalized] = true
+		}
+		for tag := range tags {
+			counts[tag]++
+		}
+		for tag := range ancestors {
+			totals[tag]++
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	children := make(map[string][]string)
+	for tag := range totals {
+		parent := ""
+		if i := strings.LastIndex(tag, "/"); i >= 0 {
+			parent = tag[:i]
+		}
+		children[parent] = append(children[parent], tag)
+	}
+	return tagNodes("", children, totals, counts), nil
+}
+
+// tagNodes returns the nodes directly beneath the tag parent, or the top
+// level when parent is empty
+func tagNodes(parent string, children map[string][]string, totals, counts map[string]int) []TagNode {
+	nodes := make([]TagNode, 0, len(children[parent]))
+	for _, tag := range children[parent] {
+		nodes = append(nodes, TagNode{
+			Name:     tag[strings.LastIndex(tag, "/")+1:],
+			Tag:      tag,
+			Count:    counts[tag],
+			Total:    totals[tag],
+			Children: tagNodes(tag, children, totals, counts),
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
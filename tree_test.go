@@ -0,0 +1,112 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTagTree(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md": "#project #project/alpha/spec",
+		"b.md": "#project/alpha #project/beta",
+		"c.md": "#project/alpha #area/health",
+		"d.md": "#golang",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	nodes, err := manager.TagTree(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, []tagmanager.TagNode{
+		{Name: "area", Tag: "area", Count: 0, Total: 1, Children: []tagmanager.TagNode{
+			{Name: "health", Tag: "area/health", Count: 1, Total: 1, Children: []tagmanager.TagNode{}},
+		}},
+		{Name: "golang", Tag: "golang", Count: 1, Total: 1, Children: []tagmanager.TagNode{}},
+		// a.md has both project and a nested tag, but counts once in the total
+		{Name: "project", Tag: "project", Count: 1, Total: 3, Children: []tagmanager.TagNode{
+			{Name: "alpha", Tag: "project/alpha", Count: 2, Total: 3, Children: []tagmanager.TagNode{
+				{Name: "spec", Tag: "project/alpha/spec", Count: 1, Total: 1, Children: []tagmanager.TagNode{}},
+			}},
+			{Name: "beta", Tag: "project/beta", Count: 1, Total: 1, Children: []tagmanager.TagNode{}},
+		}},
+	}, nodes)
+
+	t.Run("Empty", func(t *testing.T) {
+		nodes, err := manager.TagTree(context.Background(), writeVault(t, map[string]string{"a.md": "# Untagged"}))
+		require.NoError(t, err)
+		assert.Empty(t, nodes)
+	})
+}
+
+func TestTreeCommand(t *testing.T) {
+	run := func(t *testing.T, args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		return stdout.String(), err
+	}
+	root := writeVault(t, map[string]string{
+		"a.md": "#project #project/alpha/spec",
+		"b.md": "#project/alpha #project/beta",
+		"c.md": "#golang",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "Text",
+			args: []string{"tree"},
+			expected: "Found 2 top-level tags:\n" +
+				"#golang 1 files\n" +
+				"#project 1 files, 2 with nested tags\n" +
+				"├── alpha 1 files, 2 with nested tags\n" +
+				"│   └── spec 1 files\n" +
+				"└── beta 1 files\n",
+		},
+		{
+			name: "Depth",
+			args: []string{"tree", "--depth", "2"},
+			expected: "Found 2 top-level tags:\n" +
+				"#golang 1 files\n" +
+				"#project 1 files, 2 with nested tags\n" +
+				"├── alpha 1 files, 2 with nested tags\n" +
+				"└── beta 1 files\n",
+		},
+		{
+			name: "CSV",
+			args: []string{"tree", "--format", "csv"},
+			expected: "tag,count,total\n" +
+				"golang,1,1\n" +
+				"project,1,2\n" +
+				"project/alpha,1,2\n" +
+				"project/alpha/spec,1,1\n" +
+				"project/beta,1,1\n",
+		},
+		{
+			name:     "JSON",
+			args:     []string{"tree", "--json", "--depth", "1"},
+			expected: `[{"name":"golang","tag":"golang","count":1,"total":1},{"name":"project","tag":"project","count":1,"total":2}]` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, append(test.args, "--root", root)...)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, output)
+		})
+	}
+
+	t.Run("InvalidDepth", func(t *testing.T) {
+		_, err := run(t, "tree", "--depth", "-1", "--root", root)
+		assert.EqualError(t, err, "invalid depth -1: must be 0 or more")
+	})
+}
@@ -0,0 +1,213 @@
+package tagmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	// TriageFileName records triage decisions inside IndexDir so a session can be resumed
+	TriageFileName = "triage.json"
+
+	triagePreviewLines    = 5
+	triageMaxSuggestions  = 5
+	triageMaxPreviewWidth = 100
+)
+
+// Triage decisions recorded in a TriageSession
+const (
+	TriageTagged  = "tagged"
+	TriageSkipped = "skipped"
+	TriageEdited  = "edited"
+)
+
+// TriageItem describes an untagged file and the existing vault tags which
+// appear to fit it.
+type TriageItem struct {
+	Path        string   `json:"path"`
+	Title       string   `json:"title"`
+	Preview     string   `json:"preview"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// TriageSession is the persisted record of decisions made while triaging a vault
+type TriageSession struct {
+	path      string
+	perm      filePerm
+	Decisions map[string]string `json:"decisions"`
+}
+
+// TriageUntagged returns every untagged file under rootPath with its title, a
+// short preview and suggested tags. Suggestions are existing vault tags whose
+// name, or last nested segment, appears as a word in the file; the most used
+// tags are suggested first. Paths are relative to rootPath.
+func (m *DefaultTagManager) TriageUntagged(ctx context.Context, rootPath string) ([]TriageItem, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	untagged, err := m.GetUntaggedFiles(ctx, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	known, err := m.ListAllTags(ctx, rootPath, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []TriageItem
+	for _, fileInfo := range untagged {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		content, err := readNote(ctx, m.config, fileInfo.Path)
+		if err != nil {
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootPath, fileInfo.Path)
+		if err != nil {
+			relPath = fileInfo.Path
+		}
+
+		text, _ := normalizeText(string(content))
+		if _, body, ok := splitFrontmatter(text); ok {
+			text = body
+		}
+
+		items = append(items, TriageItem{
+			Path:        relPath,
+			Title:       noteTitle(relPath, text),
+			Preview:     notePreview(text),
+			Suggestions: suggestTags(text, known),
+		})
+	}
+
+	return items, nil
+}
+
+// noteTitle returns the first markdown heading of body, or the file name
+func noteTitle(path, body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		}
+	}
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func notePreview(body string) string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if len([]rune(line)) > triageMaxPreviewWidth {
+			line = string([]rune(line)[:triageMaxPreviewWidth]) + "…"
+		}
+		lines = append(lines, line)
+		if len(lines) == triagePreviewLines {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// suggestTags returns the known tags mentioned as words in body. known must be
+// ordered by preference, as returned by ListAllTags.
+func suggestTags(body string, known []TagInfo) []string {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' && r != '_'
+	}) {
+		words[word] = true
+	}
+
+	var suggestions []string
+	for _, tag := range known {
+		name := strings.ToLower(tag.Name)
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if !words[name] {
+			continue
+		}
+		suggestions = append(suggestions, tag.Name)
+		if len(suggestions) == triageMaxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// LoadTriageSession reads the triage decisions recorded for the vault at
+// rootPath. A vault which has not been triaged has an empty session.
+func LoadTriageSession(rootPath string) (*TriageSession, error) {
+	session := &TriageSession{
+		path:      filepath.Join(rootPath, IndexDir, TriageFileName),
+		Decisions: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(session.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return session, nil
+		}
+		return nil, fmt.Errorf("failed to read triage session: %w", err)
+	}
+
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to parse triage session: %w", err)
+	}
+	if session.Decisions == nil {
+		session.Decisions = make(map[string]string)
+	}
+	return session, nil
+}
+
+// Decided reports whether a decision has been recorded for path
+func (s *TriageSession) Decided(path string) bool {
+	_, ok := s.Decisions[path]
+	return ok
+}
+
+// Record stores the decision for path and saves the session, so an
+// interrupted triage resumes after the last file handled.
+func (s *TriageSession) Record(path, decision string) error {
+	s.Decisions[path] = decision
+	return s.Save()
+}
+
+func (s *TriageSession) Save() error {
+	if err := s.perm.mkdirAll(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("failed to create triage directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode triage session: %w", err)
+	}
+
+	if err := s.perm.writeFile(s.path, data); err != nil {
+		return fmt.Errorf("failed to write triage session: %w", err)
+	}
+	return nil
+}
+
+// Reset discards every recorded decision
+func (s *TriageSession) Reset() error {
+	s.Decisions = make(map[string]string)
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reset triage session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tuiMode is what the keys of the tui command act on
+type tuiMode int
+
+const (
+	tuiTags tuiMode = iota
+	tuiSearch
+	tuiFiles
+	tuiRename
+	tuiMerge
+	tuiConfirm
+)
+
+const tuiTagsHelp = "↑/↓ move  enter files  / search  r rename  m merge  q quit"
+
+const tuiFilesHelp = "↑/↓ move  esc back  q quit"
+
+// tuiModel is the vault browser of the tui command. It lists the tags with
+// their counts, drills into the files of a tag, and renames or merges tags
+// with ReplaceTagsBatch.
+type tuiModel struct {
+	ctx     context.Context
+	manager TagManager
+	root    string
+	dryRun  bool
+	color   palette
+
+	tags []TagInfo
+	// matches are the indexes in tags of the tags matching search, best first
+	matches []int
+	search  string
+	cursor  int
+	height  int
+
+	files      []string
+	fileCursor int
+
+	mode tuiMode
+	// confirming is the mode, rename or merge, whose target is being confirmed
+	confirming tuiMode
+	input      string
+	target     string
+	status     string
+	// busy is set while a rename or merge runs, quitting once it finishes
+	busy     bool
+	quitting bool
+}
+
+// tuiReplacedMsg reports a finished rename or merge, with the tags listed
+// again after it
+type tuiReplacedMsg struct {
+	mode        tuiMode
+	replacement TagReplacement
+	result      *TagReplaceResult
+	tags        []TagInfo
+	err         error
+}
+
+func newTUIModel(ctx context.Context, manager TagManager, root string, dryRun bool, color palette, tags []TagInfo) tuiModel {
+	m := tuiModel{ctx: ctx, manager: manager, root: root, dryRun: dryRun, color: color, tags: tags}
+	m.filter()
+	return m
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tuiReplacedMsg:
+		m.busy = false
+		m.status = m.replaced(msg)
+		if msg.tags != nil {
+			m.tags = msg.tags
+			m.filter()
+		}
+		if m.quitting {
+			return m, tea.Quit
+		}
+	case tea.KeyMsg:
+		if m.busy {
+			// Quitting mid-way would leave the vault half renamed
+			if msg.Type == tea.KeyCtrlC || msg.String() == "q" {
+				m.quitting = true
+			}
+			return m, nil
+		}
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if msg.Type == tea.KeyRunes && len(msg.Runes) > 1 && !msg.Paste {
+			return m.splitKeys(msg.Runes)
+		}
+
+		switch m.mode {
+		case tuiSearch:
+			m.searchKey(msg)
+		case tuiFiles:
+			return m.filesKey(msg)
+		case tuiRename, tuiMerge:
+			m.inputKey(msg)
+		case tuiConfirm:
+			return m.confirmKey(msg)
+		default:
+			return m.tagsKey(msg)
+		}
+	}
+	return m, nil
+}
+
+// splitKeys handles keys typed faster than they were read, which arrive
+// together, one at a time
+func (m tuiModel) splitKeys(runes []rune) (tea.Model, tea.Cmd) {
+	var model tea.Model = m
+	var cmds []tea.Cmd
+	for _, r := range runes {
+		var cmd tea.Cmd
+		model, cmd = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		cmds = append(cmds, cmd)
+	}
+	return model, tea.Sequence(cmds...)
+}
+
+func (m tuiModel) tagsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup":
+		m.cursor -= m.pageSize()
+	case "pgdown":
+		m.cursor += m.pageSize()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.matches) - 1
+	case "/":
+		m.mode = tuiSearch
+	case "esc":
+		m.search = ""
+		m.filter()
+	case "enter", "right", "l":
+		if tag, ok := m.selected(); ok {
+			m.files = tag.Files
+			m.fileCursor = 0
+			m.mode = tuiFiles
+		}
+	case "r":
+		if _, ok := m.selected(); ok {
+			m.mode, m.input = tuiRename, ""
+		}
+	case "m":
+		if _, ok := m.selected(); ok {
+			m.mode, m.input = tuiMerge, ""
+		}
+	}
+	m.cursor = max(0, min(m.cursor, len(m.matches)-1))
+	return m, nil
+}
+
+func (m *tuiModel) searchKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.mode = tuiTags
+	case tea.KeyEsc:
+		m.search = ""
+		m.mode = tuiTags
+	case tea.KeyBackspace:
+		m.search = dropLastRune(m.search)
+	case tea.KeyRunes, tea.KeySpace:
+		m.search += string(msg.Runes)
+	}
+	m.filter()
+}
+
+func (m tuiModel) filesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.fileCursor--
+	case "down", "j":
+		m.fileCursor++
+	case "pgup":
+		m.fileCursor -= m.pageSize()
+	case "pgdown":
+		m.fileCursor += m.pageSize()
+	case "esc", "left", "h", "backspace":
+		m.mode = tuiTags
+	}
+	m.fileCursor = max(0, min(m.fileCursor, len(m.files)-1))
+	return m, nil
+}
+
+// inputKey edits the target of a rename or merge. Renaming to an existing tag
+// or merging into a missing one is refused, as it's likely a typo.
+func (m *tuiModel) inputKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.mode = tuiTags
+	case tea.KeyBackspace:
+		m.input = dropLastRune(m.input)
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	case tea.KeyEnter:
+		source, _ := m.selected()
+		target := strings.TrimPrefix(strings.TrimSpace(m.input), "#")
+		_, exists := m.find(target)
+		switch {
+		case target == "" || strings.EqualFold(target, source.Name):
+			m.mode = tuiTags
+		case m.mode == tuiRename && exists:
+			m.status = fmt.Sprintf("#%s already exists, merge into it with m", target)
+			m.mode = tuiTags
+		case m.mode == tuiMerge && !exists:
+			m.status = fmt.Sprintf("#%s isn't a tag in the vault, rename to it with r", target)
+			m.mode = tuiTags
+		default:
+			m.confirming, m.target, m.mode = m.mode, target, tuiConfirm
+		}
+	}
+}
+
+func (m tuiModel) confirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.mode = tuiTags
+	if msg.String() != "y" {
+		m.status = "Cancelled"
+		return m, nil
+	}
+	source, _ := m.selected()
+	m.busy = true
+	return m, m.replace(m.confirming, TagReplacement{OldTag: source.Name, NewTag: m.target})
+}
+
+// replace runs a rename or merge, listing the tags again once it's done
+func (m tuiModel) replace(mode tuiMode, replacement TagReplacement) tea.Cmd {
+	return func() tea.Msg {
+		msg := tuiReplacedMsg{mode: mode, replacement: replacement}
+		msg.result, msg.err = m.manager.ReplaceTagsBatch(m.ctx, []TagReplacement{replacement}, m.root, m.dryRun)
+		if msg.err == nil && !m.dryRun {
+			msg.tags, msg.err = m.manager.ListAllTags(m.ctx, m.root, 1)
+		}
+		return msg
+	}
+}
+
+// replaced describes a finished rename or merge for the status line
+func (m tuiModel) replaced(msg tuiReplacedMsg) string {
+	verb, done, joiner := "rename", "Renamed", "to"
+	if msg.mode == tuiMerge {
+		verb, done, joiner = "merge", "Merged", "into"
+	}
+	if msg.err != nil {
+		return m.color.fail(fmt.Sprintf("Failed to %s #%s: %v", verb, msg.replacement.OldTag, msg.err))
+	}
+
+	modified := len(msg.result.ModifiedFiles)
+	if m.dryRun {
+		return fmt.Sprintf("Would %s #%s %s #%s in %d files (dry run)", verb, msg.replacement.OldTag, joiner, msg.replacement.NewTag, modified)
+	}
+	status := fmt.Sprintf("%s #%s %s #%s in %d files", done, msg.replacement.OldTag, joiner, msg.replacement.NewTag, modified)
+	if failed := len(msg.result.FailedFiles); failed > 0 {
+		status += ", " + m.color.fail(fmt.Sprintf("%d failed", failed))
+	}
+	if msg.result.Operation != "" {
+		status += fmt.Sprintf(" (tag-manager undo --op-id=%s)", msg.result.Operation)
+	}
+	return status
+}
+
+// filter matches the tags against search, moving the cursor to the best match
+func (m *tuiModel) filter() {
+	type match struct{ index, score int }
+	var matches []match
+	for i, tag := range m.tags {
+		if score, ok := fuzzyScore(m.search, tag.Name); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	m.matches = make([]int, len(matches))
+	for i, match := range matches {
+		m.matches[i] = match.index
+	}
+	m.cursor = 0
+}
+
+// fuzzyScore reports whether the runes of pattern appear in s in order,
+// ignoring case, and how far apart they are, lower being a closer match
+func fuzzyScore(pattern, s string) (int, bool) {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	score, pos := 0, 0
+	for _, r := range pattern {
+		i := strings.IndexRune(s[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		score += i
+		pos += i + utf8.RuneLen(r)
+	}
+	return score, true
+}
+
+func (m tuiModel) selected() (TagInfo, bool) {
+	if m.cursor >= len(m.matches) {
+		return TagInfo{}, false
+	}
+	return m.tags[m.matches[m.cursor]], true
+}
+
+// find returns the tag named name, ignoring case
+func (m tuiModel) find(name string) (TagInfo, bool) {
+	for _, tag := range m.tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag, true
+		}
+	}
+	return TagInfo{}, false
+}
+
+// pageSize is the number of rows which fit between the header and footer
+func (m tuiModel) pageSize() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(1, m.height-5)
+}
+
+func (m tuiModel) View() string {
+	if m.mode == tuiFiles {
+		return m.filesView()
+	}
+
+	var b strings.Builder
+	header := fmt.Sprintf("Tags in %s: %s", m.root, m.color.count(fmt.Sprint(len(m.tags))))
+	if m.dryRun {
+		header += " (dry run)"
+	}
+	b.WriteString(header + "\n")
+	switch {
+	case m.mode == tuiSearch:
+		fmt.Fprintf(&b, "Search: %s█\n", m.search)
+	case m.search != "":
+		fmt.Fprintf(&b, "Search: %s (%d matches, esc clears)\n", m.search, len(m.matches))
+	default:
+		b.WriteString("\n")
+	}
+
+	width := 0
+	for _, index := range m.matches {
+		width = max(width, utf8.RuneCountInString(m.tags[index].Name))
+	}
+	start, end := window(m.cursor, len(m.matches), m.pageSize())
+	for i := start; i < end; i++ {
+		tag := m.tags[m.matches[i]]
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		name := "#" + tag.Name + strings.Repeat(" ", width-utf8.RuneCountInString(tag.Name))
+		fmt.Fprintf(&b, "%s%s  %s\n", cursor, m.color.tag(name), m.color.count(fmt.Sprint(tag.Count)))
+	}
+	if len(m.matches) == 0 {
+		b.WriteString("  No tags\n")
+	}
+
+	source, _ := m.selected()
+	switch m.mode {
+	case tuiRename:
+		fmt.Fprintf(&b, "\nRename #%s to: %s█\n", source.Name, m.input)
+	case tuiMerge:
+		fmt.Fprintf(&b, "\nMerge #%s into: %s█\n", source.Name, m.input)
+	case tuiConfirm:
+		verb, joiner := "Rename", "to"
+		if m.confirming == tuiMerge {
+			verb, joiner = "Merge", "into"
+		}
+		fmt.Fprintf(&b, "\n%s #%s %s #%s in %d files? (y/n)\n", verb, source.Name, joiner, m.target, source.Count)
+	default:
+		if m.busy {
+			fmt.Fprintf(&b, "\nWorking...\n")
+		} else {
+			fmt.Fprintf(&b, "\n%s\n", m.status)
+		}
+	}
+	b.WriteString(tuiTagsHelp)
+	return b.String()
+}
+
+func (m tuiModel) filesView() string {
+	var b strings.Builder
+	source, _ := m.selected()
+	fmt.Fprintf(&b, "%s: %s files\n\n", m.color.tag("#"+source.Name), m.color.count(fmt.Sprint(len(m.files))))
+
+	start, end := window(m.fileCursor, len(m.files), m.pageSize())
+	for i := start; i < end; i++ {
+		cursor := "  "
+		if i == m.fileCursor {
+			cursor = "> "
+		}
+		path := m.files[i]
+		if rel, err := filepath.Rel(m.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		fmt.Fprintf(&b, "%s%s\n", cursor, path)
+	}
+	fmt.Fprintf(&b, "\n%s", tuiFilesHelp)
+	return b.String()
+}
+
+// window returns the rows to show of n so the cursor stays on screen
+func window(cursor, n, size int) (int, int) {
+	start := max(0, cursor-size+1)
+	return start, min(n, start+size)
+}
+
+func dropLastRune(s string) string {
+	_, size := utf8.DecodeLastRuneInString(s)
+	return s[:len(s)-size]
+}
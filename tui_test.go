@@ -0,0 +1,92 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestTUI(t *testing.T) {
+	// Tags are listed by count, so golang is first and python second
+	files := map[string]string{
+		"a.md": "---\ntags: [golang, python]\n---\nBody\n",
+		"b.md": "Notes on #golang",
+	}
+
+	run := func(t *testing.T, root string, keys string, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager", "tui", "--root", root}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}, Stdin: strings.NewReader(keys)})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+
+	tests := []struct {
+		name string
+		keys string
+		args []string
+		// expected are the tags of each file afterwards
+		expected map[string][]string
+	}{
+		{
+			name:     "Rename",
+			keys:     "rprogramming\ryq",
+			expected: map[string][]string{"a.md": {"programming", "python"}, "b.md": {"programming"}},
+		},
+		{
+			name:     "Merge",
+			keys:     "jmgolang\ryq",
+			expected: map[string][]string{"a.md": {"golang"}, "b.md": {"golang"}},
+		},
+		{
+			name:     "DryRun",
+			keys:     "rprogramming\ryq",
+			args:     []string{"--dry-run"},
+			expected: map[string][]string{"a.md": {"golang", "python"}, "b.md": {"golang"}},
+		},
+		{
+			name:     "Cancelled",
+			keys:     "rprogramming\rnq",
+			expected: map[string][]string{"a.md": {"golang", "python"}, "b.md": {"golang"}},
+		},
+		{
+			name:     "RenameToExistingTag",
+			keys:     "rpython\rq",
+			expected: map[string][]string{"a.md": {"golang", "python"}, "b.md": {"golang"}},
+		},
+		{
+			name:     "MergeIntoMissingTag",
+			keys:     "mrust\rq",
+			expected: map[string][]string{"a.md": {"golang", "python"}, "b.md": {"golang"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := writeVault(t, files)
+			run(t, root, test.keys, test.args...)
+
+			manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+			require.NoError(t, err)
+			for name, expected := range test.expected {
+				tags, err := manager.GetFilesTags(context.Background(), []string{filepath.Join(root, name)})
+				require.NoError(t, err)
+				require.Len(t, tags, 1)
+				assert.ElementsMatch(t, expected, tags[0].Tags, name)
+			}
+		})
+	}
+
+	t.Run("SearchAndDrillDown", func(t *testing.T) {
+		root := writeVault(t, files)
+		output := run(t, root, "/pyt\r\rq")
+		assert.Contains(t, output, "#python: 1 files")
+		assert.Contains(t, output, "> a.md")
+	})
+}
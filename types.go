@@ -1,9 +1,12 @@
 package tagmanager
 
 type TagInfo struct {
-	Name  string   `json:"name"`
-	Count int      `json:"count"`
-	Files []string `json:"files"`
+	Name   string   `json:"name"`
+	Count  int      `json:"count"`
+	Files  []string `json:"files"`
+	Pinned bool     `json:"pinned,omitempty"`
+	// Metadata is the display metadata configured for the tag
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type FileTagInfo struct {
@@ -15,6 +18,21 @@ type TagReplaceResult struct {
 	ModifiedFiles []string `json:"modified_files"`
 	FailedFiles   []string `json:"failed_files,omitempty"`
 	Errors        []string `json:"errors,omitempty"`
+	// Diffs are the changes a dry run would make to each file
+	Diffs []PlannedChange `json:"diffs,omitempty"`
+	// Preflight are the files PreflightSkip left alone
+	Preflight []PreflightProblem `json:"preflight,omitempty"`
+	// Backup is the backup tree holding the files as they were before
+	Backup string `json:"backup,omitempty"`
+	// Operation is the journal id of the changes, which undo takes
+	Operation string `json:"operation,omitempty"`
+	// Stopped is why the operation ended before Remaining were processed,
+	// such as its context's deadline. Running it again on Remaining resumes it.
+	Stopped   string   `json:"stopped,omitempty"`
+	Remaining []string `json:"remaining,omitempty"`
+	// Resumed is the number of files skipped because the interrupted run
+	// being resumed finished them
+	Resumed int `json:"resumed,omitempty"`
 }
 
 type ScanStats struct {
@@ -39,7 +57,11 @@ type TagUpdateParams struct {
 	RemoveTags []string `json:"remove_tags"`
 	FilePaths  []string `json:"file_paths"`
 	AddTags    []string `json:"add_tags"`
-	Root       string   `json:"root"`
+	Root       string   `json:"root,omitempty"`
+	RootName   string   `json:"root_name,omitempty"`
+	DryRun     bool     `json:"dry_run,omitempty"`
+	// Migrate limits hashtag migration to the tags matching these patterns
+	Migrate []string `json:"migrate,omitempty"`
 }
 
 type TagUpdateResult struct {
@@ -48,4 +70,45 @@ type TagUpdateResult struct {
 	TagsRemoved   map[string]int `json:"tags_removed"`
 	TagsAdded     map[string]int `json:"tags_added"`
 	Errors        []string       `json:"errors,omitempty"`
+	// Diffs are the changes a dry run would make to each file
+	Diffs []PlannedChange `json:"diffs,omitempty"`
+	// Preflight are the files PreflightSkip left alone
+	Preflight []PreflightProblem `json:"preflight,omitempty"`
+	// Backup is the backup tree holding the files as they were before
+	Backup string `json:"backup,omitempty"`
+	// Operation is the journal id of the changes, which undo takes
+	Operation string `json:"operation,omitempty"`
+	// Stopped is why the operation ended before Remaining were processed,
+	// such as its context's deadline. Running it again on Remaining resumes it.
+	Stopped   string   `json:"stopped,omitempty"`
+	Remaining []string `json:"remaining,omitempty"`
+	// Resumed is the number of files skipped because the interrupted run
+	// being resumed finished them
+	Resumed int `json:"resumed,omitempty"`
+}
+
+type NamespaceSuggestion struct {
+	Tag          string  `json:"tag"`
+	Namespace    string  `json:"namespace"`
+	SuggestedTag string  `json:"suggested_tag"`
+	Count        int     `json:"count"`
+	CoOccurrence int     `json:"co_occurrence"`
+	Ratio        float64 `json:"ratio"`
+}
+
+type ConfigProposal struct {
+	Root                string   `json:"root"`
+	TotalFiles          int      `json:"total_files"`
+	ExcludeDirs         []string `json:"exclude_dirs"`
+	ExcludePatterns     []string `json:"exclude_patterns"`
+	FrontmatterTagFiles int      `json:"frontmatter_tag_files"`
+	InlineTagFiles      int      `json:"inline_tag_files"`
+	TagStyle            string   `json:"tag_style"`
+	// TagsStyle is the way most frontmatter tags are written, array or
+	// list, proposed as tags_style
+	TagsStyle string `json:"tags_style"`
+	// CaseConvention is the casing most tags follow, proposed as tag_case;
+	// empty when there is none
+	CaseConvention string   `json:"case_convention"`
+	ProtectedTags  []string `json:"protected_tags"`
 }
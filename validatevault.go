@@ -0,0 +1,83 @@
+package tagmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// TagValidation is a tag found in the vault which failed validation
+type TagValidation struct {
+	Tag string `json:"tag"`
+	ValidationResult
+	// Files are the files the tag appears in
+	Files []string `json:"files"`
+}
+
+// VaultValidation is the result of validating every tag in a vault
+type VaultValidation struct {
+	// Files and Tags are how many files were scanned and tags checked
+	Files   int             `json:"files"`
+	Tags    int             `json:"tags"`
+	Invalid []TagValidation `json:"invalid"`
+}
+
+// ValidateVault validates every tag found under rootPath, returning those
+// which fail with the files they appear in. Frontmatter tags the scanner skips
+// as invalid are checked too; inline text the scanner doesn't read as a
+// hashtag, such as #123, is not a tag and isn't.
+func (m *DefaultTagManager) ValidateVault(ctx context.Context, rootPath string) (*VaultValidation, error) {
+	if err := m.validator.ValidatePath(rootPath); err != nil {
+		return nil, fmt.Errorf("invalid root path: %w", err)
+	}
+
+	result := &VaultValidation{Invalid: []TagValidation{}}
+	tagFiles := make(map[string][]string)
+
+	for fileInfo, err := range m.scanner.ScanDirectory(ctx, rootPath, nil) {
+		if err != nil {
+			if err := m.scanFailed(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		result.Files++
+
+		tags := make(map[string]bool)
+		for _, tag := range fileInfo.Tags {
+			tags[m.normalizeTag(tag)] = true
+		}
+		if content, err := readNote(ctx, m.config, fileInfo.Path); err == nil {
+			text, _ := normalizeText(string(content))
+			if !hasIgnoreFileDirective(text) {
+				if data, _, err := m.parseFrontmatter(text); err == nil {
+					for _, tag := range frontmatterTags(data) {
+						tags[m.normalizeTag(tag)] = true
+					}
+				}
+			}
+		}
+
+		for tag := range tags {
+			tagFiles[tag] = append(tagFiles[tag], fileInfo.Path)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result.Tags = len(tagFiles)
+	for tag, files := range tagFiles {
+		validation := m.validator.ValidateTag(tag)
+		if validation.IsValid {
+			continue
+		}
+		sort.Strings(files)
+		result.Invalid = append(result.Invalid, TagValidation{Tag: tag, ValidationResult: *validation, Files: files})
+	}
+	sort.Slice(result.Invalid, func(i, j int) bool {
+		return result.Invalid[i].Tag < result.Invalid[j].Tag
+	})
+
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestValidateVault(t *testing.T) {
+	root := writeVault(t, map[string]string{
+		"a.md":       "---\ntags: [golang, \"12\"]\n---\nNotes on #python",
+		"b.md":       "---\ntags: [\"12\", bad--tag]\n---\nIssue #123 is not a tag",
+		"c.md":       "#golang",
+		"ignored.md": "<!-- tag-manager:ignore -->\n---\ntags: [\"99\"]\n---\n",
+	})
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	result, err := manager.ValidateVault(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Files)
+	assert.Equal(t, 4, result.Tags)
+
+	var tags []string
+	for _, invalid := range result.Invalid {
+		tags = append(tags, invalid.Tag)
+		assert.False(t, invalid.IsValid)
+		assert.NotEmpty(t, invalid.Issues)
+	}
+	assert.Equal(t, []string{"12", "bad--tag"}, tags)
+	assert.Equal(t, []string{filepath.Join(root, "a.md"), filepath.Join(root, "b.md")}, result.Invalid[0].Files)
+	assert.Equal(t, []string{filepath.Join(root, "b.md")}, result.Invalid[1].Files)
+
+	t.Run("Command", func(t *testing.T) {
+		run := func(t *testing.T, args ...string) (string, error) {
+			var stdout bytes.Buffer
+			err := tagmanager.RunCmd(append([]string{"tag-manager", "validate"}, args...),
+				&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+			return stdout.String(), err
+		}
+
+		output, err := run(t, "--root", root)
+		require.Error(t, err)
+		assert.Equal(t, tagmanager.ExitErrors, tagmanager.ExitCode(err))
+		assertOutputContains(t, output, []string{
+			"12: INVALID (2 files)",
+			"Issue: Tag must start with a letter",
+			"bad--tag: INVALID (1 files)",
+			"Issue: Tag contains consecutive hyphens",
+			filepath.Join(root, "b.md"),
+			"Checked 4 tags in 4 files: 2 invalid",
+		})
+
+		output, err = run(t, "--root", root, "--json")
+		require.Error(t, err)
+		var decoded tagmanager.VaultValidation
+		require.NoError(t, json.Unmarshal([]byte(output), &decoded))
+		assert.Len(t, decoded.Invalid, 2)
+
+		clean := writeVault(t, map[string]string{"a.md": "#golang"})
+		output, err = run(t, "--root", clean)
+		require.NoError(t, err)
+		assert.Contains(t, output, "Checked 1 tags in 1 files: 0 invalid")
+	})
+}
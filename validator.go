@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 type Validator interface {
@@ -13,13 +14,34 @@ type Validator interface {
 	ValidateConfig(config *Config) error
 }
 
+// Patterns used to build suggestions, compiled once rather than per tag
+var (
+	leadingDigit = regexp.MustCompile(`^[0-9]`)
+	hyphenRun    = regexp.MustCompile(`-+`)
+	slashRun     = regexp.MustCompile(`/+`)
+)
+
 type DefaultValidator struct {
 	config *Config
+	rules  *RuleSet
+	// rulesErr is reported by ValidateTag when the config can't be compiled
+	rulesErr error
 }
 
 func NewDefaultValidator(config *Config) *DefaultValidator {
+	rules, err := NewRuleSet(config)
 	return &DefaultValidator{
-		config: config,
+		config:   config,
+		rules:    rules,
+		rulesErr: err,
+	}
+}
+
+// newDefaultValidator returns a validator using an already compiled RuleSet
+func newDefaultValidator(rules *RuleSet) *DefaultValidator {
+	return &DefaultValidator{
+		config: rules.config,
+		rules:  rules,
 	}
 }
 
@@ -30,6 +52,12 @@ func (v *DefaultValidator) ValidateTag(tag string) *ValidationResult {
 		Suggestions: []string{},
 	}
 
+	if v.rulesErr != nil {
+		result.IsValid = false
+		result.Issues = append(result.Issues, fmt.Sprintf("Invalid regex configuration: %v", v.rulesErr))
+		return result
+	}
+
 	cleanTag := strings.TrimSpace(tag)
 	cleanTag = strings.TrimPrefix(cleanTag, "#")
 
@@ -39,26 +67,27 @@ func (v *DefaultValidator) ValidateTag(tag string) *ValidationResult {
 		return result
 	}
 
-	if len(cleanTag) < v.config.MinTagLength {
+	tagLength := utf8.RuneCountInString(cleanTag)
+	if tagLength < v.config.MinTagLength {
 		result.IsValid = false
 		result.Issues = append(result.Issues, fmt.Sprintf("Tag must be at least %d characters long", v.config.MinTagLength))
 	}
 
-	if !regexp.MustCompile(`^[a-zA-Z]`).MatchString(cleanTag) {
+	if first, _ := utf8.DecodeRuneInString(cleanTag); !v.rules.isLetter(first) {
 		result.IsValid = false
 		result.Issues = append(result.Issues, "Tag must start with a letter")
-		if regexp.MustCompile(`^[0-9]`).MatchString(cleanTag) {
+		if leadingDigit.MatchString(cleanTag) {
 			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Consider: tag-%s", cleanTag))
 		}
 	}
 
-	invalidChars := regexp.MustCompile(`[^a-zA-Z0-9\-_]`)
+	invalidChars := v.rules.invalidChars
 	if invalidChars.MatchString(cleanTag) {
 		result.IsValid = false
-		result.Issues = append(result.Issues, "Tag contains invalid characters (only letters, numbers, hyphens, and underscores allowed)")
+		result.Issues = append(result.Issues, "Tag contains invalid characters (only letters, numbers, hyphens, underscores, and slashes allowed)")
 
 		suggested := invalidChars.ReplaceAllString(cleanTag, "-")
-		suggested = regexp.MustCompile(`-+`).ReplaceAllString(suggested, "-")
+		suggested = hyphenRun.ReplaceAllString(suggested, "-")
 		suggested = strings.Trim(suggested, "-")
 		if suggested != cleanTag {
 			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Suggested: %s", suggested))
@@ -68,41 +97,50 @@ func (v *DefaultValidator) ValidateTag(tag string) *ValidationResult {
 	if strings.Contains(cleanTag, "--") {
 		result.IsValid = false
 		result.Issues = append(result.Issues, "Tag contains consecutive hyphens")
-		suggested := regexp.MustCompile(`-+`).ReplaceAllString(cleanTag, "-")
+		suggested := hyphenRun.ReplaceAllString(cleanTag, "-")
 		if suggested != cleanTag {
 			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Suggested: %s", suggested))
 		}
 	}
 
-	scanner, err := NewFilesystemScanner(v.config)
-	if err != nil {
+	if strings.HasPrefix(cleanTag, "/") || strings.HasSuffix(cleanTag, "/") || strings.Contains(cleanTag, "//") {
 		result.IsValid = false
-		result.Issues = append(result.Issues, fmt.Sprintf("Invalid regex configuration: %v", err))
-		return result
+		result.Issues = append(result.Issues, "Nested tag contains an empty segment")
+		suggested := slashRun.ReplaceAllString(cleanTag, "/")
+		suggested = strings.Trim(suggested, "/")
+		if suggested != "" && suggested != cleanTag {
+			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Suggested: %s", suggested))
+		}
 	}
-	if scanner.isHexColor(cleanTag) {
+
+	if v.rules.isHexColor(cleanTag) {
 		result.IsValid = false
 		result.Issues = append(result.Issues, "Tag appears to be a hex color code")
 		result.Suggestions = append(result.Suggestions, fmt.Sprintf("Consider: color-%s", cleanTag))
 	}
 
-	if scanner.looksLikeID(cleanTag) {
+	if v.rules.looksLikeID(cleanTag) {
 		result.IsValid = false
 		result.Issues = append(result.Issues, "Tag appears to be an ID or hash")
 		result.Suggestions = append(result.Suggestions, "Consider using a more descriptive tag name")
 	}
 
-	if scanner.isURLFragment(cleanTag) {
+	if v.rules.isURLFragment(cleanTag) {
 		result.IsValid = false
 		result.Issues = append(result.Issues, "Tag appears to contain URL fragments")
 		result.Suggestions = append(result.Suggestions, "Consider using a more descriptive tag name")
 	}
 
-	for _, keyword := range v.config.ExcludeKeywords {
-		if strings.Contains(strings.ToLower(cleanTag), keyword) {
-			result.IsValid = false
-			result.Issues = append(result.Issues, fmt.Sprintf("Tag contains excluded keyword: %s", keyword))
-			break
+	if keyword, excluded := v.rules.excludedKeyword(cleanTag); excluded {
+		result.IsValid = false
+		result.Issues = append(result.Issues, fmt.Sprintf("Tag contains excluded keyword: %s", keyword))
+	}
+
+	if !followsTagCase(cleanTag, v.config.TagCase) {
+		result.IsValid = false
+		result.Issues = append(result.Issues, fmt.Sprintf("Tag doesn't follow the %s convention", v.config.TagCase))
+		if suggested := convertTagCase(cleanTag, v.config.TagCase); suggested != cleanTag {
+			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Suggested: %s", suggested))
 		}
 	}
 
@@ -112,7 +150,7 @@ func (v *DefaultValidator) ValidateTag(tag string) *ValidationResult {
 			digitCount++
 		}
 	}
-	digitRatio := float64(digitCount) / float64(len(cleanTag))
+	digitRatio := float64(digitCount) / float64(tagLength)
 	if digitRatio > v.config.MaxDigitRatio {
 		result.IsValid = false
 		result.Issues = append(result.Issues, fmt.Sprintf("Tag contains too many digits (%.0f%% digits, max allowed: %.0f%%)",
@@ -123,7 +161,16 @@ func (v *DefaultValidator) ValidateTag(tag string) *ValidationResult {
 	return result
 }
 
+// ValidatePath fails for a path commands refuse before running: empty,
+// relative or traversing with ..
 func (v *DefaultValidator) ValidatePath(path string) error {
+	if err := validatePath(path); err != nil {
+		return &usageError{err: err}
+	}
+	return nil
+}
+
+func validatePath(path string) error {
 	if path == "" {
 		return fmt.Errorf("path cannot be empty")
 	}
@@ -170,6 +217,14 @@ func (v *DefaultValidator) ValidateConfig(config *Config) error {
 		return fmt.Errorf("invalid hashtag_pattern regex: %w", err)
 	}
 
+	if _, err := compileExcludeKeywords(config); err != nil {
+		return err
+	}
+
+	if err := validateCryptHooks(config.CryptHooks); err != nil {
+		return err
+	}
+
 	if config.YAMLTagPattern != "" {
 		if _, err := regexp.Compile(config.YAMLTagPattern); err != nil {
 			return fmt.Errorf("invalid yaml_tag_pattern regex: %w", err)
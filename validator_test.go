@@ -33,6 +33,28 @@ func TestDefaultValidatorValidateTag(t *testing.T) {
 			tag:         "data_science",
 			expectValid: true,
 		},
+		{
+			name:        "ValidUnicodeTag",
+			tag:         "développement",
+			expectValid: true,
+		},
+		{
+			name:        "ValidNestedTag",
+			tag:         "project/alpha",
+			expectValid: true,
+		},
+		{
+			name:           "NestedTagEmptySegment",
+			tag:            "project//alpha",
+			expectValid:    false,
+			expectedIssues: []string{"empty segment"},
+		},
+		{
+			name:           "NestedTagTrailingSlash",
+			tag:            "project/",
+			expectValid:    false,
+			expectedIssues: []string{"empty segment"},
+		},
 		{
 			name:        "ValidTagWithHashPrefix",
 			tag:         "#programming",
@@ -143,6 +165,20 @@ func TestDefaultValidatorValidateTag(t *testing.T) {
 	}
 }
 
+func TestDefaultValidatorASCIIOnlyTags(t *testing.T) {
+	config := tagmanager.DefaultConfig()
+	config.UnicodeTags = false
+	validator := tagmanager.NewDefaultValidator(config)
+
+	result := validator.ValidateTag("développement")
+	assert.False(t, result.IsValid)
+	assert.Contains(t, result.Issues[0], "invalid characters")
+
+	result = validator.ValidateTag("étude")
+	assert.False(t, result.IsValid)
+	assert.Contains(t, result.Issues, "Tag must start with a letter")
+}
+
 func TestDefaultValidatorValidatePath(t *testing.T) {
 	config := tagmanager.DefaultConfig()
 	validator := tagmanager.NewDefaultValidator(config)
@@ -316,3 +352,38 @@ func TestDefaultValidatorWithInvalidRegexConfig(t *testing.T) {
 
 	assert.True(t, found)
 }
+
+// benchmarkTags returns a mix of valid and invalid tags, as seen when
+// validating every tag in a large vault.
+func benchmarkTags(n int) []string {
+	samples := []string{"golang", "project/alpha", "web-dev", "123abc", "ff0000", "bad!tag", "développement", "a--b"}
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = samples[i%len(samples)]
+	}
+	return tags
+}
+
+func BenchmarkValidateTag(b *testing.B) {
+	config := tagmanager.DefaultConfig()
+	tags := benchmarkTags(5000)
+
+	b.Run("SharedRuleSet", func(b *testing.B) {
+		validator := tagmanager.NewDefaultValidator(config)
+		for i := 0; i < b.N; i++ {
+			for _, tag := range tags {
+				validator.ValidateTag(tag)
+			}
+		}
+	})
+
+	// Compiling the rules for every tag is how validation worked before the
+	// RuleSet was cached in the validator.
+	b.Run("RuleSetPerTag", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, tag := range tags {
+				tagmanager.NewDefaultValidator(config).ValidateTag(tag)
+			}
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package tagmanager
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FindVaultRoot returns the nearest directory at or above dir holding an
+// .obsidian folder or a .tag-manager.yaml, and false when there is none
+func FindVaultRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".obsidian")); err == nil && info.IsDir() {
+			return dir, true
+		}
+		if info, err := os.Stat(filepath.Join(dir, VaultConfigFile)); err == nil && !info.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// defaultRoot returns the root commands use when their --root isn't given:
+// root when the global --root is, else the vault the current directory is
+// in, else the current directory
+func defaultRoot(root string) (string, error) {
+	if root != "" {
+		return root, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if vault, ok := FindVaultRoot(cwd); ok {
+		return vault, nil
+	}
+	return cwd, nil
+}
+
+// vaultConfigFile returns the .tag-manager.yaml of the vault holding root, or
+// of the vault the current directory is in when root is empty, and false when
+// that vault has none
+func vaultConfigFile(root string) (string, bool) {
+	dir := root
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", false
+		}
+		dir = cwd
+	}
+	vault, ok := FindVaultRoot(dir)
+	if !ok {
+		return "", false
+	}
+	path := filepath.Join(vault, VaultConfigFile)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
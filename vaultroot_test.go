@@ -0,0 +1,122 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestFindVaultRoot(t *testing.T) {
+	base := t.TempDir()
+	obsidian := filepath.Join(base, "obsidian")
+	configured := filepath.Join(base, "configured")
+	plain := filepath.Join(base, "plain")
+	for _, dir := range []string{
+		filepath.Join(obsidian, ".obsidian"),
+		filepath.Join(obsidian, "Projects", "alpha"),
+		filepath.Join(configured, "Daily"),
+		filepath.Join(plain, "notes"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(configured, tagmanager.VaultConfigFile), []byte("{}\n"), 0644))
+	// A file named .obsidian doesn't mark a vault
+	require.NoError(t, os.WriteFile(filepath.Join(plain, ".obsidian"), nil, 0644))
+
+	tests := []struct {
+		name     string
+		dir      string
+		expected string
+		found    bool
+	}{
+		{name: "Root", dir: obsidian, expected: obsidian, found: true},
+		{name: "Nested", dir: filepath.Join(obsidian, "Projects", "alpha"), expected: obsidian, found: true},
+		{name: "ConfigFile", dir: filepath.Join(configured, "Daily"), expected: configured, found: true},
+		{name: "NoVault", dir: filepath.Join(plain, "notes"), found: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, found := tagmanager.FindVaultRoot(test.dir)
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.expected, root)
+		})
+	}
+}
+
+func TestGlobalRoot(t *testing.T) {
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+	root := writeVault(t, map[string]string{
+		"a.md":          "#golang",
+		"Projects/b.md": "#python",
+		".obsidian/x":   "{}",
+	})
+
+	t.Run("Detected", func(t *testing.T) {
+		t.Chdir(filepath.Join(root, "Projects"))
+		output := run(t, "list")
+		assertOutputContains(t, output, []string{"golang", "python"})
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		output := run(t, "--root", root, "find", "--tags", "golang")
+		assertOutputContains(t, output, []string{"a.md"})
+	})
+
+	t.Run("CommandOverrides", func(t *testing.T) {
+		other := writeVault(t, map[string]string{"c.md": "#rust"})
+		output := run(t, "--root", root, "list", "--root", other)
+		assertOutputContains(t, output, []string{"rust"})
+		assert.NotContains(t, output, "golang")
+	})
+}
+
+func TestVaultConfigFile(t *testing.T) {
+	run := func(t *testing.T, args ...string) string {
+		var stdout bytes.Buffer
+		err := tagmanager.RunCmd(append([]string{"tag-manager"}, args...),
+			&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+		require.NoError(t, err)
+		return stdout.String()
+	}
+	root := writeVault(t, map[string]string{
+		"a.md":                     "#alpha",
+		"Projects/b.md":            "#zeta",
+		tagmanager.VaultConfigFile: "pinned_tags: [zeta]\nprofiles:\n  work:\n    min_tag_length: 6\n",
+	})
+
+	t.Run("Detected", func(t *testing.T) {
+		t.Chdir(filepath.Join(root, "Projects"))
+		assert.Regexp(t, `#zeta\s+1 files \(pinned\)`, run(t, "list"))
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		assert.Regexp(t, `#zeta\s+1 files \(pinned\)`, run(t, "--root", root, "list"))
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		t.Chdir(root)
+		output := run(t, "--profile", "work", "list")
+		assert.NotContains(t, output, "alpha")
+	})
+
+	t.Run("ConfigFlagWins", func(t *testing.T) {
+		t.Chdir(root)
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte("{}\n"), 0644))
+		assert.NotContains(t, run(t, "--config", configFile, "list"), "(pinned)")
+	})
+}
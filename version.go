@@ -0,0 +1,87 @@
+package tagmanager
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set when building a release with
+//
+//	go build -ldflags "-X github.com/thrawn01/tag-manager.version=v1.2.3
+//	  -X github.com/thrawn01/tag-manager.commit=abc1234
+//	  -X github.com/thrawn01/tag-manager.date=2025-01-02T15:04:05Z"
+//
+// Left empty, they are read from the build info Go embeds instead.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// MCPProtocolVersion is the newest MCP protocol version the MCP SDK
+// negotiates with clients
+const MCPProtocolVersion = "2025-06-18"
+
+const mcpSDKModule = "github.com/modelcontextprotocol/go-sdk"
+
+// BuildInfo describes the build of tag-manager which is running
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	// Date is when the binary was built, or the time of its commit when it
+	// wasn't set with -ldflags
+	Date               string `json:"date,omitempty"`
+	GoVersion          string `json:"go_version"`
+	MCPSDKVersion      string `json:"mcp_sdk_version,omitempty"`
+	MCPProtocolVersion string `json:"mcp_protocol_version"`
+}
+
+// ReadBuildInfo returns the version, commit and build date set with
+// -ldflags, or else those of the module and VCS checkout Go recorded at build
+// time, along with the Go and MCP versions
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:            version,
+		Commit:             commit,
+		Date:               date,
+		GoVersion:          runtime.Version(),
+		MCPProtocolVersion: MCPProtocolVersion,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		modified := false
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+		for _, dep := range build.Deps {
+			if dep.Path == mcpSDKModule {
+				info.MCPSDKVersion = dep.Version
+				if dep.Replace != nil {
+					info.MCPSDKVersion = dep.Replace.Version
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
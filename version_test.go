@@ -0,0 +1,37 @@
+package tagmanager_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestVersion(t *testing.T) {
+	info := tagmanager.ReadBuildInfo()
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, tagmanager.MCPProtocolVersion, info.MCPProtocolVersion)
+
+	var stdout bytes.Buffer
+	err := tagmanager.RunCmd([]string{"tag-manager", "version"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	assertOutputContains(t, stdout.String(), []string{
+		"tag-manager " + info.Version + "\n",
+		"Go:           " + runtime.Version(),
+		"MCP protocol: " + tagmanager.MCPProtocolVersion,
+	})
+
+	stdout.Reset()
+	err = tagmanager.RunCmd([]string{"tag-manager", "version", "--json"},
+		&tagmanager.RunCmdOptions{Stdout: &stdout, Stderr: &bytes.Buffer{}})
+	require.NoError(t, err)
+	var decoded tagmanager.BuildInfo
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &decoded))
+	assert.Equal(t, info, decoded)
+}
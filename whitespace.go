@@ -0,0 +1,89 @@
+package tagmanager
+
+import "strings"
+
+// tidyEdit cleans up the whitespace an edit left behind in after, the edited
+// version of before. Only the lines the edit changed, and the runs of blank
+// lines touching them, are tidied:
+//   - trailing whitespace is trimmed from changed lines
+//   - lines the edit emptied are dropped, and a run of blank lines they were
+//     part of becomes a single blank line
+//   - blank lines the edit left at the start or end of the text are dropped,
+//     and a changed end gets exactly one trailing newline
+func tidyEdit(before, after string) string {
+	type line struct {
+		text   string
+		edited bool
+	}
+	var lines []line
+	// deleted[i] is true when the edit removed lines just before lines[i]
+	// without replacing them
+	deleted := []bool{false}
+	var removed, inserted bool
+	for _, op := range diffLines(splitLines(before), splitLines(after)) {
+		switch op.kind {
+		case '-':
+			removed = true
+			continue
+		case '+':
+			inserted = true
+		default:
+			deleted[len(lines)] = removed && !inserted
+			removed, inserted = false, false
+		}
+		lines = append(lines, line{text: op.line, edited: op.kind == '+'})
+		deleted = append(deleted, false)
+	}
+	deleted[len(lines)] = removed && !inserted
+
+	isBlank := func(l line) bool {
+		return strings.TrimSpace(l.text) == ""
+	}
+
+	var b strings.Builder
+	changedEnd := deleted[len(lines)]
+	for i := 0; i < len(lines); {
+		if !isBlank(lines[i]) {
+			text := lines[i].text
+			if lines[i].edited {
+				text = strings.TrimRight(text, " \t\n")
+				if strings.HasSuffix(lines[i].text, "\n") || i == len(lines)-1 {
+					text += "\n"
+				}
+			}
+			b.WriteString(text)
+			changedEnd = lines[i].edited
+			i++
+			continue
+		}
+
+		end := i
+		touched := deleted[i]
+		var kept *line
+		for ; end < len(lines) && isBlank(lines[end]); end++ {
+			touched = touched || lines[end].edited || deleted[end+1]
+			if kept == nil && !lines[end].edited {
+				kept = &lines[end]
+			}
+		}
+
+		switch {
+		case !touched:
+			for _, l := range lines[i:end] {
+				b.WriteString(l.text)
+			}
+			changedEnd = false
+		case i == 0 || end == len(lines):
+			changedEnd = true
+		case kept != nil:
+			b.WriteString(kept.text)
+		}
+		i = end
+	}
+
+	result := b.String()
+	if changedEnd && result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package tagmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/tag-manager"
+)
+
+func TestUpdateTagsTidiesWhitespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "EmptiedLineBetweenBlankLines",
+			content:  "---\ntags: [a, draft]\n---\nIntro\n\n#draft\n\nOutro\n",
+			expected: "---\ntags: [a]\n---\nIntro\n\nOutro\n",
+		},
+		{
+			name:     "EmptiedLineInParagraph",
+			content:  "---\ntags: [a, draft]\n---\nFirst line\n#draft\nSecond line\n",
+			expected: "---\ntags: [a]\n---\nFirst line\nSecond line\n",
+		},
+		{
+			name:     "TrailingHashtag",
+			content:  "---\ntags: [a, draft]\n---\nSome text #draft\n",
+			expected: "---\ntags: [a]\n---\nSome text\n",
+		},
+		{
+			name:     "EmptiedLastLine",
+			content:  "---\ntags: [a, draft]\n---\nBody\n\n#draft",
+			expected: "---\ntags: [a]\n---\nBody\n",
+		},
+		{
+			name:     "DroppedFrontmatter",
+			content:  "---\ntags: [draft]\n---\n\nBody\n",
+			expected: "Body\n",
+		},
+		{
+			name:     "UntouchedRegionsKept",
+			content:  "---\ntags: [a, draft]\n---\nIntro\n\n\n\nMiddle #draft\n\n\n\nOutro  \nNo newline",
+			expected: "---\ntags: [a]\n---\nIntro\n\n\n\nMiddle\n\n\n\nOutro  \nNo newline",
+		},
+	}
+
+	manager, err := tagmanager.NewDefaultTagManager(tagmanager.DefaultConfig())
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "note.md")
+			require.NoError(t, os.WriteFile(testFile, []byte(test.content), tagmanager.DefaultFilePermissions))
+
+			result, err := manager.UpdateTags(context.Background(), nil, []string{"draft"}, tempDir, []string{"note.md"}, false)
+			require.NoError(t, err)
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(testFile)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(content))
+		})
+	}
+}